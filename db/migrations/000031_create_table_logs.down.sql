@@ -0,0 +1,2 @@
+-- logs
+DROP TABLE IF EXISTS logs;
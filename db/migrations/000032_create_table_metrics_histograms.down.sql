@@ -0,0 +1,2 @@
+-- metrics_histograms
+DROP TABLE IF EXISTS metrics_histograms;
@@ -0,0 +1,26 @@
+-- metrics_histograms
+CREATE TABLE IF NOT EXISTS metrics_histograms (
+    id String,
+    iid Int64,
+    job_id Int64,
+    pipeline_id Int64,
+    project_id Int64,
+
+    name String,
+    labels Map(String, String),
+
+    count UInt64,
+    sum Float64,
+    min Float64,
+    max Float64,
+    bucket_counts Array(UInt64),
+    explicit_bounds Array(Float64),
+
+    timestamp Int64
+)
+ENGINE = ReplacingMergeTree()
+{{ partitionBy "metrics_histograms" "fromUnixTimestamp64Milli(timestamp)" "" }}
+ORDER BY (job_id, iid)
+{{ ttl "metrics_histograms" }}
+{{ settings "metrics_histograms" }}
+;
@@ -0,0 +1,2 @@
+-- timeseries
+DROP TABLE IF EXISTS timeseries;
@@ -0,0 +1,15 @@
+-- timeseries
+CREATE TABLE IF NOT EXISTS timeseries (
+    metric_name LowCardinality(String),
+    labels Map(LowCardinality(String), String),
+    fingerprint UInt64,
+
+    timestamp Int64 CODEC(DoubleDelta, ZSTD(1)),
+    value Float64 CODEC(Gorilla, ZSTD(1))
+)
+ENGINE = ReplacingMergeTree()
+{{ partitionBy "timeseries" "fromUnixTimestamp64Milli(timestamp)" "day" }}
+ORDER BY (metric_name, fingerprint, timestamp)
+{{ ttl "timeseries" }}
+{{ settings "timeseries" }}
+;
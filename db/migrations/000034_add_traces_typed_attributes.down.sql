@@ -0,0 +1,15 @@
+-- traces
+ALTER TABLE traces
+    DROP COLUMN IF EXISTS ResourceAttributesInt,
+    DROP COLUMN IF EXISTS ResourceAttributesFloat,
+    DROP COLUMN IF EXISTS SpanAttributesInt,
+    DROP COLUMN IF EXISTS SpanAttributesFloat,
+    DROP COLUMN IF EXISTS `Events.AttributesInt`,
+    DROP COLUMN IF EXISTS `Events.AttributesFloat`,
+    DROP COLUMN IF EXISTS `Links.AttributesInt`,
+    DROP COLUMN IF EXISTS `Links.AttributesFloat`
+;
+
+-- traces_in
+DROP TABLE IF EXISTS traces_in;
+CREATE TABLE IF NOT EXISTS traces_in AS traces ENGINE = Null;
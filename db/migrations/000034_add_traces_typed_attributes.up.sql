@@ -0,0 +1,15 @@
+-- traces
+ALTER TABLE traces
+    ADD COLUMN IF NOT EXISTS ResourceAttributesInt Map(LowCardinality(String), Int64) CODEC(ZSTD(1)) AFTER ResourceAttributes,
+    ADD COLUMN IF NOT EXISTS ResourceAttributesFloat Map(LowCardinality(String), Float64) CODEC(ZSTD(1)) AFTER ResourceAttributesInt,
+    ADD COLUMN IF NOT EXISTS SpanAttributesInt Map(LowCardinality(String), Int64) CODEC(ZSTD(1)) AFTER SpanAttributes,
+    ADD COLUMN IF NOT EXISTS SpanAttributesFloat Map(LowCardinality(String), Float64) CODEC(ZSTD(1)) AFTER SpanAttributesInt,
+    ADD COLUMN IF NOT EXISTS `Events.AttributesInt` Array(Map(LowCardinality(String), Int64)) CODEC(ZSTD(1)) AFTER `Events.Attributes`,
+    ADD COLUMN IF NOT EXISTS `Events.AttributesFloat` Array(Map(LowCardinality(String), Float64)) CODEC(ZSTD(1)) AFTER `Events.AttributesInt`,
+    ADD COLUMN IF NOT EXISTS `Links.AttributesInt` Array(Map(LowCardinality(String), Int64)) CODEC(ZSTD(1)) AFTER `Links.Attributes`,
+    ADD COLUMN IF NOT EXISTS `Links.AttributesFloat` Array(Map(LowCardinality(String), Float64)) CODEC(ZSTD(1)) AFTER `Links.AttributesInt`
+;
+
+-- traces_in
+DROP TABLE IF EXISTS traces_in;
+CREATE TABLE IF NOT EXISTS traces_in AS traces ENGINE = Null;
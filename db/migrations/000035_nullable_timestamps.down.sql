@@ -0,0 +1,122 @@
+-- pipelines_compat
+DROP VIEW IF EXISTS pipelines_compat;
+
+-- pipelines
+ALTER TABLE pipelines UPDATE committed_at = toDateTime64(0, 3) WHERE committed_at IS NULL SETTINGS mutations_sync = 2;
+ALTER TABLE pipelines UPDATE started_at = toDateTime64(0, 3) WHERE started_at IS NULL SETTINGS mutations_sync = 2;
+ALTER TABLE pipelines UPDATE finished_at = toDateTime64(0, 3) WHERE finished_at IS NULL SETTINGS mutations_sync = 2;
+ALTER TABLE pipelines
+    MODIFY COLUMN IF EXISTS committed_at Float64,
+    MODIFY COLUMN IF EXISTS started_at Float64,
+    MODIFY COLUMN IF EXISTS finished_at Float64
+SETTINGS mutations_sync = 2
+;
+
+-- pipelines_in
+DROP VIEW IF EXISTS pipelines_mv;
+DROP TABLE IF EXISTS pipelines_in;
+CREATE TABLE IF NOT EXISTS pipelines_in AS pipelines ENGINE = Null;
+
+-- pipelines_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS pipelines_mv
+TO pipelines
+AS SELECT * FROM pipelines_in LEFT OUTER JOIN pipelines ON pipelines_in.id = pipelines.id
+WHERE pipelines_in.updated_at > pipelines.updated_at
+;
+
+-- jobs_compat
+DROP VIEW IF EXISTS jobs_compat;
+
+-- jobs
+ALTER TABLE jobs UPDATE queued_at = toDateTime64(0, 3) WHERE queued_at IS NULL SETTINGS mutations_sync = 2;
+ALTER TABLE jobs UPDATE started_at = toDateTime64(0, 3) WHERE started_at IS NULL SETTINGS mutations_sync = 2;
+ALTER TABLE jobs UPDATE finished_at = toDateTime64(0, 3) WHERE finished_at IS NULL SETTINGS mutations_sync = 2;
+ALTER TABLE jobs UPDATE erased_at = toDateTime64(0, 3) WHERE erased_at IS NULL SETTINGS mutations_sync = 2;
+ALTER TABLE jobs
+    MODIFY COLUMN IF EXISTS queued_at Float64,
+    MODIFY COLUMN IF EXISTS started_at Float64,
+    MODIFY COLUMN IF EXISTS finished_at Float64,
+    MODIFY COLUMN IF EXISTS erased_at Float64
+SETTINGS mutations_sync = 2
+;
+
+-- jobs_in
+DROP VIEW IF EXISTS jobs_mv;
+DROP TABLE IF EXISTS jobs_in;
+CREATE TABLE IF NOT EXISTS jobs_in AS jobs ENGINE = Null;
+
+-- jobs_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS jobs_mv
+TO jobs
+AS SELECT * FROM jobs_in WHERE id NOT IN (
+    SELECT id FROM jobs WHERE pipeline.id IN (
+        SELECT DISTINCT tupleElement(pipeline, 'id') FROM jobs_in
+    )
+)
+;
+
+-- mergerequests_compat
+DROP VIEW IF EXISTS mergerequests_compat;
+
+-- mergerequests
+ALTER TABLE mergerequests UPDATE merged_at = toDateTime64(0, 3) WHERE merged_at IS NULL SETTINGS mutations_sync = 2;
+ALTER TABLE mergerequests UPDATE closed_at = toDateTime64(0, 3) WHERE closed_at IS NULL SETTINGS mutations_sync = 2;
+ALTER TABLE mergerequests
+    MODIFY COLUMN IF EXISTS merged_at Float64,
+    MODIFY COLUMN IF EXISTS closed_at Float64
+SETTINGS mutations_sync = 2
+;
+
+-- mergerequests_in
+DROP VIEW IF EXISTS mergerequests_mv;
+DROP TABLE IF EXISTS mergerequests_in;
+CREATE TABLE IF NOT EXISTS mergerequests_in AS mergerequests ENGINE = Null;
+
+-- mergerequests_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS mergerequests_mv
+TO mergerequests
+AS SELECT * FROM mergerequests_in LEFT OUTER JOIN mergerequests ON mergerequests_in.id = mergerequests.id
+WHERE mergerequests_in.updated_at > mergerequests.updated_at
+;
+
+-- issues_compat
+DROP VIEW IF EXISTS issues_compat;
+
+-- issues
+ALTER TABLE issues UPDATE closed_at = toDateTime64(0, 3) WHERE closed_at IS NULL SETTINGS mutations_sync = 2;
+ALTER TABLE issues
+    MODIFY COLUMN IF EXISTS closed_at Float64
+SETTINGS mutations_sync = 2
+;
+
+-- issues_in
+DROP VIEW IF EXISTS issues_mv;
+DROP TABLE IF EXISTS issues_in;
+CREATE TABLE IF NOT EXISTS issues_in AS issues ENGINE = Null;
+
+-- issues_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS issues_mv TO issues AS
+SELECT * FROM issues_in LEFT OUTER JOIN issues USING id
+WHERE issues_in.updated_at > issues.updated_at
+;
+
+-- deployments_compat
+DROP VIEW IF EXISTS deployments_compat;
+
+-- deployments
+ALTER TABLE deployments UPDATE finished_at = toDateTime64(0, 3) WHERE finished_at IS NULL SETTINGS mutations_sync = 2;
+ALTER TABLE deployments
+    MODIFY COLUMN IF EXISTS finished_at Float64
+SETTINGS mutations_sync = 2
+;
+
+-- deployments_in
+DROP VIEW IF EXISTS deployments_mv;
+DROP TABLE IF EXISTS deployments_in;
+CREATE TABLE IF NOT EXISTS deployments_in AS deployments ENGINE = Null;
+
+-- deployments_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS deployments_mv TO deployments AS
+    SELECT * FROM deployments_in LEFT OUTER JOIN deployments ON deployments_in.id = deployments.id
+    WHERE deployments_in.updated_at > deployments.updated_at
+;
@@ -0,0 +1,158 @@
+-- Optional timestamps are stored as NULL instead of 0 if absent, so that
+-- "not started" and "started at the epoch" can be told apart. The *_compat
+-- views expose the previous Float64 columns for existing queries. Mutations
+-- are synchronous so that later migrations copy the converted data.
+
+-- pipelines
+ALTER TABLE pipelines
+    MODIFY COLUMN IF EXISTS committed_at Nullable(DateTime64(3)),
+    MODIFY COLUMN IF EXISTS started_at Nullable(DateTime64(3)),
+    MODIFY COLUMN IF EXISTS finished_at Nullable(DateTime64(3))
+SETTINGS mutations_sync = 2
+;
+ALTER TABLE pipelines UPDATE committed_at = NULL WHERE committed_at = toDateTime64(0, 3) SETTINGS mutations_sync = 2;
+ALTER TABLE pipelines UPDATE started_at = NULL WHERE started_at = toDateTime64(0, 3) SETTINGS mutations_sync = 2;
+ALTER TABLE pipelines UPDATE finished_at = NULL WHERE finished_at = toDateTime64(0, 3) SETTINGS mutations_sync = 2;
+
+-- pipelines_in
+DROP VIEW IF EXISTS pipelines_mv;
+DROP TABLE IF EXISTS pipelines_in;
+CREATE TABLE IF NOT EXISTS pipelines_in AS pipelines ENGINE = Null;
+
+-- pipelines_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS pipelines_mv
+TO pipelines
+AS SELECT * FROM pipelines_in LEFT OUTER JOIN pipelines ON pipelines_in.id = pipelines.id
+WHERE pipelines_in.updated_at > pipelines.updated_at
+;
+
+-- pipelines_compat
+CREATE OR REPLACE VIEW pipelines_compat AS
+SELECT * REPLACE (
+    ifNull(toFloat64(committed_at), 0) AS committed_at,
+    ifNull(toFloat64(started_at), 0) AS started_at,
+    ifNull(toFloat64(finished_at), 0) AS finished_at
+)
+FROM pipelines
+;
+
+-- jobs
+ALTER TABLE jobs
+    MODIFY COLUMN IF EXISTS queued_at Nullable(DateTime64(3)),
+    MODIFY COLUMN IF EXISTS started_at Nullable(DateTime64(3)),
+    MODIFY COLUMN IF EXISTS finished_at Nullable(DateTime64(3)),
+    MODIFY COLUMN IF EXISTS erased_at Nullable(DateTime64(3))
+SETTINGS mutations_sync = 2
+;
+ALTER TABLE jobs UPDATE queued_at = NULL WHERE queued_at = toDateTime64(0, 3) SETTINGS mutations_sync = 2;
+ALTER TABLE jobs UPDATE started_at = NULL WHERE started_at = toDateTime64(0, 3) SETTINGS mutations_sync = 2;
+ALTER TABLE jobs UPDATE finished_at = NULL WHERE finished_at = toDateTime64(0, 3) SETTINGS mutations_sync = 2;
+ALTER TABLE jobs UPDATE erased_at = NULL WHERE erased_at = toDateTime64(0, 3) SETTINGS mutations_sync = 2;
+
+-- jobs_in
+DROP VIEW IF EXISTS jobs_mv;
+DROP TABLE IF EXISTS jobs_in;
+CREATE TABLE IF NOT EXISTS jobs_in AS jobs ENGINE = Null;
+
+-- jobs_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS jobs_mv
+TO jobs
+AS SELECT * FROM jobs_in WHERE id NOT IN (
+    SELECT id FROM jobs WHERE pipeline.id IN (
+        SELECT DISTINCT tupleElement(pipeline, 'id') FROM jobs_in
+    )
+)
+;
+
+-- jobs_compat
+CREATE OR REPLACE VIEW jobs_compat AS
+SELECT * REPLACE (
+    ifNull(toFloat64(queued_at), 0) AS queued_at,
+    ifNull(toFloat64(started_at), 0) AS started_at,
+    ifNull(toFloat64(finished_at), 0) AS finished_at,
+    ifNull(toFloat64(erased_at), 0) AS erased_at
+)
+FROM jobs
+;
+
+-- mergerequests
+ALTER TABLE mergerequests
+    MODIFY COLUMN IF EXISTS merged_at Nullable(DateTime64(3)),
+    MODIFY COLUMN IF EXISTS closed_at Nullable(DateTime64(3))
+SETTINGS mutations_sync = 2
+;
+ALTER TABLE mergerequests UPDATE merged_at = NULL WHERE merged_at = toDateTime64(0, 3) SETTINGS mutations_sync = 2;
+ALTER TABLE mergerequests UPDATE closed_at = NULL WHERE closed_at = toDateTime64(0, 3) SETTINGS mutations_sync = 2;
+
+-- mergerequests_in
+DROP VIEW IF EXISTS mergerequests_mv;
+DROP TABLE IF EXISTS mergerequests_in;
+CREATE TABLE IF NOT EXISTS mergerequests_in AS mergerequests ENGINE = Null;
+
+-- mergerequests_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS mergerequests_mv
+TO mergerequests
+AS SELECT * FROM mergerequests_in LEFT OUTER JOIN mergerequests ON mergerequests_in.id = mergerequests.id
+WHERE mergerequests_in.updated_at > mergerequests.updated_at
+;
+
+-- mergerequests_compat
+CREATE OR REPLACE VIEW mergerequests_compat AS
+SELECT * REPLACE (
+    ifNull(toFloat64(merged_at), 0) AS merged_at,
+    ifNull(toFloat64(closed_at), 0) AS closed_at
+)
+FROM mergerequests
+;
+
+-- issues
+ALTER TABLE issues
+    MODIFY COLUMN IF EXISTS closed_at Nullable(DateTime64(3))
+SETTINGS mutations_sync = 2
+;
+ALTER TABLE issues UPDATE closed_at = NULL WHERE closed_at = toDateTime64(0, 3) SETTINGS mutations_sync = 2;
+
+-- issues_in
+DROP VIEW IF EXISTS issues_mv;
+DROP TABLE IF EXISTS issues_in;
+CREATE TABLE IF NOT EXISTS issues_in AS issues ENGINE = Null;
+
+-- issues_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS issues_mv TO issues AS
+SELECT * FROM issues_in LEFT OUTER JOIN issues USING id
+WHERE issues_in.updated_at > issues.updated_at
+;
+
+-- issues_compat
+CREATE OR REPLACE VIEW issues_compat AS
+SELECT * REPLACE (
+    ifNull(toFloat64(closed_at), 0) AS closed_at
+)
+FROM issues
+;
+
+-- deployments
+ALTER TABLE deployments
+    MODIFY COLUMN IF EXISTS finished_at Nullable(DateTime64(3))
+SETTINGS mutations_sync = 2
+;
+ALTER TABLE deployments UPDATE finished_at = NULL WHERE finished_at = toDateTime64(0, 3) SETTINGS mutations_sync = 2;
+
+-- deployments_in
+DROP VIEW IF EXISTS deployments_mv;
+DROP TABLE IF EXISTS deployments_in;
+CREATE TABLE IF NOT EXISTS deployments_in AS deployments ENGINE = Null;
+
+-- deployments_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS deployments_mv TO deployments AS
+    SELECT * FROM deployments_in LEFT OUTER JOIN deployments ON deployments_in.id = deployments.id
+    WHERE deployments_in.updated_at > deployments.updated_at
+;
+
+-- deployments_compat
+CREATE OR REPLACE VIEW deployments_compat AS
+SELECT * REPLACE (
+    ifNull(toFloat64(finished_at), 0) AS finished_at
+)
+FROM deployments
+;
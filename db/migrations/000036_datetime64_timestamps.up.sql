@@ -0,0 +1,1237 @@
+-- Times are stored as DateTime64(3) instead of Float64 seconds since the
+-- epoch. Partition and sorting keys can't be altered, so the tables are
+-- rebuilt and their data is copied, which may take a while for large tables.
+-- The previous tables are only dropped once all their rows were copied.
+-- The rebuilt tables are partitioned by month of their creation time by
+-- default, except for the small projects table, so that queries and retention
+-- can prune partitions by time.
+-- The *_compat views expose the previous Float64 columns for existing queries
+-- during a transition period.
+--
+-- Recorders must be stopped while this migration runs, inserts fail while the
+-- *_in tables and *_mv views are being replaced.
+
+-- projects
+DROP VIEW IF EXISTS projects_mv;
+DROP VIEW IF EXISTS projects_compat;
+DROP TABLE IF EXISTS projects_in;
+
+DROP TABLE IF EXISTS projects_new;
+
+CREATE TABLE projects_new (
+    id Int64 {{ codec "projects" "id" }},
+    namespace_id Int64 {{ codec "projects" "namespace_id" }},
+    name String {{ codec "projects" "name" }},
+    full_name String {{ codec "projects" "full_name" }},
+    path String {{ codec "projects" "path" }},
+    full_path String {{ codec "projects" "full_path" }},
+    description String {{ codec "projects" "description" }},
+    visibility String {{ codec "projects" "visibility" }},
+    created_at DateTime64(3) {{ codec "projects" "created_at" }},
+    updated_at DateTime64(3) {{ codec "projects" "updated_at" }},
+    last_activity_at DateTime64(3) {{ codec "projects" "last_activity_at" }},
+    topics Array(String) {{ codec "projects" "topics" }},
+    archived Bool {{ codec "projects" "archived" }},
+    forks_count Int64 {{ codec "projects" "forks_count" }},
+    stars_count Int64 {{ codec "projects" "stars_count" }},
+    commit_count Int64 {{ codec "projects" "commit_count" }},
+    storage_size Int64 {{ codec "projects" "storage_size" }},
+    repository_size Int64 {{ codec "projects" "repository_size" }},
+    wiki_size Int64 {{ codec "projects" "wiki_size" }},
+    lfs_objects_size Int64 {{ codec "projects" "lfs_objects_size" }},
+    job_artifacts_size Int64 {{ codec "projects" "job_artifacts_size" }},
+    container_registry_size Int64 {{ codec "projects" "container_registry_size" }},
+    pipeline_artifacts_size Int64 {{ codec "projects" "pipeline_artifacts_size" }},
+    packages_size Int64 {{ codec "projects" "packages_size" }},
+    snippets_size Int64 {{ codec "projects" "snippets_size" }},
+    uploads_size Int64 {{ codec "projects" "uploads_size" }},
+    open_issues_count Int64 {{ codec "projects" "open_issues_count" }},
+    default_branch String {{ codec "projects" "default_branch" }}
+)
+ENGINE = ReplacingMergeTree(last_activity_at)
+{{ partitionBy "projects" "created_at" "" }}
+ORDER BY id
+{{ ttl "projects" }}
+{{ settings "projects" }}
+;
+
+INSERT INTO projects_new (
+    id,
+    namespace_id,
+    name,
+    full_name,
+    path,
+    full_path,
+    description,
+    visibility,
+    created_at,
+    updated_at,
+    last_activity_at,
+    topics,
+    archived,
+    forks_count,
+    stars_count,
+    commit_count,
+    storage_size,
+    repository_size,
+    wiki_size,
+    lfs_objects_size,
+    job_artifacts_size,
+    container_registry_size,
+    pipeline_artifacts_size,
+    packages_size,
+    snippets_size,
+    uploads_size,
+    open_issues_count,
+    default_branch
+)
+SELECT
+    id,
+    namespace_id,
+    name,
+    full_name,
+    path,
+    full_path,
+    description,
+    visibility,
+    toDateTime64(created_at, 3),
+    toDateTime64(updated_at, 3),
+    toDateTime64(last_activity_at, 3),
+    topics,
+    archived,
+    forks_count,
+    stars_count,
+    commit_count,
+    storage_size,
+    repository_size,
+    wiki_size,
+    lfs_objects_size,
+    job_artifacts_size,
+    container_registry_size,
+    pipeline_artifacts_size,
+    packages_size,
+    snippets_size,
+    uploads_size,
+    open_issues_count,
+    default_branch
+FROM projects
+;
+
+SELECT throwIf(
+    (SELECT count() FROM projects_new) != (SELECT count() FROM projects),
+    'rows of projects were not copied to projects_new'
+);
+
+RENAME TABLE projects TO projects_old, projects_new TO projects;
+DROP TABLE projects_old;
+
+-- projects_in
+CREATE TABLE IF NOT EXISTS projects_in AS projects ENGINE = Null;
+
+-- projects_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS projects_mv
+TO projects
+AS SELECT * FROM projects_in LEFT OUTER JOIN projects ON projects_in.id = projects.id
+WHERE projects_in.last_activity_at > projects.last_activity_at
+;
+
+-- projects_compat
+CREATE OR REPLACE VIEW projects_compat AS
+SELECT * REPLACE (
+    toFloat64(created_at) AS created_at,
+    toFloat64(updated_at) AS updated_at,
+    toFloat64(last_activity_at) AS last_activity_at
+)
+FROM projects
+;
+
+-- pipelines
+DROP VIEW IF EXISTS pipelines_mv;
+DROP VIEW IF EXISTS pipelines_compat;
+DROP TABLE IF EXISTS pipelines_in;
+
+DROP TABLE IF EXISTS pipelines_new;
+
+CREATE TABLE pipelines_new (
+    id Int64 {{ codec "pipelines" "id" }},
+    iid Int64 {{ codec "pipelines" "iid" }},
+    project_id Int64 {{ codec "pipelines" "project_id" }},
+    name String {{ codec "pipelines" "name" }},
+    status String {{ codec "pipelines" "status" }},
+    failure_reason String {{ codec "pipelines" "failure_reason" }},
+    source String {{ codec "pipelines" "source" }},
+    ref String {{ codec "pipelines" "ref" }},
+    ref_path String {{ codec "pipelines" "ref_path" }},
+    sha String {{ codec "pipelines" "sha" }},
+    warnings Bool {{ codec "pipelines" "warnings" }},
+    yaml_errors Bool {{ codec "pipelines" "yaml_errors" }},
+    created_at DateTime64(3) {{ codec "pipelines" "created_at" }},
+    updated_at DateTime64(3) {{ codec "pipelines" "updated_at" }},
+    started_at Nullable(DateTime64(3)) {{ codec "pipelines" "started_at" }},
+    finished_at Nullable(DateTime64(3)) {{ codec "pipelines" "finished_at" }},
+    committed_at Nullable(DateTime64(3)) {{ codec "pipelines" "committed_at" }},
+    duration Float64 {{ codec "pipelines" "duration" }},
+    queued_duration Float64 {{ codec "pipelines" "queued_duration" }},
+    coverage Float64 {{ codec "pipelines" "coverage" }},
+    child Bool {{ codec "pipelines" "child" }},
+    upstream_pipeline_id Int64 {{ codec "pipelines" "upstream_pipeline_id" }},
+    upstream_pipeline_iid Int64 {{ codec "pipelines" "upstream_pipeline_iid" }},
+    upstream_pipeline_project_id Int64 {{ codec "pipelines" "upstream_pipeline_project_id" }},
+    downstream_pipelines Array(Tuple(id Int64, iid Int64, project_id Int64)) {{ codec "pipelines" "downstream_pipelines" }},
+    merge_request_id Int64 {{ codec "pipelines" "merge_request_id" }},
+    merge_request_iid Int64 {{ codec "pipelines" "merge_request_iid" }},
+    merge_request_project_id Int64 {{ codec "pipelines" "merge_request_project_id" }},
+    user_id Int64 {{ codec "pipelines" "user_id" }}
+)
+ENGINE = ReplacingMergeTree(updated_at)
+{{ partitionBy "pipelines" "created_at" "month" }}
+ORDER BY id
+{{ ttl "pipelines" }}
+{{ settings "pipelines" }}
+;
+
+INSERT INTO pipelines_new (
+    id,
+    iid,
+    project_id,
+    name,
+    status,
+    failure_reason,
+    source,
+    ref,
+    ref_path,
+    sha,
+    warnings,
+    yaml_errors,
+    created_at,
+    updated_at,
+    started_at,
+    finished_at,
+    committed_at,
+    duration,
+    queued_duration,
+    coverage,
+    child,
+    upstream_pipeline_id,
+    upstream_pipeline_iid,
+    upstream_pipeline_project_id,
+    downstream_pipelines,
+    merge_request_id,
+    merge_request_iid,
+    merge_request_project_id,
+    user_id
+)
+SELECT
+    id,
+    iid,
+    project_id,
+    name,
+    status,
+    failure_reason,
+    source,
+    ref,
+    ref_path,
+    sha,
+    warnings,
+    yaml_errors,
+    toDateTime64(created_at, 3),
+    toDateTime64(updated_at, 3),
+    started_at,
+    finished_at,
+    committed_at,
+    duration,
+    queued_duration,
+    coverage,
+    child,
+    upstream_pipeline_id,
+    upstream_pipeline_iid,
+    upstream_pipeline_project_id,
+    downstream_pipelines,
+    merge_request_id,
+    merge_request_iid,
+    merge_request_project_id,
+    user_id
+FROM pipelines
+;
+
+SELECT throwIf(
+    (SELECT count() FROM pipelines_new) != (SELECT count() FROM pipelines),
+    'rows of pipelines were not copied to pipelines_new'
+);
+
+RENAME TABLE pipelines TO pipelines_old, pipelines_new TO pipelines;
+DROP TABLE pipelines_old;
+
+-- pipelines_in
+CREATE TABLE IF NOT EXISTS pipelines_in AS pipelines ENGINE = Null;
+
+-- pipelines_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS pipelines_mv
+TO pipelines
+AS SELECT * FROM pipelines_in LEFT OUTER JOIN pipelines ON pipelines_in.id = pipelines.id
+WHERE pipelines_in.updated_at > pipelines.updated_at
+;
+
+-- pipelines_compat
+CREATE OR REPLACE VIEW pipelines_compat AS
+SELECT * REPLACE (
+    toFloat64(created_at) AS created_at,
+    toFloat64(updated_at) AS updated_at,
+    ifNull(toFloat64(started_at), 0) AS started_at,
+    ifNull(toFloat64(finished_at), 0) AS finished_at,
+    ifNull(toFloat64(committed_at), 0) AS committed_at
+)
+FROM pipelines
+;
+
+-- jobs
+DROP VIEW IF EXISTS jobs_mv;
+DROP VIEW IF EXISTS jobs_compat;
+DROP TABLE IF EXISTS jobs_in;
+
+DROP TABLE IF EXISTS jobs_new;
+
+CREATE TABLE jobs_new (
+    id Int64 {{ codec "jobs" "id" }},
+    pipeline_id Int64 {{ codec "jobs" "pipeline_id" }},
+    project_id Int64 {{ codec "jobs" "project_id" }},
+    created_at DateTime64(3) {{ codec "jobs" "created_at" }},
+    queued_at Nullable(DateTime64(3)) {{ codec "jobs" "queued_at" }},
+    started_at Nullable(DateTime64(3)) {{ codec "jobs" "started_at" }},
+    finished_at Nullable(DateTime64(3)) {{ codec "jobs" "finished_at" }},
+    erased_at Nullable(DateTime64(3)) {{ codec "jobs" "erased_at" }},
+    duration Float64 {{ codec "jobs" "duration" }},
+    queued_duration Float64 {{ codec "jobs" "queued_duration" }},
+    coverage Float64 {{ codec "jobs" "coverage" }},
+    tag_list Array(String) {{ codec "jobs" "tag_list" }},
+    properties Array(Tuple(name String, value String)) {{ codec "jobs" "properties" }},
+    allow_failure Bool {{ codec "jobs" "allow_failure" }},
+    manual Bool {{ codec "jobs" "manual" }},
+    retried Bool {{ codec "jobs" "retried" }},
+    retryable Bool {{ codec "jobs" "retryable" }},
+    name String {{ codec "jobs" "name" }},
+    pipeline Tuple(
+        id Int64,
+        project_id Int64,
+        ref String,
+        sha String,
+        status String
+    ) COMMENT 'deprecated' {{ codec "jobs" "pipeline" }},
+    ref String {{ codec "jobs" "ref" }},
+    ref_path String {{ codec "jobs" "ref_path" }},
+    stage String {{ codec "jobs" "stage" }},
+    status String {{ codec "jobs" "status" }},
+    failure_reason String {{ codec "jobs" "failure_reason" }},
+    exit_code Int64 {{ codec "jobs" "exit_code" }},
+    kind String {{ codec "jobs" "kind" }},
+    downstream_pipeline_id Int64 {{ codec "jobs" "downstream_pipeline_id" }},
+    downstream_pipeline_iid Int64 {{ codec "jobs" "downstream_pipeline_iid" }},
+    downstream_pipeline_project_id Int64 {{ codec "jobs" "downstream_pipeline_project_id" }},
+    runner_id String {{ codec "jobs" "runner_id" }}
+)
+ENGINE = ReplacingMergeTree()
+{{ partitionBy "jobs" "created_at" "month" }}
+ORDER BY id
+{{ ttl "jobs" }}
+{{ settings "jobs" }}
+;
+
+INSERT INTO jobs_new (
+    id,
+    pipeline_id,
+    project_id,
+    created_at,
+    queued_at,
+    started_at,
+    finished_at,
+    erased_at,
+    duration,
+    queued_duration,
+    coverage,
+    tag_list,
+    properties,
+    allow_failure,
+    manual,
+    retried,
+    retryable,
+    name,
+    pipeline,
+    ref,
+    ref_path,
+    stage,
+    status,
+    failure_reason,
+    exit_code,
+    kind,
+    downstream_pipeline_id,
+    downstream_pipeline_iid,
+    downstream_pipeline_project_id,
+    runner_id
+)
+SELECT
+    id,
+    pipeline_id,
+    project_id,
+    toDateTime64(created_at, 3),
+    queued_at,
+    started_at,
+    finished_at,
+    erased_at,
+    duration,
+    queued_duration,
+    coverage,
+    tag_list,
+    properties,
+    allow_failure,
+    manual,
+    retried,
+    retryable,
+    name,
+    pipeline,
+    ref,
+    ref_path,
+    stage,
+    status,
+    failure_reason,
+    exit_code,
+    kind,
+    downstream_pipeline_id,
+    downstream_pipeline_iid,
+    downstream_pipeline_project_id,
+    runner_id
+FROM jobs
+;
+
+SELECT throwIf(
+    (SELECT count() FROM jobs_new) != (SELECT count() FROM jobs),
+    'rows of jobs were not copied to jobs_new'
+);
+
+RENAME TABLE jobs TO jobs_old, jobs_new TO jobs;
+DROP TABLE jobs_old;
+
+-- jobs_in
+CREATE TABLE IF NOT EXISTS jobs_in AS jobs ENGINE = Null;
+
+-- jobs_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS jobs_mv
+TO jobs
+AS SELECT * FROM jobs_in WHERE id NOT IN (
+    SELECT id FROM jobs WHERE pipeline.id IN (
+        SELECT DISTINCT tupleElement(pipeline, 'id') FROM jobs_in
+    )
+)
+;
+
+-- jobs_compat
+CREATE OR REPLACE VIEW jobs_compat AS
+SELECT * REPLACE (
+    toFloat64(created_at) AS created_at,
+    ifNull(toFloat64(queued_at), 0) AS queued_at,
+    ifNull(toFloat64(started_at), 0) AS started_at,
+    ifNull(toFloat64(finished_at), 0) AS finished_at,
+    ifNull(toFloat64(erased_at), 0) AS erased_at
+)
+FROM jobs
+;
+
+-- sections
+DROP VIEW IF EXISTS sections_mv;
+DROP VIEW IF EXISTS sections_compat;
+DROP TABLE IF EXISTS sections_in;
+
+DROP TABLE IF EXISTS sections_new;
+
+CREATE TABLE sections_new (
+    id Int64 {{ codec "sections" "id" }},
+    job_id Int64 {{ codec "sections" "job_id" }},
+    pipeline_id Int64 {{ codec "sections" "pipeline_id" }},
+    project_id Int64 {{ codec "sections" "project_id" }},
+    name String {{ codec "sections" "name" }},
+    job Tuple(
+        id Int64,
+        name String,
+        status String
+    ) COMMENT 'deprecated' {{ codec "sections" "job" }},
+    pipeline Tuple(
+        id Int64,
+        project_id Int64,
+        ref String,
+        sha String,
+        status String
+    ) COMMENT 'deprecated' {{ codec "sections" "pipeline" }},
+    started_at DateTime64(3) {{ codec "sections" "started_at" }},
+    finished_at DateTime64(3) {{ codec "sections" "finished_at" }},
+    duration Float64 {{ codec "sections" "duration" }}
+)
+ENGINE = ReplacingMergeTree()
+{{ partitionBy "sections" "started_at" "month" }}
+ORDER BY id
+{{ ttl "sections" }}
+{{ settings "sections" }}
+;
+
+INSERT INTO sections_new (
+    id,
+    job_id,
+    pipeline_id,
+    project_id,
+    name,
+    job,
+    pipeline,
+    started_at,
+    finished_at,
+    duration
+)
+SELECT
+    id,
+    job_id,
+    pipeline_id,
+    project_id,
+    name,
+    job,
+    pipeline,
+    toDateTime64(started_at, 3),
+    toDateTime64(finished_at, 3),
+    duration
+FROM sections
+;
+
+SELECT throwIf(
+    (SELECT count() FROM sections_new) != (SELECT count() FROM sections),
+    'rows of sections were not copied to sections_new'
+);
+
+RENAME TABLE sections TO sections_old, sections_new TO sections;
+DROP TABLE sections_old;
+
+-- sections_in
+CREATE TABLE IF NOT EXISTS sections_in AS sections ENGINE = Null;
+
+-- sections_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS sections_mv
+TO sections
+AS SELECT * FROM sections_in WHERE id NOT IN (
+    SELECT id FROM sections WHERE job.id IN (
+        SELECT DISTINCT tupleElement(job, 'id') FROM sections_in
+    )
+)
+;
+
+-- sections_compat
+CREATE OR REPLACE VIEW sections_compat AS
+SELECT * REPLACE (
+    toFloat64(started_at) AS started_at,
+    toFloat64(finished_at) AS finished_at
+)
+FROM sections
+;
+
+-- bridges
+DROP VIEW IF EXISTS bridges_mv;
+DROP VIEW IF EXISTS bridges_compat;
+DROP TABLE IF EXISTS bridges_in;
+
+DROP TABLE IF EXISTS bridges_new;
+
+CREATE TABLE bridges_new (
+    coverage Float64 {{ codec "bridges" "coverage" }},
+    allow_failure Bool {{ codec "bridges" "allow_failure" }},
+    created_at DateTime64(3) {{ codec "bridges" "created_at" }},
+    started_at Nullable(DateTime64(3)) {{ codec "bridges" "started_at" }},
+    finished_at Nullable(DateTime64(3)) {{ codec "bridges" "finished_at" }},
+    erased_at Nullable(DateTime64(3)) {{ codec "bridges" "erased_at" }},
+    duration Float64 {{ codec "bridges" "duration" }},
+    queued_duration Float64 {{ codec "bridges" "queued_duration" }},
+    id Int64 {{ codec "bridges" "id" }},
+    name String {{ codec "bridges" "name" }},
+    pipeline Tuple(
+        id Int64,
+        iid Int64,
+        project_id Int64,
+        status String,
+        source String,
+        ref String,
+        sha String,
+        web_url String,
+        created_at Float64,
+        updated_at Float64
+    ) {{ codec "bridges" "pipeline" }},
+    ref String {{ codec "bridges" "ref" }},
+    stage String {{ codec "bridges" "stage" }},
+    status String {{ codec "bridges" "status" }},
+    failure_reason String {{ codec "bridges" "failure_reason" }},
+    tag Bool {{ codec "bridges" "tag" }},
+    web_url String {{ codec "bridges" "web_url" }},
+    downstream_pipeline Tuple(
+        id Int64,
+        iid Int64,
+        project_id Int64,
+        status String,
+        source String,
+        ref String,
+        sha String,
+        web_url String,
+        created_at Float64,
+        updated_at Float64
+    ) {{ codec "bridges" "downstream_pipeline" }}
+)
+ENGINE = ReplacingMergeTree()
+{{ partitionBy "bridges" "created_at" "month" }}
+ORDER BY id
+{{ ttl "bridges" }}
+{{ settings "bridges" }}
+;
+
+INSERT INTO bridges_new (
+    coverage,
+    allow_failure,
+    created_at,
+    started_at,
+    finished_at,
+    erased_at,
+    duration,
+    queued_duration,
+    id,
+    name,
+    pipeline,
+    ref,
+    stage,
+    status,
+    failure_reason,
+    tag,
+    web_url,
+    downstream_pipeline
+)
+SELECT
+    coverage,
+    allow_failure,
+    toDateTime64(created_at, 3),
+    if(started_at = 0, NULL, toDateTime64(started_at, 3)),
+    if(finished_at = 0, NULL, toDateTime64(finished_at, 3)),
+    if(erased_at = 0, NULL, toDateTime64(erased_at, 3)),
+    duration,
+    queued_duration,
+    id,
+    name,
+    pipeline,
+    ref,
+    stage,
+    status,
+    failure_reason,
+    tag,
+    web_url,
+    downstream_pipeline
+FROM bridges
+;
+
+SELECT throwIf(
+    (SELECT count() FROM bridges_new) != (SELECT count() FROM bridges),
+    'rows of bridges were not copied to bridges_new'
+);
+
+RENAME TABLE bridges TO bridges_old, bridges_new TO bridges;
+DROP TABLE bridges_old;
+
+-- bridges_in
+CREATE TABLE IF NOT EXISTS bridges_in AS bridges ENGINE = Null;
+
+-- bridges_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS bridges_mv
+TO bridges
+AS SELECT * FROM bridges_in WHERE id NOT IN (
+    SELECT id FROM bridges WHERE pipeline.id IN (
+        SELECT DISTINCT tupleElement(pipeline, 'id') FROM bridges_in
+    )
+)
+;
+
+-- bridges_compat
+CREATE OR REPLACE VIEW bridges_compat AS
+SELECT * REPLACE (
+    toFloat64(created_at) AS created_at,
+    ifNull(toFloat64(started_at), 0) AS started_at,
+    ifNull(toFloat64(finished_at), 0) AS finished_at,
+    ifNull(toFloat64(erased_at), 0) AS erased_at
+)
+FROM bridges
+;
+
+-- mergerequests
+DROP VIEW IF EXISTS mergerequests_mv;
+DROP VIEW IF EXISTS mergerequests_compat;
+DROP TABLE IF EXISTS mergerequests_in;
+
+DROP TABLE IF EXISTS mergerequests_new;
+
+CREATE TABLE mergerequests_new (
+    id Int64 {{ codec "mergerequests" "id" }},
+    iid Int64 {{ codec "mergerequests" "iid" }},
+    project_id Int64 {{ codec "mergerequests" "project_id" }},
+    created_at DateTime64(3) {{ codec "mergerequests" "created_at" }},
+    updated_at DateTime64(3) {{ codec "mergerequests" "updated_at" }},
+    merged_at Nullable(DateTime64(3)) {{ codec "mergerequests" "merged_at" }},
+    closed_at Nullable(DateTime64(3)) {{ codec "mergerequests" "closed_at" }},
+    source_project_id Int64 {{ codec "mergerequests" "source_project_id" }},
+    target_project_id Int64 {{ codec "mergerequests" "target_project_id" }},
+    source_branch String {{ codec "mergerequests" "source_branch" }},
+    target_branch String {{ codec "mergerequests" "target_branch" }},
+    additions Int64 {{ codec "mergerequests" "additions" }},
+    changes Int64 {{ codec "mergerequests" "changes" }},
+    deletions Int64 {{ codec "mergerequests" "deletions" }},
+    file_count Int64 {{ codec "mergerequests" "file_count" }},
+    commit_count Int64 {{ codec "mergerequests" "commit_count" }},
+    title String {{ codec "mergerequests" "title" }},
+    name String {{ codec "mergerequests" "name" }},
+    labels Array(String) {{ codec "mergerequests" "labels" }},
+    state String {{ codec "mergerequests" "state" }},
+    merge_status String {{ codec "mergerequests" "merge_status" }},
+    merge_error String {{ codec "mergerequests" "merge_error" }},
+    draft Bool {{ codec "mergerequests" "draft" }},
+    conflicts Bool {{ codec "mergerequests" "conflicts" }},
+    approved Bool {{ codec "mergerequests" "approved" }},
+    mergeable Bool {{ codec "mergerequests" "mergeable" }},
+    base_sha String {{ codec "mergerequests" "base_sha" }},
+    head_sha String {{ codec "mergerequests" "head_sha" }},
+    start_sha String {{ codec "mergerequests" "start_sha" }},
+    merge_commit_sha String {{ codec "mergerequests" "merge_commit_sha" }},
+    rebase_commit_sha String {{ codec "mergerequests" "rebase_commit_sha" }},
+    author_id Int64 {{ codec "mergerequests" "author_id" }},
+    author_username String {{ codec "mergerequests" "author_username" }},
+    author_name String {{ codec "mergerequests" "author_name" }},
+    assignees_id Array(Int64) {{ codec "mergerequests" "assignees_id" }},
+    assignees_username Array(String) {{ codec "mergerequests" "assignees_username" }},
+    assignees_name Array(String) {{ codec "mergerequests" "assignees_name" }},
+    reviewers_id Array(Int64) {{ codec "mergerequests" "reviewers_id" }},
+    reviewers_username Array(String) {{ codec "mergerequests" "reviewers_username" }},
+    reviewers_name Array(String) {{ codec "mergerequests" "reviewers_name" }},
+    approvers_id Array(Int64) {{ codec "mergerequests" "approvers_id" }},
+    approvers_username Array(String) {{ codec "mergerequests" "approvers_username" }},
+    approvers_name Array(String) {{ codec "mergerequests" "approvers_name" }},
+    merge_user_id Int64 {{ codec "mergerequests" "merge_user_id" }},
+    merge_user_username String {{ codec "mergerequests" "merge_user_username" }},
+    merge_user_name String {{ codec "mergerequests" "merge_user_name" }},
+    milestone_id Int64 {{ codec "mergerequests" "milestone_id" }},
+    milestone_iid Int64 {{ codec "mergerequests" "milestone_iid" }},
+    milestone_project_id Int64 {{ codec "mergerequests" "milestone_project_id" }}
+)
+ENGINE = ReplacingMergeTree(updated_at)
+{{ partitionBy "mergerequests" "created_at" "month" }}
+ORDER BY id
+{{ ttl "mergerequests" }}
+{{ settings "mergerequests" }}
+;
+
+INSERT INTO mergerequests_new (
+    id,
+    iid,
+    project_id,
+    created_at,
+    updated_at,
+    merged_at,
+    closed_at,
+    source_project_id,
+    target_project_id,
+    source_branch,
+    target_branch,
+    additions,
+    changes,
+    deletions,
+    file_count,
+    commit_count,
+    title,
+    name,
+    labels,
+    state,
+    merge_status,
+    merge_error,
+    draft,
+    conflicts,
+    approved,
+    mergeable,
+    base_sha,
+    head_sha,
+    start_sha,
+    merge_commit_sha,
+    rebase_commit_sha,
+    author_id,
+    author_username,
+    author_name,
+    assignees_id,
+    assignees_username,
+    assignees_name,
+    reviewers_id,
+    reviewers_username,
+    reviewers_name,
+    approvers_id,
+    approvers_username,
+    approvers_name,
+    merge_user_id,
+    merge_user_username,
+    merge_user_name,
+    milestone_id,
+    milestone_iid,
+    milestone_project_id
+)
+SELECT
+    id,
+    iid,
+    project_id,
+    toDateTime64(created_at, 3),
+    toDateTime64(updated_at, 3),
+    merged_at,
+    closed_at,
+    source_project_id,
+    target_project_id,
+    source_branch,
+    target_branch,
+    additions,
+    changes,
+    deletions,
+    file_count,
+    commit_count,
+    title,
+    name,
+    labels,
+    state,
+    merge_status,
+    merge_error,
+    draft,
+    conflicts,
+    approved,
+    mergeable,
+    base_sha,
+    head_sha,
+    start_sha,
+    merge_commit_sha,
+    rebase_commit_sha,
+    author_id,
+    author_username,
+    author_name,
+    assignees_id,
+    assignees_username,
+    assignees_name,
+    reviewers_id,
+    reviewers_username,
+    reviewers_name,
+    approvers_id,
+    approvers_username,
+    approvers_name,
+    merge_user_id,
+    merge_user_username,
+    merge_user_name,
+    milestone_id,
+    milestone_iid,
+    milestone_project_id
+FROM mergerequests
+;
+
+SELECT throwIf(
+    (SELECT count() FROM mergerequests_new) != (SELECT count() FROM mergerequests),
+    'rows of mergerequests were not copied to mergerequests_new'
+);
+
+RENAME TABLE mergerequests TO mergerequests_old, mergerequests_new TO mergerequests;
+DROP TABLE mergerequests_old;
+
+-- mergerequests_in
+CREATE TABLE IF NOT EXISTS mergerequests_in AS mergerequests ENGINE = Null;
+
+-- mergerequests_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS mergerequests_mv
+TO mergerequests
+AS SELECT * FROM mergerequests_in LEFT OUTER JOIN mergerequests ON mergerequests_in.id = mergerequests.id
+WHERE mergerequests_in.updated_at > mergerequests.updated_at
+;
+
+-- mergerequests_compat
+CREATE OR REPLACE VIEW mergerequests_compat AS
+SELECT * REPLACE (
+    toFloat64(created_at) AS created_at,
+    toFloat64(updated_at) AS updated_at,
+    ifNull(toFloat64(merged_at), 0) AS merged_at,
+    ifNull(toFloat64(closed_at), 0) AS closed_at
+)
+FROM mergerequests
+;
+
+-- mergerequest_noteevents
+DROP VIEW IF EXISTS mergerequest_noteevents_mv;
+DROP VIEW IF EXISTS mergerequest_noteevents_compat;
+DROP TABLE IF EXISTS mergerequest_noteevents_in;
+
+DROP TABLE IF EXISTS mergerequest_noteevents_new;
+
+CREATE TABLE mergerequest_noteevents_new (
+    id Int64 {{ codec "mergerequest_noteevents" "id" }},
+    mergerequest_id Int64 {{ codec "mergerequest_noteevents" "mergerequest_id" }},
+    mergerequest_iid Int64 {{ codec "mergerequest_noteevents" "mergerequest_iid" }},
+    mergerequest_project_id Int64 {{ codec "mergerequest_noteevents" "mergerequest_project_id" }},
+    created_at DateTime64(3) {{ codec "mergerequest_noteevents" "created_at" }},
+    updated_at DateTime64(3) {{ codec "mergerequest_noteevents" "updated_at" }},
+    resolved_at Nullable(DateTime64(3)) {{ codec "mergerequest_noteevents" "resolved_at" }},
+    type String {{ codec "mergerequest_noteevents" "type" }},
+    system Bool {{ codec "mergerequest_noteevents" "system" }},
+    author_id Int64 {{ codec "mergerequest_noteevents" "author_id" }},
+    author_username String {{ codec "mergerequest_noteevents" "author_username" }},
+    author_name String {{ codec "mergerequest_noteevents" "author_name" }},
+    resolvable Bool {{ codec "mergerequest_noteevents" "resolvable" }},
+    resolved Bool {{ codec "mergerequest_noteevents" "resolved" }},
+    resolver_id Int64 {{ codec "mergerequest_noteevents" "resolver_id" }},
+    resolver_username String {{ codec "mergerequest_noteevents" "resolver_username" }},
+    resolver_name String {{ codec "mergerequest_noteevents" "resolver_name" }},
+    internal Bool {{ codec "mergerequest_noteevents" "internal" }}
+)
+ENGINE = ReplacingMergeTree(updated_at)
+{{ partitionBy "mergerequest_noteevents" "created_at" "month" }}
+ORDER BY id
+{{ ttl "mergerequest_noteevents" }}
+{{ settings "mergerequest_noteevents" }}
+;
+
+INSERT INTO mergerequest_noteevents_new (
+    id,
+    mergerequest_id,
+    mergerequest_iid,
+    mergerequest_project_id,
+    created_at,
+    updated_at,
+    resolved_at,
+    type,
+    system,
+    author_id,
+    author_username,
+    author_name,
+    resolvable,
+    resolved,
+    resolver_id,
+    resolver_username,
+    resolver_name,
+    internal
+)
+SELECT
+    id,
+    mergerequest_id,
+    mergerequest_iid,
+    mergerequest_project_id,
+    toDateTime64(created_at, 3),
+    toDateTime64(updated_at, 3),
+    if(resolved_at = 0, NULL, toDateTime64(resolved_at, 3)),
+    type,
+    system,
+    author_id,
+    author_username,
+    author_name,
+    resolvable,
+    resolved,
+    resolver_id,
+    resolver_username,
+    resolver_name,
+    internal
+FROM mergerequest_noteevents
+;
+
+SELECT throwIf(
+    (SELECT count() FROM mergerequest_noteevents_new) != (SELECT count() FROM mergerequest_noteevents),
+    'rows of mergerequest_noteevents were not copied to mergerequest_noteevents_new'
+);
+
+RENAME TABLE mergerequest_noteevents TO mergerequest_noteevents_old, mergerequest_noteevents_new TO mergerequest_noteevents;
+DROP TABLE mergerequest_noteevents_old;
+
+-- mergerequest_noteevents_in
+CREATE TABLE IF NOT EXISTS mergerequest_noteevents_in AS mergerequest_noteevents ENGINE = Null;
+
+-- mergerequest_noteevents_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS mergerequest_noteevents_mv
+TO mergerequest_noteevents
+AS SELECT * FROM mergerequest_noteevents_in LEFT OUTER JOIN mergerequest_noteevents USING (id)
+    WHERE mergerequest_noteevents_in.updated_at > mergerequest_noteevents.updated_at
+;
+
+-- mergerequest_noteevents_compat
+CREATE OR REPLACE VIEW mergerequest_noteevents_compat AS
+SELECT * REPLACE (
+    toFloat64(created_at) AS created_at,
+    toFloat64(updated_at) AS updated_at,
+    ifNull(toFloat64(resolved_at), 0) AS resolved_at
+)
+FROM mergerequest_noteevents
+;
+
+-- deployments
+DROP VIEW IF EXISTS deployments_mv;
+DROP VIEW IF EXISTS deployments_compat;
+DROP TABLE IF EXISTS deployments_in;
+
+DROP TABLE IF EXISTS deployments_new;
+
+CREATE TABLE deployments_new (
+    id Int64 {{ codec "deployments" "id" }},
+    iid Int64 {{ codec "deployments" "iid" }},
+    job_id Int64 {{ codec "deployments" "job_id" }},
+    pipeline_id Int64 {{ codec "deployments" "pipeline_id" }},
+    project_id Int64 {{ codec "deployments" "project_id" }},
+    environment_id Int64 {{ codec "deployments" "environment_id" }},
+    environment_name String {{ codec "deployments" "environment_name" }},
+    environment_tier String {{ codec "deployments" "environment_tier" }},
+    triggerer_id Int64 {{ codec "deployments" "triggerer_id" }},
+    triggerer_username String {{ codec "deployments" "triggerer_username" }},
+    triggerer_name String {{ codec "deployments" "triggerer_name" }},
+    created_at DateTime64(3) {{ codec "deployments" "created_at" }},
+    finished_at Nullable(DateTime64(3)) {{ codec "deployments" "finished_at" }},
+    updated_at DateTime64(3) {{ codec "deployments" "updated_at" }},
+    status String {{ codec "deployments" "status" }},
+    ref String {{ codec "deployments" "ref" }},
+    sha String {{ codec "deployments" "sha" }}
+)
+ENGINE = ReplacingMergeTree(updated_at)
+{{ partitionBy "deployments" "created_at" "month" }}
+ORDER BY (project_id, id)
+{{ ttl "deployments" }}
+{{ settings "deployments" }}
+;
+
+INSERT INTO deployments_new (
+    id,
+    iid,
+    job_id,
+    pipeline_id,
+    project_id,
+    environment_id,
+    environment_name,
+    environment_tier,
+    triggerer_id,
+    triggerer_username,
+    triggerer_name,
+    created_at,
+    finished_at,
+    updated_at,
+    status,
+    ref,
+    sha
+)
+SELECT
+    id,
+    iid,
+    job_id,
+    pipeline_id,
+    project_id,
+    environment_id,
+    environment_name,
+    environment_tier,
+    triggerer_id,
+    triggerer_username,
+    triggerer_name,
+    toDateTime64(created_at, 3),
+    finished_at,
+    toDateTime64(updated_at, 3),
+    status,
+    ref,
+    sha
+FROM deployments
+;
+
+SELECT throwIf(
+    (SELECT count() FROM deployments_new) != (SELECT count() FROM deployments),
+    'rows of deployments were not copied to deployments_new'
+);
+
+RENAME TABLE deployments TO deployments_old, deployments_new TO deployments;
+DROP TABLE deployments_old;
+
+-- deployments_in
+CREATE TABLE IF NOT EXISTS deployments_in AS deployments ENGINE = Null;
+
+-- deployments_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS deployments_mv TO deployments AS
+    SELECT * FROM deployments_in LEFT OUTER JOIN deployments ON deployments_in.id = deployments.id
+    WHERE deployments_in.updated_at > deployments.updated_at
+;
+
+-- deployments_compat
+CREATE OR REPLACE VIEW deployments_compat AS
+SELECT * REPLACE (
+    toFloat64(created_at) AS created_at,
+    toFloat64(updated_at) AS updated_at,
+    ifNull(toFloat64(finished_at), 0) AS finished_at
+)
+FROM deployments
+;
+
+-- issues
+DROP VIEW IF EXISTS issues_mv;
+DROP VIEW IF EXISTS issues_compat;
+DROP TABLE IF EXISTS issues_in;
+
+DROP TABLE IF EXISTS issues_new;
+
+CREATE TABLE issues_new (
+    id Int64 {{ codec "issues" "id" }},
+    iid Int64 {{ codec "issues" "iid" }},
+    project_id Int64 {{ codec "issues" "project_id" }},
+    created_at DateTime64(3) {{ codec "issues" "created_at" }},
+    updated_at DateTime64(3) {{ codec "issues" "updated_at" }},
+    closed_at Nullable(DateTime64(3)) {{ codec "issues" "closed_at" }},
+    title String {{ codec "issues" "title" }},
+    labels Array(String) {{ codec "issues" "labels" }},
+    type String {{ codec "issues" "type" }},
+    severity String {{ codec "issues" "severity" }},
+    state String {{ codec "issues" "state" }}
+)
+ENGINE = ReplacingMergeTree(updated_at)
+{{ partitionBy "issues" "created_at" "month" }}
+ORDER BY (project_id, id)
+{{ ttl "issues" }}
+{{ settings "issues" }}
+;
+
+INSERT INTO issues_new (
+    id,
+    iid,
+    project_id,
+    created_at,
+    updated_at,
+    closed_at,
+    title,
+    labels,
+    type,
+    severity,
+    state
+)
+SELECT
+    id,
+    iid,
+    project_id,
+    toDateTime64(created_at, 3),
+    toDateTime64(updated_at, 3),
+    closed_at,
+    title,
+    labels,
+    type,
+    severity,
+    state
+FROM issues
+;
+
+SELECT throwIf(
+    (SELECT count() FROM issues_new) != (SELECT count() FROM issues),
+    'rows of issues were not copied to issues_new'
+);
+
+RENAME TABLE issues TO issues_old, issues_new TO issues;
+DROP TABLE issues_old;
+
+-- issues_in
+CREATE TABLE IF NOT EXISTS issues_in AS issues ENGINE = Null;
+
+-- issues_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS issues_mv TO issues AS
+SELECT * FROM issues_in LEFT OUTER JOIN issues USING id
+WHERE issues_in.updated_at > issues.updated_at
+;
+
+-- issues_compat
+CREATE OR REPLACE VIEW issues_compat AS
+SELECT * REPLACE (
+    toFloat64(created_at) AS created_at,
+    toFloat64(updated_at) AS updated_at,
+    ifNull(toFloat64(closed_at), 0) AS closed_at
+)
+FROM issues
+;
+
+-- coverage_reports
+DROP VIEW IF EXISTS coverage_reports_mv;
+DROP VIEW IF EXISTS coverage_reports_compat;
+DROP TABLE IF EXISTS coverage_reports_in;
+
+DROP TABLE IF EXISTS coverage_reports_new;
+
+CREATE TABLE coverage_reports_new (
+    id String {{ codec "coverage_reports" "id" }},
+    job_id Int64 {{ codec "coverage_reports" "job_id" }},
+    pipeline_id Int64 {{ codec "coverage_reports" "pipeline_id" }},
+    project_id Int64 {{ codec "coverage_reports" "project_id" }},
+    line_rate Float32 {{ codec "coverage_reports" "line_rate" }},
+    lines_covered Int32 {{ codec "coverage_reports" "lines_covered" }},
+    lines_valid Int32 {{ codec "coverage_reports" "lines_valid" }},
+    branch_rate Float32 {{ codec "coverage_reports" "branch_rate" }},
+    branches_covered Int32 {{ codec "coverage_reports" "branches_covered" }},
+    branches_valid Int32 {{ codec "coverage_reports" "branches_valid" }},
+    complexity Float32 {{ codec "coverage_reports" "complexity" }},
+    version String {{ codec "coverage_reports" "version" }},
+    timestamp DateTime64(3) {{ codec "coverage_reports" "timestamp" }},
+    source_paths Array(String) {{ codec "coverage_reports" "source_paths" }}
+)
+ENGINE = ReplacingMergeTree()
+{{ partitionBy "coverage_reports" "timestamp" "month" }}
+ORDER BY (project_id, pipeline_id, job_id, id)
+{{ ttl "coverage_reports" }}
+{{ settings "coverage_reports" }}
+;
+
+INSERT INTO coverage_reports_new (
+    id,
+    job_id,
+    pipeline_id,
+    project_id,
+    line_rate,
+    lines_covered,
+    lines_valid,
+    branch_rate,
+    branches_covered,
+    branches_valid,
+    complexity,
+    version,
+    timestamp,
+    source_paths
+)
+SELECT
+    id,
+    job_id,
+    pipeline_id,
+    project_id,
+    line_rate,
+    lines_covered,
+    lines_valid,
+    branch_rate,
+    branches_covered,
+    branches_valid,
+    complexity,
+    version,
+    toDateTime64(timestamp, 3),
+    source_paths
+FROM coverage_reports
+;
+
+SELECT throwIf(
+    (SELECT count() FROM coverage_reports_new) != (SELECT count() FROM coverage_reports),
+    'rows of coverage_reports were not copied to coverage_reports_new'
+);
+
+RENAME TABLE coverage_reports TO coverage_reports_old, coverage_reports_new TO coverage_reports;
+DROP TABLE coverage_reports_old;
+
+-- coverage_reports_in
+CREATE TABLE IF NOT EXISTS coverage_reports_in AS coverage_reports ENGINE = Null;
+
+-- coverage_reports_mv
+CREATE MATERIALIZED VIEW IF NOT EXISTS coverage_reports_mv TO coverage_reports
+AS
+SELECT * FROM coverage_reports_in
+WHERE id NOT IN (
+    SELECT id FROM coverage_reports
+    WHERE job_id IN (
+        SELECT DISTINCT job_id FROM coverage_reports_in
+    )
+)
+;
+
+-- coverage_reports_compat
+CREATE OR REPLACE VIEW coverage_reports_compat AS
+SELECT * REPLACE (
+    toFloat64(timestamp) AS timestamp
+)
+FROM coverage_reports
+;
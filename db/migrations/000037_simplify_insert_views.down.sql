@@ -0,0 +1,160 @@
+-- Recorders must be stopped while this migration runs, records inserted while
+-- an insert view is being replaced are lost.
+
+-- projects
+DROP VIEW IF EXISTS projects_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS projects_mv
+TO projects
+AS SELECT * FROM projects_in LEFT OUTER JOIN projects ON projects_in.id = projects.id
+WHERE projects_in.last_activity_at > projects.last_activity_at
+;
+
+-- pipelines
+DROP VIEW IF EXISTS pipelines_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS pipelines_mv
+TO pipelines
+AS SELECT * FROM pipelines_in LEFT OUTER JOIN pipelines ON pipelines_in.id = pipelines.id
+WHERE pipelines_in.updated_at > pipelines.updated_at
+;
+
+-- jobs
+DROP VIEW IF EXISTS jobs_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS jobs_mv
+TO jobs
+AS SELECT * FROM jobs_in WHERE id NOT IN (
+    SELECT id FROM jobs WHERE pipeline.id IN (
+        SELECT DISTINCT tupleElement(pipeline, 'id') FROM jobs_in
+    )
+)
+;
+
+-- sections
+DROP VIEW IF EXISTS sections_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS sections_mv
+TO sections
+AS SELECT * FROM sections_in WHERE id NOT IN (
+    SELECT id FROM sections WHERE job.id IN (
+        SELECT DISTINCT tupleElement(job, 'id') FROM sections_in
+    )
+)
+;
+
+-- bridges
+DROP VIEW IF EXISTS bridges_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS bridges_mv
+TO bridges
+AS SELECT * FROM bridges_in WHERE id NOT IN (
+    SELECT id FROM bridges WHERE pipeline.id IN (
+        SELECT DISTINCT tupleElement(pipeline, 'id') FROM bridges_in
+    )
+)
+;
+
+-- testreports
+DROP VIEW IF EXISTS testreports_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS testreports_mv
+TO testreports
+AS SELECT * FROM testreports_in WHERE id NOT IN (
+    SELECT id FROM testreports WHERE pipeline_id IN (
+        SELECT DISTINCT pipeline_id FROM testreports_in
+    )
+)
+;
+
+-- testsuites
+DROP VIEW IF EXISTS testsuites_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS testsuites_mv
+TO testsuites
+AS SELECT * FROM testsuites_in WHERE id NOT IN (
+    SELECT id FROM testsuites WHERE testreport_id IN (
+        SELECT DISTINCT testreport_id FROM testsuites_in
+    )
+)
+;
+
+-- testcases
+DROP VIEW IF EXISTS testcases_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS testcases_mv
+TO testcases
+AS SELECT * FROM testcases_in WHERE id NOT IN (
+    SELECT id FROM testcases WHERE testsuite_id IN (
+        SELECT DISTINCT testsuite_id FROM testcases_in
+    )
+)
+;
+
+-- mergerequests
+DROP VIEW IF EXISTS mergerequests_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS mergerequests_mv
+TO mergerequests
+AS SELECT * FROM mergerequests_in LEFT OUTER JOIN mergerequests ON mergerequests_in.id = mergerequests.id
+WHERE mergerequests_in.updated_at > mergerequests.updated_at
+;
+
+-- mergerequest_noteevents
+DROP VIEW IF EXISTS mergerequest_noteevents_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS mergerequest_noteevents_mv
+TO mergerequest_noteevents
+AS SELECT * FROM mergerequest_noteevents_in LEFT OUTER JOIN mergerequest_noteevents USING (id)
+WHERE mergerequest_noteevents_in.updated_at > mergerequest_noteevents.updated_at
+;
+
+-- deployments
+DROP VIEW IF EXISTS deployments_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS deployments_mv
+TO deployments
+AS SELECT * FROM deployments_in LEFT OUTER JOIN deployments ON deployments_in.id = deployments.id
+WHERE deployments_in.updated_at > deployments.updated_at
+;
+
+-- issues
+DROP VIEW IF EXISTS issues_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS issues_mv
+TO issues
+AS SELECT * FROM issues_in LEFT OUTER JOIN issues USING id
+WHERE issues_in.updated_at > issues.updated_at
+;
+
+-- coverage_reports
+DROP VIEW IF EXISTS coverage_reports_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS coverage_reports_mv
+TO coverage_reports
+AS SELECT * FROM coverage_reports_in WHERE id NOT IN (
+    SELECT id FROM coverage_reports WHERE job_id IN (
+        SELECT DISTINCT job_id FROM coverage_reports_in
+    )
+)
+;
+
+-- coverage_packages
+DROP VIEW IF EXISTS coverage_packages_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS coverage_packages_mv
+TO coverage_packages
+AS SELECT * FROM coverage_packages_in WHERE id NOT IN (
+    SELECT id FROM coverage_packages WHERE job_id IN (
+        SELECT DISTINCT job_id FROM coverage_packages_in
+    )
+)
+;
+
+-- coverage_classes
+DROP VIEW IF EXISTS coverage_classes_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS coverage_classes_mv
+TO coverage_classes
+AS SELECT * FROM coverage_classes_in WHERE id NOT IN (
+    SELECT id FROM coverage_classes WHERE job_id IN (
+        SELECT DISTINCT job_id FROM coverage_classes_in
+    )
+)
+;
+
+-- coverage_methods
+DROP VIEW IF EXISTS coverage_methods_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS coverage_methods_mv
+TO coverage_methods
+AS SELECT * FROM coverage_methods_in WHERE id NOT IN (
+    SELECT id FROM coverage_methods WHERE job_id IN (
+        SELECT DISTINCT job_id FROM coverage_methods_in
+    )
+)
+;
@@ -0,0 +1,120 @@
+-- The recorder skips records that are already known before inserting them
+-- (see the `id_cache` settings), so the insert views don't need to look up the
+-- target tables anymore. Duplicates and stale versions that get through, e.g.
+-- if several recorders insert the same records, are removed when parts are
+-- merged.
+--
+-- Recorders must be stopped while this migration runs, records inserted while
+-- an insert view is being replaced are lost.
+
+-- projects
+DROP VIEW IF EXISTS projects_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS projects_mv
+TO projects
+AS SELECT * FROM projects_in
+;
+
+-- pipelines
+DROP VIEW IF EXISTS pipelines_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS pipelines_mv
+TO pipelines
+AS SELECT * FROM pipelines_in
+;
+
+-- jobs
+DROP VIEW IF EXISTS jobs_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS jobs_mv
+TO jobs
+AS SELECT * FROM jobs_in
+;
+
+-- sections
+DROP VIEW IF EXISTS sections_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS sections_mv
+TO sections
+AS SELECT * FROM sections_in
+;
+
+-- bridges
+DROP VIEW IF EXISTS bridges_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS bridges_mv
+TO bridges
+AS SELECT * FROM bridges_in
+;
+
+-- testreports
+DROP VIEW IF EXISTS testreports_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS testreports_mv
+TO testreports
+AS SELECT * FROM testreports_in
+;
+
+-- testsuites
+DROP VIEW IF EXISTS testsuites_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS testsuites_mv
+TO testsuites
+AS SELECT * FROM testsuites_in
+;
+
+-- testcases
+DROP VIEW IF EXISTS testcases_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS testcases_mv
+TO testcases
+AS SELECT * FROM testcases_in
+;
+
+-- mergerequests
+DROP VIEW IF EXISTS mergerequests_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS mergerequests_mv
+TO mergerequests
+AS SELECT * FROM mergerequests_in
+;
+
+-- mergerequest_noteevents
+DROP VIEW IF EXISTS mergerequest_noteevents_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS mergerequest_noteevents_mv
+TO mergerequest_noteevents
+AS SELECT * FROM mergerequest_noteevents_in
+;
+
+-- deployments
+DROP VIEW IF EXISTS deployments_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS deployments_mv
+TO deployments
+AS SELECT * FROM deployments_in
+;
+
+-- issues
+DROP VIEW IF EXISTS issues_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS issues_mv
+TO issues
+AS SELECT * FROM issues_in
+;
+
+-- coverage_reports
+DROP VIEW IF EXISTS coverage_reports_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS coverage_reports_mv
+TO coverage_reports
+AS SELECT * FROM coverage_reports_in
+;
+
+-- coverage_packages
+DROP VIEW IF EXISTS coverage_packages_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS coverage_packages_mv
+TO coverage_packages
+AS SELECT * FROM coverage_packages_in
+;
+
+-- coverage_classes
+DROP VIEW IF EXISTS coverage_classes_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS coverage_classes_mv
+TO coverage_classes
+AS SELECT * FROM coverage_classes_in
+;
+
+-- coverage_methods
+DROP VIEW IF EXISTS coverage_methods_mv;
+CREATE MATERIALIZED VIEW IF NOT EXISTS coverage_methods_mv
+TO coverage_methods
+AS SELECT * FROM coverage_methods_in
+;
@@ -23,6 +23,8 @@ import (
 	"github.com/testcontainers/testcontainers-go"
 	tcexec "github.com/testcontainers/testcontainers-go/exec"
 	tcwait "github.com/testcontainers/testcontainers-go/wait"
+
+	chrec "go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
 )
 
 const (
@@ -41,15 +43,18 @@ var tables = []string{
 	"deployments",
 	"issues",
 	"jobs",
+	"logs",
 	"mergerequest_noteevents",
 	"mergerequests",
 	"metrics",
+	"metrics_histograms",
 	"pipelines",
 	"projects",
 	"sections",
 	"testcases",
 	"testreports",
 	"testsuites",
+	"timeseries",
 	"traces",
 }
 
@@ -289,7 +294,7 @@ func (fsys *osPathFS) Open(name string) (fs.File, error) {
 }
 
 func createMigration(fsys fs.FS, path string, host string, port string, database string) (*migrate.Migrate, error) {
-	drv, err := iofs.New(fsys, path)
+	drv, err := iofs.New(chrec.NewSchemaTemplateFS(fsys, chrec.SchemaOptions{}), path)
 	if err != nil {
 		return nil, fmt.Errorf("create source driver: %w", err)
 	}
@@ -0,0 +1,184 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slices"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	filePrefix string = "capture-"
+	fileSuffix string = ".binpb"
+
+	// sorts lexically in chronological order
+	fileTimeLayout string = "20060102T150405.000000000Z"
+)
+
+type Options struct {
+	// The directory to write capture files to
+	Dir string
+	// The size in bytes at which files are rotated
+	MaxFileSize int64
+	// The number of files to keep, older files are removed. Zero keeps all.
+	MaxFiles int
+	// The fraction of requests to capture
+	SampleRate float64
+}
+
+// Capturer writes requests to rotating capture files in a directory, so that
+// they can be replayed later on.
+type Capturer struct {
+	opts    Options
+	metrics *metrics
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func New(opts Options) (*Capturer, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("capture directory is required")
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Capturer{
+		opts:    opts,
+		metrics: newMetrics(),
+	}, nil
+}
+
+func (c *Capturer) MetricsCollector() prometheus.Collector {
+	return c.metrics
+}
+
+// Capture writes the request of the method to the current capture file, if
+// sampled. Failures are only logged so as not to affect the request. It's a
+// no-op on a nil capturer.
+func (c *Capturer) Capture(ctx context.Context, method string, req proto.Message) {
+	if c == nil || (c.opts.SampleRate < 1 && rand.Float64() >= c.opts.SampleRate) {
+		return
+	}
+
+	data, err := proto.Marshal(req)
+	if err == nil {
+		err = c.write(Record{
+			Method:    method,
+			Peer:      peerAddr(ctx),
+			Timestamp: time.Now().UTC(),
+			Data:      data,
+		})
+	}
+	if err != nil {
+		slog.Warn("Failed to capture request", "method", method, "error", err)
+		c.metrics.observe(method, resultFailed)
+		return
+	}
+	c.metrics.observe(method, resultCaptured)
+}
+
+func (c *Capturer) write(r Record) error {
+	data := appendRecord(nil, r)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil || (c.size > 0 && c.size+int64(len(data)) > c.opts.MaxFileSize) {
+		if err := c.rotate(r.Timestamp); err != nil {
+			return fmt.Errorf("rotate file: %w", err)
+		}
+	}
+
+	n, err := c.file.Write(data)
+	c.size += int64(n)
+	return err
+}
+
+// rotate closes the current file, opens a new one and removes the oldest
+// files exceeding the maximum number of files.
+func (c *Capturer) rotate(t time.Time) error {
+	if err := c.closeFile(); err != nil {
+		return err
+	}
+
+	name := filepath.Join(c.opts.Dir, filePrefix+t.UTC().Format(fileTimeLayout)+fileSuffix)
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	c.file = f
+	c.size = 0
+
+	if c.opts.MaxFiles <= 0 {
+		return nil
+	}
+	files, err := Files(c.opts.Dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > c.opts.MaxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+func (c *Capturer) closeFile() error {
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// Close closes the current capture file.
+func (c *Capturer) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeFile()
+}
+
+// Files returns the paths of the capture files in the directory, oldest
+// first.
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasPrefix(e.Name(), filePrefix) && strings.HasSuffix(e.Name(), fileSuffix) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
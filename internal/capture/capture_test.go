@@ -0,0 +1,166 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func readFile(t *testing.T, path string) []Record {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []Record
+	r := NewReader(f)
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	want := Record{
+		Method:    "RecordPipelines",
+		Peer:      "10.0.0.1:43210",
+		Timestamp: time.Date(2024, 6, 1, 12, 30, 0, 123456789, time.UTC),
+		Data:      []byte{0x0a, 0x01, 0x61},
+	}
+
+	r := NewReader(bytes.NewReader(appendRecord(nil, want)))
+	got, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != want.Method || got.Peer != want.Peer || !got.Timestamp.Equal(want.Timestamp) || !bytes.Equal(got.Data, want.Data) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+func TestReader_Truncated(t *testing.T) {
+	data := appendRecord(nil, Record{Method: "RecordJobs", Data: []byte("data")})
+	if _, err := NewReader(bytes.NewReader(data[:len(data)-1])).Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected unexpected EOF, got %v", err)
+	}
+}
+
+func TestCapturer(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(Options{Dir: dir, MaxFileSize: 1 << 20, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 43210}})
+	c.Capture(ctx, "RecordPipelines", wrapperspb.String("a"))
+	c.Capture(context.Background(), "RecordJobs", wrapperspb.String("b"))
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 file, got %v", files)
+	}
+
+	records := readFile(t, files[0])
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0].Method != "RecordPipelines" || records[0].Peer != "10.0.0.1:43210" {
+		t.Errorf("Unexpected metadata: %+v", records[0])
+	}
+	if records[1].Method != "RecordJobs" || records[1].Peer != "" {
+		t.Errorf("Unexpected metadata: %+v", records[1])
+	}
+
+	var v wrapperspb.StringValue
+	if err := proto.Unmarshal(records[1].Data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetValue() != "b" {
+		t.Errorf("Expected request b, got %q", v.GetValue())
+	}
+}
+
+func TestCapturer_Rotate(t *testing.T) {
+	dir := t.TempDir()
+	// rotates after each record
+	c, err := New(Options{Dir: dir, MaxFileSize: 1, MaxFiles: 2, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, v := range []string{"a", "b", "c"} {
+		c.Capture(context.Background(), "RecordJobs", wrapperspb.String(v))
+	}
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %v", files)
+	}
+
+	var values []string
+	for _, f := range files {
+		for _, rec := range readFile(t, f) {
+			var v wrapperspb.StringValue
+			if err := proto.Unmarshal(rec.Data, &v); err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, v.GetValue())
+		}
+	}
+	if len(values) != 2 || values[0] != "b" || values[1] != "c" {
+		t.Errorf("Expected the latest records b and c, got %v", values)
+	}
+}
+
+func TestCapturer_Sampling(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(Options{Dir: dir, MaxFileSize: 1 << 20, SampleRate: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Capture(context.Background(), "RecordJobs", wrapperspb.String("a"))
+
+	if files, _ := Files(dir); len(files) != 0 {
+		t.Errorf("Expected no files, got %v", files)
+	}
+}
+
+func TestCapturer_Nil(t *testing.T) {
+	var c *Capturer
+	c.Capture(context.Background(), "RecordJobs", wrapperspb.String("a"))
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+}
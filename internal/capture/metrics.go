@@ -0,0 +1,38 @@
+package capture
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/promutil"
+)
+
+const (
+	resultCaptured string = "captured"
+	resultFailed   string = "failed"
+)
+
+type metrics struct {
+	promutil.Collectors
+
+	requests *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "capture",
+				Name:      "requests_total",
+				Help:      "Total number of sampled requests by method and result.",
+			},
+			[]string{"method", "result"},
+		),
+	}
+	m.Collectors = promutil.Collectors{m.requests}
+	return m
+}
+
+func (m *metrics) observe(method string, result string) {
+	m.requests.WithLabelValues(method, result).Inc()
+}
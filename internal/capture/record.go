@@ -0,0 +1,169 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maximum size of a single captured record
+const maxRecordSize int = 64 << 20
+
+// Record is a captured request along with its metadata.
+//
+// Capture files hold records encoded as the following message, each prefixed
+// with its size as varint:
+//
+//	message Record {
+//	  string method = 1;
+//	  string peer = 2;
+//	  google.protobuf.Timestamp timestamp = 3;
+//	  bytes request = 4;
+//	}
+type Record struct {
+	// The name of the method, e.g. `RecordPipelines`
+	Method string
+	// The address of the client that sent the request, if known
+	Peer string
+	// The time the request was received
+	Timestamp time.Time
+	// The binary protobuf encoded request
+	Data []byte
+}
+
+const (
+	fieldMethod    protowire.Number = 1
+	fieldPeer      protowire.Number = 2
+	fieldTimestamp protowire.Number = 3
+	fieldRequest   protowire.Number = 4
+
+	fieldSeconds protowire.Number = 1
+	fieldNanos   protowire.Number = 2
+)
+
+// appendRecord appends the size delimited encoding of the record to b.
+func appendRecord(b []byte, r Record) []byte {
+	var ts []byte
+	ts = protowire.AppendTag(ts, fieldSeconds, protowire.VarintType)
+	ts = protowire.AppendVarint(ts, uint64(r.Timestamp.Unix()))
+	ts = protowire.AppendTag(ts, fieldNanos, protowire.VarintType)
+	ts = protowire.AppendVarint(ts, uint64(r.Timestamp.Nanosecond()))
+
+	var m []byte
+	m = protowire.AppendTag(m, fieldMethod, protowire.BytesType)
+	m = protowire.AppendString(m, r.Method)
+	if r.Peer != "" {
+		m = protowire.AppendTag(m, fieldPeer, protowire.BytesType)
+		m = protowire.AppendString(m, r.Peer)
+	}
+	m = protowire.AppendTag(m, fieldTimestamp, protowire.BytesType)
+	m = protowire.AppendBytes(m, ts)
+	m = protowire.AppendTag(m, fieldRequest, protowire.BytesType)
+	m = protowire.AppendBytes(m, r.Data)
+
+	return protowire.AppendBytes(b, m)
+}
+
+func unmarshalRecord(b []byte) (Record, error) {
+	var (
+		r       Record
+		seconds int64
+		nanos   int64
+	)
+
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.BytesType {
+			return protowire.ConsumeFieldValue(num, typ, b), nil
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+		switch num {
+		case fieldMethod:
+			r.Method = string(v)
+		case fieldPeer:
+			r.Peer = string(v)
+		case fieldTimestamp:
+			err := consumeFields(v, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				if typ != protowire.VarintType {
+					return protowire.ConsumeFieldValue(num, typ, b), nil
+				}
+				v, n := protowire.ConsumeVarint(b)
+				switch num {
+				case fieldSeconds:
+					seconds = int64(v)
+				case fieldNanos:
+					nanos = int64(int32(v))
+				}
+				return n, nil
+			})
+			if err != nil {
+				return 0, fmt.Errorf("timestamp: %w", err)
+			}
+		case fieldRequest:
+			r.Data = v
+		}
+		return n, nil
+	})
+	if err != nil {
+		return Record{}, err
+	}
+
+	r.Timestamp = time.Unix(seconds, nanos).UTC()
+	return r, nil
+}
+
+// consumeFields calls consume for each field of the encoded message, which
+// returns the size of the field's value or a negative protowire error code.
+func consumeFields(b []byte, consume func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		n, err := consume(num, typ, b)
+		if err != nil {
+			return err
+		} else if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// Reader reads the records of a capture file.
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, 1<<20)}
+}
+
+// Next returns the next record, or io.EOF at the end of the file.
+func (r *Reader) Next() (Record, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return Record{}, err
+	} else if size > uint64(maxRecordSize) {
+		return Record{}, fmt.Errorf("record exceeds %d bytes", maxRecordSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Record{}, err
+	}
+	return unmarshalRecord(data)
+}
@@ -3,6 +3,7 @@ package clickhouse
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"golang.org/x/sync/semaphore"
 
@@ -15,6 +16,9 @@ type Client struct {
 	dbName string
 
 	sem *semaphore.Weighted
+
+	// the schema batches are validated against in dry-run mode
+	schema SchemaSnapshot
 }
 
 type ClientConfig struct {
@@ -23,6 +27,9 @@ type ClientConfig struct {
 	Database string
 	User     string
 	Password string
+
+	// Port of the HTTP interface, used for exports
+	HTTPPort string
 }
 
 func NewClient(conn driver.Conn, database string) *Client {
@@ -94,10 +101,14 @@ func (c *Client) Ping(ctx context.Context) error {
 }
 
 func WithParameters(ctx context.Context, params map[string]string) context.Context {
+	ctx = context.WithValue(ctx, parametersKey{}, params)
 	return clickhouse.Context(ctx, clickhouse.WithParameters(params))
 }
 
 func (c *Client) Exec(ctx context.Context, query string, args ...any) error {
+	if c.DryRun() {
+		return ErrDryRun
+	}
 	if err := c.acquire(ctx, 1); err != nil {
 		return err
 	}
@@ -113,7 +124,35 @@ func (c *Client) Select(ctx context.Context, dest any, query string, args ...any
 	return c.conn.Select(ctx, dest, query, args...)
 }
 
+// Query runs the query and returns its rows, which are streamed from the
+// server and must be closed.
+func (c *Client) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	if err := c.acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	rows, err := c.conn.Query(ctx, query, args...)
+	if err != nil {
+		c.release(1)
+		return nil, err
+	}
+	return &releasingRows{Rows: rows, release: sync.OnceFunc(func() { c.release(1) })}, nil
+}
+
+// releasingRows releases the acquired query slot once closed.
+type releasingRows struct {
+	driver.Rows
+	release func()
+}
+
+func (r *releasingRows) Close() error {
+	defer r.release()
+	return r.Rows.Close()
+}
+
 func (c *Client) PrepareBatch(ctx context.Context, query string) (driver.Batch, error) {
+	if c.DryRun() {
+		return c.prepareDryRunBatch(ctx)
+	}
 	if err := c.acquire(ctx, 1); err != nil {
 		return nil, err
 	}
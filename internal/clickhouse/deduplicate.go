@@ -12,6 +12,7 @@ import (
 type DeduplicateTableOptions struct {
 	Database    string
 	Table       string
+	PartitionId string
 	Final       *bool
 	By          []string
 	Except      []string
@@ -45,6 +46,11 @@ func PrepareDeduplicateQuery(opt DeduplicateTableOptions) (string, map[string]st
 	params["database"] = dbName
 	params["table"] = opt.Table
 
+	// PARTITION
+	if opt.PartitionId != "" {
+		query += fmt.Sprintf(" PARTITION ID '%s'", opt.PartitionId)
+	}
+
 	// FINAL
 	if opt.Final == nil || *opt.Final {
 		query += " FINAL"
@@ -79,6 +85,95 @@ func PrepareDeduplicateQuery(opt DeduplicateTableOptions) (string, map[string]st
 	return query, params
 }
 
+type DuplicateReport struct {
+	Table      string
+	Rows       uint64
+	Keys       uint64
+	Partitions []PartitionDuplicates
+	// Examples of duplicate keys along with their number of occurrences
+	Examples []DuplicateKey
+}
+
+type DuplicateKey struct {
+	Key   string `ch:"key"`
+	Count uint64 `ch:"count"`
+}
+
+// Duplicates returns the number of rows that would be removed by deduplication.
+func (r DuplicateReport) Duplicates() uint64 {
+	var n uint64
+	for _, p := range r.Partitions {
+		n += p.Duplicates()
+	}
+	return n
+}
+
+// ReportTableDuplicates analyzes what deduplicating the table with the given
+// options would do, to the partition if set, without modifying it.
+func ReportTableDuplicates(ctx context.Context, opt DeduplicateTableOptions, examples int, ch *Client) (DuplicateReport, error) {
+	report := DuplicateReport{
+		Table: opt.Table,
+	}
+
+	if err := validateDeduplicateTableOptions(ctx, opt, ch); err != nil {
+		return report, fmt.Errorf("error validating deduplication options: %w", err)
+	}
+
+	partitions, err := selectPartitions(ch, ctx, deduplicateDatabase(opt), opt.Table)
+	if err != nil {
+		return report, fmt.Errorf("error selecting partitions: %w", err)
+	}
+
+	for _, p := range partitions {
+		if opt.PartitionId != "" && p.PartitionId != opt.PartitionId {
+			continue
+		}
+
+		d, err := estimatePartitionDuplicates(ctx, opt, p.PartitionId, 0, ch)
+		if err != nil {
+			return report, fmt.Errorf("error counting duplicates of partition %s: %w", p.PartitionId, err)
+		}
+		d.Parts = p.Parts
+
+		report.Rows += d.Rows
+		report.Keys += d.Keys
+		report.Partitions = append(report.Partitions, d)
+	}
+
+	if examples > 0 && report.Duplicates() > 0 {
+		query, params := PrepareDuplicateKeysQuery(opt, examples)
+		if err := ch.Select(WithParameters(ctx, params), &report.Examples, query); err != nil {
+			return report, fmt.Errorf("error selecting duplicate keys: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// PrepareDuplicateKeysQuery returns a query that selects the most frequent
+// duplicate keys of a table, or of its partition if set.
+func PrepareDuplicateKeysQuery(opt DeduplicateTableOptions, limit int) (string, map[string]string) {
+	params := map[string]string{
+		"database": deduplicateDatabase(opt),
+		"table":    opt.Table,
+	}
+
+	var where string
+	if opt.PartitionId != "" {
+		where = " WHERE _partition_id = {partition:String}"
+		params["partition"] = opt.PartitionId
+	}
+
+	query := fmt.Sprintf(""+
+		"SELECT toString(tuple(*)) AS key, count() AS count"+
+		" FROM (SELECT %s FROM {database:Identifier}.{table:Identifier}%s)"+
+		" GROUP BY key HAVING count > 1 ORDER BY count DESC LIMIT %d",
+		deduplicateKey(opt), where, limit,
+	)
+
+	return query, params
+}
+
 func validateDeduplicateTableOptions(ctx context.Context, opt DeduplicateTableOptions, ch *Client) error {
 	var dbName string = opt.Database
 	if dbName == "" {
@@ -95,6 +190,13 @@ func validateDeduplicateTableOptions(ctx context.Context, opt DeduplicateTableOp
 		return err
 	}
 
+	// validate partition id
+	if opt.PartitionId != "" {
+		if err := matchPartitionId(opt.PartitionId); err != nil {
+			return err
+		}
+	}
+
 	// validate column identifiers
 	cols, err := getColumnNames(ctx, dbName, opt.Table, ch)
 	if err != nil {
@@ -121,6 +223,17 @@ func matchIdentifier(s string) error {
 	return nil
 }
 
+func matchPartitionId(s string) error {
+	pattern := `^[0-9a-zA-Z_-]+$`
+	matched, err := regexp.MatchString(pattern, s)
+	if err != nil {
+		return err
+	} else if !matched {
+		return fmt.Errorf("invalid partition id: `%s`", s)
+	}
+	return nil
+}
+
 func getColumnNames(ctx context.Context, database string, table string, ch *Client) ([]string, error) {
 	var columnNames []string
 
@@ -231,3 +231,62 @@ func TestPrepareDeduplicateQuery_WithByAndExcept(t *testing.T) {
 	checkQuery(t, expectedQuery, query)
 	checkParams(t, expectedParams, params)
 }
+
+func TestPrepareDuplicateKeysQuery(t *testing.T) {
+	opt := DeduplicateTableOptions{
+		Database: "gitlab_ci",
+		Table:    "pipelines",
+		Except:   []string{"updated_at"},
+	}
+
+	expectedQuery := "" +
+		"SELECT toString(tuple(*)) AS key, count() AS count" +
+		" FROM (SELECT * EXCEPT (updated_at) FROM {database:Identifier}.{table:Identifier})" +
+		" GROUP BY key HAVING count > 1 ORDER BY count DESC LIMIT 5"
+	expectedParams := map[string]string{
+		"database": "gitlab_ci",
+		"table":    "pipelines",
+	}
+
+	query, params := PrepareDuplicateKeysQuery(opt, 5)
+
+	checkQuery(t, expectedQuery, query)
+	checkParams(t, expectedParams, params)
+}
+
+func TestPrepareDuplicateKeysQuery_Partition(t *testing.T) {
+	opt := DeduplicateTableOptions{
+		Database:    "gitlab_ci",
+		Table:       "pipelines",
+		PartitionId: "202401",
+	}
+
+	expectedQuery := "" +
+		"SELECT toString(tuple(*)) AS key, count() AS count" +
+		" FROM (SELECT * FROM {database:Identifier}.{table:Identifier} WHERE _partition_id = {partition:String})" +
+		" GROUP BY key HAVING count > 1 ORDER BY count DESC LIMIT 5"
+	expectedParams := map[string]string{
+		"database":  "gitlab_ci",
+		"table":     "pipelines",
+		"partition": "202401",
+	}
+
+	query, params := PrepareDuplicateKeysQuery(opt, 5)
+
+	checkQuery(t, expectedQuery, query)
+	checkParams(t, expectedParams, params)
+}
+
+func TestDuplicateReport_Duplicates(t *testing.T) {
+	report := DuplicateReport{
+		Partitions: []PartitionDuplicates{
+			{PartitionId: "202401", Rows: 100, Keys: 90},
+			{PartitionId: "202402", Rows: 50, Keys: 50},
+			{PartitionId: "202403", Rows: 20, Keys: 15},
+		},
+	}
+
+	if got := report.Duplicates(); got != 15 {
+		t.Errorf("Expected 15 duplicates, got %d", got)
+	}
+}
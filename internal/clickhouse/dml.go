@@ -2,14 +2,23 @@ package clickhouse
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"log/slog"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
 	otlp_comonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	otlp_logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	otlp_metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
 	otlp_tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"golang.org/x/exp/slices"
 
 	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
 	"google.golang.org/protobuf/types/known/durationpb"
@@ -25,20 +34,59 @@ const (
 	DeploymentsTable            string = "deployments"
 	IssuesTable                 string = "issues"
 	JobsTable                   string = "jobs"
+	LogsTable                   string = "logs"
 	MergeRequestNoteEventsTable string = "mergerequest_noteevents"
 	MergeRequestsTable          string = "mergerequests"
 	MetricsTable                string = "metrics"
+	MetricHistogramsTable       string = "metrics_histograms"
 	PipelinesTable              string = "pipelines"
 	ProjectsTable               string = "projects"
 	SectionsTable               string = "sections"
 	TestCasesTable              string = "testcases"
 	TestReportsTable            string = "testreports"
 	TestSuitesTable             string = "testsuites"
+	TimeSeriesTable             string = "timeseries"
 	TraceSpansTable             string = "traces"
 )
 
-func convertTimestamp(ts *timestamppb.Timestamp) float64 {
-	return float64(ts.GetSeconds()) + float64(ts.GetNanos())*1.0e-09
+// Tables contains the names of all tables that data is recorded to.
+var Tables = []string{
+	BridgesTable,
+	CoverageReportsTable,
+	CoveragePackagesTable,
+	CoverageClassesTable,
+	CoverageMethodsTable,
+	DeploymentsTable,
+	IssuesTable,
+	JobsTable,
+	LogsTable,
+	MergeRequestNoteEventsTable,
+	MergeRequestsTable,
+	MetricsTable,
+	MetricHistogramsTable,
+	PipelinesTable,
+	ProjectsTable,
+	SectionsTable,
+	TestCasesTable,
+	TestReportsTable,
+	TestSuitesTable,
+	TimeSeriesTable,
+	TraceSpansTable,
+}
+
+// convertTimestamp returns the time of the timestamp, or the epoch if absent.
+func convertTimestamp(ts *timestamppb.Timestamp) time.Time {
+	return time.Unix(ts.GetSeconds(), int64(ts.GetNanos())).UTC()
+}
+
+// convertNullableTimestamp returns nil for absent or zero timestamps, which
+// are stored as NULL.
+func convertNullableTimestamp(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil || (ts.GetSeconds() == 0 && ts.GetNanos() == 0) {
+		return nil
+	}
+	t := convertTimestamp(ts)
+	return &t
 }
 
 func convertDuration(d *durationpb.Duration) float64 {
@@ -63,45 +111,7 @@ func InsertPipelines(c *Client, ctx context.Context, pipelines []*typespb.Pipeli
 	}
 
 	for _, p := range pipelines {
-		err = batch.AppendStruct(&Pipeline{
-			Id:        p.Id,
-			Iid:       p.Iid,
-			ProjectId: p.GetProject().GetId(),
-
-			Name:          p.Name,
-			Ref:           p.Ref,
-			RefPath:       p.RefPath,
-			Sha:           p.Sha,
-			Source:        p.Source,
-			Status:        p.Status,
-			FailureReason: p.FailureReason,
-
-			CommittedAt: convertTimestamp(p.Timestamps.GetCommittedAt()),
-			CreatedAt:   convertTimestamp(p.Timestamps.GetCreatedAt()),
-			UpdatedAt:   convertTimestamp(p.Timestamps.GetUpdatedAt()),
-			StartedAt:   convertTimestamp(p.Timestamps.GetStartedAt()),
-			FinishedAt:  convertTimestamp(p.Timestamps.GetFinishedAt()),
-
-			QueuedDuration: convertDuration(p.QueuedDuration),
-			Duration:       convertDuration(p.Duration),
-
-			Coverage: p.Coverage,
-
-			Warnings:   p.Warnings,
-			YamlErrors: p.YamlErrors,
-
-			Child:                     p.Child,
-			UpstreamPipelineId:        p.UpstreamPipeline.GetId(),
-			UpstreamPipelineIid:       p.UpstreamPipeline.GetIid(),
-			UpstreamPipelineProjectId: p.UpstreamPipeline.GetProject().GetId(),
-			DownstreamPipelines:       convertPipelineReferences(p.DownstreamPipelines),
-
-			MergeRequestId:        p.MergeRequest.GetId(),
-			MergeRequestIid:       p.MergeRequest.GetIid(),
-			MergeRequestProjectId: p.MergeRequest.GetProject().GetId(),
-
-			UserId: p.User.GetId(),
-		})
+		err = batch.AppendStruct(convertPipeline(p))
 		if err != nil {
 			return 0, fmt.Errorf("append batch: %w", err)
 		}
@@ -146,7 +156,7 @@ func InsertIssues(c *Client, ctx context.Context, issues []*typespb.Issue) (int,
 
 			CreatedAt: convertTimestamp(issue.Timestamps.GetCreatedAt()),
 			UpdatedAt: convertTimestamp(issue.Timestamps.GetUpdatedAt()),
-			ClosedAt:  convertTimestamp(issue.Timestamps.GetClosedAt()),
+			ClosedAt:  convertNullableTimestamp(issue.Timestamps.GetClosedAt()),
 
 			Title:  issue.Title,
 			Labels: issue.Labels,
@@ -191,66 +201,7 @@ func InsertJobs(c *Client, ctx context.Context, jobs []*typespb.Job) (int, error
 			continue
 		}
 
-		var jobKind string
-		switch j.Kind {
-		case typespb.JobKind_JOBKIND_UNSPECIFIED:
-			jobKind = "unspecified"
-		case typespb.JobKind_JOBKIND_BUILD:
-			jobKind = "build"
-		case typespb.JobKind_JOBKIND_BRIDGE:
-			jobKind = "bridge"
-		default:
-			jobKind = "unknown"
-		}
-
-		err = batch.AppendStruct(&Job{
-			Id:         j.Id,
-			PipelineId: j.Pipeline.GetId(),
-			ProjectId:  j.Pipeline.GetProject().GetId(),
-
-			Name:          j.Name,
-			Ref:           j.Ref,
-			RefPath:       j.RefPath,
-			Status:        j.Status,
-			FailureReason: j.FailureReason,
-			ExitCode:      j.ExitCode,
-
-			CreatedAt:  convertTimestamp(j.Timestamps.GetCreatedAt()),
-			QueuedAt:   convertTimestamp(j.Timestamps.GetQueuedAt()),
-			StartedAt:  convertTimestamp(j.Timestamps.GetStartedAt()),
-			FinishedAt: convertTimestamp(j.Timestamps.GetFinishedAt()),
-			ErasedAt:   convertTimestamp(j.Timestamps.GetErasedAt()),
-
-			QueuedDuration: convertDuration(j.QueuedDuration),
-			Duration:       convertDuration(j.Duration),
-
-			Coverage: j.Coverage,
-
-			Stage:      j.Stage,
-			TagList:    j.Tags,
-			Properties: convertJobProperties(j.Properties),
-
-			AllowFailure: j.AllowFailure,
-			Manual:       j.Manual,
-			Retried:      j.Retried,
-			Retryable:    j.Retryable,
-
-			Kind:                        jobKind,
-			DownstreamPipelineId:        j.DownstreamPipeline.GetId(),
-			DownstreamPipelineIid:       j.DownstreamPipeline.GetIid(),
-			DownstreamPipelineProjectId: j.DownstreamPipeline.GetProject().GetId(),
-
-			RunnerId: j.Runner.GetId(),
-
-			// deprecated
-			Pipeline: []any{
-				j.Pipeline.GetId(),
-				j.Pipeline.GetProject().GetId(),
-				j.Ref,
-				"", // sha
-				"", // status
-			},
-		})
+		err = batch.AppendStruct(convertJob(j))
 		if err != nil {
 			errs = errors.Join(errs, fmt.Errorf("append job %d to batch: %w", j.Id, err))
 		}
@@ -285,9 +236,9 @@ func InsertBridges(c *Client, ctx context.Context, bridges []*typespb.Job) (int,
 			b.Coverage,
 			b.AllowFailure,
 			convertTimestamp(b.GetTimestamps().GetCreatedAt()),
-			convertTimestamp(b.GetTimestamps().GetStartedAt()),
-			convertTimestamp(b.GetTimestamps().GetFinishedAt()),
-			convertTimestamp(b.GetTimestamps().GetErasedAt()),
+			convertNullableTimestamp(b.GetTimestamps().GetStartedAt()),
+			convertNullableTimestamp(b.GetTimestamps().GetFinishedAt()),
+			convertNullableTimestamp(b.GetTimestamps().GetErasedAt()),
 			convertDuration(b.Duration),
 			convertDuration(b.QueuedDuration),
 			b.Id,
@@ -353,33 +304,7 @@ func InsertSections(c *Client, ctx context.Context, sections []*typespb.Section)
 	}
 
 	for _, s := range sections {
-		err = batch.AppendStruct(&Section{
-			Id:         s.Id,
-			JobId:      s.Job.GetId(),
-			PipelineId: s.Job.GetPipeline().GetId(),
-			ProjectId:  s.Job.GetPipeline().GetProject().GetId(),
-
-			Name: s.Name,
-
-			StartedAt:  convertTimestamp(s.StartedAt),
-			FinishedAt: convertTimestamp(s.FinishedAt),
-
-			Duration: convertDuration(s.Duration),
-
-			// deprecated
-			Job: []any{
-				s.Job.GetId(),
-				s.Job.GetName(),
-				"", // status
-			},
-			Pipeline: []any{
-				s.Job.GetPipeline().GetId(),
-				s.Job.GetPipeline().GetProject().GetId(),
-				"", // ref
-				"", // sha
-				"", // status
-			},
-		})
+		err = batch.AppendStruct(convertSection(s))
 		if err != nil {
 			return 0, fmt.Errorf("append batch: %w", err)
 		}
@@ -562,8 +487,8 @@ func InsertMergeRequests(c *Client, ctx context.Context, mrs []*typespb.MergeReq
 
 			CreatedAt: convertTimestamp(mr.Timestamps.GetCreatedAt()),
 			UpdatedAt: convertTimestamp(mr.Timestamps.GetUpdatedAt()),
-			MergedAt:  convertTimestamp(mr.Timestamps.GetMergedAt()),
-			ClosedAt:  convertTimestamp(mr.Timestamps.GetClosedAt()),
+			MergedAt:  convertNullableTimestamp(mr.Timestamps.GetMergedAt()),
+			ClosedAt:  convertNullableTimestamp(mr.Timestamps.GetClosedAt()),
 
 			Name:   mr.Name,
 			Title:  mr.Title,
@@ -672,7 +597,7 @@ func InsertMergeRequestNoteEvents(c *Client, ctx context.Context, mres []*typesp
 
 			CreatedAt:  convertTimestamp(mre.CreatedAt),
 			UpdatedAt:  convertTimestamp(mre.UpdatedAt),
-			ResolvedAt: convertTimestamp(mre.ResolvedAt),
+			ResolvedAt: convertNullableTimestamp(mre.ResolvedAt),
 
 			Type:     mre.Type,
 			System:   mre.System,
@@ -744,6 +669,233 @@ func InsertMetrics(c *Client, ctx context.Context, metrics []*typespb.Metric) (i
 	return n, nil
 }
 
+// InsertOTLPMetrics inserts the data points of OTLP metrics. Gauges and sums
+// are stored as metrics, summaries and exponential histograms as `_count`,
+// `_sum` and quantile metrics, and histograms in a separate table.
+func InsertOTLPMetrics(c *Client, ctx context.Context, data []*otlp_metricspb.MetricsData) (int, error) {
+	metrics, histograms := convertOTLPMetrics(data)
+
+	var n int
+	if len(metrics) > 0 {
+		m, err := insertMetricRows(c, ctx, metrics)
+		if err != nil {
+			return m, err
+		}
+		n += m
+	}
+	if len(histograms) > 0 {
+		m, err := insertMetricHistograms(c, ctx, histograms)
+		if err != nil {
+			return m, err
+		}
+		n += m
+	}
+
+	return n, nil
+}
+
+func insertMetricRows(c *Client, ctx context.Context, metrics []*Metric) (int, error) {
+	const query string = `INSERT INTO {db:Identifier}.{table:Identifier} SETTINGS async_insert=1`
+	var params = map[string]string{
+		"db":    c.dbName,
+		"table": MetricsTable + "_in",
+	}
+
+	ctx = WithParameters(ctx, params)
+
+	batch, err := c.PrepareBatch(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("prepare batch: %w", err)
+	}
+
+	for _, m := range metrics {
+		if err := batch.AppendStruct(m); err != nil {
+			return 0, fmt.Errorf("append batch: %w", err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return -1, fmt.Errorf("send batch: %w", err)
+	}
+
+	n := batch.Rows()
+	slog.Debug("Recorded metrics", "received", len(metrics), "inserted", n)
+
+	return n, nil
+}
+
+func insertMetricHistograms(c *Client, ctx context.Context, histograms []*MetricHistogram) (int, error) {
+	const query string = `INSERT INTO {db:Identifier}.{table:Identifier} SETTINGS async_insert=1`
+	var params = map[string]string{
+		"db":    c.dbName,
+		"table": MetricHistogramsTable,
+	}
+
+	ctx = WithParameters(ctx, params)
+
+	batch, err := c.PrepareBatch(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("prepare batch: %w", err)
+	}
+
+	for _, h := range histograms {
+		if err := batch.AppendStruct(h); err != nil {
+			return 0, fmt.Errorf("append batch: %w", err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return -1, fmt.Errorf("send batch: %w", err)
+	}
+
+	n := batch.Rows()
+	slog.Debug("Recorded metric histograms", "received", len(histograms), "inserted", n)
+
+	return n, nil
+}
+
+func convertOTLPMetrics(data []*otlp_metricspb.MetricsData) ([]*Metric, []*MetricHistogram) {
+	var (
+		metrics    []*Metric
+		histograms []*MetricHistogram
+	)
+
+	for _, d := range data {
+		for _, resourceMetrics := range d.GetResourceMetrics() {
+			resource := resourceMetrics.GetResource().GetAttributes()
+			for _, scopeMetrics := range resourceMetrics.GetScopeMetrics() {
+				for _, m := range scopeMetrics.GetMetrics() {
+					name := m.GetName()
+					switch data := m.GetData().(type) {
+					case *otlp_metricspb.Metric_Gauge:
+						for _, p := range data.Gauge.GetDataPoints() {
+							metrics = append(metrics, newMetric(name, convertAttributes(p.GetAttributes()), numberValue(p), p.GetTimeUnixNano(), p.GetAttributes(), resource))
+						}
+					case *otlp_metricspb.Metric_Sum:
+						for _, p := range data.Sum.GetDataPoints() {
+							metrics = append(metrics, newMetric(name, convertAttributes(p.GetAttributes()), numberValue(p), p.GetTimeUnixNano(), p.GetAttributes(), resource))
+						}
+					case *otlp_metricspb.Metric_Summary:
+						for _, p := range data.Summary.GetDataPoints() {
+							labels := convertAttributes(p.GetAttributes())
+							ts := p.GetTimeUnixNano()
+							metrics = append(metrics,
+								newMetric(name+"_count", labels, float64(p.GetCount()), ts, p.GetAttributes(), resource),
+								newMetric(name+"_sum", labels, p.GetSum(), ts, p.GetAttributes(), resource),
+							)
+							for _, q := range p.GetQuantileValues() {
+								qlabels := make(map[string]string, len(labels)+1)
+								for k, v := range labels {
+									qlabels[k] = v
+								}
+								qlabels["quantile"] = strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)
+								metrics = append(metrics, newMetric(name, qlabels, q.GetValue(), ts, p.GetAttributes(), resource))
+							}
+						}
+					case *otlp_metricspb.Metric_ExponentialHistogram:
+						for _, p := range data.ExponentialHistogram.GetDataPoints() {
+							labels := convertAttributes(p.GetAttributes())
+							ts := p.GetTimeUnixNano()
+							metrics = append(metrics,
+								newMetric(name+"_count", labels, float64(p.GetCount()), ts, p.GetAttributes(), resource),
+								newMetric(name+"_sum", labels, p.GetSum(), ts, p.GetAttributes(), resource),
+							)
+						}
+					case *otlp_metricspb.Metric_Histogram:
+						for _, p := range data.Histogram.GetDataPoints() {
+							histograms = append(histograms, newMetricHistogram(name, p, resource))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return metrics, histograms
+}
+
+func numberValue(p *otlp_metricspb.NumberDataPoint) float64 {
+	switch v := p.GetValue().(type) {
+	case *otlp_metricspb.NumberDataPoint_AsDouble:
+		return v.AsDouble
+	case *otlp_metricspb.NumberDataPoint_AsInt:
+		return float64(v.AsInt)
+	default:
+		return 0
+	}
+}
+
+func newMetric(name string, labels map[string]string, value float64, ts uint64, attrs ...[]*otlp_comonpb.KeyValue) *Metric {
+	jobId := attributeInt64(JobIdAttribute, attrs...)
+	timestamp := int64(ts / 1e06)
+	hash := metricHash(name, labels, math.Float64bits(value), uint64(timestamp))
+
+	return &Metric{
+		Id:         fmt.Sprintf("%d-%X", jobId, hash),
+		Iid:        int64(hash),
+		JobId:      jobId,
+		PipelineId: attributeInt64(PipelineIdAttribute, attrs...),
+		ProjectId:  attributeInt64(ProjectIdAttribute, attrs...),
+
+		Name:      name,
+		Labels:    labels,
+		Value:     value,
+		Timestamp: timestamp,
+	}
+}
+
+func newMetricHistogram(name string, p *otlp_metricspb.HistogramDataPoint, resource []*otlp_comonpb.KeyValue) *MetricHistogram {
+	attrs := [][]*otlp_comonpb.KeyValue{p.GetAttributes(), resource}
+	labels := convertAttributes(p.GetAttributes())
+	jobId := attributeInt64(JobIdAttribute, attrs...)
+	timestamp := int64(p.GetTimeUnixNano() / 1e06)
+	hash := metricHash(name, labels, p.GetCount(), math.Float64bits(p.GetSum()), uint64(timestamp))
+
+	return &MetricHistogram{
+		Id:         fmt.Sprintf("%d-%X", jobId, hash),
+		Iid:        int64(hash),
+		JobId:      jobId,
+		PipelineId: attributeInt64(PipelineIdAttribute, attrs...),
+		ProjectId:  attributeInt64(ProjectIdAttribute, attrs...),
+
+		Name:   name,
+		Labels: labels,
+
+		Count:          p.GetCount(),
+		Sum:            p.GetSum(),
+		Min:            p.GetMin(),
+		Max:            p.GetMax(),
+		BucketCounts:   p.GetBucketCounts(),
+		ExplicitBounds: p.GetExplicitBounds(),
+
+		Timestamp: timestamp,
+	}
+}
+
+// metricHash returns a hash identifying a metric data point.
+func metricHash(name string, labels map[string]string, values ...uint64) uint64 {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	for _, k := range keys {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(k))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(labels[k]))
+	}
+	var b [8]byte
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(b[:], v)
+		_, _ = h.Write(b[:])
+	}
+	return h.Sum64()
+}
+
 func InsertProjects(c *Client, ctx context.Context, projects []*typespb.Project) (int, error) {
 	const query string = `INSERT INTO {db:Identifier}.{table:Identifier} SETTINGS async_insert=1`
 	var params = map[string]string{
@@ -846,7 +998,7 @@ func InsertCoverageReports(c *Client, ctx context.Context, reports []*typespb.Co
 			Complexity: report.Complexity,
 
 			Version:   report.Version,
-			Timestamp: report.Timestamp.GetSeconds(),
+			Timestamp: convertTimestamp(report.Timestamp),
 
 			SourcePaths: report.SourcePaths,
 		})
@@ -1083,7 +1235,7 @@ func InsertDeployments(c *Client, ctx context.Context, deployments []*typespb.De
 			TriggererName:     deployment.GetTriggerer().GetName(),
 
 			CreatedAt:  convertTimestamp(deployment.Timestamps.GetCreatedAt()),
-			FinishedAt: convertTimestamp(deployment.Timestamps.GetFinishedAt()),
+			FinishedAt: convertNullableTimestamp(deployment.Timestamps.GetFinishedAt()),
 			UpdatedAt:  convertTimestamp(deployment.Timestamps.GetUpdatedAt()),
 
 			Status: deploymentStatus,
@@ -1105,6 +1257,9 @@ func InsertDeployments(c *Client, ctx context.Context, deployments []*typespb.De
 	return n, nil
 }
 
+// InsertTraces inserts the spans of the traces. Numeric attribute values are
+// also inserted into the typed attribute maps, which the columns of promoted
+// attributes (see `PromoteAttribute`) are read from without parsing.
 func InsertTraces(c *Client, ctx context.Context, traces []*typespb.Trace) (int, error) {
 	const query string = `INSERT INTO {db:Identifier}.{table:Identifier} SETTINGS async_insert=1`
 	var params = map[string]string{
@@ -1121,21 +1276,24 @@ func InsertTraces(c *Client, ctx context.Context, traces []*typespb.Trace) (int,
 
 	var spanCount int = 0
 	for _, trace := range traces {
-		for _, resourceSpans := range trace.Data.ResourceSpans {
-			resourceAttrs := convertAttributes(resourceSpans.Resource.Attributes)
+		for _, resourceSpans := range trace.GetData().GetResourceSpans() {
+			resource := resourceSpans.GetResource().GetAttributes()
+			resourceAttrs := convertAttributes(resource)
+			resourceAttrsInt, resourceAttrsFloat := convertNumericAttributes(resource)
 			serviceName := ""
 			if sn, ok := resourceAttrs["service.name"]; ok {
 				serviceName = sn
 			}
 			for _, scopeSpans := range resourceSpans.ScopeSpans {
-				scopeName := scopeSpans.Scope.Name
-				scopeVersion := scopeSpans.Scope.Version
+				scopeName := scopeSpans.GetScope().GetName()
+				scopeVersion := scopeSpans.GetScope().GetVersion()
 				for _, span := range scopeSpans.Spans {
 					spanCount++
 
 					spanAttrs := convertAttributes(span.Attributes)
-					eventTimes, eventNames, eventAttrs := convertEvents(span.Events)
-					linkTraceIDs, linkSpanIDs, linkStates, linkAttrs := convertLinks(span.Links)
+					spanAttrsInt, spanAttrsFloat := convertNumericAttributes(span.Attributes)
+					events := convertEvents(span.Events)
+					links := convertLinks(span.Links)
 
 					err = batch.Append(
 						timeFromUnixNano(int64(span.StartTimeUnixNano)),
@@ -1147,19 +1305,27 @@ func InsertTraces(c *Client, ctx context.Context, traces []*typespb.Trace) (int,
 						span.Kind.String(),
 						serviceName,
 						resourceAttrs,
+						resourceAttrsInt,
+						resourceAttrsFloat,
 						scopeName,
 						scopeVersion,
 						spanAttrs,
+						spanAttrsInt,
+						spanAttrsFloat,
 						int64(span.EndTimeUnixNano-span.StartTimeUnixNano),
-						span.Status.Code.String(),
-						span.Status.Message,
-						eventTimes,
-						eventNames,
-						eventAttrs,
-						linkTraceIDs,
-						linkSpanIDs,
-						linkStates,
-						linkAttrs,
+						span.GetStatus().GetCode().String(),
+						span.GetStatus().GetMessage(),
+						events.Times,
+						events.Names,
+						events.Attrs,
+						events.AttrsInt,
+						events.AttrsFloat,
+						links.TraceIDs,
+						links.SpanIDs,
+						links.States,
+						links.Attrs,
+						links.AttrsInt,
+						links.AttrsFloat,
 					)
 
 					if err != nil {
@@ -1184,6 +1350,93 @@ func InsertTraces(c *Client, ctx context.Context, traces []*typespb.Trace) (int,
 	return n, nil
 }
 
+// Resource or log record attributes that identify the CI entities a log
+// record belongs to.
+const (
+	ProjectIdAttribute  string = "ci.project.id"
+	PipelineIdAttribute string = "ci.pipeline.id"
+	JobIdAttribute      string = "ci.job.id"
+)
+
+func InsertLogs(c *Client, ctx context.Context, logs []*otlp_logspb.LogsData) (int, error) {
+	const query string = `INSERT INTO {db:Identifier}.{table:Identifier} SETTINGS async_insert=1`
+	var params = map[string]string{
+		"db":    c.dbName,
+		"table": LogsTable,
+	}
+
+	ctx = WithParameters(ctx, params)
+
+	batch, err := c.PrepareBatch(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("prepare batch: %w", err)
+	}
+
+	var recordCount int = 0
+	for _, data := range logs {
+		for _, resourceLogs := range data.GetResourceLogs() {
+			resource := resourceLogs.GetResource().GetAttributes()
+			resourceAttrs := convertAttributes(resource)
+			serviceName := resourceAttrs["service.name"]
+
+			for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+				scope := scopeLogs.GetScope()
+				scopeAttrs := convertAttributes(scope.GetAttributes())
+
+				for _, record := range scopeLogs.GetLogRecords() {
+					recordCount++
+
+					ts := record.GetTimeUnixNano()
+					if ts == 0 {
+						ts = record.GetObservedTimeUnixNano()
+					}
+					timestamp := timeFromUnixNano(int64(ts))
+
+					attrs := record.GetAttributes()
+					err = batch.AppendStruct(&Log{
+						Timestamp:          timestamp,
+						TimestampTime:      timestamp.Truncate(time.Second),
+						TraceId:            string(record.GetTraceId()),
+						SpanId:             string(record.GetSpanId()),
+						TraceFlags:         uint8(record.GetFlags()),
+						SeverityText:       record.GetSeverityText(),
+						SeverityNumber:     uint8(record.GetSeverityNumber()),
+						ServiceName:        serviceName,
+						Body:               convertAnyValue(record.GetBody()),
+						ResourceSchemaUrl:  resourceLogs.GetSchemaUrl(),
+						ResourceAttributes: resourceAttrs,
+						ScopeSchemaUrl:     scopeLogs.GetSchemaUrl(),
+						ScopeName:          scope.GetName(),
+						ScopeVersion:       scope.GetVersion(),
+						ScopeAttributes:    scopeAttrs,
+						LogAttributes:      convertAttributes(attrs),
+
+						ProjectId:  attributeInt64(ProjectIdAttribute, attrs, resource),
+						PipelineId: attributeInt64(PipelineIdAttribute, attrs, resource),
+						JobId:      attributeInt64(JobIdAttribute, attrs, resource),
+					})
+					if err != nil {
+						return 0, fmt.Errorf("append batch: %w", err)
+					}
+				}
+			}
+		}
+	}
+
+	if recordCount == 0 {
+		return 0, nil
+	}
+
+	if err := batch.Send(); err != nil {
+		return -1, fmt.Errorf("send batch: %w", err)
+	}
+
+	n := batch.Rows()
+	slog.Debug("Recorded logs", "received", recordCount, "inserted", n)
+
+	return n, nil
+}
+
 func timeFromUnixNano(ts int64) time.Time {
 	const nsecPerSecond int64 = 1e09
 	sec := ts / nsecPerSecond
@@ -1191,47 +1444,297 @@ func timeFromUnixNano(ts int64) time.Time {
 	return time.Unix(sec, nsec)
 }
 
+// convertAttributes returns the string representation of all attributes,
+// see `convertAnyValue`.
 func convertAttributes(list []*otlp_comonpb.KeyValue) map[string]string {
-	attrs := make(map[string]string)
+	attrs := make(map[string]string, len(list))
 
 	for _, attr := range list {
-		value, ok := attr.GetValue().Value.(*otlp_comonpb.AnyValue_StringValue)
-		if ok {
-			attrs[attr.Key] = value.StringValue
+		if attr.GetValue().GetValue() == nil {
+			continue
 		}
+		attrs[attr.GetKey()] = convertAnyValue(attr.GetValue())
 	}
 
 	return attrs
 }
 
-func convertEvents(events []*otlp_tracepb.Span_Event) ([]time.Time, []string, []map[string]string) {
-	var (
-		times []time.Time
-		names []string
-		attrs []map[string]string
-	)
+// convertNumericAttributes returns the integer and double valued attributes
+// with their original types.
+func convertNumericAttributes(list []*otlp_comonpb.KeyValue) (map[string]int64, map[string]float64) {
+	ints := make(map[string]int64)
+	floats := make(map[string]float64)
+
+	for _, attr := range list {
+		switch v := attr.GetValue().GetValue().(type) {
+		case *otlp_comonpb.AnyValue_IntValue:
+			ints[attr.GetKey()] = v.IntValue
+		case *otlp_comonpb.AnyValue_DoubleValue:
+			floats[attr.GetKey()] = v.DoubleValue
+		}
+	}
+
+	return ints, floats
+}
+
+// convertAnyValue returns the string representation of the value. Arrays and
+// key-value lists are encoded as JSON.
+func convertAnyValue(v *otlp_comonpb.AnyValue) string {
+	switch v := v.GetValue().(type) {
+	case *otlp_comonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *otlp_comonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *otlp_comonpb.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *otlp_comonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	case *otlp_comonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	case *otlp_comonpb.AnyValue_ArrayValue, *otlp_comonpb.AnyValue_KvlistValue:
+		b, err := json.Marshal(anyValueJSON(&otlp_comonpb.AnyValue{Value: v}))
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	default:
+		return ""
+	}
+}
+
+// anyValueJSON returns the value as a JSON encodable type.
+func anyValueJSON(v *otlp_comonpb.AnyValue) any {
+	switch v := v.GetValue().(type) {
+	case *otlp_comonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *otlp_comonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *otlp_comonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *otlp_comonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *otlp_comonpb.AnyValue_BytesValue:
+		return v.BytesValue
+	case *otlp_comonpb.AnyValue_ArrayValue:
+		values := make([]any, 0, len(v.ArrayValue.GetValues()))
+		for _, value := range v.ArrayValue.GetValues() {
+			values = append(values, anyValueJSON(value))
+		}
+		return values
+	case *otlp_comonpb.AnyValue_KvlistValue:
+		values := make(map[string]any, len(v.KvlistValue.GetValues()))
+		for _, kv := range v.KvlistValue.GetValues() {
+			values[kv.GetKey()] = anyValueJSON(kv.GetValue())
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// attributeInt64 returns the integer value of the first attribute list that
+// contains the given key. String values are parsed, zero is returned if the
+// attribute is missing or not an integer.
+func attributeInt64(key string, lists ...[]*otlp_comonpb.KeyValue) int64 {
+	for _, list := range lists {
+		for _, attr := range list {
+			if attr.GetKey() != key {
+				continue
+			}
+			switch v := attr.GetValue().GetValue().(type) {
+			case *otlp_comonpb.AnyValue_IntValue:
+				return v.IntValue
+			case *otlp_comonpb.AnyValue_DoubleValue:
+				return int64(v.DoubleValue)
+			case *otlp_comonpb.AnyValue_StringValue:
+				if n, err := strconv.ParseInt(v.StringValue, 10, 64); err == nil {
+					return n
+				}
+			}
+		}
+	}
+	return 0
+}
+
+type spanEvents struct {
+	Times      []time.Time
+	Names      []string
+	Attrs      []map[string]string
+	AttrsInt   []map[string]int64
+	AttrsFloat []map[string]float64
+}
+
+func convertEvents(events []*otlp_tracepb.Span_Event) spanEvents {
+	var e spanEvents
 	for _, event := range events {
-		times = append(times, timeFromUnixNano(int64(event.TimeUnixNano)))
-		names = append(names, event.Name)
-		attrs = append(attrs, convertAttributes(event.Attributes))
+		ints, floats := convertNumericAttributes(event.GetAttributes())
+
+		e.Times = append(e.Times, timeFromUnixNano(int64(event.GetTimeUnixNano())))
+		e.Names = append(e.Names, event.GetName())
+		e.Attrs = append(e.Attrs, convertAttributes(event.GetAttributes()))
+		e.AttrsInt = append(e.AttrsInt, ints)
+		e.AttrsFloat = append(e.AttrsFloat, floats)
 	}
-	return times, names, attrs
+	return e
 }
 
-func convertLinks(links []*otlp_tracepb.Span_Link) ([]string, []string, []string, []map[string]string) {
-	var (
-		traceIDs []string
-		spanIDs  []string
-		states   []string
-		attrs    []map[string]string
-	)
+type spanLinks struct {
+	TraceIDs   []string
+	SpanIDs    []string
+	States     []string
+	Attrs      []map[string]string
+	AttrsInt   []map[string]int64
+	AttrsFloat []map[string]float64
+}
+
+func convertLinks(links []*otlp_tracepb.Span_Link) spanLinks {
+	var l spanLinks
 	for _, link := range links {
-		traceIDs = append(traceIDs, string(link.TraceId))
-		spanIDs = append(spanIDs, string(link.SpanId))
-		states = append(states, link.TraceState)
-		attrs = append(attrs, convertAttributes(link.Attributes))
+		ints, floats := convertNumericAttributes(link.GetAttributes())
+
+		l.TraceIDs = append(l.TraceIDs, string(link.GetTraceId()))
+		l.SpanIDs = append(l.SpanIDs, string(link.GetSpanId()))
+		l.States = append(l.States, link.GetTraceState())
+		l.Attrs = append(l.Attrs, convertAttributes(link.GetAttributes()))
+		l.AttrsInt = append(l.AttrsInt, ints)
+		l.AttrsFloat = append(l.AttrsFloat, floats)
+	}
+	return l
+}
+
+func convertPipeline(p *typespb.Pipeline) *Pipeline {
+	return &Pipeline{
+		Id:        p.Id,
+		Iid:       p.Iid,
+		ProjectId: p.GetProject().GetId(),
+
+		Name:          p.Name,
+		Ref:           p.Ref,
+		RefPath:       p.RefPath,
+		Sha:           p.Sha,
+		Source:        p.Source,
+		Status:        p.Status,
+		FailureReason: p.FailureReason,
+
+		CommittedAt: convertNullableTimestamp(p.Timestamps.GetCommittedAt()),
+		CreatedAt:   convertTimestamp(p.Timestamps.GetCreatedAt()),
+		UpdatedAt:   convertTimestamp(p.Timestamps.GetUpdatedAt()),
+		StartedAt:   convertNullableTimestamp(p.Timestamps.GetStartedAt()),
+		FinishedAt:  convertNullableTimestamp(p.Timestamps.GetFinishedAt()),
+
+		QueuedDuration: convertDuration(p.QueuedDuration),
+		Duration:       convertDuration(p.Duration),
+
+		Coverage: p.Coverage,
+
+		Warnings:   p.Warnings,
+		YamlErrors: p.YamlErrors,
+
+		Child:                     p.Child,
+		UpstreamPipelineId:        p.UpstreamPipeline.GetId(),
+		UpstreamPipelineIid:       p.UpstreamPipeline.GetIid(),
+		UpstreamPipelineProjectId: p.UpstreamPipeline.GetProject().GetId(),
+		DownstreamPipelines:       convertPipelineReferences(p.DownstreamPipelines),
+
+		MergeRequestId:        p.MergeRequest.GetId(),
+		MergeRequestIid:       p.MergeRequest.GetIid(),
+		MergeRequestProjectId: p.MergeRequest.GetProject().GetId(),
+
+		UserId: p.User.GetId(),
+	}
+}
+
+func convertJob(j *typespb.Job) *Job {
+	var jobKind string
+	switch j.Kind {
+	case typespb.JobKind_JOBKIND_UNSPECIFIED:
+		jobKind = "unspecified"
+	case typespb.JobKind_JOBKIND_BUILD:
+		jobKind = "build"
+	case typespb.JobKind_JOBKIND_BRIDGE:
+		jobKind = "bridge"
+	default:
+		jobKind = "unknown"
+	}
+
+	return &Job{
+		Id:         j.Id,
+		PipelineId: j.Pipeline.GetId(),
+		ProjectId:  j.Pipeline.GetProject().GetId(),
+
+		Name:          j.Name,
+		Ref:           j.Ref,
+		RefPath:       j.RefPath,
+		Status:        j.Status,
+		FailureReason: j.FailureReason,
+		ExitCode:      j.ExitCode,
+
+		CreatedAt:  convertTimestamp(j.Timestamps.GetCreatedAt()),
+		QueuedAt:   convertNullableTimestamp(j.Timestamps.GetQueuedAt()),
+		StartedAt:  convertNullableTimestamp(j.Timestamps.GetStartedAt()),
+		FinishedAt: convertNullableTimestamp(j.Timestamps.GetFinishedAt()),
+		ErasedAt:   convertNullableTimestamp(j.Timestamps.GetErasedAt()),
+
+		QueuedDuration: convertDuration(j.QueuedDuration),
+		Duration:       convertDuration(j.Duration),
+
+		Coverage: j.Coverage,
+
+		Stage:      j.Stage,
+		TagList:    j.Tags,
+		Properties: convertJobProperties(j.Properties),
+
+		AllowFailure: j.AllowFailure,
+		Manual:       j.Manual,
+		Retried:      j.Retried,
+		Retryable:    j.Retryable,
+
+		Kind:                        jobKind,
+		DownstreamPipelineId:        j.DownstreamPipeline.GetId(),
+		DownstreamPipelineIid:       j.DownstreamPipeline.GetIid(),
+		DownstreamPipelineProjectId: j.DownstreamPipeline.GetProject().GetId(),
+
+		RunnerId: j.Runner.GetId(),
+
+		// deprecated
+		Pipeline: []any{
+			j.Pipeline.GetId(),
+			j.Pipeline.GetProject().GetId(),
+			j.Ref,
+			"", // sha
+			"", // status
+		},
+	}
+}
+
+func convertSection(s *typespb.Section) *Section {
+	return &Section{
+		Id:         s.Id,
+		JobId:      s.Job.GetId(),
+		PipelineId: s.Job.GetPipeline().GetId(),
+		ProjectId:  s.Job.GetPipeline().GetProject().GetId(),
+
+		Name: s.Name,
+
+		StartedAt:  convertTimestamp(s.StartedAt),
+		FinishedAt: convertTimestamp(s.FinishedAt),
+
+		Duration: convertDuration(s.Duration),
+
+		// deprecated
+		Job: []any{
+			s.Job.GetId(),
+			s.Job.GetName(),
+			"", // status
+		},
+		Pipeline: []any{
+			s.Job.GetPipeline().GetId(),
+			s.Job.GetPipeline().GetProject().GetId(),
+			"", // ref
+			"", // sha
+			"", // status
+		},
 	}
-	return traceIDs, spanIDs, states, attrs
 }
 
 func convertLabels(labels []*typespb.Metric_Label) map[string]string {
@@ -1275,3 +1778,33 @@ func convertTestProperties(properties []*typespb.TestProperty) [][]string {
 	}
 	return ps
 }
+
+func InsertTimeSeries(c *Client, ctx context.Context, samples []*TimeSeriesSample) (int, error) {
+	const query string = `INSERT INTO {db:Identifier}.{table:Identifier} SETTINGS async_insert=1`
+	var params = map[string]string{
+		"db":    c.dbName,
+		"table": TimeSeriesTable,
+	}
+
+	ctx = WithParameters(ctx, params)
+
+	batch, err := c.PrepareBatch(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("prepare batch: %w", err)
+	}
+
+	for _, s := range samples {
+		if err := batch.AppendStruct(s); err != nil {
+			return 0, fmt.Errorf("append batch: %w", err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return -1, fmt.Errorf("send batch: %w", err)
+	}
+
+	n := batch.Rows()
+	slog.Debug("Recorded time series samples", "received", len(samples), "inserted", n)
+
+	return n, nil
+}
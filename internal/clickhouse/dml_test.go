@@ -0,0 +1,229 @@
+package clickhouse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	otlp_comonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	otlp_metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	otlp_resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func stringValue(s string) *otlp_comonpb.AnyValue {
+	return &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+func intValue(i int64) *otlp_comonpb.AnyValue {
+	return &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_IntValue{IntValue: i}}
+}
+
+func TestConvertAnyValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *otlp_comonpb.AnyValue
+		want  string
+	}{
+		{name: "nil", value: nil, want: ""},
+		{name: "string", value: stringValue("main"), want: "main"},
+		{name: "int", value: intValue(42), want: "42"},
+		{name: "double", value: &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_DoubleValue{DoubleValue: 0.5}}, want: "0.5"},
+		{name: "bool", value: &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_BoolValue{BoolValue: true}}, want: "true"},
+		{
+			name: "array",
+			value: &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_ArrayValue{ArrayValue: &otlp_comonpb.ArrayValue{
+				Values: []*otlp_comonpb.AnyValue{stringValue("a"), intValue(1)},
+			}}},
+			want: `["a",1]`,
+		},
+		{
+			name: "kvlist",
+			value: &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_KvlistValue{KvlistValue: &otlp_comonpb.KeyValueList{
+				Values: []*otlp_comonpb.KeyValue{{Key: "retry", Value: intValue(2)}},
+			}}},
+			want: `{"retry":2}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertAnyValue(tt.value); got != tt.want {
+				t.Errorf("Expected `%s`, got `%s`", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertTimestamp(t *testing.T) {
+	if ts := convertTimestamp(nil); ts.Unix() != 0 {
+		t.Errorf("Expected epoch for absent timestamp, got %v", ts)
+	}
+
+	want := time.Date(2023, 11, 22, 22, 4, 19, 10000000, time.UTC)
+	if ts := convertTimestamp(timestamppb.New(want)); !ts.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, ts)
+	}
+}
+
+func TestConvertNullableTimestamp(t *testing.T) {
+	if ts := convertNullableTimestamp(nil); ts != nil {
+		t.Errorf("Expected nil for absent timestamp, got %v", ts)
+	}
+	if ts := convertNullableTimestamp(&timestamppb.Timestamp{}); ts != nil {
+		t.Errorf("Expected nil for zero timestamp, got %v", ts)
+	}
+
+	want := time.Date(2023, 11, 22, 22, 4, 19, 10000000, time.UTC)
+	if ts := convertNullableTimestamp(timestamppb.New(want)); ts == nil || !ts.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, ts)
+	}
+}
+
+func TestAttributeInt64(t *testing.T) {
+	record := []*otlp_comonpb.KeyValue{
+		{Key: JobIdAttribute, Value: intValue(5599404160)},
+	}
+	resource := []*otlp_comonpb.KeyValue{
+		{Key: JobIdAttribute, Value: intValue(1)},
+		{Key: PipelineIdAttribute, Value: stringValue("1082136862")},
+		{Key: ProjectIdAttribute, Value: stringValue("not a number")},
+	}
+
+	if got := attributeInt64(JobIdAttribute, record, resource); got != 5599404160 {
+		t.Errorf("Expected record attribute to take precedence, got %d", got)
+	}
+	if got := attributeInt64(PipelineIdAttribute, record, resource); got != 1082136862 {
+		t.Errorf("Expected string attribute to be parsed, got %d", got)
+	}
+	if got := attributeInt64(ProjectIdAttribute, record, resource); got != 0 {
+		t.Errorf("Expected invalid attribute to be zero, got %d", got)
+	}
+}
+
+func TestConvertAttributes(t *testing.T) {
+	attrs := []*otlp_comonpb.KeyValue{
+		{Key: "http.method", Value: stringValue("GET")},
+		{Key: "http.status_code", Value: intValue(200)},
+		{Key: "ci.job.retry", Value: &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_BoolValue{BoolValue: true}}},
+		{Key: "duration", Value: &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_DoubleValue{DoubleValue: 1.5}}},
+		{Key: "tags", Value: &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_ArrayValue{
+			ArrayValue: &otlp_comonpb.ArrayValue{Values: []*otlp_comonpb.AnyValue{stringValue("a"), intValue(1)}},
+		}}},
+		{Key: "empty"},
+	}
+
+	want := map[string]string{
+		"http.method":      "GET",
+		"http.status_code": "200",
+		"ci.job.retry":     "true",
+		"duration":         "1.5",
+		"tags":             `["a",1]`,
+	}
+	if diff := cmp.Diff(want, convertAttributes(attrs)); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	ints, floats := convertNumericAttributes(attrs)
+	if diff := cmp.Diff(map[string]int64{"http.status_code": 200}, ints); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]float64{"duration": 1.5}, floats); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConvertOTLPMetrics(t *testing.T) {
+	resource := []*otlp_comonpb.KeyValue{
+		{Key: JobIdAttribute, Value: intValue(5599404160)},
+		{Key: PipelineIdAttribute, Value: intValue(1082136862)},
+		{Key: ProjectIdAttribute, Value: intValue(50817395)},
+	}
+	attrs := []*otlp_comonpb.KeyValue{
+		{Key: "target", Value: stringValue("build")},
+	}
+
+	data := []*otlp_metricspb.MetricsData{{
+		ResourceMetrics: []*otlp_metricspb.ResourceMetrics{{
+			Resource: &otlp_resourcepb.Resource{Attributes: resource},
+			ScopeMetrics: []*otlp_metricspb.ScopeMetrics{{
+				Metrics: []*otlp_metricspb.Metric{
+					{
+						Name: "cache_hits",
+						Data: &otlp_metricspb.Metric_Sum{Sum: &otlp_metricspb.Sum{
+							DataPoints: []*otlp_metricspb.NumberDataPoint{
+								{Attributes: attrs, TimeUnixNano: 1700690851366000000, Value: &otlp_metricspb.NumberDataPoint_AsInt{AsInt: 42}},
+							},
+						}},
+					},
+					{
+						Name: "compile_seconds",
+						Data: &otlp_metricspb.Metric_Summary{Summary: &otlp_metricspb.Summary{
+							DataPoints: []*otlp_metricspb.SummaryDataPoint{
+								{
+									TimeUnixNano: 1700690851366000000,
+									Count:        3,
+									Sum:          4.5,
+									QuantileValues: []*otlp_metricspb.SummaryDataPoint_ValueAtQuantile{
+										{Quantile: 0.5, Value: 1.2},
+									},
+								},
+							},
+						}},
+					},
+					{
+						Name: "request_duration",
+						Data: &otlp_metricspb.Metric_Histogram{Histogram: &otlp_metricspb.Histogram{
+							DataPoints: []*otlp_metricspb.HistogramDataPoint{
+								{
+									TimeUnixNano:   1700690851366000000,
+									Count:          3,
+									BucketCounts:   []uint64{1, 2},
+									ExplicitBounds: []float64{0.5},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		}},
+	}}
+
+	metrics, histograms := convertOTLPMetrics(data)
+
+	wantNames := []string{"cache_hits", "compile_seconds_count", "compile_seconds_sum", "compile_seconds"}
+	if len(metrics) != len(wantNames) {
+		t.Fatalf("Expected %d metrics, got %d", len(wantNames), len(metrics))
+	}
+	for i, name := range wantNames {
+		if metrics[i].Name != name {
+			t.Errorf("Expected metric %d to be `%s`, got `%s`", i, name, metrics[i].Name)
+		}
+	}
+
+	m := metrics[0]
+	if m.Value != 42 || m.Timestamp != 1700690851366 {
+		t.Errorf("Unexpected value or timestamp: %v, %v", m.Value, m.Timestamp)
+	}
+	if m.JobId != 5599404160 || m.PipelineId != 1082136862 || m.ProjectId != 50817395 {
+		t.Errorf("Unexpected references: %d, %d, %d", m.JobId, m.PipelineId, m.ProjectId)
+	}
+	if m.Labels["target"] != "build" {
+		t.Errorf("Expected label `target`, got %v", m.Labels)
+	}
+	if metrics[3].Labels["quantile"] != "0.5" {
+		t.Errorf("Expected quantile label, got %v", metrics[3].Labels)
+	}
+
+	if len(histograms) != 1 {
+		t.Fatalf("Expected 1 histogram, got %d", len(histograms))
+	}
+	if h := histograms[0]; h.Name != "request_duration" || h.Count != 3 || len(h.BucketCounts) != 2 {
+		t.Errorf("Unexpected histogram: %+v", h)
+	}
+
+	again, _ := convertOTLPMetrics(data)
+	if again[0].Id != m.Id {
+		t.Errorf("Expected deterministic ids, got `%s` and `%s`", m.Id, again[0].Id)
+	}
+}
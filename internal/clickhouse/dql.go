@@ -1,8 +1,16 @@
 package clickhouse
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-func SelectPipelineMaxUpdatedAt(c *Client, ctx context.Context) (map[int64]float64, error) {
+	"golang.org/x/exp/slices"
+)
+
+func SelectPipelineMaxUpdatedAt(c *Client, ctx context.Context) (map[int64]time.Time, error) {
 	const query string = `
         SELECT id, max(updated_at) AS updated_at
         FROM {db:Identifier}.{table:Identifier}
@@ -16,15 +24,15 @@ func SelectPipelineMaxUpdatedAt(c *Client, ctx context.Context) (map[int64]float
 	ctx = WithParameters(ctx, params)
 
 	var results []struct {
-		ID        int64   `ch:"id"`
-		UpdatedAt float64 `ch:"updated_at"`
+		ID        int64     `ch:"id"`
+		UpdatedAt time.Time `ch:"updated_at"`
 	}
 
 	if err := c.Select(ctx, &results, query); err != nil {
 		return nil, err
 	}
 
-	m := make(map[int64]float64, len(results))
+	m := make(map[int64]time.Time, len(results))
 	for _, res := range results {
 		m[res.ID] = res.UpdatedAt
 	}
@@ -32,7 +40,7 @@ func SelectPipelineMaxUpdatedAt(c *Client, ctx context.Context) (map[int64]float
 	return m, nil
 }
 
-func SelectTableIDLastestUpdates(c *Client, ctx context.Context, table string, idColumn string, updatedAtColumn string) (map[int64]float64, error) {
+func SelectTableIDLastestUpdates(c *Client, ctx context.Context, table string, idColumn string, updatedAtColumn string) (map[int64]time.Time, error) {
 	const query string = `
         SELECT {id:Identifier} AS id, max({updated_at:Identifier}) AS updated_at
         FROM {db:Identifier}.{table:Identifier}
@@ -48,15 +56,15 @@ func SelectTableIDLastestUpdates(c *Client, ctx context.Context, table string, i
 	ctx = WithParameters(ctx, params)
 
 	var results []struct {
-		ID        int64   `ch:"id"`
-		UpdatedAt float64 `ch:"updated_at"`
+		ID        int64     `ch:"id"`
+		UpdatedAt time.Time `ch:"updated_at"`
 	}
 
 	if err := c.Select(ctx, &results, query); err != nil {
 		return nil, err
 	}
 
-	m := make(map[int64]float64, len(results))
+	m := make(map[int64]time.Time, len(results))
 	for _, res := range results {
 		m[res.ID] = res.UpdatedAt
 	}
@@ -92,6 +100,157 @@ func SelectTableIDs[T int64 | string](c *Client, ctx context.Context, table stri
 	return m, nil
 }
 
+// CountTableIDs returns the approximate number of distinct ids of the table.
+func CountTableIDs(c *Client, ctx context.Context, table string, column string) (uint64, error) {
+	const query string = `
+        SELECT uniq({column:Identifier}) AS count FROM {db:Identifier}.{table:Identifier}
+        `
+	var params = map[string]string{
+		"db":     c.dbName,
+		"table":  table,
+		"column": column,
+	}
+
+	var results []struct {
+		Count uint64 `ch:"count"`
+	}
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return 0, err
+	} else if len(results) == 0 {
+		return 0, nil
+	}
+	return results[0].Count, nil
+}
+
+// ScanTableIDs calls fn with each distinct id of the table. The ids are
+// streamed rather than loaded into memory at once.
+func ScanTableIDs[T int64 | string](c *Client, ctx context.Context, table string, column string, fn func(id T)) error {
+	const query string = `
+        SELECT DISTINCT {column:Identifier} AS id FROM {db:Identifier}.{table:Identifier}
+        `
+	var params = map[string]string{
+		"db":     c.dbName,
+		"table":  table,
+		"column": column,
+	}
+
+	rows, err := c.Query(WithParameters(ctx, params), query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var id T
+	for rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		fn(id)
+	}
+	return rows.Err()
+}
+
+// ScanTableIDLatestUpdates calls fn with each id of the table and the time
+// of its latest update. The ids are streamed rather than loaded into memory
+// at once.
+func ScanTableIDLatestUpdates(c *Client, ctx context.Context, table string, idColumn string, updatedAtColumn string, fn func(id int64, updatedAt time.Time)) error {
+	const query string = `
+        SELECT {id:Identifier} AS id, max({updated_at:Identifier}) AS updated_at
+        FROM {db:Identifier}.{table:Identifier}
+        GROUP BY id
+        `
+	var params = map[string]string{
+		"db":         c.dbName,
+		"table":      table,
+		"id":         idColumn,
+		"updated_at": updatedAtColumn,
+	}
+
+	rows, err := c.Query(WithParameters(ctx, params), query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var (
+		id        int64
+		updatedAt time.Time
+	)
+	for rows.Next() {
+		if err := rows.Scan(&id, &updatedAt); err != nil {
+			return err
+		}
+		fn(id, updatedAt)
+	}
+	return rows.Err()
+}
+
+// SelectKnownTableIDs returns those of the ids that are in the table.
+func SelectKnownTableIDs[T int64 | string](c *Client, ctx context.Context, table string, ids []T) (map[T]struct{}, error) {
+	const query string = `
+        SELECT DISTINCT id FROM {db:Identifier}.{table:Identifier}
+        WHERE id IN {ids:Array(%s)}
+        `
+	var params = map[string]string{
+		"db":    c.dbName,
+		"table": table,
+	}
+	var idType string
+	switch ids := any(ids).(type) {
+	case []int64:
+		idType = "Int64"
+		params["ids"] = formatInt64Array(ids)
+	case []string:
+		idType = "String"
+		params["ids"] = formatStringArray(ids)
+	}
+
+	var results []struct {
+		ID T `ch:"id"`
+	}
+
+	if err := c.Select(WithParameters(ctx, params), &results, fmt.Sprintf(query, idType)); err != nil {
+		return nil, err
+	}
+
+	m := make(map[T]struct{}, len(results))
+	for _, res := range results {
+		m[res.ID] = struct{}{}
+	}
+
+	return m, nil
+}
+
+func SelectTableIDVersions(c *Client, ctx context.Context, table string, versionColumn string, ids []int64) (map[int64]time.Time, error) {
+	const query string = `
+        SELECT id, max({version:Identifier}) AS version FROM {db:Identifier}.{table:Identifier}
+        WHERE id IN {ids:Array(Int64)}
+        GROUP BY id
+        `
+	var params = map[string]string{
+		"db":      c.dbName,
+		"table":   table,
+		"version": versionColumn,
+		"ids":     formatInt64Array(ids),
+	}
+
+	var results []struct {
+		ID      int64     `ch:"id"`
+		Version time.Time `ch:"version"`
+	}
+
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return nil, err
+	}
+
+	m := make(map[int64]time.Time, len(results))
+	for _, res := range results {
+		m[res.ID] = res.Version
+	}
+
+	return m, nil
+}
+
 func SelectTraceSpanIDs(c *Client, ctx context.Context) (map[string]struct{}, error) {
 	const query string = `
         SELECT TraceId, SpanId FROM {db:Identifier}.{table:Identifier}
@@ -121,3 +280,392 @@ func SelectTraceSpanIDs(c *Client, ctx context.Context) (map[string]struct{}, er
 
 	return m, nil
 }
+
+type PipelineFilter struct {
+	// Only select pipelines of the project, if not zero
+	ProjectId int64
+	// Only select pipelines created in the time range, if not zero
+	Since time.Time
+	Until time.Time
+	// Only select pipelines with an id greater than this
+	AfterId int64
+	// Maximum number of pipelines to select, 0 means all
+	Limit int
+}
+
+// SelectPipelines returns the latest version of the pipelines matching the
+// filter ordered by their id. Only the columns required to synthesize traces
+// are selected.
+func SelectPipelines(c *Client, ctx context.Context, filter PipelineFilter) ([]*Pipeline, error) {
+	var params = map[string]string{
+		"db":       c.dbName,
+		"table":    PipelinesTable,
+		"after_id": strconv.FormatInt(filter.AfterId, 10),
+	}
+
+	query := `
+        SELECT
+            id, iid, project_id, name, ref, sha, source, status, failure_reason,
+            created_at, started_at, finished_at, upstream_pipeline_id
+        FROM {db:Identifier}.{table:Identifier} FINAL
+        WHERE id > {after_id:Int64}`
+	if filter.ProjectId != 0 {
+		query += " AND project_id = {project_id:Int64}"
+		params["project_id"] = strconv.FormatInt(filter.ProjectId, 10)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND created_at >= toDateTime64({since:Int64}, 3)"
+		params["since"] = strconv.FormatInt(filter.Since.Unix(), 10)
+	}
+	if !filter.Until.IsZero() {
+		query += " AND created_at < toDateTime64({until:Int64}, 3)"
+		params["until"] = strconv.FormatInt(filter.Until.Unix(), 10)
+	}
+	query += " ORDER BY id"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	var results []Pipeline
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return nil, err
+	}
+
+	pipelines := make([]*Pipeline, 0, len(results))
+	for i := range results {
+		pipelines = append(pipelines, &results[i])
+	}
+	return pipelines, nil
+}
+
+// SelectPipelineJobs returns the latest version of the jobs and bridges of
+// the pipelines. Only the columns required to synthesize traces are selected.
+func SelectPipelineJobs(c *Client, ctx context.Context, pipelineIds []int64) ([]*Job, error) {
+	const query string = `
+        SELECT
+            id, pipeline_id, project_id, name, stage, status, failure_reason,
+            queued_at, started_at, finished_at, allow_failure, retried, kind,
+            downstream_pipeline_id, runner_id
+        FROM {db:Identifier}.{jobs:Identifier} FINAL
+        WHERE pipeline_id IN {ids:Array(Int64)}
+        UNION ALL
+        SELECT
+            id, pipeline.id AS pipeline_id, pipeline.project_id AS project_id,
+            name, stage, status, failure_reason,
+            CAST(NULL, 'Nullable(DateTime64(3))') AS queued_at,
+            started_at, finished_at, allow_failure,
+            false AS retried, 'bridge' AS kind,
+            downstream_pipeline.id AS downstream_pipeline_id, '' AS runner_id
+        FROM {db:Identifier}.{bridges:Identifier} FINAL
+        WHERE pipeline.id IN {ids:Array(Int64)}
+        `
+	var params = map[string]string{
+		"db":      c.dbName,
+		"jobs":    JobsTable,
+		"bridges": BridgesTable,
+		"ids":     formatInt64Array(pipelineIds),
+	}
+
+	var results []Job
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*Job, 0, len(results))
+	for i := range results {
+		jobs = append(jobs, &results[i])
+	}
+	return jobs, nil
+}
+
+// SelectPipelineSections returns the latest version of the job sections of
+// the pipelines. Only the columns required to synthesize traces are selected.
+func SelectPipelineSections(c *Client, ctx context.Context, pipelineIds []int64) ([]*Section, error) {
+	const query string = `
+        SELECT id, job_id, pipeline_id, project_id, name, started_at, finished_at
+        FROM {db:Identifier}.{table:Identifier} FINAL
+        WHERE pipeline_id IN {ids:Array(Int64)}
+        `
+	var params = map[string]string{
+		"db":    c.dbName,
+		"table": SectionsTable,
+		"ids":   formatInt64Array(pipelineIds),
+	}
+
+	var results []Section
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return nil, err
+	}
+
+	sections := make([]*Section, 0, len(results))
+	for i := range results {
+		sections = append(sections, &results[i])
+	}
+	return sections, nil
+}
+
+func formatInt64Array(values []int64) string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		s = append(s, strconv.FormatInt(v, 10))
+	}
+	return "[" + strings.Join(s, ",") + "]"
+}
+
+// TraceIdTimestampsTable contains the time range of each trace, which is used
+// to narrow down the partitions to scan when selecting traces by id.
+const TraceIdTimestampsTable string = "traces_trace_id_ts"
+
+// SelectTraceServices returns the names of the services that recorded spans.
+func SelectTraceServices(c *Client, ctx context.Context) ([]string, error) {
+	const query string = `
+        SELECT DISTINCT ServiceName AS service
+        FROM {db:Identifier}.{table:Identifier}
+        ORDER BY service
+        `
+	var params = map[string]string{
+		"db":    c.dbName,
+		"table": TraceSpansTable,
+	}
+
+	var results []struct {
+		Service string `ch:"service"`
+	}
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return nil, err
+	}
+
+	services := make([]string, 0, len(results))
+	for _, res := range results {
+		services = append(services, res.Service)
+	}
+	return services, nil
+}
+
+type SpanOperation struct {
+	Name string `ch:"name"`
+	Kind string `ch:"kind"`
+}
+
+// SelectTraceOperations returns the span names and kinds recorded by the
+// service, or by all services if it is empty.
+func SelectTraceOperations(c *Client, ctx context.Context, service string) ([]SpanOperation, error) {
+	var params = map[string]string{
+		"db":    c.dbName,
+		"table": TraceSpansTable,
+	}
+
+	query := `
+        SELECT DISTINCT SpanName AS name, SpanKind AS kind
+        FROM {db:Identifier}.{table:Identifier}`
+	if service != "" {
+		query += " WHERE ServiceName = {service:String}"
+		params["service"] = service
+	}
+	query += " ORDER BY name, kind"
+
+	var results []SpanOperation
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// DefaultTraceLookback is the time range searched for traces if no start time
+// is given.
+const DefaultTraceLookback time.Duration = time.Hour
+
+type TraceFilter struct {
+	// Only select traces with spans of the service
+	Service string
+	// Only select traces with spans of this name, if not empty
+	Operation string
+	// Only select traces with spans having all of these span or resource
+	// attributes
+	Attributes map[string]string
+	// Only select traces with spans that started in the time range. The end
+	// defaults to now and the start to DefaultTraceLookback before the end.
+	Start time.Time
+	End   time.Time
+	// Only select traces of at least and at most this duration, from the
+	// start of their first to the end of their last span in the time range,
+	// if not zero
+	MinDuration time.Duration
+	MaxDuration time.Duration
+	// Maximum number of traces to select, 0 means all
+	Limit int
+}
+
+// SelectTraceIds returns the hex encoded ids of the traces matching the
+// filter, the most recent first.
+func SelectTraceIds(c *Client, ctx context.Context, filter TraceFilter) ([]string, error) {
+	query, params := prepareTraceIdsQuery(c.dbName, filter, time.Now())
+
+	var results []struct {
+		TraceId   string    `ch:"trace_id"`
+		Timestamp time.Time `ch:"ts"`
+	}
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(results))
+	for _, res := range results {
+		ids = append(ids, res.TraceId)
+	}
+	return ids, nil
+}
+
+func prepareTraceIdsQuery(dbName string, filter TraceFilter, now time.Time) (string, map[string]string) {
+	var params = map[string]string{
+		"db":    dbName,
+		"table": TraceSpansTable,
+	}
+
+	end := filter.End
+	if end.IsZero() {
+		end = now
+	}
+	start := filter.Start
+	if start.IsZero() {
+		start = end.Add(-DefaultTraceLookback)
+	}
+	params["start"] = strconv.FormatInt(start.UnixNano(), 10)
+	params["end"] = strconv.FormatInt(end.UnixNano(), 10)
+	timeRange := "Timestamp BETWEEN fromUnixTimestamp64Nano({start:Int64}) AND fromUnixTimestamp64Nano({end:Int64})"
+
+	conditions := []string{timeRange}
+	if filter.Service != "" {
+		conditions = append(conditions, "ServiceName = {service:String}")
+		params["service"] = filter.Service
+	}
+	if filter.Operation != "" {
+		conditions = append(conditions, "SpanName = {operation:String}")
+		params["operation"] = filter.Operation
+	}
+
+	keys := make([]string, 0, len(filter.Attributes))
+	for key := range filter.Attributes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for i, key := range keys {
+		k, v := fmt.Sprintf("attr_key_%d", i), fmt.Sprintf("attr_value_%d", i)
+		conditions = append(conditions, fmt.Sprintf(
+			"(SpanAttributes[{%[1]s:String}] = {%[2]s:String} OR ResourceAttributes[{%[1]s:String}] = {%[2]s:String})", k, v,
+		))
+		params[k] = key
+		params[v] = filter.Attributes[key]
+	}
+
+	// durations are those of the traces rather than of the matching spans
+	const duration string = "max(toUnixTimestamp64Nano(Timestamp) + Duration) - min(toUnixTimestamp64Nano(Timestamp))"
+	var durationConditions []string
+	if filter.MinDuration > 0 {
+		durationConditions = append(durationConditions, duration+" >= {min_duration:Int64}")
+		params["min_duration"] = strconv.FormatInt(filter.MinDuration.Nanoseconds(), 10)
+	}
+	if filter.MaxDuration > 0 {
+		durationConditions = append(durationConditions, duration+" <= {max_duration:Int64}")
+		params["max_duration"] = strconv.FormatInt(filter.MaxDuration.Nanoseconds(), 10)
+	}
+	if len(durationConditions) > 0 {
+		conditions = append(conditions, ""+
+			"TraceId IN ("+
+			"SELECT TraceId FROM {db:Identifier}.{table:Identifier}"+
+			" WHERE "+timeRange+
+			" GROUP BY TraceId"+
+			" HAVING "+strings.Join(durationConditions, " AND ")+
+			")",
+		)
+	}
+
+	query := `
+        SELECT lower(hex(TraceId)) AS trace_id, max(Timestamp) AS ts
+        FROM {db:Identifier}.{table:Identifier}
+        WHERE ` + strings.Join(conditions, " AND ") + `
+        GROUP BY trace_id ORDER BY ts DESC`
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	return query, params
+}
+
+// TraceSpan is a span as stored in the traces table.
+type TraceSpan struct {
+	Timestamp           time.Time           `ch:"Timestamp"`
+	TraceId             string              `ch:"TraceId"`
+	SpanId              string              `ch:"SpanId"`
+	ParentSpanId        string              `ch:"ParentSpanId"`
+	TraceState          string              `ch:"TraceState"`
+	SpanName            string              `ch:"SpanName"`
+	SpanKind            string              `ch:"SpanKind"`
+	ServiceName         string              `ch:"ServiceName"`
+	ResourceAttributes  map[string]string   `ch:"ResourceAttributes"`
+	ScopeName           string              `ch:"ScopeName"`
+	ScopeVersion        string              `ch:"ScopeVersion"`
+	SpanAttributes      map[string]string   `ch:"SpanAttributes"`
+	SpanAttributesInt   map[string]int64    `ch:"SpanAttributesInt"`
+	SpanAttributesFloat map[string]float64  `ch:"SpanAttributesFloat"`
+	Duration            int64               `ch:"Duration"`
+	StatusCode          string              `ch:"StatusCode"`
+	StatusMessage       string              `ch:"StatusMessage"`
+	EventTimestamps     []time.Time         `ch:"EventTimestamps"`
+	EventNames          []string            `ch:"EventNames"`
+	EventAttributes     []map[string]string `ch:"EventAttributes"`
+	LinkTraceIds        []string            `ch:"LinkTraceIds"`
+	LinkSpanIds         []string            `ch:"LinkSpanIds"`
+}
+
+// SelectTraceSpans returns the spans of the traces ordered by their start
+// time. Trace ids may be given as stored or hex encoded.
+func SelectTraceSpans(c *Client, ctx context.Context, traceIds []string) ([]TraceSpan, error) {
+	if len(traceIds) == 0 {
+		return nil, nil
+	}
+
+	const query string = `
+        WITH
+            arrayConcat({ids:Array(String)}, arrayMap(id -> unhex(id), {ids:Array(String)})) AS ids,
+            (
+                SELECT (min(Start), max(End))
+                FROM {db:Identifier}.{ts_table:Identifier}
+                WHERE TraceId IN ids
+            ) AS bounds
+        SELECT
+            Timestamp, TraceId, SpanId, ParentSpanId, TraceState,
+            SpanName, SpanKind, ServiceName, ResourceAttributes,
+            ScopeName, ScopeVersion,
+            SpanAttributes, SpanAttributesInt, SpanAttributesFloat,
+            Duration, StatusCode, StatusMessage,
+            Events.Timestamp AS EventTimestamps,
+            Events.Name AS EventNames,
+            Events.Attributes AS EventAttributes,
+            Links.TraceId AS LinkTraceIds,
+            Links.SpanId AS LinkSpanIds
+        FROM {db:Identifier}.{table:Identifier}
+        WHERE TraceId IN ids AND Timestamp BETWEEN bounds.1 AND bounds.2
+        ORDER BY Timestamp
+        `
+	var params = map[string]string{
+		"db":       c.dbName,
+		"table":    TraceSpansTable,
+		"ts_table": TraceIdTimestampsTable,
+		"ids":      formatStringArray(traceIds),
+	}
+
+	var results []TraceSpan
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func formatStringArray(values []string) string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		s = append(s, quoteString(v))
+	}
+	return "[" + strings.Join(s, ",") + "]"
+}
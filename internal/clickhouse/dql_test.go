@@ -0,0 +1,84 @@
+package clickhouse
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPrepareTraceIdsQuery(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	filter := TraceFilter{
+		Service:     "gitlab-ci",
+		Operation:   "pipeline",
+		Attributes:  map[string]string{"ci.ref": "main"},
+		MinDuration: time.Minute,
+		MaxDuration: time.Hour,
+		Limit:       20,
+	}
+
+	expectedQuery := `
+        SELECT lower(hex(TraceId)) AS trace_id, max(Timestamp) AS ts
+        FROM {db:Identifier}.{table:Identifier}
+        WHERE Timestamp BETWEEN fromUnixTimestamp64Nano({start:Int64}) AND fromUnixTimestamp64Nano({end:Int64})` +
+		` AND ServiceName = {service:String}` +
+		` AND SpanName = {operation:String}` +
+		` AND (SpanAttributes[{attr_key_0:String}] = {attr_value_0:String} OR ResourceAttributes[{attr_key_0:String}] = {attr_value_0:String})` +
+		` AND TraceId IN (SELECT TraceId FROM {db:Identifier}.{table:Identifier}` +
+		` WHERE Timestamp BETWEEN fromUnixTimestamp64Nano({start:Int64}) AND fromUnixTimestamp64Nano({end:Int64})` +
+		` GROUP BY TraceId` +
+		` HAVING max(toUnixTimestamp64Nano(Timestamp) + Duration) - min(toUnixTimestamp64Nano(Timestamp)) >= {min_duration:Int64}` +
+		` AND max(toUnixTimestamp64Nano(Timestamp) + Duration) - min(toUnixTimestamp64Nano(Timestamp)) <= {max_duration:Int64})` + `
+        GROUP BY trace_id ORDER BY ts DESC LIMIT 20`
+	expectedParams := map[string]string{
+		"db":           "gitlab_ci",
+		"table":        "traces",
+		"start":        "1717239600000000000",
+		"end":          "1717243200000000000",
+		"service":      "gitlab-ci",
+		"operation":    "pipeline",
+		"attr_key_0":   "ci.ref",
+		"attr_value_0": "main",
+		"min_duration": "60000000000",
+		"max_duration": "3600000000000",
+	}
+
+	query, params := prepareTraceIdsQuery("gitlab_ci", filter, now)
+
+	checkQuery(t, expectedQuery, query)
+	checkParams(t, expectedParams, params)
+}
+
+func TestPrepareTraceIdsQuery_Range(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	end := now.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		filter TraceFilter
+		start  time.Time
+		end    time.Time
+	}{
+		{name: "default", filter: TraceFilter{}, start: now.Add(-DefaultTraceLookback), end: now},
+		{name: "end", filter: TraceFilter{End: end}, start: end.Add(-DefaultTraceLookback), end: end},
+		{name: "start", filter: TraceFilter{Start: end.Add(-time.Minute)}, start: end.Add(-time.Minute), end: now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, params := prepareTraceIdsQuery("gitlab_ci", tt.filter, now)
+
+			want := map[string]string{
+				"start": strconv.FormatInt(tt.start.UnixNano(), 10),
+				"end":   strconv.FormatInt(tt.end.UnixNano(), 10),
+			}
+			got := map[string]string{"start": params["start"], "end": params["end"]}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("Range mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	chproto "github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+// ErrDryRun is returned for statements that would modify the database while
+// the client is in dry-run mode.
+var ErrDryRun = errors.New("not executed in dry-run mode")
+
+// SchemaSnapshot maps tables to the columns rows are inserted into.
+type SchemaSnapshot map[string][]SchemaColumn
+
+type SchemaColumn struct {
+	Table string `ch:"table"`
+	Name  string `ch:"name"`
+	Type  string `ch:"type"`
+}
+
+// SelectSchemaSnapshot returns the insertable columns of all tables of the
+// database, in the order the server expects them in an insert.
+func SelectSchemaSnapshot(c *Client, ctx context.Context) (SchemaSnapshot, error) {
+	const query string = `
+        SELECT table, name, type
+        FROM system.columns
+        WHERE database = {db:String} AND default_kind NOT IN ('MATERIALIZED', 'ALIAS')
+        ORDER BY table, position
+        `
+	params := map[string]string{
+		"db": c.dbName,
+	}
+
+	var columns []SchemaColumn
+	if err := c.Select(WithParameters(ctx, params), &columns, query); err != nil {
+		return nil, err
+	}
+
+	schema := SchemaSnapshot{}
+	for _, col := range columns {
+		schema[col.Table] = append(schema[col.Table], col)
+	}
+	return schema, nil
+}
+
+// SetDryRun puts the client in dry-run mode if the schema is not nil. Batches
+// then validate appended rows against the columns of the schema snapshot, the
+// same way the driver does, but are never sent, and statements modifying the
+// database fail with ErrDryRun.
+func (c *Client) SetDryRun(schema SchemaSnapshot) {
+	c.schema = schema
+}
+
+// DryRun returns whether the client is in dry-run mode.
+func (c *Client) DryRun() bool {
+	return c.schema != nil
+}
+
+// prepareDryRunBatch returns a batch for the table named by the `table`
+// query parameter of the context.
+func (c *Client) prepareDryRunBatch(ctx context.Context) (driver.Batch, error) {
+	table := parameters(ctx)["table"]
+	columns, ok := c.schema[table]
+	if !ok {
+		return nil, fmt.Errorf("table not found in schema snapshot: %q", table)
+	}
+	return newDryRunBatch(columns)
+}
+
+type parametersKey struct{}
+
+// parameters returns the query parameters set using WithParameters.
+func parameters(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(parametersKey{}).(map[string]string)
+	return params
+}
+
+// dryRunBatch implements driver.Batch by appending rows to an unsent block.
+type dryRunBatch struct {
+	block *chproto.Block
+	err   error
+	sent  bool
+}
+
+func newDryRunBatch(columns []SchemaColumn) (*dryRunBatch, error) {
+	block := chproto.NewBlock()
+	block.ServerContext.Timezone = time.UTC
+	for _, col := range columns {
+		if err := block.AddColumn(col.Name, column.Type(col.Type)); err != nil {
+			return nil, fmt.Errorf("column `%s`: %w", col.Name, err)
+		}
+	}
+	return &dryRunBatch{block: block}, nil
+}
+
+func (b *dryRunBatch) Abort() error {
+	if b.sent {
+		return clickhouse.ErrBatchAlreadySent
+	}
+	b.sent = true
+	return nil
+}
+
+func (b *dryRunBatch) Append(v ...any) error {
+	if b.sent {
+		return clickhouse.ErrBatchAlreadySent
+	}
+	if b.err != nil {
+		return b.err
+	}
+
+	if err := b.block.Append(v...); err != nil {
+		b.err = fmt.Errorf("%w: %w", clickhouse.ErrBatchInvalid, err)
+		return err
+	}
+	return nil
+}
+
+func (b *dryRunBatch) AppendStruct(v any) error {
+	if b.err != nil {
+		return b.err
+	}
+	values, err := structValues(b.block.ColumnsNames(), v)
+	if err != nil {
+		return err
+	}
+	return b.Append(values...)
+}
+
+func (b *dryRunBatch) Column(idx int) driver.BatchColumn {
+	if idx < 0 || idx >= len(b.block.Columns) {
+		return dryRunColumn{err: fmt.Errorf("invalid column index %d", idx)}
+	}
+	return dryRunColumn{col: b.block.Columns[idx]}
+}
+
+func (b *dryRunBatch) Flush() error {
+	return nil
+}
+
+// Send marks the batch as sent without sending it.
+func (b *dryRunBatch) Send() error {
+	if b.sent {
+		return clickhouse.ErrBatchAlreadySent
+	}
+	if b.err != nil {
+		return b.err
+	}
+	b.sent = true
+	return nil
+}
+
+func (b *dryRunBatch) IsSent() bool {
+	return b.sent
+}
+
+func (b *dryRunBatch) Rows() int {
+	return b.block.Rows()
+}
+
+func (b *dryRunBatch) Columns() []column.Interface {
+	return b.block.Columns
+}
+
+func (b *dryRunBatch) Close() error {
+	return b.Abort()
+}
+
+type dryRunColumn struct {
+	col column.Interface
+	err error
+}
+
+func (c dryRunColumn) Append(v any) error {
+	if c.err != nil {
+		return c.err
+	}
+	_, err := c.col.Append(v)
+	return err
+}
+
+func (c dryRunColumn) AppendRow(v any) error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.col.AppendRow(v)
+}
+
+var structIndexes sync.Map
+
+// structValues returns the values of the fields of the struct pointer v for
+// the columns, mapping fields to columns by their `ch` tag or name like the
+// driver's AppendStruct.
+func structValues(columns []string, v any) ([]any, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("AppendStruct expects a non-nil struct pointer, got %T", v)
+	}
+	rv = rv.Elem()
+
+	index, ok := structIndexes.Load(rv.Type())
+	if !ok {
+		index, _ = structIndexes.LoadOrStore(rv.Type(), structIndex(rv.Type()))
+	}
+
+	values := make([]any, 0, len(columns))
+	for _, name := range columns {
+		idx, ok := index.(map[string][]int)[name]
+		if !ok {
+			return nil, fmt.Errorf("missing destination name %q in %T", name, v)
+		}
+		values = append(values, rv.FieldByIndex(idx).Interface())
+	}
+	return values, nil
+}
+
+func structIndex(t reflect.Type) map[string][]int {
+	fields := map[string][]int{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Name
+		if tag := f.Tag.Get("ch"); tag != "" {
+			name = tag
+		}
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		if f.Anonymous {
+			if f.Type.Kind() != reflect.Pointer {
+				for k, idx := range structIndex(f.Type) {
+					fields[k] = append([]int{i}, idx...)
+				}
+			}
+			continue
+		}
+		fields[name] = f.Index
+	}
+	return fields
+}
@@ -0,0 +1,102 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type dryRunRow struct {
+	Id        int64             `ch:"id"`
+	Name      string            `ch:"name"`
+	CreatedAt float64           `ch:"created_at"`
+	Labels    map[string]string `ch:"labels"`
+	Ignored   string            `ch:"-"`
+}
+
+func dryRunClient() *Client {
+	c := NewClient(nil, "default")
+	c.SetDryRun(SchemaSnapshot{
+		"rows_in": {
+			{Table: "rows_in", Name: "id", Type: "Int64"},
+			{Table: "rows_in", Name: "name", Type: "LowCardinality(String)"},
+			{Table: "rows_in", Name: "created_at", Type: "Float64"},
+			{Table: "rows_in", Name: "labels", Type: "Map(String, String)"},
+		},
+	})
+	return c
+}
+
+func TestDryRunBatch(t *testing.T) {
+	c := dryRunClient()
+	ctx := WithParameters(context.Background(), map[string]string{"db": "default", "table": "rows_in"})
+
+	batch, err := c.PrepareBatch(ctx, "INSERT INTO {db:Identifier}.{table:Identifier}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		if err := batch.AppendStruct(&dryRunRow{Id: i, Name: "main", Labels: map[string]string{"k": "v"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := batch.Send(); err != nil {
+		t.Fatal(err)
+	}
+	if !batch.IsSent() || batch.Rows() != 3 {
+		t.Errorf("Expected 3 sent rows, got %d (sent: %v)", batch.Rows(), batch.IsSent())
+	}
+}
+
+func TestDryRunBatch_TypeMismatch(t *testing.T) {
+	c := dryRunClient()
+	ctx := WithParameters(context.Background(), map[string]string{"db": "default", "table": "rows_in"})
+
+	batch, err := c.PrepareBatch(ctx, "INSERT INTO {db:Identifier}.{table:Identifier}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = batch.Append(int64(1), "main", "yesterday", map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "created_at") {
+		t.Errorf("Expected error for column created_at, got %v", err)
+	}
+	if err := batch.Send(); err == nil {
+		t.Error("Expected error sending invalid batch")
+	}
+}
+
+func TestDryRunBatch_MissingField(t *testing.T) {
+	c := dryRunClient()
+	ctx := WithParameters(context.Background(), map[string]string{"db": "default", "table": "rows_in"})
+
+	batch, err := c.PrepareBatch(ctx, "INSERT INTO {db:Identifier}.{table:Identifier}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type row struct {
+		Id int64 `ch:"id"`
+	}
+	if err := batch.AppendStruct(&row{Id: 1}); err == nil {
+		t.Error("Expected error for missing fields")
+	}
+}
+
+func TestDryRun_UnknownTable(t *testing.T) {
+	c := dryRunClient()
+	ctx := WithParameters(context.Background(), map[string]string{"db": "default", "table": "builds_in"})
+
+	if _, err := c.PrepareBatch(ctx, "INSERT INTO {db:Identifier}.{table:Identifier}"); err == nil {
+		t.Error("Expected error for unknown table")
+	}
+}
+
+func TestDryRun_Exec(t *testing.T) {
+	c := dryRunClient()
+	if err := c.Exec(context.Background(), "OPTIMIZE TABLE pipelines FINAL"); !errors.Is(err, ErrDryRun) {
+		t.Errorf("Expected ErrDryRun, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	ExportFormatParquet string = "parquet"
+	ExportFormatNDJSON  string = "ndjson"
+	ExportFormatCSV     string = "csv"
+)
+
+// exportOutputFormats maps export formats to the ClickHouse output formats
+// used for the first and subsequent chunks of a file.
+var exportOutputFormats = map[string][2]string{
+	ExportFormatParquet: {"Parquet", "Parquet"},
+	ExportFormatNDJSON:  {"JSONEachRow", "JSONEachRow"},
+	ExportFormatCSV:     {"CSVWithNames", "CSV"},
+}
+
+// exportProjectColumns maps tables to the expression of the project id of
+// a row. Tables that aren't listed can't be filtered by project.
+var exportProjectColumns = map[string]string{
+	BridgesTable:                "pipeline.project_id",
+	CoverageReportsTable:        "project_id",
+	CoveragePackagesTable:       "project_id",
+	CoverageClassesTable:        "project_id",
+	CoverageMethodsTable:        "project_id",
+	DeploymentsTable:            "project_id",
+	IssuesTable:                 "project_id",
+	JobsTable:                   "project_id",
+	MergeRequestNoteEventsTable: "mergerequest_project_id",
+	MergeRequestsTable:          "project_id",
+	MetricsTable:                "project_id",
+	MetricHistogramsTable:       "project_id",
+	PipelinesTable:              "project_id",
+	ProjectsTable:               "id",
+	SectionsTable:               "project_id",
+	TestCasesTable:              "project_id",
+	TestReportsTable:            "project_id",
+	TestSuitesTable:             "project_id",
+}
+
+// table engines that merge rows and thus support FINAL, including their
+// replicated variants
+var exportFinalEngines = []string{
+	"ReplacingMergeTree",
+	"CollapsingMergeTree",
+	"VersionedCollapsingMergeTree",
+	"SummingMergeTree",
+	"AggregatingMergeTree",
+}
+
+type ExportOptions struct {
+	Table string
+	// One of `parquet`, `ndjson` or `csv`
+	Format string
+	// Only export rows of the project, if not zero
+	ProjectId int64
+	// Only export rows created in the time range, if not zero
+	Since time.Time
+	Until time.Time
+}
+
+type ExportResult struct {
+	PartitionId string
+	Rows        uint64
+	Bytes       int64
+}
+
+// ExportOutput returns the writer for the rows of a partition and whether
+// it starts a new file, or continues the file of the previous partition.
+type ExportOutput func(partitionId string) (w io.Writer, newFile bool, err error)
+
+// ExportTable streams the latest version of the rows of the table that match
+// the options in the requested format, one partition at a time to limit the
+// memory used by the server.
+func ExportTable(c *Client, hc *HTTPClient, ctx context.Context, opt ExportOptions, output ExportOutput) ([]ExportResult, error) {
+	if !slices.Contains(Tables, opt.Table) {
+		return nil, fmt.Errorf("unknown table: %q", opt.Table)
+	}
+	formats, ok := exportOutputFormats[opt.Format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format: %q", opt.Format)
+	}
+
+	final, err := selectExportFinal(c, ctx, opt.Table)
+	if err != nil {
+		return nil, fmt.Errorf("select table engine: %w", err)
+	}
+	columns, err := getColumnNames(ctx, c.dbName, opt.Table, c)
+	if err != nil {
+		return nil, fmt.Errorf("select columns: %w", err)
+	}
+
+	condition, params, err := exportCondition(opt, columns)
+	if err != nil {
+		return nil, err
+	}
+	params["db"] = c.dbName
+	params["table"] = opt.Table
+
+	partitions, err := selectExportPartitions(c, ctx, final, condition, params)
+	if err != nil {
+		return nil, fmt.Errorf("select partitions: %w", err)
+	}
+
+	var results []ExportResult
+	for i, p := range partitions {
+		w, newFile, err := output(p.PartitionId)
+		if err != nil {
+			return results, err
+		}
+
+		format := formats[0]
+		if !newFile && i > 0 {
+			if opt.Format == ExportFormatParquet {
+				return results, fmt.Errorf("parquet files can't hold multiple partitions, export to a directory instead")
+			}
+			format = formats[1]
+		}
+
+		params["partition"] = p.PartitionId
+		query := exportQuery(final, condition, format)
+		n, err := hc.QueryTo(ctx, w, query, params)
+		if err != nil {
+			return results, fmt.Errorf("export partition %s: %w", p.PartitionId, err)
+		}
+
+		results = append(results, ExportResult{
+			PartitionId: p.PartitionId,
+			Rows:        p.Rows,
+			Bytes:       n,
+		})
+		slog.Debug("Exported partition", "table", opt.Table, "partition", p.PartitionId, "rows", p.Rows, "bytes", n)
+	}
+
+	return results, nil
+}
+
+// exportCondition returns the condition matching the rows to export and the
+// parameters it references. Tables without a time column are matched by the
+// creation time of the pipeline the rows belong to, if they have a
+// `pipeline_id` column.
+func exportCondition(opt ExportOptions, columns []string) (string, map[string]string, error) {
+	conditions := []string{"1"}
+	params := map[string]string{}
+
+	if opt.ProjectId != 0 {
+		column, ok := exportProjectColumns[opt.Table]
+		if !ok {
+			return "", nil, fmt.Errorf("table `%s` can't be filtered by project", opt.Table)
+		}
+		conditions = append(conditions, column+" = {project_id:Int64}")
+		params["project_id"] = strconv.FormatInt(opt.ProjectId, 10)
+	}
+
+	if !opt.Since.IsZero() || !opt.Until.IsZero() {
+		var timeConditions []string
+		expr, ok := retentionTimeExpressions[opt.Table]
+		if !ok {
+			expr = retentionTimeExpressions[PipelinesTable]
+		}
+		if !opt.Since.IsZero() {
+			timeConditions = append(timeConditions, fmt.Sprintf("%s >= toDateTime(%d)", expr, opt.Since.Unix()))
+		}
+		if !opt.Until.IsZero() {
+			timeConditions = append(timeConditions, fmt.Sprintf("%s < toDateTime(%d)", expr, opt.Until.Unix()))
+		}
+
+		if ok {
+			conditions = append(conditions, timeConditions...)
+		} else if !slices.Contains(columns, "pipeline_id") {
+			return "", nil, fmt.Errorf("table `%s` can't be filtered by time", opt.Table)
+		} else {
+			conditions = append(conditions, fmt.Sprintf(
+				"pipeline_id IN (SELECT id FROM {db:Identifier}.%s WHERE %s)",
+				PipelinesTable, strings.Join(timeConditions, " AND "),
+			))
+		}
+	}
+
+	return strings.Join(conditions, " AND "), params, nil
+}
+
+// selectExportFinal reports whether the engine of the table merges rows, so
+// that the latest version of the rows is selected using FINAL.
+func selectExportFinal(c *Client, ctx context.Context, table string) (bool, error) {
+	const query string = `
+        SELECT engine FROM system.tables
+        WHERE database = {db:String} AND name = {table:String}
+        `
+	var params = map[string]string{
+		"db":    c.dbName,
+		"table": table,
+	}
+
+	var results []struct {
+		Engine string `ch:"engine"`
+	}
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return false, err
+	} else if len(results) == 0 {
+		return false, fmt.Errorf("table `%s` not found", table)
+	}
+	return exportFinalEngine(results[0].Engine), nil
+}
+
+func exportFinalEngine(engine string) bool {
+	engine = strings.TrimPrefix(engine, "Replicated")
+	engine = strings.TrimPrefix(engine, "Shared")
+	return slices.Contains(exportFinalEngines, engine)
+}
+
+func exportFrom(final bool) string {
+	if !final {
+		return "{db:Identifier}.{table:Identifier}"
+	}
+	return "{db:Identifier}.{table:Identifier} FINAL"
+}
+
+func exportQuery(final bool, condition string, format string) string {
+	return fmt.Sprintf(
+		"SELECT * FROM %s WHERE _partition_id = {partition:String} AND %s FORMAT %s",
+		exportFrom(final), condition, format,
+	)
+}
+
+type exportPartition struct {
+	PartitionId string `ch:"partition_id"`
+	Rows        uint64 `ch:"rows"`
+}
+
+// selectExportPartitions returns the partitions containing rows to export
+// along with their number of rows.
+func selectExportPartitions(c *Client, ctx context.Context, final bool, condition string, params map[string]string) ([]exportPartition, error) {
+	query := fmt.Sprintf(`
+        SELECT _partition_id AS partition_id, count() AS rows
+        FROM %s
+        WHERE %s
+        GROUP BY partition_id
+        ORDER BY partition_id
+        `, exportFrom(final), condition)
+
+	var results []exportPartition
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
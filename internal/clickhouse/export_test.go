@@ -0,0 +1,128 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportCondition(t *testing.T) {
+	since := time.Unix(1717200000, 0)
+	until := time.Unix(1719792000, 0)
+
+	tests := []struct {
+		name      string
+		opt       ExportOptions
+		condition string
+		params    map[string]string
+	}{
+		{
+			name:      "unfiltered",
+			opt:       ExportOptions{Table: PipelinesTable},
+			condition: "1",
+			params:    map[string]string{},
+		},
+		{
+			name:      "project",
+			opt:       ExportOptions{Table: BridgesTable, ProjectId: 42},
+			condition: "1 AND pipeline.project_id = {project_id:Int64}",
+			params:    map[string]string{"project_id": "42"},
+		},
+		{
+			name:      "time range",
+			opt:       ExportOptions{Table: TraceSpansTable, Since: since, Until: until},
+			condition: "1 AND toDateTime(Timestamp) >= toDateTime(1717200000) AND toDateTime(Timestamp) < toDateTime(1719792000)",
+			params:    map[string]string{},
+		},
+		{
+			name:      "pipeline time",
+			opt:       ExportOptions{Table: TestCasesTable, ProjectId: 42, Since: since},
+			condition: "1 AND project_id = {project_id:Int64} AND pipeline_id IN (SELECT id FROM {db:Identifier}.pipelines WHERE toDateTime(created_at) >= toDateTime(1717200000))",
+			params:    map[string]string{"project_id": "42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition, params, err := exportCondition(tt.opt, []string{"project_id", "pipeline_id"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkQuery(t, tt.condition, condition)
+			checkParams(t, tt.params, params)
+		})
+	}
+}
+
+func TestExportCondition_NoProjectColumn(t *testing.T) {
+	if _, _, err := exportCondition(ExportOptions{Table: TimeSeriesTable, ProjectId: 42}, nil); err == nil {
+		t.Error("Expected error for table without project column")
+	}
+}
+
+func TestExportCondition_NoPipelineColumn(t *testing.T) {
+	opt := ExportOptions{Table: CoveragePackagesTable, Since: time.Unix(1717200000, 0)}
+	if _, _, err := exportCondition(opt, []string{"project_id", "report_id"}); err == nil {
+		t.Error("Expected error for table without time or pipeline column")
+	}
+}
+
+func TestExportFinalEngine(t *testing.T) {
+	for engine, want := range map[string]bool{
+		"ReplacingMergeTree":           true,
+		"ReplicatedReplacingMergeTree": true,
+		"SharedReplacingMergeTree":     true,
+		"MergeTree":                    false,
+		"ReplicatedMergeTree":          false,
+		"Null":                         false,
+	} {
+		if got := exportFinalEngine(engine); got != want {
+			t.Errorf("%s: expected %v, got %v", engine, want, got)
+		}
+	}
+}
+
+func TestExportQuery(t *testing.T) {
+	checkQuery(t,
+		"SELECT * FROM {db:Identifier}.{table:Identifier} FINAL WHERE _partition_id = {partition:String} AND 1 FORMAT Parquet",
+		exportQuery(true, "1", "Parquet"),
+	)
+	checkQuery(t,
+		"SELECT * FROM {db:Identifier}.{table:Identifier} WHERE _partition_id = {partition:String} AND 1 FORMAT JSONEachRow",
+		exportQuery(false, "1", "JSONEachRow"),
+	)
+}
+
+func TestHTTPClient_QueryTo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := io.ReadAll(r.Body)
+		if r.URL.Query().Get("param_partition") != "202406" || r.Header.Get("X-ClickHouse-User") != "default" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("Code: 62. DB::Exception: Syntax error"))
+			return
+		}
+		_, _ = w.Write(query)
+	}))
+	defer srv.Close()
+
+	c := &HTTPClient{url: srv.URL + "/", database: "default", user: "default", client: srv.Client()}
+
+	var buf bytes.Buffer
+	n, err := c.QueryTo(context.Background(), &buf, "SELECT 1", map[string]string{"partition": "202406"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 8 || buf.String() != "SELECT 1" {
+		t.Errorf("Expected echoed query, got %d bytes: %q", n, buf.String())
+	}
+
+	_, err = c.QueryTo(context.Background(), io.Discard, "SELECT 1", nil)
+	if err == nil || !strings.Contains(err.Error(), "Syntax error") {
+		t.Errorf("Expected server error, got %v", err)
+	}
+}
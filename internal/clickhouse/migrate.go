@@ -19,6 +19,8 @@ type MigrationOptions struct {
 
 	FileSystem fs.FS
 	Path       string
+
+	Schema SchemaOptions
 }
 
 var (
@@ -85,7 +87,8 @@ func NewMigration(opts MigrationOptions) (*migrate.Migrate, error) {
 	if opts.FileSystem == nil {
 		return nil, errors.New("missing migrations file system")
 	}
-	drv, err := iofs.New(opts.FileSystem, opts.Path)
+	fsys := NewSchemaTemplateFS(opts.FileSystem, opts.Schema)
+	drv, err := iofs.New(fsys, opts.Path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create source driver: %w", err)
 	}
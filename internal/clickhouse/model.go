@@ -1,5 +1,7 @@
 package clickhouse
 
+import "time"
+
 type Project struct {
 	Id          int64 `ch:"id"`
 	NamespaceId int64 `ch:"namespace_id"`
@@ -12,9 +14,9 @@ type Project struct {
 	Description string   `ch:"description"`
 	Topics      []string `ch:"topics"`
 
-	CreatedAt      float64 `ch:"created_at"`
-	UpdatedAt      float64 `ch:"updated_at"`
-	LastActivityAt float64 `ch:"last_activity_at"`
+	CreatedAt      time.Time `ch:"created_at"`
+	UpdatedAt      time.Time `ch:"updated_at"`
+	LastActivityAt time.Time `ch:"last_activity_at"`
 
 	JobArtifactsSize      int64 `ch:"job_artifacts_size"`
 	ContainerRegistrySize int64 `ch:"container_registry_size"`
@@ -51,11 +53,11 @@ type Pipeline struct {
 	Status        string `ch:"status"`
 	FailureReason string `ch:"failure_reason"`
 
-	CommittedAt float64 `ch:"committed_at"`
-	CreatedAt   float64 `ch:"created_at"`
-	UpdatedAt   float64 `ch:"updated_at"`
-	StartedAt   float64 `ch:"started_at"`
-	FinishedAt  float64 `ch:"finished_at"`
+	CommittedAt *time.Time `ch:"committed_at"`
+	CreatedAt   time.Time  `ch:"created_at"`
+	UpdatedAt   time.Time  `ch:"updated_at"`
+	StartedAt   *time.Time `ch:"started_at"`
+	FinishedAt  *time.Time `ch:"finished_at"`
 
 	QueuedDuration float64 `ch:"queued_duration"`
 	Duration       float64 `ch:"duration"`
@@ -89,9 +91,9 @@ type Issue struct {
 	Iid       int64 `ch:"iid"`
 	ProjectId int64 `ch:"project_id"`
 
-	CreatedAt float64 `ch:"created_at"`
-	UpdatedAt float64 `ch:"updated_at"`
-	ClosedAt  float64 `ch:"closed_at"`
+	CreatedAt time.Time  `ch:"created_at"`
+	UpdatedAt time.Time  `ch:"updated_at"`
+	ClosedAt  *time.Time `ch:"closed_at"`
 
 	Title  string   `ch:"title"`
 	Labels []string `ch:"labels"`
@@ -113,11 +115,11 @@ type Job struct {
 	FailureReason string `ch:"failure_reason"`
 	ExitCode      int64  `ch:"exit_code"`
 
-	CreatedAt  float64 `ch:"created_at"`
-	QueuedAt   float64 `ch:"queued_at"`
-	StartedAt  float64 `ch:"started_at"`
-	FinishedAt float64 `ch:"finished_at"`
-	ErasedAt   float64 `ch:"erased_at"`
+	CreatedAt  time.Time  `ch:"created_at"`
+	QueuedAt   *time.Time `ch:"queued_at"`
+	StartedAt  *time.Time `ch:"started_at"`
+	FinishedAt *time.Time `ch:"finished_at"`
+	ErasedAt   *time.Time `ch:"erased_at"`
 
 	QueuedDuration float64 `ch:"queued_duration"`
 	Duration       float64 `ch:"duration"`
@@ -152,8 +154,8 @@ type Section struct {
 
 	Name string `ch:"name"`
 
-	StartedAt  float64 `ch:"started_at"`
-	FinishedAt float64 `ch:"finished_at"`
+	StartedAt  time.Time `ch:"started_at"`
+	FinishedAt time.Time `ch:"finished_at"`
 
 	Duration float64 `ch:"duration"`
 
@@ -226,15 +228,44 @@ type Metric struct {
 	Timestamp int64             `ch:"timestamp"`
 }
 
+type MetricHistogram struct {
+	Id         string `ch:"id"`
+	Iid        int64  `ch:"iid"`
+	JobId      int64  `ch:"job_id"`
+	PipelineId int64  `ch:"pipeline_id"`
+	ProjectId  int64  `ch:"project_id"`
+
+	Name   string            `ch:"name"`
+	Labels map[string]string `ch:"labels"`
+
+	Count          uint64    `ch:"count"`
+	Sum            float64   `ch:"sum"`
+	Min            float64   `ch:"min"`
+	Max            float64   `ch:"max"`
+	BucketCounts   []uint64  `ch:"bucket_counts"`
+	ExplicitBounds []float64 `ch:"explicit_bounds"`
+
+	Timestamp int64 `ch:"timestamp"`
+}
+
+type TimeSeriesSample struct {
+	MetricName  string            `ch:"metric_name"`
+	Labels      map[string]string `ch:"labels"`
+	Fingerprint uint64            `ch:"fingerprint"`
+
+	Timestamp int64   `ch:"timestamp"`
+	Value     float64 `ch:"value"`
+}
+
 type MergeRequest struct {
 	Id        int64 `ch:"id"`
 	Iid       int64 `ch:"iid"`
 	ProjectId int64 `ch:"project_id"`
 
-	CreatedAt float64 `ch:"created_at"`
-	UpdatedAt float64 `ch:"updated_at"`
-	MergedAt  float64 `ch:"merged_at"`
-	ClosedAt  float64 `ch:"closed_at"`
+	CreatedAt time.Time  `ch:"created_at"`
+	UpdatedAt time.Time  `ch:"updated_at"`
+	MergedAt  *time.Time `ch:"merged_at"`
+	ClosedAt  *time.Time `ch:"closed_at"`
 
 	Name   string   `ch:"name"`
 	Title  string   `ch:"title"`
@@ -293,9 +324,9 @@ type MergeRequestNoteEvent struct {
 	MergeRequestIid       int64 `ch:"mergerequest_iid"`
 	MergeRequestProjectId int64 `ch:"mergerequest_project_id"`
 
-	CreatedAt  float64 `ch:"created_at"`
-	UpdatedAt  float64 `ch:"updated_at"`
-	ResolvedAt float64 `ch:"resolved_at"`
+	CreatedAt  time.Time  `ch:"created_at"`
+	UpdatedAt  time.Time  `ch:"updated_at"`
+	ResolvedAt *time.Time `ch:"resolved_at"`
 
 	Type     string `ch:"type"`
 	System   bool   `ch:"system"`
@@ -328,8 +359,8 @@ type CoverageReport struct {
 
 	Complexity float32 `ch:"complexity"`
 
-	Version   string `ch:"version"`
-	Timestamp int64  `ch:"timestamp"`
+	Version   string    `ch:"version"`
+	Timestamp time.Time `ch:"timestamp"`
 
 	SourcePaths []string `ch:"source_paths"`
 }
@@ -401,11 +432,34 @@ type Deployment struct {
 	TriggererUsername string `ch:"triggerer_username"`
 	TriggererName     string `ch:"triggerer_name"`
 
-	CreatedAt  float64 `ch:"created_at"`
-	FinishedAt float64 `ch:"finished_at"`
-	UpdatedAt  float64 `ch:"updated_at"`
+	CreatedAt  time.Time  `ch:"created_at"`
+	FinishedAt *time.Time `ch:"finished_at"`
+	UpdatedAt  time.Time  `ch:"updated_at"`
 
 	Status string `ch:"status"`
 	Ref    string `ch:"ref"`
 	Sha    string `ch:"sha"`
 }
+
+type Log struct {
+	Timestamp          time.Time         `ch:"Timestamp"`
+	TimestampTime      time.Time         `ch:"TimestampTime"`
+	TraceId            string            `ch:"TraceId"`
+	SpanId             string            `ch:"SpanId"`
+	TraceFlags         uint8             `ch:"TraceFlags"`
+	SeverityText       string            `ch:"SeverityText"`
+	SeverityNumber     uint8             `ch:"SeverityNumber"`
+	ServiceName        string            `ch:"ServiceName"`
+	Body               string            `ch:"Body"`
+	ResourceSchemaUrl  string            `ch:"ResourceSchemaUrl"`
+	ResourceAttributes map[string]string `ch:"ResourceAttributes"`
+	ScopeSchemaUrl     string            `ch:"ScopeSchemaUrl"`
+	ScopeName          string            `ch:"ScopeName"`
+	ScopeVersion       string            `ch:"ScopeVersion"`
+	ScopeAttributes    map[string]string `ch:"ScopeAttributes"`
+	LogAttributes      map[string]string `ch:"LogAttributes"`
+
+	ProjectId  int64 `ch:"ProjectId"`
+	PipelineId int64 `ch:"PipelineId"`
+	JobId      int64 `ch:"JobId"`
+}
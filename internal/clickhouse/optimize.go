@@ -0,0 +1,163 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+type AdaptiveDeduplicateOptions struct {
+	// Minimum number of active parts a partition must have to be considered
+	MinParts uint64
+	// Minimum estimated ratio of duplicate rows a partition must have to be
+	// optimized
+	MinDuplicateRatio float64
+	// Approximate number of rows per partition used to estimate duplicates,
+	// 0 means all. Rows are sampled by the hash of their deduplication key, so
+	// that duplicates are sampled along with each other.
+	SampleRows uint64
+}
+
+type PartitionDuplicates struct {
+	PartitionId string
+	Parts       uint64
+	// Number of rows and distinct deduplication keys, estimated from the
+	// sample if sampled
+	Rows uint64
+	Keys uint64
+	// Whether the partition has been optimized
+	Optimized bool
+}
+
+// Duplicates returns the (estimated) number of duplicate rows.
+func (p PartitionDuplicates) Duplicates() uint64 {
+	if p.Rows < p.Keys {
+		return 0
+	}
+	return p.Rows - p.Keys
+}
+
+// Ratio returns the estimated ratio of duplicate rows.
+func (p PartitionDuplicates) Ratio() float64 {
+	if p.Rows == 0 {
+		return 0
+	}
+	return float64(p.Duplicates()) / float64(p.Rows)
+}
+
+// AdaptiveDeduplicateTable deduplicates only those partitions of the table
+// that have enough parts and an estimated duplicate ratio above the given
+// thresholds. It returns the inspected partitions.
+func AdaptiveDeduplicateTable(ctx context.Context, opt DeduplicateTableOptions, aopt AdaptiveDeduplicateOptions, ch *Client) ([]PartitionDuplicates, error) {
+	if err := validateDeduplicateTableOptions(ctx, opt, ch); err != nil {
+		return nil, fmt.Errorf("error validating deduplication options: %w", err)
+	}
+
+	partitions, err := selectPartitions(ch, ctx, deduplicateDatabase(opt), opt.Table)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting partitions: %w", err)
+	}
+
+	var results []PartitionDuplicates
+	for _, p := range partitions {
+		if p.Parts < aopt.MinParts {
+			continue
+		}
+
+		d, err := estimatePartitionDuplicates(ctx, opt, p.PartitionId, sampleModulus(p.Rows, aopt.SampleRows), ch)
+		if err != nil {
+			return results, fmt.Errorf("error estimating duplicates of partition %s: %w", p.PartitionId, err)
+		}
+		d.Parts = p.Parts
+
+		if d.Rows > d.Keys && d.Ratio() >= aopt.MinDuplicateRatio {
+			popt := opt
+			popt.PartitionId = p.PartitionId
+			query, params := PrepareDeduplicateQuery(popt)
+			if err := ch.Exec(WithParameters(ctx, params), query); err != nil {
+				return results, fmt.Errorf("error optimizing partition %s: %w", p.PartitionId, err)
+			}
+			d.Optimized = true
+			slog.Debug("Optimized partition", "table", opt.Table, "partition", p.PartitionId, "ratio", d.Ratio())
+		}
+
+		results = append(results, d)
+	}
+
+	return results, nil
+}
+
+// estimatePartitionDuplicates counts the rows and the distinct deduplication
+// keys of the partition, from one in `modulus` keys if greater than 1.
+func estimatePartitionDuplicates(ctx context.Context, opt DeduplicateTableOptions, partitionId string, modulus uint64, ch *Client) (PartitionDuplicates, error) {
+	result := PartitionDuplicates{
+		PartitionId: partitionId,
+	}
+
+	query, params := PrepareDuplicateEstimateQuery(opt, partitionId, modulus)
+
+	var rows []struct {
+		Rows uint64 `ch:"rows"`
+		Keys uint64 `ch:"keys"`
+	}
+	if err := ch.Select(WithParameters(ctx, params), &rows, query); err != nil {
+		return result, err
+	}
+	if len(rows) > 0 {
+		result.Rows = rows[0].Rows * max(modulus, 1)
+		result.Keys = rows[0].Keys * max(modulus, 1)
+	}
+
+	return result, nil
+}
+
+// sampleModulus returns the modulus of the key hashes that samples about the
+// given number of rows from the partition rows, or 0 to count all rows.
+func sampleModulus(rows uint64, sampleRows uint64) uint64 {
+	if sampleRows == 0 || rows <= sampleRows {
+		return 0
+	}
+	return (rows + sampleRows - 1) / sampleRows
+}
+
+// PrepareDuplicateEstimateQuery returns a query that counts the rows and the
+// distinct deduplication keys of a partition. If the modulus is greater than
+// 1, only the rows whose key hashes to a multiple of it are read, so that
+// duplicates are sampled along with each other.
+func PrepareDuplicateEstimateQuery(opt DeduplicateTableOptions, partitionId string, modulus uint64) (string, map[string]string) {
+	params := map[string]string{
+		"database":  deduplicateDatabase(opt),
+		"table":     opt.Table,
+		"partition": partitionId,
+	}
+
+	key := deduplicateKey(opt)
+	query := fmt.Sprintf(
+		"SELECT count() AS rows, uniqExact(*) AS keys FROM (SELECT %s FROM {database:Identifier}.{table:Identifier} WHERE _partition_id = {partition:String}",
+		key,
+	)
+	if modulus > 1 {
+		query += fmt.Sprintf(" AND cityHash64(%s) %% %d = 0", key, modulus)
+	}
+	query += ")"
+
+	return query, params
+}
+
+// deduplicateKey returns the column expression rows are deduplicated by.
+func deduplicateKey(opt DeduplicateTableOptions) string {
+	if len(opt.By) > 0 {
+		return strings.Join(opt.By, ",")
+	} else if len(opt.Except) > 0 {
+		return "* EXCEPT (" + strings.Join(opt.Except, ",") + ")"
+	}
+	return "*"
+}
+
+func deduplicateDatabase(opt DeduplicateTableOptions) string {
+	if opt.Database == "" {
+		return "gitlab_ci"
+	}
+	return opt.Database
+}
@@ -0,0 +1,120 @@
+package clickhouse
+
+import (
+	"testing"
+)
+
+func TestPrepareDuplicateEstimateQuery_By(t *testing.T) {
+	opt := DeduplicateTableOptions{
+		Database: "gitlab_ci",
+		Table:    "pipelines",
+		By:       []string{"id", "updated_at"},
+	}
+
+	expectedQuery := "" +
+		"SELECT count() AS rows, uniqExact(*) AS keys FROM (" +
+		"SELECT id,updated_at FROM {database:Identifier}.{table:Identifier}" +
+		" WHERE _partition_id = {partition:String}" +
+		" AND cityHash64(id,updated_at) % 10 = 0)"
+	expectedParams := map[string]string{
+		"database":  "gitlab_ci",
+		"table":     "pipelines",
+		"partition": "202401",
+	}
+
+	query, params := PrepareDuplicateEstimateQuery(opt, "202401", 10)
+
+	checkQuery(t, expectedQuery, query)
+	checkParams(t, expectedParams, params)
+}
+
+func TestPrepareDuplicateEstimateQuery_Except(t *testing.T) {
+	opt := DeduplicateTableOptions{
+		Table:  "jobs",
+		Except: []string{"finished_at", "status"},
+	}
+
+	expectedQuery := "" +
+		"SELECT count() AS rows, uniqExact(*) AS keys FROM (" +
+		"SELECT * EXCEPT (finished_at,status) FROM {database:Identifier}.{table:Identifier}" +
+		" WHERE _partition_id = {partition:String})"
+	expectedParams := map[string]string{
+		"database":  "gitlab_ci",
+		"table":     "jobs",
+		"partition": "all",
+	}
+
+	query, params := PrepareDuplicateEstimateQuery(opt, "all", 0)
+
+	checkQuery(t, expectedQuery, query)
+	checkParams(t, expectedParams, params)
+}
+
+func TestPrepareDuplicateEstimateQuery_SampledExcept(t *testing.T) {
+	opt := DeduplicateTableOptions{
+		Table:  "jobs",
+		Except: []string{"finished_at", "status"},
+	}
+
+	expectedQuery := "" +
+		"SELECT count() AS rows, uniqExact(*) AS keys FROM (" +
+		"SELECT * EXCEPT (finished_at,status) FROM {database:Identifier}.{table:Identifier}" +
+		" WHERE _partition_id = {partition:String}" +
+		" AND cityHash64(* EXCEPT (finished_at,status)) % 4 = 0)"
+
+	query, _ := PrepareDuplicateEstimateQuery(opt, "all", 4)
+
+	checkQuery(t, expectedQuery, query)
+}
+
+func TestSampleModulus(t *testing.T) {
+	tests := []struct {
+		rows       uint64
+		sampleRows uint64
+		modulus    uint64
+	}{
+		{rows: 1000, sampleRows: 0, modulus: 0},
+		{rows: 1000, sampleRows: 1000, modulus: 0},
+		{rows: 1000, sampleRows: 100, modulus: 10},
+		{rows: 1001, sampleRows: 100, modulus: 11},
+	}
+
+	for _, tt := range tests {
+		if got := sampleModulus(tt.rows, tt.sampleRows); got != tt.modulus {
+			t.Errorf("Expected modulus %d for %d/%d, got %d", tt.modulus, tt.sampleRows, tt.rows, got)
+		}
+	}
+}
+
+func TestPrepareDeduplicateQuery_Partition(t *testing.T) {
+	opt := DeduplicateTableOptions{
+		Database:    "gitlab_ci",
+		Table:       "traces",
+		PartitionId: "20240101",
+	}
+
+	expectedQuery := "OPTIMIZE TABLE {database:Identifier}.{table:Identifier} PARTITION ID '20240101' FINAL DEDUPLICATE"
+
+	query, _ := PrepareDeduplicateQuery(opt)
+
+	checkQuery(t, expectedQuery, query)
+}
+
+func TestPartitionDuplicates_Ratio(t *testing.T) {
+	tests := []struct {
+		rows  uint64
+		keys  uint64
+		ratio float64
+	}{
+		{rows: 0, keys: 0, ratio: 0},
+		{rows: 100, keys: 100, ratio: 0},
+		{rows: 100, keys: 75, ratio: 0.25},
+	}
+
+	for _, tt := range tests {
+		d := PartitionDuplicates{Rows: tt.rows, Keys: tt.keys}
+		if got := d.Ratio(); got != tt.ratio {
+			t.Errorf("Expected ratio %v for %d/%d, got %v", tt.ratio, tt.keys, tt.rows, got)
+		}
+	}
+}
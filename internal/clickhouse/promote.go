@@ -0,0 +1,168 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// PromotedAttribute describes a span attribute that is stored in a dedicated
+// materialized column of the traces table, so that queries filtering on it
+// don't need to scan the attributes map.
+type PromotedAttribute struct {
+	// Attribute key, e.g. `ci.job.id`
+	Key string
+	// Column name, defaults to the key prefixed with `attr_` and with
+	// non-identifier characters replaced by underscores, e.g. `attr_ci_job_id`
+	Column string
+	// Column type, one of `String`, `LowCardinality(String)`, `Int64`,
+	// `Float64` or `Bool`, defaults to `String`
+	Type string
+	// Skip index type, one of `minmax`, `set(N)`, `bloom_filter`,
+	// `bloom_filter(P)`, `tokenbf_v1(...)`, `ngrambf_v1(...)` or `none`.
+	// Defaults to `bloom_filter(0.01)`, or `minmax` for `Float64` columns
+	Index string
+}
+
+// promotedAttributeIndexTypes are the patterns of the supported skip index
+// types, which are used verbatim in the index definition.
+var promotedAttributeIndexTypes = []*regexp.Regexp{
+	regexp.MustCompile(`^minmax$`),
+	regexp.MustCompile(`^set\(\d+\)$`),
+	regexp.MustCompile(`^bloom_filter(\((0|1|0?\.\d+)\))?$`),
+	regexp.MustCompile(`^tokenbf_v1\(\d+, ?\d+, ?\d+\)$`),
+	regexp.MustCompile(`^ngrambf_v1\(\d+, ?\d+, ?\d+, ?\d+\)$`),
+}
+
+// PromoteAttribute adds a materialized column and skip index for the span
+// attribute to the traces table. If materialize is true, the column and index
+// are also built for existing data parts, which may take a while.
+func PromoteAttribute(c *Client, ctx context.Context, attr PromotedAttribute, materialize bool) error {
+	queries, params, err := PreparePromoteAttributeQueries(attr, materialize)
+	if err != nil {
+		return fmt.Errorf("attribute `%s`: %w", attr.Key, err)
+	}
+	params["db"] = c.dbName
+
+	ctx = WithParameters(ctx, params)
+	for _, query := range queries {
+		if err := c.Exec(ctx, query); err != nil {
+			return fmt.Errorf("attribute `%s`: %w", attr.Key, err)
+		}
+	}
+	slog.Debug("Promoted span attribute", "key", attr.Key, "column", promotedAttributeColumn(attr))
+
+	return nil
+}
+
+// PreparePromoteAttributeQueries returns the queries that add the column and
+// skip index of the promoted attribute.
+func PreparePromoteAttributeQueries(attr PromotedAttribute, materialize bool) ([]string, map[string]string, error) {
+	if attr.Key == "" {
+		return nil, nil, fmt.Errorf("missing attribute key")
+	}
+
+	column := promotedAttributeColumn(attr)
+	if err := matchIdentifier(column); err != nil {
+		return nil, nil, err
+	}
+
+	typ := attr.Type
+	if typ == "" {
+		typ = "String"
+	}
+	expr, err := promotedAttributeExpression(attr.Key, typ)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := attr.Index
+	if index == "" {
+		index = "bloom_filter(0.01)"
+		if typ == "Float64" {
+			index = "minmax"
+		}
+	}
+	if err := matchIndexType(index); err != nil {
+		return nil, nil, err
+	}
+
+	params := map[string]string{
+		"table": TraceSpansTable,
+	}
+
+	const prefix string = "ALTER TABLE {db:Identifier}.{table:Identifier}"
+
+	queries := []string{
+		fmt.Sprintf("%s ADD COLUMN IF NOT EXISTS `%s` %s MATERIALIZED %s", prefix, column, typ, expr),
+	}
+	if materialize {
+		queries = append(queries, fmt.Sprintf("%s MATERIALIZE COLUMN `%s`", prefix, column))
+	}
+
+	if index != "none" {
+		queries = append(queries, fmt.Sprintf("%s ADD INDEX IF NOT EXISTS `idx_%s` `%s` TYPE %s GRANULARITY 1", prefix, column, column, index))
+		if materialize {
+			queries = append(queries, fmt.Sprintf("%s MATERIALIZE INDEX `idx_%s`", prefix, column))
+		}
+	}
+
+	return queries, params, nil
+}
+
+func promotedAttributeColumn(attr PromotedAttribute) string {
+	if attr.Column != "" {
+		return attr.Column
+	}
+
+	column := strings.Map(func(r rune) rune {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, attr.Key)
+	return "attr_" + column
+}
+
+func matchIndexType(index string) error {
+	if index == "none" {
+		return nil
+	}
+	for _, re := range promotedAttributeIndexTypes {
+		if re.MatchString(index) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported index type: `%s`", index)
+}
+
+// promotedAttributeExpression returns the expression that extracts the
+// attribute from the span attributes. Numeric values are read from the typed
+// attribute maps that `InsertTraces` fills, only values that have been sent
+// as strings are parsed.
+func promotedAttributeExpression(key string, typ string) (string, error) {
+	key = quoteString(key)
+	value := fmt.Sprintf("SpanAttributes[%s]", key)
+
+	switch typ {
+	case "String", "LowCardinality(String)":
+		return value, nil
+	case "Int64":
+		return fmt.Sprintf("if(mapContains(SpanAttributesInt, %[1]s), SpanAttributesInt[%[1]s], toInt64OrZero(%[2]s))", key, value), nil
+	case "Float64":
+		return fmt.Sprintf("if(mapContains(SpanAttributesFloat, %[1]s), SpanAttributesFloat[%[1]s], toFloat64OrZero(%[2]s))", key, value), nil
+	case "Bool":
+		return fmt.Sprintf("%s = 'true'", value), nil
+	default:
+		return "", fmt.Errorf("unsupported column type: `%s`", typ)
+	}
+}
+
+// quoteString returns the string as a single quoted SQL string literal.
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
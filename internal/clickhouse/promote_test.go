@@ -0,0 +1,82 @@
+package clickhouse
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPreparePromoteAttributeQueries(t *testing.T) {
+	const prefix string = "ALTER TABLE {db:Identifier}.{table:Identifier}"
+
+	tests := []struct {
+		name        string
+		attr        PromotedAttribute
+		materialize bool
+		want        []string
+	}{
+		{
+			name: "defaults",
+			attr: PromotedAttribute{Key: "ci.job.id"},
+			want: []string{
+				prefix + " ADD COLUMN IF NOT EXISTS `attr_ci_job_id` String MATERIALIZED SpanAttributes['ci.job.id']",
+				prefix + " ADD INDEX IF NOT EXISTS `idx_attr_ci_job_id` `attr_ci_job_id` TYPE bloom_filter(0.01) GRANULARITY 1",
+			},
+		},
+		{
+			name:        "typed",
+			attr:        PromotedAttribute{Key: "ci.pipeline.id", Column: "PipelineId", Type: "Int64", Index: "set(100)"},
+			materialize: true,
+			want: []string{
+				prefix + " ADD COLUMN IF NOT EXISTS `PipelineId` Int64 MATERIALIZED if(mapContains(SpanAttributesInt, 'ci.pipeline.id'), SpanAttributesInt['ci.pipeline.id'], toInt64OrZero(SpanAttributes['ci.pipeline.id']))",
+				prefix + " MATERIALIZE COLUMN `PipelineId`",
+				prefix + " ADD INDEX IF NOT EXISTS `idx_PipelineId` `PipelineId` TYPE set(100) GRANULARITY 1",
+				prefix + " MATERIALIZE INDEX `idx_PipelineId`",
+			},
+		},
+		{
+			name: "no index",
+			attr: PromotedAttribute{Key: "http.status'code", Type: "Float64", Index: "none"},
+			want: []string{
+				prefix + " ADD COLUMN IF NOT EXISTS `attr_http_status_code` Float64 MATERIALIZED if(mapContains(SpanAttributesFloat, 'http.status\\'code'), SpanAttributesFloat['http.status\\'code'], toFloat64OrZero(SpanAttributes['http.status\\'code']))",
+			},
+		},
+		{
+			name: "token index",
+			attr: PromotedAttribute{Key: "http.url", Type: "LowCardinality(String)", Index: "tokenbf_v1(32768, 3, 0)"},
+			want: []string{
+				prefix + " ADD COLUMN IF NOT EXISTS `attr_http_url` LowCardinality(String) MATERIALIZED SpanAttributes['http.url']",
+				prefix + " ADD INDEX IF NOT EXISTS `idx_attr_http_url` `attr_http_url` TYPE tokenbf_v1(32768, 3, 0) GRANULARITY 1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, params, err := PreparePromoteAttributeQueries(tt.attr, tt.materialize)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Mismatch (-want +got):\n%s", diff)
+			}
+			if params["table"] != TraceSpansTable {
+				t.Errorf("Expected table parameter `%s`, got `%s`", TraceSpansTable, params["table"])
+			}
+		})
+	}
+
+	invalid := []PromotedAttribute{
+		{},
+		{Key: "ci.job.id", Type: "UInt8"},
+		{Key: "ci.job.id", Column: "job id"},
+		{Key: "ci.job.id", Index: "hypothesis"},
+		{Key: "ci.job.id", Index: "minmax GRANULARITY 4, INDEX x y TYPE minmax"},
+		{Key: "ci.job.id", Index: "set(100)) GRANULARITY 1; DROP TABLE traces; --"},
+	}
+	for _, attr := range invalid {
+		if _, _, err := PreparePromoteAttributeQueries(attr, false); err == nil {
+			t.Errorf("Expected error for %+v", attr)
+		}
+	}
+}
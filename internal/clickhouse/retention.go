@@ -0,0 +1,363 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// retentionTimeExpressions maps tables to the expression that is used to
+// determine the age of a row.
+var retentionTimeExpressions = map[string]string{
+	BridgesTable:                "toDateTime(created_at)",
+	CoverageReportsTable:        "toDateTime(timestamp)",
+	DeploymentsTable:            "toDateTime(created_at)",
+	IssuesTable:                 "toDateTime(created_at)",
+	JobsTable:                   "toDateTime(created_at)",
+	LogsTable:                   "TimestampTime",
+	MergeRequestNoteEventsTable: "toDateTime(created_at)",
+	MergeRequestsTable:          "toDateTime(created_at)",
+	MetricsTable:                "toDateTime(fromUnixTimestamp64Milli(timestamp))",
+	MetricHistogramsTable:       "toDateTime(fromUnixTimestamp64Milli(timestamp))",
+	PipelinesTable:              "toDateTime(created_at)",
+	ProjectsTable:               "toDateTime(last_activity_at)",
+	SectionsTable:               "toDateTime(started_at)",
+	TimeSeriesTable:             "toDateTime(fromUnixTimestamp64Milli(timestamp))",
+	TraceSpansTable:             "toDateTime(Timestamp)",
+}
+
+const (
+	RetentionMethodDropPartitions string = "drop_partitions"
+	RetentionMethodTTL            string = "ttl"
+	RetentionMethodDelete         string = "delete"
+)
+
+type RetentionOptions struct {
+	Table  string
+	MaxAge time.Duration
+	DryRun bool
+}
+
+type RetentionResult struct {
+	Table  string
+	Method string
+
+	// Partitions that are (or would be) dropped
+	Partitions []Partition
+
+	// Number of rows and bytes that are (or would be) removed. The bytes are
+	// estimated if rows are removed using a TTL or delete.
+	Rows  uint64
+	Bytes uint64
+}
+
+type Partition struct {
+	Partition   string    `ch:"partition"`
+	PartitionId string    `ch:"partition_id"`
+	Parts       uint64    `ch:"parts"`
+	Rows        uint64    `ch:"rows"`
+	Bytes       uint64    `ch:"bytes"`
+	MinTime     time.Time `ch:"min_time"`
+	MaxTime     time.Time `ch:"max_time"`
+}
+
+// SelectTablePartitions returns the active partitions of the table along with
+// their number of parts, sizes and the time ranges of the values of the date
+// or time column of the partition key, e.g. of `created_at` for a key of
+// `toStartOfMonth(created_at)`, with dates ranging to their end. The time
+// range is zero if the partition key has no such column.
+func SelectTablePartitions(c *Client, ctx context.Context, table string) ([]Partition, error) {
+	return selectPartitions(c, ctx, c.dbName, table)
+}
+
+func selectPartitions(c *Client, ctx context.Context, database string, table string) ([]Partition, error) {
+	const query string = `
+        SELECT
+            partition,
+            partition_id,
+            count() AS parts,
+            sum(rows) AS rows,
+            sum(bytes_on_disk) AS bytes,
+            if(max(max_date) > 0, toDateTime(min(min_date)), min(min_time)) AS min_time,
+            if(max(max_date) > 0, toDateTime(max(max_date)) + 86399, max(max_time)) AS max_time
+        FROM system.parts
+        WHERE database = {db:String} AND table = {table:String} AND active
+        GROUP BY partition, partition_id
+        ORDER BY partition
+        `
+	var params = map[string]string{
+		"db":    database,
+		"table": table,
+	}
+
+	ctx = WithParameters(ctx, params)
+
+	var results []Partition
+	if err := c.Select(ctx, &results, query); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+type partitionBounds struct {
+	PartitionId string    `ch:"partition_id"`
+	MinTime     time.Time `ch:"min_time"`
+	MaxTime     time.Time `ch:"max_time"`
+}
+
+// selectPartitionBounds returns the time range of the rows of the partitions
+// of the table with the given ids, as determined by its retention time
+// expression. It reads the rows of the partitions, and is only used for the
+// partitions whose time ranges are not known from `system.parts`.
+func selectPartitionBounds(c *Client, ctx context.Context, table string, partitionIds []string) ([]partitionBounds, error) {
+	var params = map[string]string{
+		"db":         c.dbName,
+		"table":      table,
+		"partitions": formatStringArray(partitionIds),
+	}
+	ctx = WithParameters(ctx, params)
+
+	var results []partitionBounds
+	if err := c.Select(ctx, &results, partitionBoundsQuery(retentionTimeExpressions[table])); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func partitionBoundsQuery(expr string) string {
+	return fmt.Sprintf(`
+        SELECT
+            _partition_id AS partition_id,
+            min(%[1]s) AS min_time,
+            max(%[1]s) AS max_time
+        FROM {db:Identifier}.{table:Identifier}
+        WHERE _partition_id IN {partitions:Array(String)}
+        GROUP BY partition_id
+        `, expr)
+}
+
+// unboundedPartitions returns the ids of the partitions whose time ranges are
+// not known from `system.parts`, which are all of them if the partition key
+// doesn't contain the column of the retention time expression.
+func unboundedPartitions(partitions []Partition, partitionKey string, expr string) []string {
+	column := regexp.MustCompile(`\b` + regexp.QuoteMeta(timeColumn(expr)) + `\b`)
+	keyed := column.MatchString(partitionKey)
+
+	var ids []string
+	for _, p := range partitions {
+		if !keyed || p.MaxTime.Unix() <= 0 {
+			ids = append(ids, p.PartitionId)
+		}
+	}
+	return ids
+}
+
+// timeColumn returns the column of a retention time expression.
+func timeColumn(expr string) string {
+	if i := strings.LastIndexByte(expr, '('); i >= 0 {
+		expr = expr[i+1:]
+	}
+	return strings.TrimRight(expr, ")")
+}
+
+// withPartitionBounds sets the time ranges of the partitions with bounds to
+// the ones of their rows.
+func withPartitionBounds(partitions []Partition, bounds []partitionBounds) []Partition {
+	byId := make(map[string]partitionBounds, len(bounds))
+	for _, b := range bounds {
+		byId[b.PartitionId] = b
+	}
+
+	result := make([]Partition, 0, len(partitions))
+	for _, p := range partitions {
+		if b, ok := byId[p.PartitionId]; ok {
+			p.MinTime, p.MaxTime = b.MinTime, b.MaxTime
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// selectPartitionKey returns the partition key of the table, which is empty if
+// the table is not partitioned.
+func selectPartitionKey(c *Client, ctx context.Context, table string) (string, error) {
+	const query string = `
+        SELECT partition_key FROM system.tables
+        WHERE database = {db:String} AND name = {table:String}
+        `
+	var params = map[string]string{
+		"db":    c.dbName,
+		"table": table,
+	}
+	ctx = WithParameters(ctx, params)
+
+	var results []struct {
+		PartitionKey string `ch:"partition_key"`
+	}
+	if err := c.Select(ctx, &results, query); err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "", fmt.Errorf("table does not exist")
+	}
+	return results[0].PartitionKey, nil
+}
+
+// ApplyRetention removes rows from the table that are older than the maximum
+// age. Partitions whose rows have all expired are dropped if the table is
+// partitioned and has a time column, otherwise a TTL is set or, for tables
+// without a time column, rows of expired pipelines are deleted.
+func ApplyRetention(c *Client, ctx context.Context, opt RetentionOptions) (RetentionResult, error) {
+	result := RetentionResult{
+		Table: opt.Table,
+	}
+
+	if err := matchIdentifier(opt.Table); err != nil {
+		return result, err
+	}
+	if opt.MaxAge <= 0 {
+		return result, fmt.Errorf("invalid max age: %v", opt.MaxAge)
+	}
+
+	cutoff := time.Now().Add(-opt.MaxAge)
+
+	partitions, err := SelectTablePartitions(c, ctx, opt.Table)
+	if err != nil {
+		return result, fmt.Errorf("select partitions: %w", err)
+	}
+
+	var totalRows, totalBytes uint64
+	for _, p := range partitions {
+		totalRows += p.Rows
+		totalBytes += p.Bytes
+	}
+
+	estimateBytes := func(rows uint64) uint64 {
+		if totalRows == 0 {
+			return 0
+		}
+		return uint64(float64(totalBytes) * float64(rows) / float64(totalRows))
+	}
+
+	_, hasTime := retentionTimeExpressions[opt.Table]
+	partitionKey, err := selectPartitionKey(c, ctx, opt.Table)
+	if err != nil {
+		return result, fmt.Errorf("select partition key: %w", err)
+	}
+
+	params := map[string]string{
+		"db":    c.dbName,
+		"table": opt.Table,
+	}
+	ctx = WithParameters(ctx, params)
+
+	if hasTime && partitionKey != "" {
+		unbounded := unboundedPartitions(partitions, partitionKey, retentionTimeExpressions[opt.Table])
+		if len(unbounded) > 0 {
+			bounds, err := selectPartitionBounds(c, ctx, opt.Table, unbounded)
+			if err != nil {
+				return result, fmt.Errorf("select partition bounds: %w", err)
+			}
+			partitions = withPartitionBounds(partitions, bounds)
+		}
+
+		result.Method = RetentionMethodDropPartitions
+		result.Partitions = expiredPartitions(partitions, cutoff)
+		for _, p := range result.Partitions {
+			result.Rows += p.Rows
+			result.Bytes += p.Bytes
+		}
+
+		if opt.DryRun {
+			return result, nil
+		}
+
+		for _, p := range result.Partitions {
+			query := fmt.Sprintf("ALTER TABLE {db:Identifier}.{table:Identifier} DROP PARTITION ID '%s'", p.PartitionId)
+			if err := c.Exec(ctx, query); err != nil {
+				return result, fmt.Errorf("drop partition %s: %w", p.Partition, err)
+			}
+			slog.Debug("Dropped partition", "table", opt.Table, "partition", p.Partition, "rows", p.Rows, "bytes", p.Bytes)
+		}
+		return result, nil
+	}
+
+	var condition string
+	result.Method, condition = retentionCondition(opt.Table, cutoff)
+
+	var counts []struct {
+		Rows uint64 `ch:"rows"`
+	}
+	query := "SELECT count() AS rows FROM {db:Identifier}.{table:Identifier} WHERE " + condition
+	if err := c.Select(ctx, &counts, query); err != nil {
+		return result, fmt.Errorf("count expired rows: %w", err)
+	}
+	if len(counts) > 0 {
+		result.Rows = counts[0].Rows
+		result.Bytes = estimateBytes(result.Rows)
+	}
+
+	if opt.DryRun {
+		return result, nil
+	}
+
+	switch result.Method {
+	case RetentionMethodTTL:
+		modified, err := modifyTTL(c, ctx, opt.Table, retentionTTL(opt.Table, opt.MaxAge))
+		if err != nil {
+			return result, fmt.Errorf("apply %s: %w", result.Method, err)
+		}
+		if modified {
+			slog.Debug("Applied retention", "table", opt.Table, "method", result.Method, "rows", result.Rows)
+		}
+		return result, nil
+	case RetentionMethodDelete:
+		if result.Rows == 0 {
+			return result, nil
+		}
+		query = "DELETE FROM {db:Identifier}.{table:Identifier} WHERE " + condition
+	}
+
+	if err := c.Exec(ctx, query); err != nil {
+		return result, fmt.Errorf("apply %s: %w", result.Method, err)
+	}
+	slog.Debug("Applied retention", "table", opt.Table, "method", result.Method, "rows", result.Rows)
+
+	return result, nil
+}
+
+// expiredPartitions returns the partitions whose rows are all older than the
+// cutoff.
+func expiredPartitions(partitions []Partition, cutoff time.Time) []Partition {
+	var expired []Partition
+	for _, p := range partitions {
+		if p.MaxTime.Unix() <= 0 || !p.MaxTime.Before(cutoff) {
+			continue
+		}
+		expired = append(expired, p)
+	}
+	return expired
+}
+
+// retentionCondition returns the retention method and the condition matching
+// expired rows of the given table. Tables without a time column are matched
+// by the creation time of the pipeline the rows belong to.
+func retentionCondition(table string, cutoff time.Time) (string, string) {
+	if expr, ok := retentionTimeExpressions[table]; ok {
+		return RetentionMethodTTL, fmt.Sprintf("%s < toDateTime(%d)", expr, cutoff.Unix())
+	}
+	return RetentionMethodDelete, fmt.Sprintf(
+		"pipeline_id IN (SELECT id FROM {db:Identifier}.%s WHERE %s < toDateTime(%d))",
+		PipelinesTable, retentionTimeExpressions[PipelinesTable], cutoff.Unix(),
+	)
+}
+
+// retentionTTL returns the TTL for the maximum age, formatted like ClickHouse
+// formats the TTLs of tables, so that it can be compared to the current one.
+func retentionTTL(table string, maxAge time.Duration) string {
+	return fmt.Sprintf("%s + toIntervalSecond(%d)", retentionTimeExpressions[table], int64(maxAge.Seconds()))
+}
@@ -0,0 +1,194 @@
+package clickhouse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExpiredPartitions(t *testing.T) {
+	cutoff := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+
+	partitions := []Partition{
+		{
+			PartitionId: "20240401",
+			MinTime:     time.Date(2024, 4, 2, 8, 0, 0, 0, time.UTC),
+			MaxTime:     time.Date(2024, 4, 30, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			// only partially expired
+			PartitionId: "20240501",
+			MinTime:     time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC),
+			MaxTime:     time.Date(2024, 5, 28, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			// expired right before the cutoff
+			PartitionId: "20240502",
+			MinTime:     time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC),
+			MaxTime:     time.Date(2024, 5, 19, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			// the last row is at the cutoff
+			PartitionId: "20240503",
+			MinTime:     time.Date(2024, 5, 3, 8, 0, 0, 0, time.UTC),
+			MaxTime:     cutoff,
+		},
+		{
+			PartitionId: "20240601",
+			MinTime:     time.Date(2024, 6, 3, 8, 0, 0, 0, time.UTC),
+			MaxTime:     time.Date(2024, 6, 28, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			// without bounds
+			PartitionId: "20240701",
+		},
+	}
+
+	var got []string
+	for _, p := range expiredPartitions(partitions, cutoff) {
+		got = append(got, p.PartitionId)
+	}
+	expected := []string{"20240401", "20240502"}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("expiredPartitions() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestUnboundedPartitions(t *testing.T) {
+	partitions := []Partition{
+		{PartitionId: "20240401", MaxTime: time.Date(2024, 4, 30, 18, 0, 0, 0, time.UTC)},
+		{PartitionId: "20240501"},
+	}
+
+	tests := []struct {
+		name         string
+		partitionKey string
+		expr         string
+		expected     []string
+	}{
+		{
+			name:         "keyed by time column",
+			partitionKey: "toStartOfMonth(created_at)",
+			expr:         retentionTimeExpressions[PipelinesTable],
+			expected:     []string{"20240501"},
+		},
+		{
+			name:         "keyed by other column",
+			partitionKey: "toStartOfMonth(created_at)",
+			expr:         retentionTimeExpressions[ProjectsTable],
+			expected:     []string{"20240401", "20240501"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unboundedPartitions(partitions, tt.partitionKey, tt.expr)
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("unboundedPartitions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithPartitionBounds(t *testing.T) {
+	partitions := []Partition{
+		{PartitionId: "20240401", MaxTime: time.Date(2024, 4, 30, 18, 0, 0, 0, time.UTC)},
+		{PartitionId: "20240501"},
+	}
+	bounds := []partitionBounds{
+		{
+			PartitionId: "20240501",
+			MinTime:     time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC),
+			MaxTime:     time.Date(2024, 5, 28, 18, 0, 0, 0, time.UTC),
+		},
+	}
+
+	got := withPartitionBounds(partitions, bounds)
+	if !got[0].MaxTime.Equal(partitions[0].MaxTime) {
+		t.Errorf("expected the time range of partition 20240401 to be kept, got %v", got[0].MaxTime)
+	}
+	if !got[1].MinTime.Equal(bounds[0].MinTime) || !got[1].MaxTime.Equal(bounds[0].MaxTime) {
+		t.Errorf("expected the time range of the rows of partition 20240501, got %v - %v", got[1].MinTime, got[1].MaxTime)
+	}
+}
+
+func TestPartitionBoundsQuery(t *testing.T) {
+	expected := `
+        SELECT
+            _partition_id AS partition_id,
+            min(toDateTime(created_at)) AS min_time,
+            max(toDateTime(created_at)) AS max_time
+        FROM {db:Identifier}.{table:Identifier}
+        WHERE _partition_id IN {partitions:Array(String)}
+        GROUP BY partition_id
+        `
+	checkQuery(t, expected, partitionBoundsQuery(retentionTimeExpressions[PipelinesTable]))
+}
+
+func TestRetentionCondition(t *testing.T) {
+	cutoff := time.Unix(1717200000, 0)
+
+	tests := []struct {
+		table     string
+		method    string
+		condition string
+	}{
+		{
+			table:     TraceSpansTable,
+			method:    RetentionMethodTTL,
+			condition: "toDateTime(Timestamp) < toDateTime(1717200000)",
+		},
+		{
+			table:     MetricsTable,
+			method:    RetentionMethodTTL,
+			condition: "toDateTime(fromUnixTimestamp64Milli(timestamp)) < toDateTime(1717200000)",
+		},
+		{
+			table:     TestCasesTable,
+			method:    RetentionMethodDelete,
+			condition: "pipeline_id IN (SELECT id FROM {db:Identifier}.pipelines WHERE toDateTime(created_at) < toDateTime(1717200000))",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			method, condition := retentionCondition(tt.table, cutoff)
+			if method != tt.method {
+				t.Errorf("expected method %q, got %q", tt.method, method)
+			}
+			checkQuery(t, tt.condition, condition)
+		})
+	}
+}
+
+func TestRetentionTTL(t *testing.T) {
+	got := retentionTTL(TraceSpansTable, 30*24*time.Hour)
+	checkQuery(t, "toDateTime(Timestamp) + toIntervalSecond(2592000)", got)
+}
+
+func TestEngineTTL(t *testing.T) {
+	tests := []struct {
+		engine   string
+		expected string
+	}{
+		{
+			engine:   "MergeTree PARTITION BY toDate(Timestamp) ORDER BY (ServiceName, SpanName) TTL toDateTime(Timestamp) + toIntervalSecond(2592000) SETTINGS index_granularity = 8192",
+			expected: "toDateTime(Timestamp) + toIntervalSecond(2592000)",
+		},
+		{
+			engine:   "ReplacingMergeTree(updated_at) ORDER BY id TTL toDateTime(created_at) + toIntervalDay(30)",
+			expected: "toDateTime(created_at) + toIntervalDay(30)",
+		},
+		{
+			engine:   "ReplacingMergeTree(updated_at) ORDER BY id SETTINGS index_granularity = 8192",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := engineTTL(tt.engine); got != tt.expected {
+			t.Errorf("expected ttl %q, got %q", tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"text/template"
+
+	"golang.org/x/exp/slices"
+)
+
+type SchemaOptions struct {
+	Tables map[string]TableOptions
+	// Span attributes to store in dedicated columns of the traces table
+	PromotedAttributes []PromotedAttribute
+}
+
+type TableOptions struct {
+	// TTL expression, e.g. `toDateTime(created_at) + INTERVAL 1 YEAR`
+	TTL string
+	// Name of the storage policy to use for the table
+	StoragePolicy string
+	// Partition granularity, one of `none`, `day`, `week`, `month`, `year`
+	PartitionGranularity string
+	// Additional table settings, values are used verbatim
+	Settings map[string]string
+	// Column compression codecs, e.g. `ZSTD(3)`. Codecs are applied to the
+	// existing tables, so that they apply to new parts only, and to existing
+	// parts once they are merged.
+	Codecs map[string]string
+}
+
+// readonlySettings are the table settings that can only be set when the table
+// is created, in the `SETTINGS` clause of the migrations.
+var readonlySettings = []string{
+	"index_granularity",
+	"index_granularity_bytes",
+	"enable_mixed_granularity_parts",
+}
+
+// NewSchemaTemplateFS returns a file system that renders the `*.sql` files of
+// fsys as templates using the given schema options.
+func NewSchemaTemplateFS(fsys fs.FS, opts SchemaOptions) fs.FS {
+	return &templateFS{
+		fsys:  fsys,
+		funcs: opts.templateFuncs(),
+	}
+}
+
+type templateFS struct {
+	fsys  fs.FS
+	funcs template.FuncMap
+}
+
+func (t *templateFS) Open(name string) (fs.File, error) {
+	f, err := t.fsys.Open(name)
+	if err != nil || !strings.HasSuffix(name, ".sql") {
+		return f, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	tpl, err := template.New(name).Funcs(t.funcs).Parse(string(data))
+	if err != nil {
+		return nil, &fs.PathError{Op: "parse", Path: name, Err: err}
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, nil); err != nil {
+		return nil, &fs.PathError{Op: "render", Path: name, Err: err}
+	}
+
+	return &renderedFile{
+		Reader: bytes.NewReader(buf.Bytes()),
+		info:   renderedFileInfo{FileInfo: info, size: int64(buf.Len())},
+	}, nil
+}
+
+type renderedFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *renderedFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *renderedFile) Close() error               { return nil }
+
+type renderedFileInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i renderedFileInfo) Size() int64 { return i.size }
+
+func (o SchemaOptions) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"partitionBy": o.partitionByClause,
+		"ttl":         o.ttlClause,
+		"settings":    o.settingsClause,
+		"codec":       o.codecClause,
+	}
+}
+
+// partitionByClause renders the `PARTITION BY` clause for the given table
+// using the configured granularity or the default one.
+func (o SchemaOptions) partitionByClause(table string, column string, defaultGranularity string) (string, error) {
+	granularity := o.Tables[table].PartitionGranularity
+	if granularity == "" {
+		granularity = defaultGranularity
+	}
+
+	expr, err := partitionExpression(granularity, column)
+	if err != nil {
+		return "", fmt.Errorf("table `%s`: %w", table, err)
+	} else if expr == "" {
+		return "", nil
+	}
+	return "PARTITION BY " + expr, nil
+}
+
+func partitionExpression(granularity string, column string) (string, error) {
+	switch granularity {
+	case "", "none":
+		return "", nil
+	case "day":
+		return fmt.Sprintf("toDate(%s)", column), nil
+	case "week":
+		return fmt.Sprintf("toMonday(%s)", column), nil
+	case "month":
+		return fmt.Sprintf("toStartOfMonth(%s)", column), nil
+	case "year":
+		return fmt.Sprintf("toStartOfYear(%s)", column), nil
+	default:
+		return "", fmt.Errorf("invalid partition granularity: `%s`", granularity)
+	}
+}
+
+// ttlClause renders the `TTL` clause for the given table, if any.
+func (o SchemaOptions) ttlClause(table string) string {
+	ttl := o.Tables[table].TTL
+	if ttl == "" {
+		return ""
+	}
+	return "TTL " + ttl
+}
+
+// codecClause renders the `CODEC` clause for the given column, if any.
+func (o SchemaOptions) codecClause(table string, column string) string {
+	codec := o.Tables[table].Codecs[column]
+	if codec == "" {
+		return ""
+	}
+	return "CODEC(" + codec + ")"
+}
+
+// settingsClause renders the `SETTINGS` clause for the given table. The
+// defaults are given as key value pairs and may be overridden by the
+// configured settings.
+func (o SchemaOptions) settingsClause(table string, defaults ...string) (string, error) {
+	if len(defaults)%2 != 0 {
+		return "", fmt.Errorf("table `%s`: odd number of default settings", table)
+	}
+
+	var keys []string
+	values := map[string]string{}
+	set := func(k string, v string) {
+		if _, ok := values[k]; !ok {
+			keys = append(keys, k)
+		}
+		values[k] = v
+	}
+
+	for i := 0; i < len(defaults); i += 2 {
+		set(defaults[i], defaults[i+1])
+	}
+	for _, k := range o.Tables[table].settingKeys() {
+		set(k, o.Tables[table].settingValue(k))
+	}
+
+	if len(keys) == 0 {
+		return "", nil
+	}
+
+	settings := make([]string, 0, len(keys))
+	for _, k := range keys {
+		settings = append(settings, fmt.Sprintf("%s = %s", k, values[k]))
+	}
+	return "SETTINGS " + strings.Join(settings, ", "), nil
+}
+
+// settingKeys returns the names of the configured table settings, including
+// the storage policy, in a stable order.
+func (t TableOptions) settingKeys() []string {
+	keys := make([]string, 0, len(t.Settings))
+	for k := range t.Settings {
+		if k == "storage_policy" && t.StoragePolicy != "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	if t.StoragePolicy != "" {
+		keys = append([]string{"storage_policy"}, keys...)
+	}
+	return keys
+}
+
+func (t TableOptions) settingValue(key string) string {
+	if key == "storage_policy" && t.StoragePolicy != "" {
+		return "'" + t.StoragePolicy + "'"
+	}
+	return t.Settings[key]
+}
+
+// ApplySchemaOptions modifies existing tables to match the given schema
+// options. Partitioning cannot be changed for existing tables, a mismatch is
+// only reported.
+func ApplySchemaOptions(c *Client, ctx context.Context, opts SchemaOptions) error {
+	tables := make([]string, 0, len(opts.Tables))
+	for table := range opts.Tables {
+		tables = append(tables, table)
+	}
+	slices.Sort(tables)
+
+	for _, table := range tables {
+		if err := applyTableOptions(c, ctx, table, opts.Tables[table]); err != nil {
+			return fmt.Errorf("table `%s`: %w", table, err)
+		}
+	}
+
+	for _, attr := range opts.PromotedAttributes {
+		if err := PromoteAttribute(c, ctx, attr, false); err != nil {
+			return fmt.Errorf("table `%s`: %w", TraceSpansTable, err)
+		}
+	}
+	return nil
+}
+
+func applyTableOptions(c *Client, ctx context.Context, table string, opts TableOptions) error {
+	if err := matchIdentifier(table); err != nil {
+		return err
+	}
+
+	params := map[string]string{
+		"db":    c.dbName,
+		"table": table,
+	}
+	ctx = WithParameters(ctx, params)
+
+	const prefix string = "ALTER TABLE {db:Identifier}.{table:Identifier}"
+
+	if opts.TTL != "" {
+		modified, err := modifyTTL(c, ctx, table, opts.TTL)
+		if err != nil {
+			return fmt.Errorf("modify ttl: %w", err)
+		}
+		if modified {
+			slog.Debug("Modified table ttl", "table", table, "ttl", opts.TTL)
+		}
+	}
+
+	if settings := opts.modifiableSettings(); len(settings) > 0 {
+		if err := c.Exec(ctx, prefix+" MODIFY SETTING "+strings.Join(settings, ", ")); err != nil {
+			return fmt.Errorf("modify settings: %w", err)
+		}
+		slog.Debug("Modified table settings", "table", table, "settings", settings)
+	}
+
+	columns := make([]string, 0, len(opts.Codecs))
+	for column := range opts.Codecs {
+		columns = append(columns, column)
+	}
+	slices.Sort(columns)
+	for _, column := range columns {
+		if err := matchIdentifier(column); err != nil {
+			return err
+		}
+		query := fmt.Sprintf("%s MODIFY COLUMN `%s` CODEC(%s)", prefix, column, opts.Codecs[column])
+		if err := c.Exec(ctx, query); err != nil {
+			return fmt.Errorf("modify column `%s` codec: %w", column, err)
+		}
+		slog.Debug("Modified column codec", "table", table, "column", column, "codec", opts.Codecs[column])
+	}
+
+	if opts.PartitionGranularity != "" {
+		if err := checkPartitionGranularity(c, ctx, table, opts.PartitionGranularity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// modifyTTL sets the TTL of the table unless it is set already, since every
+// modification starts a mutation that materializes the TTL in all parts. The
+// context must have the `db` and `table` identifier parameters.
+func modifyTTL(c *Client, ctx context.Context, table string, ttl string) (bool, error) {
+	current, err := selectTableTTL(c, ctx, table)
+	if err != nil {
+		return false, fmt.Errorf("select ttl: %w", err)
+	}
+	if normalizeSpace(current) == normalizeSpace(ttl) {
+		return false, nil
+	}
+
+	if err := c.Exec(ctx, "ALTER TABLE {db:Identifier}.{table:Identifier} MODIFY TTL "+ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// selectTableTTL returns the TTL of the table as formatted by ClickHouse,
+// which is empty if the table has none.
+func selectTableTTL(c *Client, ctx context.Context, table string) (string, error) {
+	const query string = `
+        SELECT engine_full FROM system.tables
+        WHERE database = {db:String} AND name = {table:String}
+        `
+	var params = map[string]string{
+		"db":    c.dbName,
+		"table": table,
+	}
+
+	var results []struct {
+		EngineFull string `ch:"engine_full"`
+	}
+	if err := c.Select(WithParameters(ctx, params), &results, query); err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "", fmt.Errorf("table does not exist")
+	}
+	return engineTTL(results[0].EngineFull), nil
+}
+
+// engineTTL returns the TTL of a full table engine definition, which precedes
+// the table settings.
+func engineTTL(engine string) string {
+	i := strings.Index(engine, " TTL ")
+	if i < 0 {
+		return ""
+	}
+	ttl := engine[i+len(" TTL "):]
+	if j := strings.Index(ttl, " SETTINGS "); j >= 0 {
+		ttl = ttl[:j]
+	}
+	return strings.TrimSpace(ttl)
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// modifiableSettings returns the configured table settings that can be
+// modified on existing tables, as key value assignments.
+func (t TableOptions) modifiableSettings() []string {
+	var settings []string
+	for _, k := range t.settingKeys() {
+		if slices.Contains(readonlySettings, k) {
+			continue
+		}
+		settings = append(settings, fmt.Sprintf("%s = %s", k, t.settingValue(k)))
+	}
+	return settings
+}
+
+func checkPartitionGranularity(c *Client, ctx context.Context, table string, granularity string) error {
+	key, err := selectPartitionKey(c, ctx, table)
+	if err != nil {
+		return fmt.Errorf("get partition key: %w", err)
+	}
+
+	expected, err := partitionExpression(granularity, "")
+	if err != nil {
+		return err
+	}
+	// compare the partitioning function only, i.e. `toDate(` for `day`
+	expected = strings.TrimSuffix(expected, ")")
+
+	if (expected == "" && key != "") || !strings.HasPrefix(key, expected) {
+		slog.Warn("Partition granularity of existing table cannot be changed",
+			"table", table,
+			"partition_key", key,
+			"granularity", granularity,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package clickhouse
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testMigration string = `CREATE TABLE IF NOT EXISTS traces (
+    Timestamp DateTime64(9) {{ codec "traces" "Timestamp" }}
+) ENGINE MergeTree()
+{{ partitionBy "traces" "Timestamp" "day" }}
+ORDER BY Timestamp
+{{ ttl "traces" }}
+{{ settings "traces" "index_granularity" "8192" }}
+;
+`
+
+func renderTestMigration(t *testing.T, opts SchemaOptions) string {
+	fsys := NewSchemaTemplateFS(fstest.MapFS{
+		"000001_create_traces.up.sql": &fstest.MapFile{Data: []byte(testMigration)},
+	}, opts)
+
+	data, err := fs.ReadFile(fsys, "000001_create_traces.up.sql")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return string(data)
+}
+
+func TestSchemaTemplate_Defaults(t *testing.T) {
+	expected := `CREATE TABLE IF NOT EXISTS traces (
+    Timestamp DateTime64(9) 
+) ENGINE MergeTree()
+PARTITION BY toDate(Timestamp)
+ORDER BY Timestamp
+
+SETTINGS index_granularity = 8192
+;
+`
+
+	checkQuery(t, expected, renderTestMigration(t, SchemaOptions{}))
+}
+
+func TestSchemaTemplate_TableOptions(t *testing.T) {
+	opts := SchemaOptions{
+		Tables: map[string]TableOptions{
+			"traces": {
+				TTL:                  "toDateTime(Timestamp) + INTERVAL 30 DAY",
+				StoragePolicy:        "hot_cold",
+				PartitionGranularity: "month",
+				Settings: map[string]string{
+					"ttl_only_drop_parts": "1",
+					"index_granularity":   "4096",
+				},
+				Codecs: map[string]string{
+					"Timestamp": "Delta, ZSTD(1)",
+				},
+			},
+		},
+	}
+
+	expected := `CREATE TABLE IF NOT EXISTS traces (
+    Timestamp DateTime64(9) CODEC(Delta, ZSTD(1))
+) ENGINE MergeTree()
+PARTITION BY toStartOfMonth(Timestamp)
+ORDER BY Timestamp
+TTL toDateTime(Timestamp) + INTERVAL 30 DAY
+SETTINGS index_granularity = 4096, storage_policy = 'hot_cold', ttl_only_drop_parts = 1
+;
+`
+
+	checkQuery(t, expected, renderTestMigration(t, opts))
+}
+
+func TestTableOptions_ModifiableSettings(t *testing.T) {
+	opts := TableOptions{
+		StoragePolicy: "hot_cold",
+		Settings: map[string]string{
+			"ttl_only_drop_parts": "1",
+			"index_granularity":   "4096",
+		},
+	}
+
+	expected := []string{"storage_policy = 'hot_cold'", "ttl_only_drop_parts = 1"}
+	if diff := cmp.Diff(expected, opts.modifiableSettings()); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSchemaTemplate_InvalidGranularity(t *testing.T) {
+	fsys := NewSchemaTemplateFS(fstest.MapFS{
+		"000001_create_traces.up.sql": &fstest.MapFile{Data: []byte(testMigration)},
+	}, SchemaOptions{
+		Tables: map[string]TableOptions{
+			"traces": {PartitionGranularity: "hourly"},
+		},
+	})
+
+	if _, err := fs.ReadFile(fsys, "000001_create_traces.up.sql"); err == nil {
+		t.Error("Expected error due to invalid partition granularity, got `nil`")
+	}
+}
+
+func TestSchemaTemplate_Migrations(t *testing.T) {
+	fsys := NewSchemaTemplateFS(os.DirFS("../../db/migrations"), SchemaOptions{})
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	for _, e := range entries {
+		if _, err := fs.ReadFile(fsys, e.Name()); err != nil {
+			t.Errorf("Expected no error rendering %s, got: %v", e.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package clickhouse
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	otlp_comonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	otlp_resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	otlp_tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+)
+
+const (
+	// service name of synthesized spans
+	SynthesizedServiceName string = "gitlab-ci"
+
+	synthesizerScopeName string = "go.cluttr.dev/gitlab-exporter-clickhouse-recorder/synthesizer"
+)
+
+// SynthesizePipelineTraces builds a root span for each finished pipeline.
+func SynthesizePipelineTraces(pipelines []*typespb.Pipeline) []*typespb.Trace {
+	rows := make([]*Pipeline, 0, len(pipelines))
+	for _, p := range pipelines {
+		rows = append(rows, convertPipeline(p))
+	}
+	return SynthesizeTraces(rows, nil, nil)
+}
+
+// SynthesizeJobTraces builds a span for each finished job or bridge.
+func SynthesizeJobTraces(jobs []*typespb.Job) []*typespb.Trace {
+	rows := make([]*Job, 0, len(jobs))
+	for _, j := range jobs {
+		rows = append(rows, convertJob(j))
+	}
+	return SynthesizeTraces(nil, rows, nil)
+}
+
+// SynthesizeSectionTraces builds a span for each finished job section.
+func SynthesizeSectionTraces(sections []*typespb.Section) []*typespb.Trace {
+	rows := make([]*Section, 0, len(sections))
+	for _, s := range sections {
+		rows = append(rows, convertSection(s))
+	}
+	return SynthesizeTraces(nil, nil, rows)
+}
+
+// SynthesizeTraces builds a trace per pipeline from the recorded pipelines,
+// jobs and sections. Pipelines are the root spans with their jobs as children,
+// which in turn are the parents of their sections. Bridges are linked to their
+// downstream pipelines and child pipelines to their upstream pipelines.
+//
+// Trace and span ids are derived from the entity ids, so that spans can be
+// synthesized independently of each other and repeatedly. Entities that
+// haven't finished yet are skipped.
+func SynthesizeTraces(pipelines []*Pipeline, jobs []*Job, sections []*Section) []*typespb.Trace {
+	var (
+		order  []int64
+		traces = map[int64]*resourceSpansBuilder{}
+	)
+	builder := func(projectId int64, pipelineId int64) *resourceSpansBuilder {
+		b, ok := traces[pipelineId]
+		if !ok {
+			b = newResourceSpansBuilder(projectId, pipelineId)
+			traces[pipelineId] = b
+			order = append(order, pipelineId)
+		}
+		return b
+	}
+
+	for _, p := range pipelines {
+		if span := pipelineSpan(p); span != nil {
+			builder(p.ProjectId, p.Id).add(span)
+		}
+	}
+	for _, j := range jobs {
+		if span := jobSpan(j); span != nil {
+			builder(j.ProjectId, j.PipelineId).add(span)
+		}
+	}
+	for _, s := range sections {
+		if span := sectionSpan(s); span != nil {
+			builder(s.ProjectId, s.PipelineId).add(span)
+		}
+	}
+
+	result := make([]*typespb.Trace, 0, len(order))
+	for _, id := range order {
+		result = append(result, &typespb.Trace{
+			Data: &otlp_tracepb.TracesData{
+				ResourceSpans: []*otlp_tracepb.ResourceSpans{traces[id].build()},
+			},
+		})
+	}
+	return result
+}
+
+type resourceSpansBuilder struct {
+	projectId  int64
+	pipelineId int64
+	spans      []*otlp_tracepb.Span
+}
+
+func newResourceSpansBuilder(projectId int64, pipelineId int64) *resourceSpansBuilder {
+	return &resourceSpansBuilder{
+		projectId:  projectId,
+		pipelineId: pipelineId,
+	}
+}
+
+func (b *resourceSpansBuilder) add(span *otlp_tracepb.Span) {
+	b.spans = append(b.spans, span)
+}
+
+func (b *resourceSpansBuilder) build() *otlp_tracepb.ResourceSpans {
+	return &otlp_tracepb.ResourceSpans{
+		Resource: &otlp_resourcepb.Resource{
+			Attributes: []*otlp_comonpb.KeyValue{
+				stringAttribute("service.name", SynthesizedServiceName),
+				intAttribute(ProjectIdAttribute, b.projectId),
+				intAttribute(PipelineIdAttribute, b.pipelineId),
+			},
+		},
+		ScopeSpans: []*otlp_tracepb.ScopeSpans{
+			{
+				Scope: &otlp_comonpb.InstrumentationScope{Name: synthesizerScopeName},
+				Spans: b.spans,
+			},
+		},
+	}
+}
+
+func pipelineSpan(p *Pipeline) *otlp_tracepb.Span {
+	start := unixNano(p.CreatedAt)
+	if p.StartedAt != nil {
+		start = unixNano(*p.StartedAt)
+	}
+	if start == 0 || p.FinishedAt == nil {
+		return nil
+	}
+
+	span := &otlp_tracepb.Span{
+		TraceId:           pipelineTraceId(p.Id),
+		SpanId:            synthesizedSpanId("pipeline", p.Id),
+		Name:              "pipeline",
+		Kind:              otlp_tracepb.Span_SPAN_KIND_INTERNAL,
+		StartTimeUnixNano: start,
+		EndTimeUnixNano:   unixNano(*p.FinishedAt),
+		Attributes: []*otlp_comonpb.KeyValue{
+			intAttribute(ProjectIdAttribute, p.ProjectId),
+			intAttribute(PipelineIdAttribute, p.Id),
+			intAttribute("ci.pipeline.iid", p.Iid),
+			stringAttribute("ci.pipeline.name", p.Name),
+			stringAttribute("ci.pipeline.ref", p.Ref),
+			stringAttribute("ci.pipeline.sha", p.Sha),
+			stringAttribute("ci.pipeline.source", p.Source),
+			stringAttribute("ci.pipeline.status", p.Status),
+		},
+		Status: synthesizedStatus(p.Status, p.FailureReason),
+	}
+
+	if p.UpstreamPipelineId != 0 {
+		span.Links = append(span.Links, &otlp_tracepb.Span_Link{
+			TraceId: pipelineTraceId(p.UpstreamPipelineId),
+			SpanId:  synthesizedSpanId("pipeline", p.UpstreamPipelineId),
+			Attributes: []*otlp_comonpb.KeyValue{
+				stringAttribute("ci.link.type", "upstream"),
+			},
+		})
+	}
+
+	return span
+}
+
+func jobSpan(j *Job) *otlp_tracepb.Span {
+	if j.PipelineId == 0 || j.StartedAt == nil || j.FinishedAt == nil {
+		return nil
+	}
+
+	span := &otlp_tracepb.Span{
+		TraceId:           pipelineTraceId(j.PipelineId),
+		SpanId:            synthesizedSpanId("job", j.Id),
+		ParentSpanId:      synthesizedSpanId("pipeline", j.PipelineId),
+		Name:              j.Name,
+		Kind:              otlp_tracepb.Span_SPAN_KIND_INTERNAL,
+		StartTimeUnixNano: unixNano(*j.StartedAt),
+		EndTimeUnixNano:   unixNano(*j.FinishedAt),
+		Attributes: []*otlp_comonpb.KeyValue{
+			intAttribute(ProjectIdAttribute, j.ProjectId),
+			intAttribute(PipelineIdAttribute, j.PipelineId),
+			intAttribute(JobIdAttribute, j.Id),
+			stringAttribute("ci.job.name", j.Name),
+			stringAttribute("ci.job.stage", j.Stage),
+			stringAttribute("ci.job.status", j.Status),
+			stringAttribute("ci.job.kind", j.Kind),
+			boolAttribute("ci.job.allow_failure", j.AllowFailure),
+			boolAttribute("ci.job.retried", j.Retried),
+		},
+		Status: synthesizedStatus(j.Status, j.FailureReason),
+	}
+	if j.RunnerId != "" {
+		span.Attributes = append(span.Attributes, stringAttribute("ci.runner.id", j.RunnerId))
+	}
+	if j.QueuedAt != nil {
+		span.Events = append(span.Events, &otlp_tracepb.Span_Event{
+			TimeUnixNano: unixNano(*j.QueuedAt),
+			Name:         "queued",
+		})
+	}
+
+	if j.DownstreamPipelineId != 0 {
+		span.Links = append(span.Links, &otlp_tracepb.Span_Link{
+			TraceId: pipelineTraceId(j.DownstreamPipelineId),
+			SpanId:  synthesizedSpanId("pipeline", j.DownstreamPipelineId),
+			Attributes: []*otlp_comonpb.KeyValue{
+				stringAttribute("ci.link.type", "downstream"),
+			},
+		})
+	}
+
+	return span
+}
+
+func sectionSpan(s *Section) *otlp_tracepb.Span {
+	if s.JobId == 0 || s.PipelineId == 0 || unixNano(s.StartedAt) == 0 || unixNano(s.FinishedAt) == 0 {
+		return nil
+	}
+
+	return &otlp_tracepb.Span{
+		TraceId:           pipelineTraceId(s.PipelineId),
+		SpanId:            synthesizedSpanId("section", s.Id),
+		ParentSpanId:      synthesizedSpanId("job", s.JobId),
+		Name:              s.Name,
+		Kind:              otlp_tracepb.Span_SPAN_KIND_INTERNAL,
+		StartTimeUnixNano: unixNano(s.StartedAt),
+		EndTimeUnixNano:   unixNano(s.FinishedAt),
+		Attributes: []*otlp_comonpb.KeyValue{
+			intAttribute(ProjectIdAttribute, s.ProjectId),
+			intAttribute(PipelineIdAttribute, s.PipelineId),
+			intAttribute(JobIdAttribute, s.JobId),
+			stringAttribute("ci.section.name", s.Name),
+		},
+	}
+}
+
+func synthesizedStatus(status string, reason string) *otlp_tracepb.Status {
+	switch status {
+	case "success":
+		return &otlp_tracepb.Status{Code: otlp_tracepb.Status_STATUS_CODE_OK}
+	case "failed":
+		return &otlp_tracepb.Status{Code: otlp_tracepb.Status_STATUS_CODE_ERROR, Message: reason}
+	default:
+		return &otlp_tracepb.Status{Code: otlp_tracepb.Status_STATUS_CODE_UNSET}
+	}
+}
+
+// pipelineTraceId returns the id of the trace synthesized for a pipeline.
+func pipelineTraceId(pipelineId int64) []byte {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s/trace/%d", SynthesizedServiceName, pipelineId)))
+	return h[:16]
+}
+
+// synthesizedSpanId returns the id of the span synthesized for an entity.
+func synthesizedSpanId(kind string, id int64) []byte {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%d", SynthesizedServiceName, kind, id)))
+	return h[:8]
+}
+
+// unixNano converts the time to nanoseconds since the epoch, or 0 if it's not
+// after the epoch.
+func unixNano(t time.Time) uint64 {
+	if t.UnixNano() <= 0 {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+func stringAttribute(key string, value string) *otlp_comonpb.KeyValue {
+	return &otlp_comonpb.KeyValue{Key: key, Value: &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func intAttribute(key string, value int64) *otlp_comonpb.KeyValue {
+	return &otlp_comonpb.KeyValue{Key: key, Value: &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_IntValue{IntValue: value}}}
+}
+
+func boolAttribute(key string, value bool) *otlp_comonpb.KeyValue {
+	return &otlp_comonpb.KeyValue{Key: key, Value: &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_BoolValue{BoolValue: value}}}
+}
@@ -0,0 +1,83 @@
+package clickhouse
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	otlp_tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestSynthesizeTraces(t *testing.T) {
+	pipelines := []*Pipeline{
+		{Id: 1, ProjectId: 10, Status: "failed", FailureReason: "script_failure", CreatedAt: unixTime(1700000000), StartedAt: nullableUnixTime(1700000010), FinishedAt: nullableUnixTime(1700000100)},
+		{Id: 2, ProjectId: 10, Status: "running", CreatedAt: unixTime(1700000000), StartedAt: nullableUnixTime(1700000010)},
+		{Id: 3, ProjectId: 20, Status: "success", CreatedAt: unixTime(1700000020), StartedAt: nullableUnixTime(1700000030), FinishedAt: nullableUnixTime(1700000090), UpstreamPipelineId: 1},
+	}
+	jobs := []*Job{
+		{Id: 100, PipelineId: 1, ProjectId: 10, Name: "build", Kind: "build", StartedAt: nullableUnixTime(1700000010), FinishedAt: nullableUnixTime(1700000050)},
+		{Id: 101, PipelineId: 1, ProjectId: 10, Name: "trigger", Kind: "bridge", StartedAt: nullableUnixTime(1700000020), FinishedAt: nullableUnixTime(1700000095), DownstreamPipelineId: 3},
+		{Id: 102, PipelineId: 1, ProjectId: 10, Name: "deploy", Kind: "build"},
+	}
+	sections := []*Section{
+		{Id: 1000, JobId: 100, PipelineId: 1, ProjectId: 10, Name: "step_script", StartedAt: unixTime(1700000015), FinishedAt: unixTime(1700000045)},
+	}
+
+	traces := SynthesizeTraces(pipelines, jobs, sections)
+	if len(traces) != 2 {
+		t.Fatalf("Expected 2 traces, got %d", len(traces))
+	}
+
+	spans := map[string]*otlp_tracepb.Span{}
+	for _, trace := range traces {
+		for _, rs := range trace.GetData().GetResourceSpans() {
+			for _, ss := range rs.GetScopeSpans() {
+				for _, span := range ss.GetSpans() {
+					spans[string(span.GetSpanId())] = span
+				}
+			}
+		}
+	}
+	if len(spans) != 5 {
+		t.Fatalf("Expected 5 spans, got %d", len(spans))
+	}
+
+	child := spans[string(synthesizedSpanId("pipeline", 3))]
+	if len(child.GetLinks()) != 1 || !bytes.Equal(child.GetLinks()[0].GetSpanId(), synthesizedSpanId("pipeline", 1)) {
+		t.Errorf("Expected child pipeline to link to its upstream pipeline")
+	}
+
+	build := spans[string(synthesizedSpanId("job", 100))]
+	trigger := spans[string(synthesizedSpanId("job", 101))]
+	script := spans[string(synthesizedSpanId("section", 1000))]
+	if !bytes.Equal(build.GetTraceId(), pipelineTraceId(1)) || !bytes.Equal(build.GetParentSpanId(), synthesizedSpanId("pipeline", 1)) {
+		t.Errorf("Expected job to be a child of its pipeline")
+	}
+	if !bytes.Equal(script.GetParentSpanId(), build.GetSpanId()) {
+		t.Errorf("Expected section to be a child of its job")
+	}
+	if len(trigger.GetLinks()) != 1 || !bytes.Equal(trigger.GetLinks()[0].GetTraceId(), pipelineTraceId(3)) {
+		t.Errorf("Expected bridge to link to its downstream pipeline")
+	}
+	if build.GetStartTimeUnixNano() != 1700000010000000000 || build.GetEndTimeUnixNano() != 1700000050000000000 {
+		t.Errorf("Unexpected job span times: %d - %d", build.GetStartTimeUnixNano(), build.GetEndTimeUnixNano())
+	}
+
+	again := SynthesizeTraces(pipelines, jobs, sections)
+	first := again[0].GetData().GetResourceSpans()[0].GetScopeSpans()[0].GetSpans()[0]
+	if !bytes.Equal(first.GetSpanId(), synthesizedSpanId("pipeline", 1)) {
+		t.Errorf("Expected deterministic span ids")
+	}
+	if first.GetStatus().GetCode() != otlp_tracepb.Status_STATUS_CODE_ERROR || first.GetStatus().GetMessage() != "script_failure" {
+		t.Errorf("Unexpected pipeline status: %v", first.GetStatus())
+	}
+}
+
+func unixTime(seconds int64) time.Time {
+	return time.Unix(seconds, 0)
+}
+
+func nullableUnixTime(seconds int64) *time.Time {
+	t := unixTime(seconds)
+	return &t
+}
@@ -25,6 +25,11 @@ func Execute() error {
 		NewRunCmd(out),
 		NewDeduplicateCmd(out),
 		NewMigrateCommand(out),
+		NewRetentionCmd(out),
+		NewImportCmd(out),
+		NewExportCmd(out),
+		NewReplayCmd(out),
+		NewTracesCmd(out),
 		cli.NewVersionCommand(cli.NewBuildInfo(Version), out),
 	}
 
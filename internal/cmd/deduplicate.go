@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/cluttrdev/cli"
 
@@ -21,6 +24,15 @@ type DeduplicateConfig struct {
 	except      columnList
 	throwIfNoop bool
 
+	adaptive          bool
+	minParts          uint64
+	minDuplicateRatio float64
+	sampleRows        uint64
+
+	all      bool
+	dryRun   bool
+	examples int
+
 	flags *flag.FlagSet
 }
 
@@ -37,7 +49,7 @@ func NewDeduplicateCmd(out io.Writer) *cli.Command {
 
 	return &cli.Command{
 		Name:       "deduplicate",
-		ShortUsage: fmt.Sprintf("%s deduplicate [option]... table", exeName),
+		ShortUsage: fmt.Sprintf("%s deduplicate [option]... (table... | --all)", exeName),
 		ShortHelp:  "Deduplicate database table",
 		Flags:      fs,
 		Exec:       cfg.Exec,
@@ -50,11 +62,27 @@ func (c *DeduplicateConfig) RegisterFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&c.final, "final", true, "Optimize even if all data is already in one part. (default: true)")
 	fs.Var(&c.by, "by", "Comma separated list of columns to deduplicate by. (default: [])")
 	fs.Var(&c.except, "except", "Comma separated list of columns to not deduplicate by. (default: [])")
-	fs.BoolVar(&c.throwIfNoop, "throw-if-noop", true, "Notify if deduplication is not performed. (default: true)")
+	fs.BoolVar(&c.throwIfNoop, "throw-if-noop", true, "Notify if deduplication is not performed. (default: true, false with --all or --adaptive)")
+
+	fs.BoolVar(&c.adaptive, "adaptive", false, "Only deduplicate partitions that exceed the parts and duplicate ratio thresholds. (default: false)")
+	fs.Uint64Var(&c.minParts, "min-parts", 2, "Minimum number of active parts of a partition to deduplicate in adaptive mode. (default: 2)")
+	fs.Float64Var(&c.minDuplicateRatio, "min-duplicate-ratio", 0.01, "Minimum estimated ratio of duplicate rows of a partition to deduplicate in adaptive mode. (default: 0.01)")
+	fs.Uint64Var(&c.sampleRows, "sample-rows", 0, "Approximate number of rows per partition used to estimate duplicates in adaptive mode. (default: 0, all)")
+
+	fs.BoolVar(&c.all, "all", false, "Deduplicate all tables. (default: false)")
+	fs.BoolVar(&c.dryRun, "dry-run", false, "Only report duplicates without deduplicating. (default: false)")
+	fs.BoolVar(&c.dryRun, "report", false, "Alias for --dry-run.")
+	fs.IntVar(&c.examples, "examples", 5, "Number of example duplicate keys to report. (default: 5)")
 }
 
 func (c *DeduplicateConfig) Exec(ctx context.Context, args []string) error {
-	if len(args) != 1 {
+	tables := args
+	if c.all {
+		if len(args) > 0 {
+			return fmt.Errorf("cannot use positional arguments with --all: %v", args)
+		}
+		tables = clickhouse.Tables
+	} else if len(args) == 0 {
 		return fmt.Errorf("invalid number of positional arguments: %v", args)
 	}
 
@@ -65,6 +93,19 @@ func (c *DeduplicateConfig) Exec(ctx context.Context, args []string) error {
 		return fmt.Errorf("error loading configuration: %w", err)
 	}
 
+	if c.all || c.adaptive {
+		// tables and partitions that are already merged are not an error
+		throwIfNoopSet := false
+		c.flags.Visit(func(f *flag.Flag) {
+			if f.Name == "throw-if-noop" {
+				throwIfNoopSet = true
+			}
+		})
+		if !throwIfNoopSet {
+			c.throwIfNoop = false
+		}
+	}
+
 	// create clickhouse client
 	opts := clickhouse.ClientOptions(clickhouse.ClientConfig{
 		Host:     cfg.ClickHouse.Host,
@@ -79,18 +120,72 @@ func (c *DeduplicateConfig) Exec(ctx context.Context, args []string) error {
 	}
 	client := clickhouse.NewClient(conn, cfg.ClickHouse.Database)
 
-	table := args[0]
+	var errs []error
+	for _, table := range tables {
+		opt := clickhouse.DeduplicateTableOptions{
+			Database:    cfg.ClickHouse.Database,
+			Table:       table,
+			Final:       &c.final,
+			By:          c.by,
+			Except:      c.except,
+			ThrowIfNoop: &c.throwIfNoop,
+		}
+
+		if err := c.deduplicate(ctx, opt, client); err != nil {
+			if len(tables) == 1 {
+				return err
+			}
+			slog.Error("error deduplicating table", "table", table, "error", err)
+			errs = append(errs, fmt.Errorf("table `%s`: %w", table, err))
+		}
+	}
 
-	opt := clickhouse.DeduplicateTableOptions{
-		Database:    cfg.ClickHouse.Database,
-		Table:       table,
-		Final:       &c.final,
-		By:          c.by,
-		Except:      c.except,
-		ThrowIfNoop: &c.throwIfNoop,
+	return errors.Join(errs...)
+}
+
+func (c *DeduplicateConfig) deduplicate(ctx context.Context, opt clickhouse.DeduplicateTableOptions, client *clickhouse.Client) error {
+	if c.dryRun {
+		report, err := clickhouse.ReportTableDuplicates(ctx, opt, c.examples, client)
+		if err != nil {
+			return err
+		}
+		writeDuplicateReport(c.out, report)
+		return nil
 	}
 
-	return clickhouse.DeduplicateTable(ctx, opt, client)
+	if !c.adaptive {
+		return clickhouse.DeduplicateTable(ctx, opt, client)
+	}
+
+	aopt := clickhouse.AdaptiveDeduplicateOptions{
+		MinParts:          c.minParts,
+		MinDuplicateRatio: c.minDuplicateRatio,
+		SampleRows:        c.sampleRows,
+	}
+	results, err := clickhouse.AdaptiveDeduplicateTable(ctx, opt, aopt, client)
+	for _, r := range results {
+		fmt.Fprintf(c.out, "%s: partition %s: parts=%d rows=%d keys=%d ratio=%.4f optimized=%t\n",
+			opt.Table, r.PartitionId, r.Parts, r.Rows, r.Keys, r.Ratio(), r.Optimized)
+	}
+	return err
+}
+
+func writeDuplicateReport(out io.Writer, r clickhouse.DuplicateReport) {
+	fmt.Fprintf(out, "%s: rows=%d keys=%d duplicates=%d\n", r.Table, r.Rows, r.Keys, r.Duplicates())
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  PARTITION\tPARTS\tROWS\tKEYS\tDUPLICATES")
+	for _, p := range r.Partitions {
+		fmt.Fprintf(w, "  %s\t%d\t%d\t%d\t%d\n", p.PartitionId, p.Parts, p.Rows, p.Keys, p.Duplicates())
+	}
+	_ = w.Flush()
+
+	if len(r.Examples) > 0 {
+		fmt.Fprintln(out, "  Example duplicate keys:")
+		for _, e := range r.Examples {
+			fmt.Fprintf(out, "    %s (%d)\n", e.Key, e.Count)
+		}
+	}
 }
 
 type columnList []string
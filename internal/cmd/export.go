@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/cluttrdev/cli"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/config"
+)
+
+type ExportConfig struct {
+	RootConfig
+
+	projectId int64
+	since     string
+	until     string
+	format    string
+	output    string
+
+	flags *flag.FlagSet
+}
+
+func NewExportCmd(out io.Writer) *cli.Command {
+	fs := flag.NewFlagSet(fmt.Sprintf("%s export", exeName), flag.ContinueOnError)
+
+	cfg := ExportConfig{
+		RootConfig: RootConfig{
+			out: out,
+		},
+		flags: fs,
+	}
+	cfg.RegisterFlags(fs)
+
+	return &cli.Command{
+		Name:       "export",
+		ShortUsage: fmt.Sprintf("%s export [option]... <table>", exeName),
+		ShortHelp:  "Export table rows as Parquet, NDJSON or CSV",
+		Flags:      fs,
+		Exec:       cfg.Exec,
+	}
+}
+
+func (c *ExportConfig) RegisterFlags(fs *flag.FlagSet) {
+	c.RootConfig.RegisterFlags(fs)
+
+	fs.Int64Var(&c.projectId, "project-id", 0, "Only export rows of this project. (default: 0, all projects)")
+	fs.StringVar(&c.since, "since", "", "Only export rows created at or after this time, in RFC 3339 or YYYY-MM-DD format.")
+	fs.StringVar(&c.until, "until", "", "Only export rows created before this time, in RFC 3339 or YYYY-MM-DD format.")
+	fs.StringVar(&c.format, "format", clickhouse.ExportFormatNDJSON, "The output format, one of 'parquet', 'ndjson' or 'csv'. (default: 'ndjson')")
+	fs.StringVar(&c.output, "output", "-", "The output file, or a directory to write a file per partition to, which parquet exports require. (default: '-', stdout)")
+}
+
+func (c *ExportConfig) Exec(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("invalid number of positional arguments: %v", args)
+	}
+
+	opt := clickhouse.ExportOptions{
+		Table:     args[0],
+		Format:    c.format,
+		ProjectId: c.projectId,
+	}
+	var err error
+	if opt.Since, err = parseTime(c.since); err != nil {
+		return fmt.Errorf("invalid since: %w", err)
+	}
+	if opt.Until, err = parseTime(c.until); err != nil {
+		return fmt.Errorf("invalid until: %w", err)
+	}
+
+	// parquet files can't be concatenated, each partition needs its own
+	if opt.Format == clickhouse.ExportFormatParquet {
+		if info, err := os.Stat(c.output); err != nil || !info.IsDir() {
+			return fmt.Errorf("parquet exports require an output directory to write a file per partition to")
+		}
+	}
+
+	// load configuration
+	var cfg config.Config
+	config.SetDefaults(&cfg)
+	if err := loadConfig(c.RootConfig.filename, c.flags, &cfg); err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	// create clickhouse clients
+	clientConfig := clickhouse.ClientConfig{
+		Host:     cfg.ClickHouse.Host,
+		Port:     cfg.ClickHouse.Port,
+		Database: cfg.ClickHouse.Database,
+		User:     cfg.ClickHouse.User,
+		Password: cfg.ClickHouse.Password,
+		HTTPPort: cfg.ClickHouse.HTTPPort,
+	}
+	opts := clickhouse.ClientOptions(clientConfig)
+	conn, err := clickhouse.Connect(&opts)
+	if err != nil {
+		return fmt.Errorf("error creating clickhouse connection")
+	}
+	client := clickhouse.NewClient(conn, cfg.ClickHouse.Database)
+	httpClient := clickhouse.NewHTTPClient(clientConfig)
+
+	output, closeOutput, err := c.exportOutput(opt.Table)
+	if err != nil {
+		return fmt.Errorf("error opening output: %w", err)
+	}
+
+	results, err := clickhouse.ExportTable(client, httpClient, ctx, opt, output)
+	if cerr := closeOutput(); err == nil && cerr != nil {
+		err = fmt.Errorf("error closing output: %w", cerr)
+	}
+	writeExportSummary(c.out, opt.Table, results)
+	if err != nil {
+		return fmt.Errorf("error exporting table `%s`: %w", opt.Table, err)
+	}
+	return nil
+}
+
+// exportOutput returns the output to export to, which writes to stdout, a
+// single file, or a file per partition if the output is a directory.
+func (c *ExportConfig) exportOutput(table string) (clickhouse.ExportOutput, func() error, error) {
+	if c.output == "-" {
+		first := true
+		return func(string) (io.Writer, bool, error) {
+			newFile := first
+			first = false
+			return os.Stdout, newFile, nil
+		}, func() error { return nil }, nil
+	}
+
+	var file *os.File
+	closeFile := func() error {
+		if file == nil {
+			return nil
+		}
+		err := file.Close()
+		file = nil
+		return err
+	}
+
+	if info, err := os.Stat(c.output); err == nil && info.IsDir() {
+		return func(partitionId string) (io.Writer, bool, error) {
+			if err := closeFile(); err != nil {
+				return nil, false, err
+			}
+			name := filepath.Join(c.output, fmt.Sprintf("%s-%s.%s", table, partitionId, c.format))
+			f, err := os.Create(name)
+			if err != nil {
+				return nil, false, err
+			}
+			file = f
+			return f, true, nil
+		}, closeFile, nil
+	}
+
+	f, err := os.Create(c.output)
+	if err != nil {
+		return nil, nil, err
+	}
+	file = f
+	first := true
+	return func(string) (io.Writer, bool, error) {
+		newFile := first
+		first = false
+		return file, newFile, nil
+	}, closeFile, nil
+}
+
+func writeExportSummary(out io.Writer, table string, results []clickhouse.ExportResult) {
+	var rows uint64
+	var bytes int64
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PARTITION\tROWS\tBYTES")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", r.PartitionId, r.Rows, r.Bytes)
+		rows += r.Rows
+		bytes += r.Bytes
+	}
+	_ = w.Flush()
+	fmt.Fprintf(out, "Exported %d rows (%d bytes) of %d partitions from %s\n", rows, bytes, len(results), table)
+}
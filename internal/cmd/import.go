@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cluttrdev/cli"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/config"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/importer"
+)
+
+type ImportConfig struct {
+	RootConfig
+
+	format      string
+	batchSize   int
+	parallelism int
+	checkpoint  string
+
+	flags *flag.FlagSet
+}
+
+func NewImportCmd(out io.Writer) *cli.Command {
+	fs := flag.NewFlagSet(fmt.Sprintf("%s import", exeName), flag.ContinueOnError)
+
+	cfg := ImportConfig{
+		RootConfig: RootConfig{
+			out: out,
+		},
+		flags: fs,
+	}
+	cfg.RegisterFlags(fs)
+
+	return &cli.Command{
+		Name:       "import",
+		ShortUsage: fmt.Sprintf("%s import [option]... <entity> [file]...", exeName),
+		ShortHelp:  "Import records from files, or stdin if no file or '-' is given",
+		LongHelp:   fmt.Sprintf("Entities: %s", strings.Join(importer.Entities(), ", ")),
+		Flags:      fs,
+		Exec:       cfg.Exec,
+	}
+}
+
+func (c *ImportConfig) RegisterFlags(fs *flag.FlagSet) {
+	c.RootConfig.RegisterFlags(fs)
+
+	fs.StringVar(&c.format, "format", importer.FormatNDJSON, "The input format, either 'ndjson' (protobuf JSON lines) or 'delimited' (size-delimited binary protobuf). (default: 'ndjson')")
+	fs.IntVar(&c.batchSize, "batch-size", 1000, "The number of records inserted per batch. (default: 1000)")
+	fs.IntVar(&c.parallelism, "parallelism", 4, "The number of batches inserted concurrently. (default: 4)")
+	fs.StringVar(&c.checkpoint, "checkpoint", "", "The file to store the import progress in, to resume from it. (default: '', none)")
+}
+
+func (c *ImportConfig) Exec(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("invalid number of positional arguments: %v", args)
+	}
+	entity, inputs := args[0], args[1:]
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	var checkpoint *importer.Checkpoint
+	if c.checkpoint != "" {
+		var err error
+		checkpoint, err = importer.LoadCheckpoint(c.checkpoint)
+		if err != nil {
+			return fmt.Errorf("error loading checkpoint: %w", err)
+		}
+	}
+
+	// load configuration
+	var cfg config.Config
+	config.SetDefaults(&cfg)
+	if err := loadConfig(c.RootConfig.filename, c.flags, &cfg); err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	// create clickhouse client
+	opts := clickhouse.ClientOptions(clickhouse.ClientConfig{
+		Host:     cfg.ClickHouse.Host,
+		Port:     cfg.ClickHouse.Port,
+		Database: cfg.ClickHouse.Database,
+		User:     cfg.ClickHouse.User,
+		Password: cfg.ClickHouse.Password,
+	})
+	conn, err := clickhouse.Connect(&opts)
+	if err != nil {
+		return fmt.Errorf("error creating clickhouse connection")
+	}
+	client := clickhouse.NewClient(conn, cfg.ClickHouse.Database)
+	client.SetMaxConcurrentQueries(cfg.ClickHouse.Client.MaxConcurrentQueries)
+
+	im, err := importer.New(client, entity, importer.Options{
+		BatchSize:   c.batchSize,
+		Parallelism: c.parallelism,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, input := range inputs {
+		if err = c.importInput(ctx, im, input, checkpoint); err != nil {
+			err = fmt.Errorf("error importing `%s`: %w", input, err)
+			break
+		}
+	}
+
+	writeImportSummary(c.out, im.Summary())
+	return err
+}
+
+func (c *ImportConfig) importInput(ctx context.Context, im *importer.Importer, input string, checkpoint *importer.Checkpoint) error {
+	r, err := openImportInput(input)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var (
+		offset   int64
+		progress func(int64) error
+	)
+	// stdin can't be resumed, as it's a different input each time
+	if checkpoint != nil && input != "-" {
+		offset = checkpoint.Offset(input)
+		progress = func(offset int64) error {
+			return checkpoint.Save(input, offset)
+		}
+	}
+
+	slog.Info("Importing records", "input", input, "offset", offset)
+	return im.Import(ctx, r, c.format, offset, progress)
+}
+
+// openImportInput opens the file, or stdin for '-', and decompresses it if
+// it has a `.gz` extension.
+func openImportInput(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".gz") {
+		return f, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, f}, nil
+}
+
+func writeImportSummary(out io.Writer, summary []importer.TableSummary) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tRECORDS\tINSERTED\tFAILED")
+	for _, s := range summary {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", s.Table, s.Records, s.Inserted, s.Failed)
+	}
+	_ = w.Flush()
+}
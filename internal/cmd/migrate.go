@@ -74,13 +74,60 @@ func (c *MigrateConfig) Exec(ctx context.Context, args []string) error {
 
 		FileSystem: MigrationsFileSystem,
 		Path:       MigrationsPath,
+
+		Schema: schemaOptions(cfg.ClickHouse.Schema),
 	}
 	if err := clickhouse.MigrateUp(opts); err != nil {
-		if errors.Is(err, clickhouse.ErrMigrateNoChange) {
-			slog.Info("no schema changes")
-			return nil
+		if !errors.Is(err, clickhouse.ErrMigrateNoChange) {
+			return fmt.Errorf("error migrating database schema: %w", err)
 		}
-		return fmt.Errorf("error migrating database schema: %w", err)
+		slog.Info("no schema changes")
+	}
+
+	if len(opts.Schema.Tables) == 0 && len(opts.Schema.PromotedAttributes) == 0 {
+		return nil
+	}
+
+	// create clickhouse client
+	clientOpts := clickhouse.ClientOptions(opts.ClientConfig)
+	conn, err := clickhouse.Connect(&clientOpts)
+	if err != nil {
+		return fmt.Errorf("error creating clickhouse connection")
+	}
+	client := clickhouse.NewClient(conn, cfg.ClickHouse.Database)
+
+	if err := clickhouse.ApplySchemaOptions(client, ctx, opts.Schema); err != nil {
+		return fmt.Errorf("error applying schema options: %w", err)
 	}
 	return nil
 }
+
+func schemaOptions(cfg config.ClickHouseSchema) clickhouse.SchemaOptions {
+	tables := make(map[string]clickhouse.TableOptions, len(cfg.Tables))
+	for name, table := range cfg.Tables {
+		tables[name] = clickhouse.TableOptions{
+			TTL:                  table.TTL,
+			StoragePolicy:        table.StoragePolicy,
+			PartitionGranularity: table.PartitionGranularity,
+			Settings:             table.Settings,
+			Codecs:               table.Codecs,
+		}
+	}
+	return clickhouse.SchemaOptions{
+		Tables:             tables,
+		PromotedAttributes: promotedAttributes(cfg.PromotedAttributes),
+	}
+}
+
+func promotedAttributes(cfg []config.ClickHousePromotedAttribute) []clickhouse.PromotedAttribute {
+	attrs := make([]clickhouse.PromotedAttribute, 0, len(cfg))
+	for _, attr := range cfg {
+		attrs = append(attrs, clickhouse.PromotedAttribute{
+			Key:    attr.Key,
+			Column: attr.Column,
+			Type:   attr.Type,
+			Index:  attr.Index,
+		})
+	}
+	return attrs
+}
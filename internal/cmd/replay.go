@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cluttrdev/cli"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/servicepb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/capture"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/config"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/recorder"
+)
+
+type ReplayConfig struct {
+	RootConfig
+
+	target string
+	method string
+
+	flags *flag.FlagSet
+}
+
+// replayFunc records the captured request.
+type replayFunc func(ctx context.Context, method string, data []byte) (*servicepb.RecordSummary, error)
+
+type replaySummary struct {
+	Method   string
+	Requests int
+	Recorded int64
+	Failed   int
+}
+
+func NewReplayCmd(out io.Writer) *cli.Command {
+	fs := flag.NewFlagSet(fmt.Sprintf("%s replay", exeName), flag.ContinueOnError)
+
+	cfg := ReplayConfig{
+		RootConfig: RootConfig{
+			out: out,
+		},
+		flags: fs,
+	}
+	cfg.RegisterFlags(fs)
+
+	return &cli.Command{
+		Name:       "replay",
+		ShortUsage: fmt.Sprintf("%s replay [option]... <file|dir>...", exeName),
+		ShortHelp:  "Replay captured requests to a recorder or into ClickHouse",
+		LongHelp:   "Replays the requests of capture files, or of all capture files in a directory, in order. Requests are sent to the HTTP gateway of the recorder at --target if set, or else recorded into ClickHouse directly.",
+		Flags:      fs,
+		Exec:       cfg.Exec,
+	}
+}
+
+func (c *ReplayConfig) RegisterFlags(fs *flag.FlagSet) {
+	c.RootConfig.RegisterFlags(fs)
+
+	fs.StringVar(&c.target, "target", "", "The base URL of the recorder's HTTP gateway to send requests to, e.g. 'http://127.0.0.1:9100'. (default: '', record into ClickHouse)")
+	fs.StringVar(&c.method, "method", "", "Only replay requests of this method, e.g. 'RecordJobs'. (default: '', all methods)")
+}
+
+func (c *ReplayConfig) Exec(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("invalid number of positional arguments: %v", args)
+	}
+
+	files, err := replayFiles(args)
+	if err != nil {
+		return err
+	}
+
+	var replay replayFunc
+	if c.target != "" {
+		replay = gatewayReplayFunc(c.target)
+	} else {
+		replay, err = c.recorderReplayFunc(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	summary := map[string]*replaySummary{}
+	for _, file := range files {
+		if err = c.replayFile(ctx, replay, file, summary); err != nil {
+			err = fmt.Errorf("error replaying `%s`: %w", file, err)
+			break
+		}
+	}
+
+	writeReplaySummary(c.out, summary)
+	if err != nil {
+		return err
+	}
+	for _, s := range summary {
+		if s.Failed > 0 {
+			return fmt.Errorf("failed to replay some requests")
+		}
+	}
+	return nil
+}
+
+func (c *ReplayConfig) recorderReplayFunc(ctx context.Context) (replayFunc, error) {
+	// load configuration
+	var cfg config.Config
+	config.SetDefaults(&cfg)
+	if err := loadConfig(c.RootConfig.filename, c.flags, &cfg); err != nil {
+		return nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	// create clickhouse client
+	opts := clickhouse.ClientOptions(clickhouse.ClientConfig{
+		Host:     cfg.ClickHouse.Host,
+		Port:     cfg.ClickHouse.Port,
+		Database: cfg.ClickHouse.Database,
+		User:     cfg.ClickHouse.User,
+		Password: cfg.ClickHouse.Password,
+	})
+	conn, err := clickhouse.Connect(&opts)
+	if err != nil {
+		return nil, fmt.Errorf("error creating clickhouse connection")
+	}
+	client := clickhouse.NewClient(conn, cfg.ClickHouse.Database)
+	client.SetMaxConcurrentQueries(cfg.ClickHouse.Client.MaxConcurrentQueries)
+
+	rec := recorder.New(client)
+	if cfg.Traces.Synthesize {
+		// synthesis runs in the background of the run command only
+		slog.Info("Traces are not synthesized during replay, use the `traces backfill` command")
+	}
+	if cfg.Validation.Enabled {
+		validator, err := recordValidator(cfg.Validation)
+		if err != nil {
+			return nil, fmt.Errorf("error creating record validator: %w", err)
+		}
+		rec.SetValidator(validator)
+	}
+	if cfg.IDCache.Enabled {
+		// warm the cache before replaying, so that replayed requests that
+		// have already been recorded are skipped
+		ids, err := idCache(client, cfg.IDCache)
+		if err != nil {
+			return nil, fmt.Errorf("error creating id cache: %w", err)
+		}
+		if err := ids.Warm(ctx); err != nil {
+			return nil, fmt.Errorf("error warming id cache: %w", err)
+		}
+		rec.SetIDCache(ids)
+	}
+	return rec.Replay, nil
+}
+
+// gatewayReplayFunc returns a function that sends requests to the HTTP
+// gateway of the recorder at the base URL.
+func gatewayReplayFunc(baseURL string) replayFunc {
+	client := &http.Client{}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	return func(ctx context.Context, method string, data []byte) (*servicepb.RecordSummary, error) {
+		path, err := recorder.GatewayPath(method)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+path, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("request failed: %s", resp.Status)
+		}
+
+		var summary servicepb.RecordSummary
+		if err := proto.Unmarshal(body, &summary); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		return &summary, nil
+	}
+}
+
+func (c *ReplayConfig) replayFile(ctx context.Context, replay replayFunc, file string, summary map[string]*replaySummary) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	slog.Info("Replaying requests", "file", file)
+
+	r := capture.NewReader(f)
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if c.method != "" && rec.Method != c.method {
+			continue
+		}
+
+		s, ok := summary[rec.Method]
+		if !ok {
+			s = &replaySummary{Method: rec.Method}
+			summary[rec.Method] = s
+		}
+		s.Requests++
+
+		res, err := replay(ctx, rec.Method, rec.Data)
+		if err != nil {
+			slog.Error("Failed to replay request", "method", rec.Method, "peer", rec.Peer, "timestamp", rec.Timestamp, "error", err)
+			s.Failed++
+			continue
+		}
+		s.Recorded += int64(res.GetRecordedCount())
+	}
+}
+
+// replayFiles returns the files to replay, expanding directories to the
+// capture files they contain.
+func replayFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		dirFiles, err := capture.Files(arg)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+func writeReplaySummary(out io.Writer, summary map[string]*replaySummary) {
+	methods := make([]string, 0, len(summary))
+	for m := range summary {
+		methods = append(methods, m)
+	}
+	slices.Sort(methods)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tREQUESTS\tRECORDED\tFAILED")
+	for _, m := range methods {
+		s := summary[m]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", s.Method, s.Requests, s.Recorded, s.Failed)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cluttrdev/cli"
+	"golang.org/x/exp/slices"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/config"
+)
+
+type RetentionConfig struct {
+	RootConfig
+
+	dryRun bool
+	maxAge time.Duration
+
+	flags *flag.FlagSet
+}
+
+func NewRetentionCmd(out io.Writer) *cli.Command {
+	fs := flag.NewFlagSet(fmt.Sprintf("%s retention", exeName), flag.ContinueOnError)
+
+	cfg := RetentionConfig{
+		RootConfig: RootConfig{
+			out: out,
+		},
+		flags: fs,
+	}
+	cfg.RegisterFlags(fs)
+
+	return &cli.Command{
+		Name:       "retention",
+		ShortUsage: fmt.Sprintf("%s retention [option]... [table]...", exeName),
+		ShortHelp:  "Remove expired data from database tables",
+		Flags:      fs,
+		Exec:       cfg.Exec,
+	}
+}
+
+func (c *RetentionConfig) RegisterFlags(fs *flag.FlagSet) {
+	c.RootConfig.RegisterFlags(fs)
+
+	fs.BoolVar(&c.dryRun, "dry-run", false, "Only report what would be removed. (default: false)")
+	fs.DurationVar(&c.maxAge, "max-age", 0, "Maximum age of the data to keep, overrides configured values. (default: 0, use configuration)")
+}
+
+func (c *RetentionConfig) Exec(ctx context.Context, args []string) error {
+	// load configuration
+	var cfg config.Config
+	config.SetDefaults(&cfg)
+	if err := loadConfig(c.RootConfig.filename, c.flags, &cfg); err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	c.flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "dry-run":
+			cfg.Retention.DryRun = c.dryRun
+		}
+	})
+
+	tables := args
+	if len(tables) == 0 {
+		tables = retentionTables(cfg.Retention)
+	}
+	if len(tables) == 0 {
+		return fmt.Errorf("no tables configured")
+	}
+
+	if c.maxAge > 0 {
+		if cfg.Retention.Tables == nil {
+			cfg.Retention.Tables = map[string]config.RetentionTable{}
+		}
+		for _, table := range tables {
+			cfg.Retention.Tables[table] = config.RetentionTable{MaxAge: c.maxAge}
+		}
+		if err := checkRetentionConfig(cfg); err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+	}
+
+	// create clickhouse client
+	opts := clickhouse.ClientOptions(clickhouse.ClientConfig{
+		Host:     cfg.ClickHouse.Host,
+		Port:     cfg.ClickHouse.Port,
+		Database: cfg.ClickHouse.Database,
+		User:     cfg.ClickHouse.User,
+		Password: cfg.ClickHouse.Password,
+	})
+	conn, err := clickhouse.Connect(&opts)
+	if err != nil {
+		return fmt.Errorf("error creating clickhouse connection")
+	}
+	client := clickhouse.NewClient(conn, cfg.ClickHouse.Database)
+
+	results, err := applyRetention(ctx, client, cfg.Retention, tables)
+	writeRetentionResults(c.out, results, cfg.Retention.DryRun)
+	if err != nil {
+		return fmt.Errorf("error applying retention: %w", err)
+	}
+
+	return nil
+}
+
+// retentionTables returns the configured tables in the order they should be
+// processed. Tables that are matched by their pipeline come first so that the
+// pipelines are still present when their rows are looked up.
+func retentionTables(cfg config.Retention) []string {
+	tables := make([]string, 0, len(cfg.Tables))
+	for table := range cfg.Tables {
+		tables = append(tables, table)
+	}
+	slices.SortFunc(tables, func(a, b string) int {
+		if a == b {
+			return 0
+		} else if a == clickhouse.PipelinesTable {
+			return 1
+		} else if b == clickhouse.PipelinesTable {
+			return -1
+		} else if a < b {
+			return -1
+		}
+		return 1
+	})
+	return tables
+}
+
+func applyRetention(ctx context.Context, client *clickhouse.Client, cfg config.Retention, tables []string) ([]clickhouse.RetentionResult, error) {
+	results := make([]clickhouse.RetentionResult, 0, len(tables))
+	for _, table := range tables {
+		tableCfg, ok := cfg.Tables[table]
+		if !ok {
+			return results, fmt.Errorf("no retention configured for table: %s", table)
+		}
+
+		result, err := clickhouse.ApplyRetention(client, ctx, clickhouse.RetentionOptions{
+			Table:  table,
+			MaxAge: tableCfg.MaxAge,
+			DryRun: cfg.DryRun,
+		})
+		if err != nil {
+			return results, fmt.Errorf("table `%s`: %w", table, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func writeRetentionResults(out io.Writer, results []clickhouse.RetentionResult, dryRun bool) {
+	if dryRun {
+		fmt.Fprintln(out, "Dry run, nothing has been removed")
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tMETHOD\tPARTITIONS\tROWS\tBYTES")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n", r.Table, r.Method, len(r.Partitions), r.Rows, r.Bytes)
+	}
+	_ = w.Flush()
+
+	for _, r := range results {
+		for _, p := range r.Partitions {
+			fmt.Fprintf(out, "%s: partition %s (%s - %s), %d rows, %d bytes\n",
+				r.Table, p.Partition,
+				p.MinTime.UTC().Format(time.DateTime), p.MaxTime.UTC().Format(time.DateTime),
+				p.Rows, p.Bytes,
+			)
+		}
+	}
+}
+
+// runRetention periodically applies the configured retention until the
+// context is cancelled.
+func runRetention(ctx context.Context, client *clickhouse.Client, cfg config.Retention) error {
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("invalid retention interval: %v", cfg.Interval)
+	}
+
+	tables := retentionTables(cfg)
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		results, err := applyRetention(ctx, client, cfg, tables)
+		if err != nil {
+			slog.Error("error applying retention", "error", err)
+		}
+		for _, r := range results {
+			slog.Info("Applied retention",
+				"table", r.Table,
+				"method", r.Method,
+				"partitions", len(r.Partitions),
+				"rows", r.Rows,
+				"bytes", r.Bytes,
+				"dry_run", cfg.DryRun,
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -44,6 +44,7 @@ func (c *RootConfig) RegisterFlags(fs *flag.FlagSet) {
 	_ = fs.String("clickhouse-database", "default", "Select the current default ClickHouse database (default: 'default').")
 	_ = fs.String("clickhouse-user", "default", "The ClickHouse username to connect with (default: 'default').")
 	_ = fs.String("clickhouse-password", "", "The ClickHouse password (default: '').")
+	_ = fs.String("clickhouse-http-port", "8123", "The ClickHouse HTTP interface port, used for exports (default: '8123').")
 
 	_ = fs.Int64("clickhouse-client-max-concurrent-queries", 0, "The maximum number of concurrent queries the client sends to clickhouse (default: 0, unlimited).")
 
@@ -77,6 +78,8 @@ func loadConfig(filename string, flags *flag.FlagSet, cfg *config.Config) error
 			cfg.ClickHouse.User = f.Value.String()
 		case "clickhouse-password":
 			cfg.ClickHouse.Password = f.Value.String()
+		case "clickhouse-http-port":
+			cfg.ClickHouse.HTTPPort = f.Value.String()
 
 		case "clickhouse-client-max-concurrent-queries":
 			n, err := strconv.ParseInt(f.Value.String(), 10, 64)
@@ -91,6 +94,17 @@ func loadConfig(filename string, flags *flag.FlagSet, cfg *config.Config) error
 		return fmt.Errorf("invalid config: max_concurrent_queries")
 	}
 
+	return checkRetentionConfig(*cfg)
+}
+
+// checkRetentionConfig rejects retention max ages of tables with a schema ttl,
+// since both would set the table ttl, overwriting each other.
+func checkRetentionConfig(cfg config.Config) error {
+	for table := range cfg.Retention.Tables {
+		if cfg.ClickHouse.Schema.Tables[table].TTL != "" {
+			return fmt.Errorf("invalid config: table `%s` has both a schema ttl and a retention max age", table)
+		}
+	}
 	return nil
 }
 
@@ -17,13 +17,23 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/exp/slices"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"go.cluttr.dev/gitlab-exporter/grpc/server"
 
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/capture"
 	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
 	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/config"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/forward"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/idcache"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/jaeger"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/maintenance"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/otlp"
 	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/recorder"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/sampling"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/validation"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/webhook"
 )
 
 type RunConfig struct {
@@ -35,6 +45,8 @@ type RunConfig struct {
 	LogLevel  string
 	LogFormat string
 
+	dryRun bool
+
 	flags *flag.FlagSet
 }
 
@@ -67,6 +79,8 @@ func (c *RunConfig) RegisterFlags(fs *flag.FlagSet) {
 
 	fs.StringVar(&c.LogLevel, "log-level", "info", "The logging level, one of 'debug', 'info', 'warn', 'error'. (default: 'info')")
 	fs.StringVar(&c.LogFormat, "log-format", "text", "The logging format, either 'text' or 'json'. (default: 'text')")
+
+	fs.BoolVar(&c.dryRun, "dry-run", false, "Validate and count received records against the database schema without inserting them.")
 }
 
 func (c *RunConfig) Exec(ctx context.Context, args []string) error {
@@ -96,6 +110,13 @@ func (c *RunConfig) Exec(ctx context.Context, args []string) error {
 		cfg.Log.Level = "debug"
 	}
 
+	if c.dryRun {
+		// disable tasks that modify the database or pass data on
+		cfg.Retention.Enabled = false
+		cfg.Maintenance.Enabled = false
+		cfg.Traces.Forward.Enabled = false
+	}
+
 	if cfg.Log.Level == "debug" {
 		writeConfig(c.out, cfg)
 	}
@@ -119,8 +140,84 @@ func (c *RunConfig) Exec(ctx context.Context, args []string) error {
 		return fmt.Errorf("error checking database schema: %w", err)
 	}
 
+	if c.dryRun {
+		schema, err := clickhouse.SelectSchemaSnapshot(client, ctx)
+		if err != nil {
+			return fmt.Errorf("error taking schema snapshot: %w", err)
+		}
+		client.SetDryRun(schema)
+		slog.Warn("Running in dry-run mode, received data is validated but not inserted")
+	}
+
 	// create recorder
 	rec := recorder.New(client)
+	if cfg.Traces.Synthesize {
+		rec.SetTraceSynthesis(recorder.SynthesisOptions{
+			UntracedOnly: cfg.Traces.SynthesizeUntracedOnly,
+			QueueSize:    cfg.Traces.SynthesizeQueueSize,
+		})
+	}
+
+	// create trace sampler and forwarder
+	sampler := traceSampler(client, cfg.Traces.Sampling)
+	var forwarder *forward.Forwarder
+	if cfg.Traces.Forward.Enabled {
+		forwarder, err = traceForwarder(cfg.Traces.Forward)
+		if err != nil {
+			return fmt.Errorf("error creating trace forwarder: %w", err)
+		}
+		if sampler == nil {
+			// keep all traces, but pass them to the forwarder
+			sampler = sampling.NewSampler(client, sampling.Options{Head: sampling.HeadOptions{Rate: 1}})
+		}
+		sampler.SetForwardFunc(forwarder.Forward)
+	}
+	rec.SetTraceSampler(sampler)
+
+	// create record validator
+	var validator *validation.Validator
+	if cfg.Validation.Enabled {
+		validator, err = recordValidator(cfg.Validation)
+		if err != nil {
+			return fmt.Errorf("error creating record validator: %w", err)
+		}
+		rec.SetValidator(validator)
+	}
+
+	// create id cache
+	var ids *idcache.Cache
+	if cfg.IDCache.Enabled {
+		ids, err = idCache(client, cfg.IDCache)
+		if err != nil {
+			return fmt.Errorf("error creating id cache: %w", err)
+		}
+		rec.SetIDCache(ids)
+	}
+
+	// create request capturer
+	var capturer *capture.Capturer
+	if cfg.Capture.Enabled {
+		capturer, err = capture.New(capture.Options{
+			Dir:         cfg.Capture.Dir,
+			MaxFileSize: cfg.Capture.MaxFileSizeMB << 20,
+			MaxFiles:    cfg.Capture.MaxFiles,
+			SampleRate:  cfg.Capture.SampleRate,
+		})
+		if err != nil {
+			return fmt.Errorf("error creating request capturer: %w", err)
+		}
+		defer capturer.Close()
+		rec.SetCapturer(capturer)
+	}
+
+	// create webhook receiver
+	var webhooks *webhook.Handler
+	if cfg.Webhook.Enabled {
+		if cfg.Webhook.Token == "" {
+			return fmt.Errorf("error creating webhook receiver: token is required")
+		}
+		webhooks = webhook.NewHandler(rec, cfg.Webhook.Token)
+	}
 
 	// create grpc server
 	grpcServer := server.New(rec)
@@ -177,15 +274,150 @@ func (c *RunConfig) Exec(ctx context.Context, args []string) error {
 		}
 	}
 
+	if cfg.OTLP.HTTP.Enabled && !cfg.HTTP.Enabled {
+		slog.Warn("OTLP/HTTP requires the http server to be enabled")
+	}
+	if cfg.Prometheus.RemoteWrite.Enabled && !cfg.HTTP.Enabled {
+		slog.Warn("Prometheus remote-write requires the http server to be enabled")
+	}
+	if cfg.Traces.Query.Enabled && !cfg.HTTP.Enabled {
+		slog.Warn("Trace query API requires the http server to be enabled")
+	}
+	if cfg.Webhook.Enabled && !cfg.HTTP.Enabled {
+		slog.Warn("Webhooks require the http server to be enabled")
+	}
+
+	if ids != nil { // warm id cache
+		ctx, cancel := context.WithCancel(ctx)
+		g.Add(func() error { // execute
+			return ids.Run(ctx)
+		}, func(err error) { // interrupt
+			cancel()
+		})
+	}
+
+	if cfg.Traces.Synthesize { // synthesize traces
+		ctx, cancel := context.WithCancel(ctx)
+		g.Add(func() error { // execute
+			return rec.RunTraceSynthesis(ctx)
+		}, func(err error) { // interrupt
+			cancel()
+		})
+	}
+
+	if sampler != nil { // sample buffered traces
+		ctx, cancel := context.WithCancel(ctx)
+		g.Add(func() error { // execute
+			return sampler.Run(ctx)
+		}, func(err error) { // interrupt
+			cancel()
+		})
+	}
+
+	if forwarder != nil { // forward traces
+		ctx, cancel := context.WithCancel(ctx)
+		g.Add(func() error { // execute
+			slog.Info("Starting trace forwarder", "endpoints", len(cfg.Traces.Forward.Endpoints))
+			return forwarder.Run(ctx)
+		}, func(err error) { // interrupt
+			cancel()
+		})
+	}
+
+	if cfg.OTLP.GRPC.Enabled { // serve otlp grpc
+		otlpServer := otlp.NewServer(rec)
+
+		ctx, cancel := context.WithCancel(ctx)
+		g.Add(func() error { // execute
+			slog.Info("Starting otlp grpc server")
+			addr := fmt.Sprintf("%s:%s", cfg.OTLP.GRPC.Host, cfg.OTLP.GRPC.Port)
+			return otlpServer.ListenAndServe(ctx, addr)
+		}, func(err error) { // interrupt
+			slog.Info("Stopping otlp grpc server...")
+			cancel()
+			slog.Info("Stopping otlp grpc server... done")
+		})
+	}
+
+	var scheduler *maintenance.Scheduler
+	if cfg.Maintenance.Enabled { // run maintenance jobs
+		jobs, err := maintenanceJobs(cfg.ClickHouse.Database, cfg.Maintenance)
+		if err != nil {
+			return fmt.Errorf("error creating maintenance jobs: %w", err)
+		}
+		scheduler = maintenance.NewScheduler(client, jobs)
+
+		ctx, cancel := context.WithCancel(ctx)
+		g.Add(func() error { // execute
+			slog.Info("Starting maintenance scheduler", "jobs", len(jobs))
+			return scheduler.Run(ctx)
+		}, func(err error) { // interrupt
+			cancel()
+		})
+	}
+
 	if cfg.HTTP.Enabled { // serve http
 		reg := prometheus.NewRegistry()
 		reg.MustRegister(
 			grpcServer.MetricsCollector(),
+			rec.MetricsCollector(),
 			collectors.NewGoCollector(),
 			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 		)
+		if scheduler != nil {
+			reg.MustRegister(scheduler.MetricsCollector())
+		}
+		if sampler != nil {
+			reg.MustRegister(sampler.MetricsCollector())
+		}
+		if forwarder != nil {
+			reg.MustRegister(forwarder.MetricsCollector())
+		}
+		if webhooks != nil {
+			reg.MustRegister(webhooks.MetricsCollector())
+		}
+		if capturer != nil {
+			reg.MustRegister(capturer.MetricsCollector())
+		}
+		if validator != nil {
+			reg.MustRegister(validator.MetricsCollector())
+		}
+		if ids != nil {
+			reg.MustRegister(ids.MetricsCollector())
+		}
+
+		handlers := map[string]http.Handler{}
+		if cfg.OTLP.HTTP.Enabled {
+			for path, h := range otlp.HTTPHandlers(rec) {
+				handlers[path] = h
+			}
+		}
+		if cfg.Prometheus.RemoteWrite.Enabled {
+			handlers[cfg.Prometheus.RemoteWrite.Path] = rec.RemoteWriteHandler(cfg.Prometheus.RemoteWrite.AllowedLabels)
+		}
+		if cfg.HTTP.Gateway.Enabled {
+			handlers[recorder.GatewayPattern] = rec.GatewayHandler()
+		}
+		if cfg.Traces.Query.Enabled {
+			for pattern, h := range jaeger.HTTPHandlers(client) {
+				handlers[pattern] = h
+			}
+		}
+		if webhooks != nil {
+			handlers[cfg.Webhook.Path] = webhooks
+		}
 
-		g.Add(serveHTTP(cfg.HTTP, reg))
+		g.Add(serveHTTP(cfg.HTTP, reg, handlers))
+	}
+
+	if cfg.Retention.Enabled { // apply retention
+		ctx, cancel := context.WithCancel(ctx)
+		g.Add(func() error { // execute
+			slog.Info("Starting retention task", "interval", cfg.Retention.Interval)
+			return runRetention(ctx, client, cfg.Retention)
+		}, func(err error) { // interrupt
+			cancel()
+		})
 	}
 
 	{ // signal handler
@@ -205,6 +437,92 @@ func (c *RunConfig) Exec(ctx context.Context, args []string) error {
 	return g.Run()
 }
 
+// traceSampler returns the sampler for the configuration, or nil if all
+// traces are kept.
+func traceSampler(client *clickhouse.Client, cfg config.TracesSampling) *sampling.Sampler {
+	if cfg.Head.Rate >= 1 && len(cfg.Head.Rules) == 0 && !cfg.Tail.Enabled {
+		return nil
+	}
+
+	rules := make([]sampling.HeadRule, 0, len(cfg.Head.Rules))
+	for _, r := range cfg.Head.Rules {
+		rules = append(rules, sampling.HeadRule{
+			Service:  r.Service,
+			SpanName: r.SpanName,
+			Rate:     r.Rate,
+		})
+	}
+
+	return sampling.NewSampler(client, sampling.Options{
+		Head: sampling.HeadOptions{
+			Rate:  cfg.Head.Rate,
+			Rules: rules,
+		},
+		Tail: sampling.TailOptions{
+			Enabled:        cfg.Tail.Enabled,
+			Window:         cfg.Tail.Window,
+			MaxTraces:      cfg.Tail.MaxTraces,
+			KeepErrors:     cfg.Tail.KeepErrors,
+			KeepSlowerThan: cfg.Tail.KeepSlowerThan,
+			Rate:           cfg.Tail.Rate,
+			DecisionTTL:    cfg.Tail.DecisionTTL,
+		},
+	})
+}
+
+// recordValidator returns the validator for the configuration.
+func recordValidator(cfg config.Validation) (*validation.Validator, error) {
+	policy, err := validation.ParsePolicy(cfg.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make(map[string]validation.Policy, len(cfg.Policies))
+	for table, p := range cfg.Policies {
+		if !slices.Contains(clickhouse.Tables, table) {
+			return nil, fmt.Errorf("unknown table: %q", table)
+		}
+		if policies[table], err = validation.ParsePolicy(p); err != nil {
+			return nil, fmt.Errorf("table `%s`: %w", table, err)
+		}
+	}
+
+	return validation.New(validation.Options{
+		Policy:   policy,
+		Policies: policies,
+	}), nil
+}
+
+// idCache returns the id cache for the configuration.
+func idCache(client *clickhouse.Client, cfg config.IDCache) (*idcache.Cache, error) {
+	return idcache.New(client, idcache.Options{
+		FalsePositiveRate: cfg.FalsePositiveRate,
+		MinCapacity:       cfg.MinCapacity,
+	})
+}
+
+// traceForwarder returns the forwarder for the configuration.
+func traceForwarder(cfg config.TracesForward) (*forward.Forwarder, error) {
+	endpoints := make([]forward.Endpoint, 0, len(cfg.Endpoints))
+	for _, ep := range cfg.Endpoints {
+		endpoints = append(endpoints, forward.Endpoint{
+			Name:     ep.Name,
+			Protocol: ep.Protocol,
+			Address:  ep.Address,
+			Insecure: ep.Insecure,
+			Headers:  ep.Headers,
+			Timeout:  ep.Timeout,
+		})
+	}
+
+	return forward.New(endpoints, forward.Options{
+		QueueSize:           cfg.QueueSize,
+		MaxRetries:          cfg.MaxRetries,
+		RetryInitialBackoff: cfg.RetryInitialBackoff,
+		RetryMaxBackoff:     cfg.RetryMaxBackoff,
+	})
+}
+
 func (c *RunConfig) checkSchemaVersion(ctx context.Context, ch *clickhouse.Client) error {
 	schemaVersion, dirty, err := clickhouse.GetSchemaVersion(ch, ctx)
 	if err != nil {
@@ -228,9 +546,40 @@ func (c *RunConfig) checkSchemaVersion(ctx context.Context, ch *clickhouse.Clien
 	return nil
 }
 
-func serveHTTP(cfg config.HTTP, reg *prometheus.Registry) (func() error, func(error)) {
+func maintenanceJobs(database string, cfg config.Maintenance) ([]maintenance.Job, error) {
+	jobs := make([]maintenance.Job, 0, len(cfg.Jobs))
+	for _, j := range cfg.Jobs {
+		throwIfNoop := false
+		job, err := maintenance.NewJob(j.Schedule, clickhouse.DeduplicateTableOptions{
+			Database:    database,
+			Table:       j.Table,
+			Final:       j.Final,
+			By:          j.By,
+			Except:      j.Except,
+			ThrowIfNoop: &throwIfNoop,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("table `%s`: %w", j.Table, err)
+		}
+		if j.Adaptive != nil {
+			job.Adaptive = &clickhouse.AdaptiveDeduplicateOptions{
+				MinParts:          j.Adaptive.MinParts,
+				MinDuplicateRatio: j.Adaptive.MinDuplicateRatio,
+				SampleRows:        j.Adaptive.SampleRows,
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func serveHTTP(cfg config.HTTP, reg *prometheus.Registry, handlers map[string]http.Handler) (func() error, func(error)) {
 	m := http.NewServeMux()
 
+	for pattern, h := range handlers {
+		m.Handle(pattern, h)
+	}
+
 	m.Handle(
 		"/metrics",
 		promhttp.InstrumentMetricHandler(
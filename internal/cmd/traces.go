@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/cluttrdev/cli"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/config"
+)
+
+func NewTracesCmd(out io.Writer) *cli.Command {
+	fs := flag.NewFlagSet(fmt.Sprintf("%s traces", exeName), flag.ContinueOnError)
+
+	cfg := RootConfig{
+		out:   out,
+		flags: fs,
+	}
+	cfg.RegisterFlags(fs)
+
+	return &cli.Command{
+		Name:       "traces",
+		ShortUsage: fmt.Sprintf("%s traces <subcommand> [option]...", exeName),
+		ShortHelp:  "Manage trace data",
+		Flags:      fs,
+		Exec:       cfg.Exec,
+		Subcommands: []*cli.Command{
+			NewTracesPromoteAttributeCmd(out),
+			NewTracesBackfillCmd(out),
+		},
+	}
+}
+
+type TracesPromoteAttributeConfig struct {
+	RootConfig
+
+	column      string
+	typ         string
+	index       string
+	materialize bool
+
+	flags *flag.FlagSet
+}
+
+func NewTracesPromoteAttributeCmd(out io.Writer) *cli.Command {
+	fs := flag.NewFlagSet(fmt.Sprintf("%s traces promote-attribute", exeName), flag.ContinueOnError)
+
+	cfg := TracesPromoteAttributeConfig{
+		RootConfig: RootConfig{
+			out: out,
+		},
+		flags: fs,
+	}
+	cfg.RegisterFlags(fs)
+
+	return &cli.Command{
+		Name:       "promote-attribute",
+		ShortUsage: fmt.Sprintf("%s traces promote-attribute [option]... [key]...", exeName),
+		ShortHelp:  "Store span attributes in dedicated materialized columns, defaults to the configured attributes",
+		Flags:      fs,
+		Exec:       cfg.Exec,
+	}
+}
+
+func (c *TracesPromoteAttributeConfig) RegisterFlags(fs *flag.FlagSet) {
+	c.RootConfig.RegisterFlags(fs)
+
+	fs.StringVar(&c.column, "column", "", "The column name, only valid for a single key. (default: derived from the key)")
+	fs.StringVar(&c.typ, "type", "String", "The column type, one of 'String', 'LowCardinality(String)', 'Int64', 'Float64', 'Bool'. (default: 'String')")
+	fs.StringVar(&c.index, "index", "", "The skip index type, one of minmax, set(N), bloom_filter(P), tokenbf_v1(...), ngrambf_v1(...) or 'none'. (default: 'bloom_filter(0.01)', 'minmax' for Float64)")
+	fs.BoolVar(&c.materialize, "materialize", false, "Also build the column and index for existing data. (default: false)")
+}
+
+func (c *TracesPromoteAttributeConfig) Exec(ctx context.Context, args []string) error {
+	// load configuration
+	var cfg config.Config
+	config.SetDefaults(&cfg)
+	if err := loadConfig(c.RootConfig.filename, c.flags, &cfg); err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	if c.debug {
+		cfg.Log.Level = "debug"
+	}
+	initLogging(c.out, cfg.Log)
+
+	attrs := promotedAttributes(cfg.ClickHouse.Schema.PromotedAttributes)
+	if len(args) > 0 {
+		if c.column != "" && len(args) > 1 {
+			return fmt.Errorf("column name can only be set for a single key")
+		}
+
+		attrs = make([]clickhouse.PromotedAttribute, 0, len(args))
+		for _, key := range args {
+			attrs = append(attrs, clickhouse.PromotedAttribute{
+				Key:    key,
+				Column: c.column,
+				Type:   c.typ,
+				Index:  c.index,
+			})
+		}
+	}
+	if len(attrs) == 0 {
+		return fmt.Errorf("no attributes given or configured")
+	}
+
+	// create clickhouse client
+	opts := clickhouse.ClientOptions(clickhouse.ClientConfig{
+		Host:     cfg.ClickHouse.Host,
+		Port:     cfg.ClickHouse.Port,
+		Database: cfg.ClickHouse.Database,
+		User:     cfg.ClickHouse.User,
+		Password: cfg.ClickHouse.Password,
+	})
+	conn, err := clickhouse.Connect(&opts)
+	if err != nil {
+		return fmt.Errorf("error creating clickhouse connection")
+	}
+	client := clickhouse.NewClient(conn, cfg.ClickHouse.Database)
+
+	for _, attr := range attrs {
+		if err := clickhouse.PromoteAttribute(client, ctx, attr, c.materialize); err != nil {
+			return fmt.Errorf("error promoting attribute: %w", err)
+		}
+		slog.Info("Promoted span attribute", "key", attr.Key, "materialized", c.materialize)
+	}
+
+	return nil
+}
+
+type TracesBackfillConfig struct {
+	RootConfig
+
+	projectId int64
+	since     string
+	until     string
+	batchSize int
+
+	flags *flag.FlagSet
+}
+
+func NewTracesBackfillCmd(out io.Writer) *cli.Command {
+	fs := flag.NewFlagSet(fmt.Sprintf("%s traces backfill", exeName), flag.ContinueOnError)
+
+	cfg := TracesBackfillConfig{
+		RootConfig: RootConfig{
+			out: out,
+		},
+		flags: fs,
+	}
+	cfg.RegisterFlags(fs)
+
+	return &cli.Command{
+		Name:       "backfill",
+		ShortUsage: fmt.Sprintf("%s traces backfill [option]...", exeName),
+		ShortHelp:  "Synthesize traces from recorded pipelines, jobs and sections",
+		Flags:      fs,
+		Exec:       cfg.Exec,
+	}
+}
+
+func (c *TracesBackfillConfig) RegisterFlags(fs *flag.FlagSet) {
+	c.RootConfig.RegisterFlags(fs)
+
+	fs.Int64Var(&c.projectId, "project-id", 0, "Only synthesize traces of pipelines of this project. (default: 0, all projects)")
+	fs.StringVar(&c.since, "since", "", "Only synthesize traces of pipelines created at or after this time, in RFC 3339 or YYYY-MM-DD format.")
+	fs.StringVar(&c.until, "until", "", "Only synthesize traces of pipelines created before this time, in RFC 3339 or YYYY-MM-DD format.")
+	fs.IntVar(&c.batchSize, "batch-size", 100, "The number of pipelines to process at once. (default: 100)")
+}
+
+func (c *TracesBackfillConfig) Exec(ctx context.Context, args []string) error {
+	// load configuration
+	var cfg config.Config
+	config.SetDefaults(&cfg)
+	if err := loadConfig(c.RootConfig.filename, c.flags, &cfg); err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	if c.debug {
+		cfg.Log.Level = "debug"
+	}
+	initLogging(c.out, cfg.Log)
+
+	if c.batchSize <= 0 {
+		return fmt.Errorf("invalid batch size: %d", c.batchSize)
+	}
+
+	filter := clickhouse.PipelineFilter{
+		ProjectId: c.projectId,
+		Limit:     c.batchSize,
+	}
+	var err error
+	if filter.Since, err = parseTime(c.since); err != nil {
+		return fmt.Errorf("invalid since: %w", err)
+	}
+	if filter.Until, err = parseTime(c.until); err != nil {
+		return fmt.Errorf("invalid until: %w", err)
+	}
+
+	// create clickhouse client
+	opts := clickhouse.ClientOptions(clickhouse.ClientConfig{
+		Host:     cfg.ClickHouse.Host,
+		Port:     cfg.ClickHouse.Port,
+		Database: cfg.ClickHouse.Database,
+		User:     cfg.ClickHouse.User,
+		Password: cfg.ClickHouse.Password,
+	})
+	conn, err := clickhouse.Connect(&opts)
+	if err != nil {
+		return fmt.Errorf("error creating clickhouse connection")
+	}
+	client := clickhouse.NewClient(conn, cfg.ClickHouse.Database)
+
+	var pipelineCount, spanCount int
+	for {
+		pipelines, err := clickhouse.SelectPipelines(client, ctx, filter)
+		if err != nil {
+			return fmt.Errorf("error selecting pipelines: %w", err)
+		} else if len(pipelines) == 0 {
+			break
+		}
+
+		n, err := backfillTraces(ctx, client, pipelines)
+		if err != nil {
+			return fmt.Errorf("error backfilling traces: %w", err)
+		}
+		pipelineCount += len(pipelines)
+		spanCount += n
+		slog.Debug("Backfilled traces", "pipelines", pipelineCount, "spans", spanCount)
+
+		filter.AfterId = pipelines[len(pipelines)-1].Id
+	}
+
+	fmt.Fprintf(c.out, "Synthesized %d spans from %d pipelines\n", spanCount, pipelineCount)
+	return nil
+}
+
+func backfillTraces(ctx context.Context, client *clickhouse.Client, pipelines []*clickhouse.Pipeline) (int, error) {
+	ids := make([]int64, 0, len(pipelines))
+	for _, p := range pipelines {
+		ids = append(ids, p.Id)
+	}
+
+	jobs, err := clickhouse.SelectPipelineJobs(client, ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("select jobs: %w", err)
+	}
+	sections, err := clickhouse.SelectPipelineSections(client, ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("select sections: %w", err)
+	}
+
+	traces := clickhouse.SynthesizeTraces(pipelines, jobs, sections)
+	if len(traces) == 0 {
+		return 0, nil
+	}
+	return clickhouse.InsertTraces(client, ctx, traces)
+}
+
+// parseTime parses a time in RFC 3339 or date only format, an empty string
+// yields the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
@@ -1,14 +1,26 @@
 package config
 
 import (
+	"time"
+
 	"github.com/creasty/defaults"
 )
 
 type Config struct {
 	ClickHouse ClickHouse `default:"{}" yaml:"clickhouse"`
 	Server     Server     `default:"{}" yaml:"server"`
+	OTLP       OTLP       `default:"{}" yaml:"otlp"`
+	Prometheus Prometheus `default:"{}" yaml:"prometheus"`
+	Traces     Traces     `default:"{}" yaml:"traces"`
+	Webhook    Webhook    `default:"{}" yaml:"webhook"`
+	Capture    Capture    `default:"{}" yaml:"capture"`
+	Validation Validation `default:"{}" yaml:"validation"`
+	IDCache    IDCache    `default:"{}" yaml:"id_cache"`
 	HTTP       HTTP       `default:"{}" yaml:"http"`
 	Log        Log        `default:"{}" yaml:"log"`
+	Retention  Retention  `default:"{}" yaml:"retention"`
+
+	Maintenance Maintenance `default:"{}" yaml:"maintenance"`
 }
 
 type ClickHouse struct {
@@ -17,24 +29,160 @@ type ClickHouse struct {
 	Database string `default:"default" yaml:"database"`
 	User     string `default:"default" yaml:"user"`
 	Password string `default:"" yaml:"password"`
+	HTTPPort string `default:"8123" yaml:"http_port"`
 
 	Client ClickHouseClient `default:"{}" yaml:"client"`
+	Schema ClickHouseSchema `default:"{}" yaml:"schema"`
 }
 
 type ClickHouseClient struct {
 	MaxConcurrentQueries int64 `default:"0" yaml:"max_concurrent_queries"`
 }
 
+type ClickHouseSchema struct {
+	Tables map[string]ClickHouseTable `yaml:"tables"`
+
+	PromotedAttributes []ClickHousePromotedAttribute `yaml:"promoted_attributes"`
+}
+
+type ClickHouseTable struct {
+	TTL                  string            `yaml:"ttl"`
+	StoragePolicy        string            `yaml:"storage_policy"`
+	PartitionGranularity string            `yaml:"partition_granularity"`
+	Settings             map[string]string `yaml:"settings"`
+	Codecs               map[string]string `yaml:"codecs"`
+}
+
+type ClickHousePromotedAttribute struct {
+	Key    string `yaml:"key"`
+	Column string `yaml:"column"`
+	Type   string `yaml:"type"`
+	Index  string `yaml:"index"`
+}
+
 type Server struct {
 	Host string `default:"0.0.0.0" yaml:"host"`
 	Port string `default:"0" yaml:"port"`
 }
 
+type OTLP struct {
+	GRPC OTLPGRPC `default:"{}" yaml:"grpc"`
+	HTTP OTLPHTTP `default:"{}" yaml:"http"`
+}
+
+type OTLPGRPC struct {
+	Enabled bool   `default:"false" yaml:"enabled"`
+	Host    string `default:"0.0.0.0" yaml:"host"`
+	Port    string `default:"4317" yaml:"port"`
+}
+
+type OTLPHTTP struct {
+	Enabled bool `default:"false" yaml:"enabled"`
+}
+
+type Prometheus struct {
+	RemoteWrite PrometheusRemoteWrite `default:"{}" yaml:"remote_write"`
+}
+
+type PrometheusRemoteWrite struct {
+	Enabled       bool     `default:"false" yaml:"enabled"`
+	Path          string   `default:"/api/v1/write" yaml:"path"`
+	AllowedLabels []string `yaml:"allowed_labels"`
+}
+
+type Webhook struct {
+	Enabled bool   `default:"false" yaml:"enabled"`
+	Path    string `default:"/webhook" yaml:"path"`
+	Token   string `default:"" yaml:"token"`
+}
+
+type Capture struct {
+	Enabled       bool    `default:"false" yaml:"enabled"`
+	Dir           string  `default:"capture" yaml:"dir"`
+	MaxFileSizeMB int64   `default:"100" yaml:"max_file_size_mb"`
+	MaxFiles      int     `default:"10" yaml:"max_files"`
+	SampleRate    float64 `default:"1" yaml:"sample_rate"`
+}
+
+type Validation struct {
+	Enabled  bool              `default:"false" yaml:"enabled"`
+	Policy   string            `default:"warn" yaml:"policy"`
+	Policies map[string]string `yaml:"policies"`
+}
+
+type IDCache struct {
+	Enabled           bool    `default:"true" yaml:"enabled"`
+	FalsePositiveRate float64 `default:"0.000001" yaml:"false_positive_rate"`
+	MinCapacity       int     `default:"1000000" yaml:"min_capacity"`
+}
+
+type Traces struct {
+	Synthesize             bool           `default:"false" yaml:"synthesize"`
+	SynthesizeUntracedOnly bool           `default:"true" yaml:"synthesize_untraced_only"`
+	SynthesizeQueueSize    int            `default:"1000" yaml:"synthesize_queue_size"`
+	Sampling               TracesSampling `default:"{}" yaml:"sampling"`
+	Query                  TracesQuery    `default:"{}" yaml:"query"`
+	Forward                TracesForward  `default:"{}" yaml:"forward"`
+}
+
+type TracesForward struct {
+	Enabled             bool                    `default:"false" yaml:"enabled"`
+	QueueSize           int                     `default:"1000" yaml:"queue_size"`
+	MaxRetries          int                     `default:"5" yaml:"max_retries"`
+	RetryInitialBackoff time.Duration           `default:"1s" yaml:"retry_initial_backoff"`
+	RetryMaxBackoff     time.Duration           `default:"30s" yaml:"retry_max_backoff"`
+	Endpoints           []TracesForwardEndpoint `yaml:"endpoints"`
+}
+
+type TracesForwardEndpoint struct {
+	Name     string            `yaml:"name"`
+	Protocol string            `yaml:"protocol"`
+	Address  string            `yaml:"address"`
+	Insecure bool              `yaml:"insecure"`
+	Headers  map[string]string `yaml:"headers"`
+	Timeout  time.Duration     `yaml:"timeout"`
+}
+
+type TracesQuery struct {
+	Enabled bool `default:"false" yaml:"enabled"`
+}
+
+type TracesSampling struct {
+	Head TracesHeadSampling `default:"{}" yaml:"head"`
+	Tail TracesTailSampling `default:"{}" yaml:"tail"`
+}
+
+type TracesHeadSampling struct {
+	Rate  float64                  `default:"1" yaml:"rate"`
+	Rules []TracesHeadSamplingRule `yaml:"rules"`
+}
+
+type TracesHeadSamplingRule struct {
+	Service  string  `yaml:"service"`
+	SpanName string  `yaml:"span_name"`
+	Rate     float64 `yaml:"rate"`
+}
+
+type TracesTailSampling struct {
+	Enabled        bool          `default:"false" yaml:"enabled"`
+	Window         time.Duration `default:"30s" yaml:"window"`
+	MaxTraces      int           `default:"10000" yaml:"max_traces"`
+	KeepErrors     bool          `default:"true" yaml:"keep_errors"`
+	KeepSlowerThan time.Duration `default:"0s" yaml:"keep_slower_than"`
+	Rate           float64       `default:"0" yaml:"rate"`
+	DecisionTTL    time.Duration `default:"5m" yaml:"decision_ttl"`
+}
+
 type HTTP struct {
-	Enabled bool   `default:"true" yaml:"enabled"`
-	Host    string `default:"127.0.0.1" yaml:"host"`
-	Port    string `default:"9100" yaml:"port"`
-	Debug   bool   `default:"false" yaml:"debug"`
+	Enabled bool        `default:"true" yaml:"enabled"`
+	Host    string      `default:"127.0.0.1" yaml:"host"`
+	Port    string      `default:"9100" yaml:"port"`
+	Debug   bool        `default:"false" yaml:"debug"`
+	Gateway HTTPGateway `default:"{}" yaml:"gateway"`
+}
+
+type HTTPGateway struct {
+	Enabled bool `default:"false" yaml:"enabled"`
 }
 
 type Log struct {
@@ -42,6 +190,38 @@ type Log struct {
 	Format string `default:"text" yaml:"format"`
 }
 
+type Retention struct {
+	Enabled  bool                      `default:"false" yaml:"enabled"`
+	Interval time.Duration             `default:"24h" yaml:"interval"`
+	DryRun   bool                      `default:"false" yaml:"dry_run"`
+	Tables   map[string]RetentionTable `yaml:"tables"`
+}
+
+type RetentionTable struct {
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+type Maintenance struct {
+	Enabled bool             `default:"false" yaml:"enabled"`
+	Jobs    []MaintenanceJob `yaml:"jobs"`
+}
+
+type MaintenanceJob struct {
+	Table    string   `yaml:"table"`
+	Schedule string   `yaml:"schedule"`
+	Final    *bool    `yaml:"final"`
+	By       []string `yaml:"by"`
+	Except   []string `yaml:"except"`
+
+	Adaptive *MaintenanceAdaptive `yaml:"adaptive"`
+}
+
+type MaintenanceAdaptive struct {
+	MinParts          uint64  `yaml:"min_parts"`
+	MinDuplicateRatio float64 `yaml:"min_duplicate_ratio"`
+	SampleRows        uint64  `yaml:"sample_rows"`
+}
+
 func SetDefaults(cfg *Config) {
 	defaults.MustSet(cfg)
 }
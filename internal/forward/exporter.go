@@ -0,0 +1,120 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type grpcExporter struct {
+	conn    *grpc.ClientConn
+	client  coltracepb.TraceServiceClient
+	headers metadata.MD
+}
+
+func newGRPCExporter(ep Endpoint) (*grpcExporter, error) {
+	creds := credentials.NewTLS(&tls.Config{})
+	if ep.Insecure {
+		creds = insecure.NewCredentials()
+	}
+
+	conn, err := grpc.NewClient(ep.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("create grpc client: %w", err)
+	}
+
+	return &grpcExporter{
+		conn:    conn,
+		client:  coltracepb.NewTraceServiceClient(conn),
+		headers: metadata.New(ep.Headers),
+	}, nil
+}
+
+func (e *grpcExporter) export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+	if len(e.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, e.headers)
+	}
+
+	if _, err := e.client.Export(ctx, req); err != nil {
+		switch status.Code(err) {
+		case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted, codes.Canceled:
+			return err
+		default:
+			return &permanentError{err: err}
+		}
+	}
+	return nil
+}
+
+func (e *grpcExporter) close() error {
+	return e.conn.Close()
+}
+
+type httpExporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTPExporter(ep Endpoint) (*httpExporter, error) {
+	if ep.Address == "" {
+		return nil, fmt.Errorf("missing url")
+	}
+	return &httpExporter{
+		url:     ep.Address,
+		headers: ep.Headers,
+		client:  &http.Client{},
+	}, nil
+}
+
+func (e *httpExporter) export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return &permanentError{err: fmt.Errorf("encode request: %w", err)}
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(data))
+	if err != nil {
+		return &permanentError{err: err}
+	}
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	for key, value := range e.headers {
+		r.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	default:
+		return &permanentError{err: fmt.Errorf("unexpected status: %s", resp.Status)}
+	}
+}
+
+func (e *httpExporter) close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,270 @@
+package forward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlp_tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+)
+
+const (
+	ProtocolGRPC string = "grpc"
+	ProtocolHTTP string = "http"
+
+	DropReasonQueueFull string = "queue_full"
+	DropReasonFailed    string = "failed"
+
+	// timeout of export requests, if not configured
+	defaultTimeout time.Duration = 10 * time.Second
+)
+
+// Endpoint describes an OTLP collector that traces are forwarded to.
+type Endpoint struct {
+	// Name used in logs and metrics, defaults to the address
+	Name string
+	// Protocol, either `grpc` or `http`
+	Protocol string
+	// The `host:port` to connect to for gRPC, or the full URL for HTTP, e.g.
+	// `https://collector:4318/v1/traces`
+	Address string
+	// Whether to connect without TLS (gRPC only)
+	Insecure bool
+	// Headers to send with each request
+	Headers map[string]string
+	// Timeout of a single export request, defaults to 10s
+	Timeout time.Duration
+}
+
+type Options struct {
+	// Maximum number of payloads queued per endpoint, new payloads are
+	// dropped if the queue is full
+	QueueSize int
+	// Maximum number of retries of a failed export, before the payload is
+	// dropped
+	MaxRetries int
+	// Initial and maximum delay between retries, the delay doubles with each
+	// retry
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
+}
+
+// Forwarder forwards traces to OTLP collectors. Each endpoint has its own
+// queue, so that a slow or unavailable endpoint doesn't affect the others.
+type Forwarder struct {
+	opts    Options
+	queues  []*queue
+	metrics *metrics
+}
+
+type queue struct {
+	name     string
+	exporter exporter
+	timeout  time.Duration
+	payloads chan *coltracepb.ExportTraceServiceRequest
+}
+
+// exporter sends an export request to an endpoint.
+type exporter interface {
+	export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error
+	close() error
+}
+
+// permanentError marks errors that are not worth retrying.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+func New(endpoints []Endpoint, opts Options) (*Forwarder, error) {
+	if opts.QueueSize <= 0 {
+		return nil, fmt.Errorf("invalid queue size: %d", opts.QueueSize)
+	}
+
+	f := &Forwarder{
+		opts:    opts,
+		metrics: newMetrics(),
+	}
+	for _, ep := range endpoints {
+		name := ep.Name
+		if name == "" {
+			name = ep.Address
+		}
+
+		var (
+			exp exporter
+			err error
+		)
+		switch ep.Protocol {
+		case ProtocolGRPC, "":
+			exp, err = newGRPCExporter(ep)
+		case ProtocolHTTP:
+			exp, err = newHTTPExporter(ep)
+		default:
+			err = fmt.Errorf("unsupported protocol: %q", ep.Protocol)
+		}
+		if err != nil {
+			f.close()
+			return nil, fmt.Errorf("endpoint `%s`: %w", name, err)
+		}
+
+		timeout := ep.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+
+		f.queues = append(f.queues, &queue{
+			name:     name,
+			exporter: exp,
+			timeout:  timeout,
+			payloads: make(chan *coltracepb.ExportTraceServiceRequest, opts.QueueSize),
+		})
+	}
+
+	return f, nil
+}
+
+// Forward queues the traces to be sent to each endpoint. It never blocks,
+// the traces are dropped for endpoints whose queue is full.
+func (f *Forwarder) Forward(traces []*typespb.Trace) {
+	if f == nil {
+		return
+	}
+
+	var resourceSpans []*otlp_tracepb.ResourceSpans
+	for _, trace := range traces {
+		resourceSpans = append(resourceSpans, trace.GetData().GetResourceSpans()...)
+	}
+	if len(resourceSpans) == 0 {
+		return
+	}
+	req := &coltracepb.ExportTraceServiceRequest{ResourceSpans: resourceSpans}
+	spans := countSpans(req)
+
+	for _, q := range f.queues {
+		select {
+		case q.payloads <- req:
+			f.metrics.setQueueLength(q.name, len(q.payloads))
+		default:
+			f.metrics.observeDrop(q.name, DropReasonQueueFull, spans)
+			slog.Debug("Dropped traces to forward, queue is full", "endpoint", q.name, "spans", spans)
+		}
+	}
+}
+
+// Run sends the queued traces until the context is cancelled, after which
+// the remaining queued traces are sent without retries.
+func (f *Forwarder) Run(ctx context.Context) error {
+	defer f.close()
+
+	var wg sync.WaitGroup
+	for _, q := range f.queues {
+		wg.Add(1)
+		go func(q *queue) {
+			defer wg.Done()
+			f.process(ctx, q)
+		}(q)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (f *Forwarder) process(ctx context.Context, q *queue) {
+	for {
+		select {
+		case <-ctx.Done():
+			f.drain(q)
+			return
+		case req := <-q.payloads:
+			f.metrics.setQueueLength(q.name, len(q.payloads))
+			f.send(ctx, q, req)
+		}
+	}
+}
+
+// drain makes a single attempt to send each of the queued payloads.
+func (f *Forwarder) drain(q *queue) {
+	for {
+		select {
+		case req := <-q.payloads:
+			f.metrics.setQueueLength(q.name, len(q.payloads))
+			if err := f.export(context.Background(), q, req); err != nil {
+				f.metrics.observeDrop(q.name, DropReasonFailed, countSpans(req))
+			}
+		default:
+			return
+		}
+	}
+}
+
+// send exports the payload, retrying with exponential backoff until it
+// succeeds, fails permanently or the retries are exhausted.
+func (f *Forwarder) send(ctx context.Context, q *queue, req *coltracepb.ExportTraceServiceRequest) {
+	backoff := f.opts.RetryInitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := f.export(ctx, q, req)
+		if err == nil {
+			return
+		}
+
+		var perr *permanentError
+		if errors.As(err, &perr) || attempt >= f.opts.MaxRetries || ctx.Err() != nil {
+			slog.Warn("Failed to forward traces", "endpoint", q.name, "attempts", attempt+1, "error", err)
+			f.metrics.observeDrop(q.name, DropReasonFailed, countSpans(req))
+			return
+		}
+
+		f.metrics.observeRetry(q.name)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if f.opts.RetryMaxBackoff > 0 && backoff > f.opts.RetryMaxBackoff {
+			backoff = f.opts.RetryMaxBackoff
+		}
+	}
+}
+
+func (f *Forwarder) export(ctx context.Context, q *queue, req *coltracepb.ExportTraceServiceRequest) error {
+	// let requests in flight complete on shutdown
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), q.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := q.exporter.export(ctx, req)
+	f.metrics.observeExport(q.name, countSpans(req), time.Since(start), err)
+	return err
+}
+
+func (f *Forwarder) close() {
+	for _, q := range f.queues {
+		if err := q.exporter.close(); err != nil {
+			slog.Warn("Failed to close forwarding exporter", "endpoint", q.name, "error", err)
+		}
+	}
+}
+
+func countSpans(req *coltracepb.ExportTraceServiceRequest) int {
+	var n int
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			n += len(ss.GetSpans())
+		}
+	}
+	return n
+}
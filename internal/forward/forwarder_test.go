@@ -0,0 +1,169 @@
+package forward
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlp_tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+)
+
+func testTraces(names ...string) []*typespb.Trace {
+	spans := make([]*otlp_tracepb.Span, 0, len(names))
+	for _, name := range names {
+		spans = append(spans, &otlp_tracepb.Span{Name: name})
+	}
+	return []*typespb.Trace{
+		{Data: &otlp_tracepb.TracesData{
+			ResourceSpans: []*otlp_tracepb.ResourceSpans{
+				{ScopeSpans: []*otlp_tracepb.ScopeSpans{{Spans: spans}}},
+			},
+		}},
+	}
+}
+
+// testCollector returns a server that responds with the given status codes
+// in turn and records the received requests.
+type testCollector struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*coltracepb.ExportTraceServiceRequest
+	received chan struct{}
+}
+
+func newTestCollector(statuses ...int) (*testCollector, *httptest.Server) {
+	c := &testCollector{statuses: statuses, received: make(chan struct{}, 16)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := &coltracepb.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		code := http.StatusOK
+		if len(c.statuses) > 0 {
+			code, c.statuses = c.statuses[0], c.statuses[1:]
+		}
+		c.requests = append(c.requests, req)
+		c.mu.Unlock()
+
+		w.WriteHeader(code)
+		c.received <- struct{}{}
+	}))
+	return c, srv
+}
+
+func (c *testCollector) wait(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for request %d", i+1)
+		}
+	}
+}
+
+func runForwarder(t *testing.T, f *Forwarder) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = f.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestForwarder_Retry(t *testing.T) {
+	collector, srv := newTestCollector(http.StatusServiceUnavailable, http.StatusOK)
+	defer srv.Close()
+
+	f, err := New(
+		[]Endpoint{{Name: "test", Protocol: ProtocolHTTP, Address: srv.URL}},
+		Options{QueueSize: 10, MaxRetries: 3, RetryInitialBackoff: time.Millisecond},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := runForwarder(t, f)
+
+	f.Forward(testTraces("a", "b"))
+	collector.wait(t, 2)
+	stop()
+
+	if got := testutil.ToFloat64(f.metrics.sent.WithLabelValues("test")); got != 2 {
+		t.Errorf("Expected 2 sent spans, got %v", got)
+	}
+	if got := testutil.ToFloat64(f.metrics.retries.WithLabelValues("test")); got != 1 {
+		t.Errorf("Expected 1 retry, got %v", got)
+	}
+	if got := len(collector.requests[1].GetResourceSpans()[0].GetScopeSpans()[0].GetSpans()); got != 2 {
+		t.Errorf("Expected 2 forwarded spans, got %d", got)
+	}
+}
+
+func TestForwarder_PermanentError(t *testing.T) {
+	collector, srv := newTestCollector(http.StatusBadRequest)
+	defer srv.Close()
+
+	f, err := New(
+		[]Endpoint{{Name: "test", Protocol: ProtocolHTTP, Address: srv.URL}},
+		Options{QueueSize: 10, MaxRetries: 3, RetryInitialBackoff: time.Millisecond},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := runForwarder(t, f)
+
+	f.Forward(testTraces("a"))
+	collector.wait(t, 1)
+	stop()
+
+	if got := len(collector.requests); got != 1 {
+		t.Errorf("Expected no retries, got %d requests", got)
+	}
+	if got := testutil.ToFloat64(f.metrics.dropped.WithLabelValues("test", DropReasonFailed)); got != 1 {
+		t.Errorf("Expected 1 dropped span, got %v", got)
+	}
+}
+
+func TestForwarder_QueueFull(t *testing.T) {
+	f, err := New(
+		[]Endpoint{{Name: "test", Protocol: ProtocolHTTP, Address: "http://127.0.0.1:0/v1/traces"}},
+		Options{QueueSize: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// not running, so the queue isn't consumed
+	f.Forward(testTraces("a"))
+	f.Forward(testTraces("b", "c"))
+
+	if got := testutil.ToFloat64(f.metrics.dropped.WithLabelValues("test", DropReasonQueueFull)); got != 2 {
+		t.Errorf("Expected 2 dropped spans, got %v", got)
+	}
+	if got := testutil.ToFloat64(f.metrics.queueLength.WithLabelValues("test")); got != 1 {
+		t.Errorf("Expected queue length 1, got %v", got)
+	}
+}
+
+func TestNew_InvalidProtocol(t *testing.T) {
+	if _, err := New([]Endpoint{{Protocol: "udp", Address: "localhost:4317"}}, Options{QueueSize: 1}); err == nil {
+		t.Error("Expected error for unsupported protocol")
+	}
+}
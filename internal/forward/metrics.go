@@ -0,0 +1,108 @@
+package forward
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/promutil"
+)
+
+type metrics struct {
+	promutil.Collectors
+
+	queueLength *prometheus.GaugeVec
+	sent        *prometheus.CounterVec
+	dropped     *prometheus.CounterVec
+	retries     *prometheus.CounterVec
+	errors      *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		queueLength: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "forwarder",
+				Name:      "queue_length",
+				Help:      "Number of payloads queued to be forwarded by endpoint.",
+			},
+			[]string{"endpoint"},
+		),
+		sent: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "forwarder",
+				Name:      "spans_sent_total",
+				Help:      "Total number of spans forwarded by endpoint.",
+			},
+			[]string{"endpoint"},
+		),
+		dropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "forwarder",
+				Name:      "spans_dropped_total",
+				Help:      "Total number of spans dropped by endpoint and reason.",
+			},
+			[]string{"endpoint", "reason"},
+		),
+		retries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "forwarder",
+				Name:      "retries_total",
+				Help:      "Total number of retried exports by endpoint.",
+			},
+			[]string{"endpoint"},
+		),
+		errors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "forwarder",
+				Name:      "export_errors_total",
+				Help:      "Total number of failed exports by endpoint.",
+			},
+			[]string{"endpoint"},
+		),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "forwarder",
+				Name:      "export_duration_seconds",
+				Help:      "Duration of exports by endpoint.",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"endpoint"},
+		),
+	}
+	m.Collectors = promutil.Collectors{m.queueLength, m.sent, m.dropped, m.retries, m.errors, m.duration}
+	return m
+}
+
+func (m *metrics) setQueueLength(endpoint string, length int) {
+	m.queueLength.WithLabelValues(endpoint).Set(float64(length))
+}
+
+func (m *metrics) observeExport(endpoint string, spans int, duration time.Duration, err error) {
+	m.duration.WithLabelValues(endpoint).Observe(duration.Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(endpoint).Inc()
+	} else {
+		m.sent.WithLabelValues(endpoint).Add(float64(spans))
+	}
+}
+
+func (m *metrics) observeDrop(endpoint string, reason string, spans int) {
+	m.dropped.WithLabelValues(endpoint, reason).Add(float64(spans))
+}
+
+func (m *metrics) observeRetry(endpoint string) {
+	m.retries.WithLabelValues(endpoint).Inc()
+}
+
+// MetricsCollector returns the collector of the forwarder's metrics.
+func (f *Forwarder) MetricsCollector() prometheus.Collector {
+	return f.metrics
+}
@@ -0,0 +1,75 @@
+package idcache
+
+import (
+	"hash/maphash"
+	"math"
+)
+
+// bloomFilter is a set of keys that may report keys as contained that have
+// never been added, with a false positive rate depending on its size.
+type bloomFilter struct {
+	bits []uint64
+	// number of bits
+	m uint64
+	// number of hash functions
+	k uint64
+	// number of keys the filter is sized for
+	capacity int
+
+	seeds [2]maphash.Seed
+}
+
+// newBloomFilter returns a filter sized for n keys at a false positive rate
+// of p.
+func newBloomFilter(n int, p float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &bloomFilter{
+		bits:     make([]uint64, (m+63)/64),
+		m:        m,
+		k:        k,
+		capacity: n,
+		seeds:    [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+	}
+}
+
+func (f *bloomFilter) add(key []byte) {
+	h1, h2 := f.hash(key)
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (f *bloomFilter) contains(key []byte) bool {
+	h1, h2 := f.hash(key)
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// size returns the memory used by the filter bits, in bytes.
+func (f *bloomFilter) size() int {
+	return len(f.bits) * 8
+}
+
+// hash returns the two hashes that the k hash functions are derived from.
+func (f *bloomFilter) hash(key []byte) (uint64, uint64) {
+	h1 := maphash.Bytes(f.seeds[0], key)
+	h2 := maphash.Bytes(f.seeds[1], key) | 1
+	return h1, h2
+}
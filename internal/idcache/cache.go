@@ -0,0 +1,338 @@
+package idcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slices"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+type Options struct {
+	// The probability that an unknown record is taken for a known one, and
+	// thus looked up in the database before it is inserted
+	FalsePositiveRate float64
+	// The minimum number of keys the cache of a table is sized for
+	MinCapacity int
+}
+
+// Cache keeps track of the records in the database, so that records that are
+// already known, by id and by version if they are updated, can be skipped
+// instead of being inserted again. Only the exact versions inserted, and the
+// latest version of each id when warmed, are known, so that stale versions
+// received later are inserted again. This is harmless, since the tables keep
+// the latest version of each id when their parts are merged.
+//
+// The keys of each table are kept in a bloom filter, which is warmed from the
+// database and grows by warming it again. Since the filter has false
+// positives, records it contains are looked up in the database before they
+// are skipped.
+type Cache struct {
+	client  *clickhouse.Client
+	opts    Options
+	metrics *metrics
+
+	tables map[string]*tableCache
+	// returns which of the records are in the table
+	lookup func(ctx context.Context, table string, records []recordIdentity) ([]bool, error)
+}
+
+type tableCache struct {
+	mu sync.RWMutex
+	// nil until the cache is warmed
+	filter *bloomFilter
+	// number of keys added to the filter
+	keys int
+	// whether the filter is being warmed, keys added meanwhile are kept
+	// pending to be added to the new filter
+	warming bool
+	pending [][]byte
+}
+
+func New(client *clickhouse.Client, opts Options) (*Cache, error) {
+	if opts.FalsePositiveRate <= 0 || opts.FalsePositiveRate >= 1 {
+		return nil, fmt.Errorf("invalid false positive rate: %v", opts.FalsePositiveRate)
+	}
+	if opts.MinCapacity < 0 {
+		return nil, fmt.Errorf("invalid minimum capacity: %d", opts.MinCapacity)
+	}
+
+	c := &Cache{
+		client:  client,
+		opts:    opts,
+		metrics: newMetrics(),
+		tables:  make(map[string]*tableCache, len(tables)),
+	}
+	for table := range tables {
+		c.tables[table] = &tableCache{warming: true}
+	}
+	c.lookup = c.lookupRecords
+	return c, nil
+}
+
+func (c *Cache) MetricsCollector() prometheus.Collector {
+	return c.metrics
+}
+
+// Run warms the cache and waits for the context to be done. Records are not
+// skipped until the cache of their table is warmed.
+func (c *Cache) Run(ctx context.Context) error {
+	if err := c.Warm(ctx); err != nil {
+		slog.Error("Failed to warm id cache", "error", err)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// Warm loads the keys of the records in the database into the cache.
+func (c *Cache) Warm(ctx context.Context) error {
+	names := make([]string, 0, len(c.tables))
+	for table := range c.tables {
+		names = append(names, table)
+	}
+	slices.Sort(names)
+
+	var errs error
+	for _, table := range names {
+		if err := c.warmTable(ctx, table); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("table `%s`: %w", table, err))
+		}
+	}
+	return errs
+}
+
+func (c *Cache) warmTable(ctx context.Context, table string) error {
+	t := c.tables[table]
+
+	t.mu.Lock()
+	t.warming = true
+	t.mu.Unlock()
+
+	start := time.Now()
+	filter, n, err := c.load(ctx, table)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err != nil {
+		if t.filter != nil {
+			// keep the previous filter, which the pending keys are in
+			t.warming, t.pending = false, nil
+			return err
+		}
+		filter, n = c.newFilter(0), 0
+	}
+	for _, key := range t.pending {
+		filter.add(key)
+	}
+	t.filter, t.keys = filter, n+len(t.pending)
+	t.warming, t.pending = false, nil
+
+	c.metrics.observeSize(table, t.keys, filter.size())
+	if err == nil {
+		slog.Debug("Warmed id cache", "table", table, "keys", t.keys, "bytes", filter.size(), "duration", time.Since(start))
+	}
+	return err
+}
+
+// load returns a filter of the keys of the records in the table, and their
+// number. The keys are streamed into a filter sized by the approximate number
+// of ids, so that they are never all held in memory.
+func (c *Cache) load(ctx context.Context, table string) (*bloomFilter, int, error) {
+	count, err := clickhouse.CountTableIDs(c.client, ctx, table, "id")
+	if err != nil {
+		return nil, 0, err
+	}
+	filter := c.newFilter(int(count))
+
+	var n int
+	spec := tables[table]
+	switch {
+	case spec.versionColumn != "":
+		err = clickhouse.ScanTableIDLatestUpdates(c.client, ctx, table, "id", spec.versionColumn, func(id int64, version time.Time) {
+			filter.add(versionKey(id, version.UnixMilli()))
+			n++
+		})
+	case spec.stringIds:
+		err = clickhouse.ScanTableIDs(c.client, ctx, table, "id", func(id string) {
+			filter.add([]byte(id))
+			n++
+		})
+	default:
+		err = clickhouse.ScanTableIDs(c.client, ctx, table, "id", func(id int64) {
+			filter.add(idKey(id))
+			n++
+		})
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return filter, n, nil
+}
+
+// newFilter returns a filter with room for twice the number of keys, so that
+// it doesn't need to grow right away.
+func (c *Cache) newFilter(keys int) *bloomFilter {
+	return newBloomFilter(max(2*keys, c.opts.MinCapacity), c.opts.FalsePositiveRate)
+}
+
+// Filter returns the records that are not known to be in the table. Records
+// the cache contains are looked up in the database and only skipped if they
+// are found there, or kept if the lookup fails. It's a no-op on a nil cache,
+// for tables that aren't cached and until the cache of the table is warmed.
+func Filter[T any](c *Cache, ctx context.Context, table string, records []*T) []*T {
+	if c == nil {
+		return records
+	}
+	t, ok := c.tables[table]
+	if !ok {
+		return records
+	}
+
+	var (
+		candidates []int
+		identities []recordIdentity
+	)
+	t.mu.RLock()
+	if t.filter == nil {
+		t.mu.RUnlock()
+		return records
+	}
+	for i, r := range records {
+		if id, ok := identify(r); ok && t.filter.contains(id.key()) {
+			candidates = append(candidates, i)
+			identities = append(identities, id)
+		}
+	}
+	t.mu.RUnlock()
+
+	if len(candidates) == 0 {
+		return records
+	}
+
+	found, err := c.lookup(ctx, table, identities)
+	if err != nil {
+		slog.Warn("Failed to look up known records, inserting them", "table", table, "error", err)
+		return records
+	}
+
+	known := make(map[int]bool, len(candidates))
+	for i, idx := range candidates {
+		known[idx] = found[i]
+	}
+
+	kept := records[:0:0]
+	for i, r := range records {
+		if !known[i] {
+			kept = append(kept, r)
+		}
+	}
+
+	skipped := len(records) - len(kept)
+	if skipped > 0 {
+		c.metrics.observeSkipped(table, skipped)
+		slog.Debug("Skipped known records", "table", table, "received", len(records), "skipped", skipped)
+	}
+	if n := len(candidates) - skipped; n > 0 {
+		c.metrics.observeFalsePositives(table, n)
+	}
+	return kept
+}
+
+// lookupRecords returns which of the records are in the table. Versioned
+// records are in the table if their version or a newer one is.
+func (c *Cache) lookupRecords(ctx context.Context, table string, records []recordIdentity) ([]bool, error) {
+	found := make([]bool, len(records))
+
+	spec := tables[table]
+	switch {
+	case spec.versionColumn != "":
+		ids := make([]int64, 0, len(records))
+		for _, r := range records {
+			ids = append(ids, r.id)
+		}
+		versions, err := clickhouse.SelectTableIDVersions(c.client, ctx, table, spec.versionColumn, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i, r := range records {
+			version, ok := versions[r.id]
+			found[i] = ok && version.UnixMilli() >= r.version
+		}
+	case spec.stringIds:
+		ids := make([]string, 0, len(records))
+		for _, r := range records {
+			ids = append(ids, r.stringId)
+		}
+		known, err := clickhouse.SelectKnownTableIDs(c.client, ctx, table, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i, r := range records {
+			_, found[i] = known[r.stringId]
+		}
+	default:
+		ids := make([]int64, 0, len(records))
+		for _, r := range records {
+			ids = append(ids, r.id)
+		}
+		known, err := clickhouse.SelectKnownTableIDs(c.client, ctx, table, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i, r := range records {
+			_, found[i] = known[r.id]
+		}
+	}
+	return found, nil
+}
+
+// Add adds the records that have been inserted into the table to the cache.
+// The cache of the table is warmed again to grow it once it is full.
+func Add[T any](c *Cache, table string, records []*T) {
+	if c == nil {
+		return
+	}
+	t, ok := c.tables[table]
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	for _, r := range records {
+		key, ok := recordKey(r)
+		if !ok {
+			continue
+		}
+		if t.warming {
+			t.pending = append(t.pending, key)
+		}
+		if t.filter != nil {
+			t.filter.add(key)
+			t.keys++
+		}
+	}
+	grow := t.filter != nil && !t.warming && t.keys > t.filter.capacity
+	if grow {
+		t.warming = true
+	}
+	if t.filter != nil {
+		c.metrics.observeSize(table, t.keys, t.filter.size())
+	}
+	t.mu.Unlock()
+
+	if grow {
+		go func() {
+			slog.Info("Growing id cache", "table", table)
+			if err := c.warmTable(context.Background(), table); err != nil {
+				slog.Error("Failed to grow id cache", "table", table, "error", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,176 @@
+package idcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+var testNow = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// testCache returns a cache whose tables are warmed empty, without a client,
+// whose lookups find all records.
+func testCache(t *testing.T) *Cache {
+	c, err := New(nil, Options{FalsePositiveRate: 0.000001, MinCapacity: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range c.tables {
+		tc.filter, tc.warming = c.newFilter(0), false
+	}
+	c.lookup = func(_ context.Context, _ string, records []recordIdentity) ([]bool, error) {
+		found := make([]bool, len(records))
+		for i := range found {
+			found[i] = true
+		}
+		return found, nil
+	}
+	return c
+}
+
+func testPipeline(id int64, updatedAt time.Time) *typespb.Pipeline {
+	return &typespb.Pipeline{
+		Id: id,
+		Timestamps: &typespb.PipelineTimestamps{
+			UpdatedAt: timestamppb.New(updatedAt),
+		},
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	const n = 10000
+	f := newBloomFilter(n, 0.01)
+
+	for i := 0; i < n; i++ {
+		f.add([]byte(fmt.Sprintf("key-%d", i)))
+	}
+	for i := 0; i < n; i++ {
+		if !f.contains([]byte(fmt.Sprintf("key-%d", i))) {
+			t.Fatalf("Expected key %d to be contained", i)
+		}
+	}
+
+	positives := 0
+	for i := n; i < 2*n; i++ {
+		if f.contains([]byte(fmt.Sprintf("key-%d", i))) {
+			positives++
+		}
+	}
+	// twice the configured rate leaves room for variance
+	if rate := float64(positives) / n; rate > 0.02 {
+		t.Errorf("Expected false positive rate of about 0.01, got %v", rate)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, opts := range []Options{
+		{FalsePositiveRate: 0},
+		{FalsePositiveRate: 1},
+		{FalsePositiveRate: 0.01, MinCapacity: -1},
+	} {
+		if _, err := New(nil, opts); err == nil {
+			t.Errorf("Expected error for %+v", opts)
+		}
+	}
+}
+
+func TestFilter_Ids(t *testing.T) {
+	c := testCache(t)
+
+	jobs := []*typespb.Job{{Id: 1}, {Id: 2}}
+	Add(c, clickhouse.JobsTable, jobs)
+
+	kept := Filter(c, context.Background(), clickhouse.JobsTable, []*typespb.Job{{Id: 1}, {Id: 2}, {Id: 3}})
+	if len(kept) != 1 || kept[0].Id != 3 {
+		t.Errorf("Expected only job 3 to be kept, got %d", len(kept))
+	}
+	if kept := Filter(c, context.Background(), clickhouse.BridgesTable, jobs); len(kept) != 2 {
+		t.Errorf("Expected ids of other tables to be unknown, got %d kept", len(kept))
+	}
+}
+
+func TestFilter_Versions(t *testing.T) {
+	c := testCache(t)
+
+	Add(c, clickhouse.PipelinesTable, []*typespb.Pipeline{testPipeline(1, testNow)})
+
+	kept := Filter(c, context.Background(), clickhouse.PipelinesTable, []*typespb.Pipeline{
+		testPipeline(1, testNow),
+		testPipeline(1, testNow.Add(time.Minute)),
+	})
+	if len(kept) != 1 || !kept[0].GetTimestamps().GetUpdatedAt().AsTime().Equal(testNow.Add(time.Minute)) {
+		t.Errorf("Expected only the newer version to be kept, got %d", len(kept))
+	}
+}
+
+func TestFilter_NotWarmed(t *testing.T) {
+	c, err := New(nil, Options{FalsePositiveRate: 0.01})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keys added while warming are kept pending
+	Add(c, clickhouse.JobsTable, []*typespb.Job{{Id: 1}})
+	if kept := Filter(c, context.Background(), clickhouse.JobsTable, []*typespb.Job{{Id: 1}}); len(kept) != 1 {
+		t.Errorf("Expected records to be kept until warmed, got %d", len(kept))
+	}
+	if n := len(c.tables[clickhouse.JobsTable].pending); n != 1 {
+		t.Errorf("Expected 1 pending key, got %d", n)
+	}
+}
+
+func TestFilter_Nil(t *testing.T) {
+	var c *Cache
+
+	jobs := []*typespb.Job{{Id: 1}}
+	Add(c, clickhouse.JobsTable, jobs)
+	if kept := Filter(c, context.Background(), clickhouse.JobsTable, jobs); len(kept) != 1 {
+		t.Errorf("Expected nil cache to keep all records, got %d", len(kept))
+	}
+}
+
+func TestFilter_FalsePositives(t *testing.T) {
+	c := testCache(t)
+
+	Add(c, clickhouse.JobsTable, []*typespb.Job{{Id: 1}, {Id: 2}})
+
+	// job 2 is contained in the filter, but not in the table
+	var looked []int64
+	c.lookup = func(_ context.Context, _ string, records []recordIdentity) ([]bool, error) {
+		found := make([]bool, len(records))
+		for i, r := range records {
+			looked = append(looked, r.id)
+			found[i] = r.id == 1
+		}
+		return found, nil
+	}
+
+	kept := Filter(c, context.Background(), clickhouse.JobsTable, []*typespb.Job{{Id: 2}, {Id: 1}, {Id: 3}})
+	if len(kept) != 2 || kept[0].Id != 2 || kept[1].Id != 3 {
+		t.Errorf("Expected jobs 2 and 3 to be kept in order, got %v", kept)
+	}
+	if len(looked) != 2 {
+		t.Errorf("Expected only the contained jobs to be looked up, got %v", looked)
+	}
+}
+
+func TestFilter_LookupError(t *testing.T) {
+	c := testCache(t)
+
+	Add(c, clickhouse.JobsTable, []*typespb.Job{{Id: 1}})
+	c.lookup = func(context.Context, string, []recordIdentity) ([]bool, error) {
+		return nil, errors.New("unavailable")
+	}
+
+	if kept := Filter(c, context.Background(), clickhouse.JobsTable, []*typespb.Job{{Id: 1}}); len(kept) != 1 {
+		t.Errorf("Expected records to be kept if the lookup fails, got %d", len(kept))
+	}
+}
@@ -0,0 +1,137 @@
+package idcache
+
+import (
+	"encoding/binary"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+// tableSpec describes how the records of a table are identified.
+type tableSpec struct {
+	// The column of the record versions, if records are updated. Records
+	// are then known by id and version, so that newer versions are inserted,
+	// and stale ones as well, until they are removed when parts are merged.
+	versionColumn string
+	// Whether the ids are strings rather than integers
+	stringIds bool
+}
+
+// the tables whose records are cached, by name
+var tables = map[string]tableSpec{
+	clickhouse.ProjectsTable:               {versionColumn: "last_activity_at"},
+	clickhouse.PipelinesTable:              {versionColumn: "updated_at"},
+	clickhouse.JobsTable:                   {},
+	clickhouse.BridgesTable:                {},
+	clickhouse.SectionsTable:               {},
+	clickhouse.TestReportsTable:            {stringIds: true},
+	clickhouse.TestSuitesTable:             {stringIds: true},
+	clickhouse.TestCasesTable:              {stringIds: true},
+	clickhouse.MergeRequestsTable:          {versionColumn: "updated_at"},
+	clickhouse.MergeRequestNoteEventsTable: {versionColumn: "updated_at"},
+	clickhouse.DeploymentsTable:            {versionColumn: "updated_at"},
+	clickhouse.IssuesTable:                 {versionColumn: "updated_at"},
+	clickhouse.CoverageReportsTable:        {stringIds: true},
+	clickhouse.CoveragePackagesTable:       {stringIds: true},
+	clickhouse.CoverageClassesTable:        {stringIds: true},
+	clickhouse.CoverageMethodsTable:        {stringIds: true},
+}
+
+// recordIdentity identifies a record by its id, and by its version if the
+// records of its table are updated.
+type recordIdentity struct {
+	id       int64
+	stringId string
+	// version in milliseconds since the epoch
+	version int64
+
+	hasStringId bool
+	versioned   bool
+}
+
+// identify returns the identity of the record, which must match the spec of
+// the table it is inserted into.
+func identify(record any) (recordIdentity, bool) {
+	switch r := record.(type) {
+	case *typespb.Project:
+		return versionIdentity(r.GetId(), r.GetTimestamps().GetLastActivityAt()), true
+	case *typespb.Pipeline:
+		return versionIdentity(r.GetId(), r.GetTimestamps().GetUpdatedAt()), true
+	case *typespb.Job:
+		return recordIdentity{id: r.GetId()}, true
+	case *typespb.Section:
+		return recordIdentity{id: r.GetId()}, true
+	case *typespb.TestReport:
+		return stringIdentity(r.GetId()), true
+	case *typespb.TestSuite:
+		return stringIdentity(r.GetId()), true
+	case *typespb.TestCase:
+		return stringIdentity(r.GetId()), true
+	case *typespb.MergeRequest:
+		return versionIdentity(r.GetId(), r.GetTimestamps().GetUpdatedAt()), true
+	case *typespb.MergeRequestNoteEvent:
+		return versionIdentity(r.GetId(), r.GetUpdatedAt()), true
+	case *typespb.Deployment:
+		return versionIdentity(r.GetId(), r.GetTimestamps().GetUpdatedAt()), true
+	case *typespb.Issue:
+		return versionIdentity(r.GetId(), r.GetTimestamps().GetUpdatedAt()), true
+	case *typespb.CoverageReport:
+		return stringIdentity(r.GetId()), true
+	case *typespb.CoveragePackage:
+		return stringIdentity(r.GetId()), true
+	case *typespb.CoverageClass:
+		return stringIdentity(r.GetId()), true
+	case *typespb.CoverageMethod:
+		return stringIdentity(r.GetId()), true
+	default:
+		return recordIdentity{}, false
+	}
+}
+
+func versionIdentity(id int64, version *timestamppb.Timestamp) recordIdentity {
+	return recordIdentity{id: id, version: unixMilli(version), versioned: true}
+}
+
+func stringIdentity(id string) recordIdentity {
+	return recordIdentity{stringId: id, hasStringId: true}
+}
+
+// key returns the key the record is known by in the cache.
+func (r recordIdentity) key() []byte {
+	switch {
+	case r.hasStringId:
+		return []byte(r.stringId)
+	case r.versioned:
+		return versionKey(r.id, r.version)
+	default:
+		return idKey(r.id)
+	}
+}
+
+// recordKey returns the key the record is known by in the cache.
+func recordKey(record any) ([]byte, bool) {
+	r, ok := identify(record)
+	if !ok {
+		return nil, false
+	}
+	return r.key(), true
+}
+
+// unixMilli returns the milliseconds since the epoch of the timestamp, or 0 if
+// absent, like the converters of the clickhouse package.
+func unixMilli(ts *timestamppb.Timestamp) int64 {
+	return ts.AsTime().UnixMilli()
+}
+
+func idKey(id int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+// versionKey returns the key of the id at the version given in milliseconds
+// since the epoch, the precision versions are stored with.
+func versionKey(id int64, version int64) []byte {
+	return binary.BigEndian.AppendUint64(idKey(id), uint64(version))
+}
@@ -0,0 +1,72 @@
+package idcache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/promutil"
+)
+
+type metrics struct {
+	promutil.Collectors
+
+	skipped        *prometheus.CounterVec
+	falsePositives *prometheus.CounterVec
+	keys           *prometheus.GaugeVec
+	bytes          *prometheus.GaugeVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		skipped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "id_cache",
+				Name:      "records_skipped_total",
+				Help:      "Total number of known records that have not been inserted by table.",
+			},
+			[]string{"table"},
+		),
+		falsePositives: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "id_cache",
+				Name:      "false_positives_total",
+				Help:      "Total number of records taken for known ones that have not been found in the table by table.",
+			},
+			[]string{"table"},
+		),
+		keys: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "id_cache",
+				Name:      "keys",
+				Help:      "Number of keys in the cache by table.",
+			},
+			[]string{"table"},
+		),
+		bytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "id_cache",
+				Name:      "size_bytes",
+				Help:      "Memory used by the cache by table, in bytes.",
+			},
+			[]string{"table"},
+		),
+	}
+	m.Collectors = promutil.Collectors{m.skipped, m.falsePositives, m.keys, m.bytes}
+	return m
+}
+
+func (m *metrics) observeSkipped(table string, n int) {
+	m.skipped.WithLabelValues(table).Add(float64(n))
+}
+
+func (m *metrics) observeFalsePositives(table string, n int) {
+	m.falsePositives.WithLabelValues(table).Add(float64(n))
+}
+
+func (m *metrics) observeSize(table string, keys int, bytes int) {
+	m.keys.WithLabelValues(table).Set(float64(keys))
+	m.bytes.WithLabelValues(table).Set(float64(bytes))
+}
@@ -0,0 +1,77 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Checkpoint persists the number of records of each input that have been
+// imported, so that an interrupted import can be resumed.
+type Checkpoint struct {
+	path string
+
+	mu      sync.Mutex
+	offsets map[string]int64
+}
+
+// LoadCheckpoint reads the checkpoint file at path, a missing file yields an
+// empty checkpoint.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	c := &Checkpoint{
+		path:    path,
+		offsets: map[string]int64{},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &c.offsets); err != nil {
+		return nil, fmt.Errorf("decode checkpoint: %w", err)
+	}
+	return c, nil
+}
+
+// Offset returns the number of imported records of the input.
+func (c *Checkpoint) Offset(input string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offsets[input]
+}
+
+// Save records the number of imported records of the input and writes the
+// checkpoint file.
+func (c *Checkpoint) Save(input string, offset int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.offsets[input] = offset
+	data, err := json.MarshalIndent(c.offsets, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// replace the file atomically, so that it's never left incomplete
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
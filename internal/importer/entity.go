@@ -0,0 +1,109 @@
+package importer
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+// entity describes how the records of an entity type are decoded and
+// inserted.
+type entity struct {
+	newMessage func() proto.Message
+	insert     insertFunc
+}
+
+// insertFunc inserts a batch of messages and returns the summary of the
+// tables they were inserted into.
+type insertFunc func(c *clickhouse.Client, ctx context.Context, batch []proto.Message) ([]TableSummary, error)
+
+func entityOf[T any, PT interface {
+	*T
+	proto.Message
+}](table string, insert func(*clickhouse.Client, context.Context, []*T) (int, error)) entity {
+	return entity{
+		newMessage: func() proto.Message { return PT(new(T)) },
+		insert: func(c *clickhouse.Client, ctx context.Context, batch []proto.Message) ([]TableSummary, error) {
+			data := make([]*T, 0, len(batch))
+			for _, m := range batch {
+				data = append(data, (*T)(m.(PT)))
+			}
+			return insertTable(c, ctx, table, data, insert)
+		},
+	}
+}
+
+func insertTable[T any](c *clickhouse.Client, ctx context.Context, table string, data []*T, insert func(*clickhouse.Client, context.Context, []*T) (int, error)) ([]TableSummary, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	s := TableSummary{Table: table, Records: len(data)}
+	n, err := insert(c, ctx, data)
+	if err != nil {
+		s.Failed = len(data)
+	} else {
+		s.Inserted = n
+	}
+	return []TableSummary{s}, err
+}
+
+// jobsEntity splits jobs into builds and bridges, like the recorder does.
+func jobsEntity() entity {
+	return entity{
+		newMessage: func() proto.Message { return &typespb.Job{} },
+		insert: func(c *clickhouse.Client, ctx context.Context, batch []proto.Message) ([]TableSummary, error) {
+			var builds, bridges []*typespb.Job
+			for _, m := range batch {
+				job := m.(*typespb.Job)
+				if job.Kind == typespb.JobKind_JOBKIND_BRIDGE {
+					bridges = append(bridges, job)
+				} else {
+					builds = append(builds, job)
+				}
+			}
+
+			buildsSummary, buildsErr := insertTable(c, ctx, clickhouse.JobsTable, builds, clickhouse.InsertJobs)
+			bridgesSummary, bridgesErr := insertTable(c, ctx, clickhouse.BridgesTable, bridges, clickhouse.InsertBridges)
+			return append(buildsSummary, bridgesSummary...), errors.Join(buildsErr, bridgesErr)
+		},
+	}
+}
+
+// entities are the importable entity types, named like the tables they're
+// recorded to.
+var entities = map[string]entity{
+	"pipelines":               entityOf(clickhouse.PipelinesTable, clickhouse.InsertPipelines),
+	"jobs":                    jobsEntity(),
+	"sections":                entityOf(clickhouse.SectionsTable, clickhouse.InsertSections),
+	"testreports":             entityOf(clickhouse.TestReportsTable, clickhouse.InsertTestReports),
+	"testsuites":              entityOf(clickhouse.TestSuitesTable, clickhouse.InsertTestSuites),
+	"testcases":               entityOf(clickhouse.TestCasesTable, clickhouse.InsertTestCases),
+	"mergerequests":           entityOf(clickhouse.MergeRequestsTable, clickhouse.InsertMergeRequests),
+	"mergerequest_noteevents": entityOf(clickhouse.MergeRequestNoteEventsTable, clickhouse.InsertMergeRequestNoteEvents),
+	"projects":                entityOf(clickhouse.ProjectsTable, clickhouse.InsertProjects),
+	"coverage_reports":        entityOf(clickhouse.CoverageReportsTable, clickhouse.InsertCoverageReports),
+	"coverage_packages":       entityOf(clickhouse.CoveragePackagesTable, clickhouse.InsertCoveragePackages),
+	"coverage_classes":        entityOf(clickhouse.CoverageClassesTable, clickhouse.InsertCoverageClasses),
+	"coverage_methods":        entityOf(clickhouse.CoverageMethodsTable, clickhouse.InsertCoverageMethods),
+	"deployments":             entityOf(clickhouse.DeploymentsTable, clickhouse.InsertDeployments),
+	"issues":                  entityOf(clickhouse.IssuesTable, clickhouse.InsertIssues),
+	"metrics":                 entityOf(clickhouse.MetricsTable, clickhouse.InsertMetrics),
+	"traces":                  entityOf(clickhouse.TraceSpansTable, clickhouse.InsertTraces),
+}
+
+// Entities returns the names of the importable entity types.
+func Entities() []string {
+	names := make([]string, 0, len(entities))
+	for name := range entities {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,231 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/proto"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+type Options struct {
+	// Number of records inserted per batch
+	BatchSize int
+	// Number of batches inserted concurrently
+	Parallelism int
+}
+
+// TableSummary counts the records imported into a table.
+type TableSummary struct {
+	Table    string
+	Records  int
+	Inserted int
+	Failed   int
+}
+
+// Importer inserts records of an entity type read from files.
+type Importer struct {
+	client *clickhouse.Client
+	entity entity
+	opts   Options
+
+	mu      sync.Mutex
+	summary map[string]*TableSummary
+}
+
+func New(client *clickhouse.Client, entityName string, opts Options) (*Importer, error) {
+	e, ok := entities[entityName]
+	if !ok {
+		return nil, fmt.Errorf("unknown entity: %q", entityName)
+	}
+	if opts.BatchSize <= 0 {
+		return nil, fmt.Errorf("invalid batch size: %d", opts.BatchSize)
+	}
+	if opts.Parallelism <= 0 {
+		return nil, fmt.Errorf("invalid parallelism: %d", opts.Parallelism)
+	}
+
+	return &Importer{
+		client:  client,
+		entity:  e,
+		opts:    opts,
+		summary: map[string]*TableSummary{},
+	}, nil
+}
+
+// batch is a range of records of an input.
+type batch struct {
+	offset  int64
+	records [][]byte
+}
+
+// Import reads the records of the input, skipping the first offset records,
+// and inserts them in batches. The progress func is called with the number of
+// records up to which all batches have been inserted. The import stops at the
+// first batch that fails.
+func (im *Importer) Import(ctx context.Context, r io.Reader, format string, offset int64, progress func(offset int64) error) error {
+	reader, err := newRecordReader(r, format)
+	if err != nil {
+		return err
+	}
+
+	for i := int64(0); i < offset; i++ {
+		if _, err := reader.next(); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("skip record %d: %w", i+1, err)
+		}
+	}
+
+	tracker := newProgressTracker(offset, progress)
+	batches := make(chan batch)
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error { // read
+		defer close(batches)
+
+		next := offset
+		for {
+			b := batch{offset: next}
+			var err error
+			for len(b.records) < im.opts.BatchSize {
+				var data []byte
+				if data, err = reader.next(); err != nil {
+					break
+				}
+				b.records = append(b.records, data)
+			}
+			next += int64(len(b.records))
+
+			if len(b.records) > 0 {
+				select {
+				case batches <- b:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			if errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("read record %d: %w", next+1, err)
+			}
+		}
+	})
+	for i := 0; i < im.opts.Parallelism; i++ {
+		g.Go(func() error { // insert
+			for b := range batches {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if err := im.insert(ctx, format, b); err != nil {
+					return err
+				}
+				if err := tracker.done(b.offset, b.offset+int64(len(b.records))); err != nil {
+					return fmt.Errorf("save progress: %w", err)
+				}
+			}
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+func (im *Importer) insert(ctx context.Context, format string, b batch) error {
+	msgs := make([]proto.Message, 0, len(b.records))
+	for i, data := range b.records {
+		m := im.entity.newMessage()
+		if err := decodeRecord(format, data, m); err != nil {
+			return fmt.Errorf("decode record %d: %w", b.offset+int64(i)+1, err)
+		}
+		msgs = append(msgs, m)
+	}
+
+	summaries, err := im.entity.insert(im.client, ctx, msgs)
+	im.observe(summaries)
+	if err != nil {
+		return fmt.Errorf("insert records %d-%d: %w", b.offset+1, b.offset+int64(len(b.records)), err)
+	}
+	slog.Debug("Imported batch", "offset", b.offset, "records", len(b.records))
+	return nil
+}
+
+func (im *Importer) observe(summaries []TableSummary) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	for _, s := range summaries {
+		t, ok := im.summary[s.Table]
+		if !ok {
+			t = &TableSummary{Table: s.Table}
+			im.summary[s.Table] = t
+		}
+		t.Records += s.Records
+		t.Inserted += s.Inserted
+		t.Failed += s.Failed
+	}
+}
+
+// Summary returns the import summary of each table, ordered by table name.
+func (im *Importer) Summary() []TableSummary {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	summary := make([]TableSummary, 0, len(im.summary))
+	for _, s := range im.summary {
+		summary = append(summary, *s)
+	}
+	slices.SortFunc(summary, func(a, b TableSummary) int {
+		return strings.Compare(a.Table, b.Table)
+	})
+	return summary
+}
+
+// progressTracker tracks the offset up to which all batches are done, as
+// batches inserted concurrently may complete out of order.
+type progressTracker struct {
+	mu      sync.Mutex
+	offset  int64
+	pending map[int64]int64
+	report  func(offset int64) error
+}
+
+func newProgressTracker(offset int64, report func(offset int64) error) *progressTracker {
+	return &progressTracker{
+		offset:  offset,
+		pending: map[int64]int64{},
+		report:  report,
+	}
+}
+
+// done marks the records from start up to end as done.
+func (t *progressTracker) done(start int64, end int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending[start] = end
+	advanced := false
+	for {
+		end, ok := t.pending[t.offset]
+		if !ok {
+			break
+		}
+		delete(t.pending, t.offset)
+		t.offset = end
+		advanced = true
+	}
+
+	if !advanced || t.report == nil {
+		return nil
+	}
+	return t.report(t.offset)
+}
@@ -0,0 +1,186 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+// testImporter returns an importer of string values that records the
+// inserted values, and fails batches containing "fail".
+func testImporter(opts Options) (*Importer, func() []string) {
+	var (
+		mu       sync.Mutex
+		inserted []string
+	)
+
+	im := &Importer{
+		entity: entity{
+			newMessage: func() proto.Message { return &wrapperspb.StringValue{} },
+			insert: func(_ *clickhouse.Client, _ context.Context, batch []proto.Message) ([]TableSummary, error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				s := TableSummary{Table: "values", Records: len(batch)}
+				for _, m := range batch {
+					if m.(*wrapperspb.StringValue).GetValue() == "fail" {
+						s.Failed = len(batch)
+						return []TableSummary{s}, errors.New("failed")
+					}
+				}
+				for _, m := range batch {
+					inserted = append(inserted, m.(*wrapperspb.StringValue).GetValue())
+				}
+				s.Inserted = len(batch)
+				return []TableSummary{s}, nil
+			},
+		},
+		opts:    opts,
+		summary: map[string]*TableSummary{},
+	}
+
+	return im, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return inserted
+	}
+}
+
+func delimited(t *testing.T, values ...string) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		data, err := proto.Marshal(wrapperspb.String(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(binary.AppendUvarint(nil, uint64(len(data))))
+		buf.Write(data)
+	}
+	return buf.Bytes()
+}
+
+func TestImport_NDJSON(t *testing.T) {
+	im, inserted := testImporter(Options{BatchSize: 2, Parallelism: 2})
+
+	input := "\"a\"\n\"b\"\n\n\"c\"\n\"d\"\n\"e\""
+	var progress []int64
+	err := im.Import(context.Background(), strings.NewReader(input), FormatNDJSON, 1, func(offset int64) error {
+		progress = append(progress, offset)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(inserted()); got != 4 {
+		t.Errorf("Expected 4 inserted values, got %d: %v", got, inserted())
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 5 {
+		t.Errorf("Expected progress up to 5, got %v", progress)
+	}
+
+	summary := im.Summary()
+	if len(summary) != 1 || summary[0].Records != 4 || summary[0].Inserted != 4 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+}
+
+func TestImport_Delimited(t *testing.T) {
+	im, inserted := testImporter(Options{BatchSize: 10, Parallelism: 1})
+
+	input := delimited(t, "a", "b", "c")
+	if err := im.Import(context.Background(), bytes.NewReader(input), FormatDelimited, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(inserted(), ","); got != "a,b,c" {
+		t.Errorf("Expected a,b,c, got %s", got)
+	}
+}
+
+func TestImport_Truncated(t *testing.T) {
+	im, _ := testImporter(Options{BatchSize: 10, Parallelism: 1})
+
+	input := delimited(t, "a", "b")
+	err := im.Import(context.Background(), bytes.NewReader(input[:len(input)-1]), FormatDelimited, 0, nil)
+	if err == nil {
+		t.Error("Expected error for truncated input")
+	}
+}
+
+func TestImport_Failure(t *testing.T) {
+	im, _ := testImporter(Options{BatchSize: 1, Parallelism: 1})
+
+	var offset int64
+	input := "\"a\"\n\"b\"\n\"fail\"\n\"c\"\n"
+	err := im.Import(context.Background(), strings.NewReader(input), FormatNDJSON, 0, func(o int64) error {
+		offset = o
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Expected error for failed batch")
+	}
+
+	if offset != 2 {
+		t.Errorf("Expected progress up to 2, got %d", offset)
+	}
+	if s := im.Summary(); len(s) != 1 || s[0].Failed != 1 {
+		t.Errorf("Expected 1 failed record, got %+v", s)
+	}
+}
+
+func TestProgressTracker(t *testing.T) {
+	var reported []int64
+	tracker := newProgressTracker(10, func(offset int64) error {
+		reported = append(reported, offset)
+		return nil
+	})
+
+	_ = tracker.done(20, 30)
+	_ = tracker.done(30, 35)
+	_ = tracker.done(10, 20)
+	_ = tracker.done(40, 50)
+
+	if len(reported) != 1 || reported[0] != 35 {
+		t.Errorf("Expected progress reported up to 35, got %v", reported)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	c, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Offset("pipelines.ndjson"); got != 0 {
+		t.Errorf("Expected offset 0, got %d", got)
+	}
+	if err := c.Save("pipelines.ndjson", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Offset("pipelines.ndjson"); got != 42 {
+		t.Errorf("Expected offset 42, got %d", got)
+	}
+}
+
+func TestNew_UnknownEntity(t *testing.T) {
+	if _, err := New(nil, "builds", Options{BatchSize: 1, Parallelism: 1}); err == nil {
+		t.Error("Expected error for unknown entity")
+	}
+}
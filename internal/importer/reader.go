@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// FormatNDJSON is protobuf JSON, one message per line.
+	FormatNDJSON string = "ndjson"
+	// FormatDelimited is binary protobuf, each message prefixed with its
+	// size as varint.
+	FormatDelimited string = "delimited"
+
+	// maximum size of a single record
+	maxRecordSize int = 64 << 20
+)
+
+// recordReader reads the encoded records of an input.
+type recordReader interface {
+	// next returns the next record, or io.EOF at the end of the input.
+	next() ([]byte, error)
+}
+
+func newRecordReader(r io.Reader, format string) (recordReader, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	switch format {
+	case FormatNDJSON:
+		return &ndjsonReader{r: br}, nil
+	case FormatDelimited:
+		return &delimitedReader{r: br}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %q", format)
+	}
+}
+
+// decodeRecord unmarshals the record into the message.
+func decodeRecord(format string, data []byte, m proto.Message) error {
+	if format == FormatNDJSON {
+		return protojson.Unmarshal(data, m)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+type ndjsonReader struct {
+	r *bufio.Reader
+}
+
+func (r *ndjsonReader) next() ([]byte, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) > maxRecordSize {
+			return nil, fmt.Errorf("record exceeds %d bytes", maxRecordSize)
+		}
+		// skip blank lines
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+type delimitedReader struct {
+	r *bufio.Reader
+}
+
+func (r *delimitedReader) next() ([]byte, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	} else if size > uint64(maxRecordSize) {
+		return nil, fmt.Errorf("record exceeds %d bytes", maxRecordSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
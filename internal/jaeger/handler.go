@@ -0,0 +1,282 @@
+package jaeger
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+const (
+	// number of traces returned by searches if no limit is given
+	defaultLimit int = 20
+	// time range searched if no start time is given
+	defaultLookback time.Duration = clickhouse.DefaultTraceLookback
+)
+
+// HTTPHandlers returns the handlers of the Jaeger query API keyed by their
+// URL pattern.
+func HTTPHandlers(client *clickhouse.Client) map[string]http.Handler {
+	api := &queryAPI{client: client}
+
+	return map[string]http.Handler{
+		"GET /api/services":                      http.HandlerFunc(api.getServices),
+		"GET /api/services/{service}/operations": http.HandlerFunc(api.getServiceOperations),
+		"GET /api/operations":                    http.HandlerFunc(api.getOperations),
+		"GET /api/traces":                        http.HandlerFunc(api.findTraces),
+		"GET /api/traces/{traceId}":              http.HandlerFunc(api.getTrace),
+	}
+}
+
+type queryAPI struct {
+	client *clickhouse.Client
+}
+
+func (a *queryAPI) getServices(w http.ResponseWriter, r *http.Request) {
+	services, err := clickhouse.SelectTraceServices(a.client, r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("select services: %w", err))
+		return
+	}
+
+	writeResponse(w, services, len(services))
+}
+
+func (a *queryAPI) getServiceOperations(w http.ResponseWriter, r *http.Request) {
+	ops, err := clickhouse.SelectTraceOperations(a.client, r.Context(), r.PathValue("service"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("select operations: %w", err))
+		return
+	}
+
+	names := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if len(names) == 0 || names[len(names)-1] != op.Name {
+			names = append(names, op.Name)
+		}
+	}
+
+	writeResponse(w, names, len(names))
+}
+
+func (a *queryAPI) getOperations(w http.ResponseWriter, r *http.Request) {
+	service := r.URL.Query().Get("service")
+	if service == "" {
+		writeError(w, http.StatusBadRequest, errors.New("parameter 'service' is required"))
+		return
+	}
+	kind := r.URL.Query().Get("spanKind")
+
+	ops, err := clickhouse.SelectTraceOperations(a.client, r.Context(), service)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("select operations: %w", err))
+		return
+	}
+
+	operations := make([]operation, 0, len(ops))
+	for _, op := range ops {
+		if kind != "" && spanKind(op.Kind) != kind {
+			continue
+		}
+		operations = append(operations, operation{
+			Name:     op.Name,
+			SpanKind: spanKind(op.Kind),
+		})
+	}
+
+	writeResponse(w, operations, len(operations))
+}
+
+func (a *queryAPI) getTrace(w http.ResponseWriter, r *http.Request) {
+	traceId, err := parseTraceId(r.PathValue("traceId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	spans, err := clickhouse.SelectTraceSpans(a.client, r.Context(), []string{traceId})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("select spans: %w", err))
+		return
+	}
+	if len(spans) == 0 {
+		writeError(w, http.StatusNotFound, errors.New("trace not found"))
+		return
+	}
+
+	traces := convertTraces(spans)
+	writeResponse(w, traces, len(traces))
+}
+
+func (a *queryAPI) findTraces(w http.ResponseWriter, r *http.Request) {
+	traceIds, filter, err := parseTraceQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(traceIds) == 0 {
+		traceIds, err = clickhouse.SelectTraceIds(a.client, r.Context(), filter)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("select traces: %w", err))
+			return
+		}
+	}
+
+	spans, err := clickhouse.SelectTraceSpans(a.client, r.Context(), traceIds)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("select spans: %w", err))
+		return
+	}
+
+	traces := convertTraces(spans)
+	writeResponse(w, traces, len(traces))
+}
+
+// parseTraceQuery parses the parameters of a trace search. If trace ids are
+// given, the other parameters are ignored.
+func parseTraceQuery(q url.Values, now time.Time) ([]string, clickhouse.TraceFilter, error) {
+	var filter clickhouse.TraceFilter
+
+	if ids := q["traceID"]; len(ids) > 0 {
+		traceIds := make([]string, 0, len(ids))
+		for _, id := range ids {
+			traceId, err := parseTraceId(id)
+			if err != nil {
+				return nil, filter, err
+			}
+			traceIds = append(traceIds, traceId)
+		}
+		return traceIds, filter, nil
+	}
+
+	filter.Service = q.Get("service")
+	if filter.Service == "" {
+		return nil, filter, errors.New("parameter 'service' is required")
+	}
+	filter.Operation = q.Get("operation")
+
+	var err error
+	if filter.End, err = parseMicros(q.Get("end")); err != nil {
+		return nil, filter, fmt.Errorf("invalid parameter 'end': %w", err)
+	} else if filter.End.IsZero() {
+		filter.End = now
+	}
+	if filter.Start, err = parseMicros(q.Get("start")); err != nil {
+		return nil, filter, fmt.Errorf("invalid parameter 'start': %w", err)
+	} else if filter.Start.IsZero() {
+		lookback := defaultLookback
+		if s := q.Get("lookback"); s != "" && s != "custom" {
+			if lookback, err = time.ParseDuration(s); err != nil {
+				return nil, filter, fmt.Errorf("invalid parameter 'lookback': %w", err)
+			}
+		}
+		filter.Start = filter.End.Add(-lookback)
+	}
+
+	if s := q.Get("minDuration"); s != "" {
+		if filter.MinDuration, err = time.ParseDuration(s); err != nil {
+			return nil, filter, fmt.Errorf("invalid parameter 'minDuration': %w", err)
+		}
+	}
+	if s := q.Get("maxDuration"); s != "" {
+		if filter.MaxDuration, err = time.ParseDuration(s); err != nil {
+			return nil, filter, fmt.Errorf("invalid parameter 'maxDuration': %w", err)
+		}
+	}
+
+	filter.Limit = defaultLimit
+	if s := q.Get("limit"); s != "" {
+		if filter.Limit, err = strconv.Atoi(s); err != nil || filter.Limit < 0 {
+			return nil, filter, fmt.Errorf("invalid parameter 'limit': %q", s)
+		}
+	}
+
+	if filter.Attributes, err = parseTags(q); err != nil {
+		return nil, filter, err
+	}
+
+	return nil, filter, nil
+}
+
+// parseTags parses the tags to search for, given either as JSON object in the
+// `tags` parameter or as `key:value` pairs in repeated `tag` parameters.
+func parseTags(q url.Values) (map[string]string, error) {
+	tags := map[string]string{}
+
+	if s := q.Get("tags"); s != "" {
+		if err := json.Unmarshal([]byte(s), &tags); err != nil {
+			return nil, fmt.Errorf("invalid parameter 'tags': %w", err)
+		}
+	}
+	for _, tag := range q["tag"] {
+		key, value, ok := strings.Cut(tag, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid parameter 'tag': %q", tag)
+		}
+		tags[key] = value
+	}
+
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	return tags, nil
+}
+
+// parseTraceId validates the hex encoded trace id and returns it lower case.
+func parseTraceId(s string) (string, error) {
+	if _, err := hex.DecodeString(s); err != nil || s == "" {
+		return "", fmt.Errorf("invalid trace id: %q", s)
+	}
+	return strings.ToLower(s), nil
+}
+
+// parseMicros parses microseconds since the epoch, an empty string yields the
+// zero time.
+func parseMicros(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	us, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMicro(us), nil
+}
+
+func writeResponse(w http.ResponseWriter, data any, total int) {
+	writeJSON(w, http.StatusOK, response{
+		Data:  data,
+		Total: total,
+	})
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	if code >= http.StatusInternalServerError {
+		slog.Error("Failed to serve jaeger query", "error", err)
+	}
+	writeJSON(w, code, response{
+		Errors: []responseError{{Code: code, Msg: err.Error()}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Failed to encode jaeger response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
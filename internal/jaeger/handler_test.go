@@ -0,0 +1,167 @@
+package jaeger
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+func TestParseTraceQuery(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	q := url.Values{
+		"service":     {"gitlab-ci"},
+		"operation":   {"pipeline"},
+		"start":       {"1704106800000000"},
+		"minDuration": {"1m"},
+		"limit":       {"5"},
+		"tags":        {`{"ci.pipeline.status":"failed"}`},
+		"tag":         {"ci.pipeline.ref:main"},
+	}
+
+	ids, filter, err := parseTraceQuery(q, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("Expected no trace ids, got %v", ids)
+	}
+	if filter.Service != "gitlab-ci" || filter.Operation != "pipeline" {
+		t.Errorf("Unexpected service or operation: %q, %q", filter.Service, filter.Operation)
+	}
+	if want := time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC); !filter.Start.Equal(want) {
+		t.Errorf("Expected start %v, got %v", want, filter.Start)
+	}
+	if !filter.End.Equal(now) {
+		t.Errorf("Expected end to default to now, got %v", filter.End)
+	}
+	if filter.MinDuration != time.Minute || filter.MaxDuration != 0 {
+		t.Errorf("Unexpected durations: %v, %v", filter.MinDuration, filter.MaxDuration)
+	}
+	if filter.Limit != 5 {
+		t.Errorf("Expected limit 5, got %d", filter.Limit)
+	}
+	if len(filter.Attributes) != 2 || filter.Attributes["ci.pipeline.status"] != "failed" || filter.Attributes["ci.pipeline.ref"] != "main" {
+		t.Errorf("Unexpected attributes: %v", filter.Attributes)
+	}
+
+	_, filter, err = parseTraceQuery(url.Values{"service": {"gitlab-ci"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filter.Start.Equal(now.Add(-defaultLookback)) || filter.Limit != defaultLimit {
+		t.Errorf("Expected default lookback and limit, got %v, %d", filter.Start, filter.Limit)
+	}
+
+	ids, _, err = parseTraceQuery(url.Values{"traceID": {"5B8EFFF798038103D269B633813FC60C"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "5b8efff798038103d269b633813fc60c" {
+		t.Errorf("Unexpected trace ids: %v", ids)
+	}
+
+	for _, q := range []url.Values{
+		{},
+		{"service": {"gitlab-ci"}, "limit": {"-1"}},
+		{"service": {"gitlab-ci"}, "tag": {"invalid"}},
+		{"traceID": {"xyz"}},
+	} {
+		if _, _, err := parseTraceQuery(q, now); err == nil {
+			t.Errorf("Expected error for %v", q)
+		}
+	}
+}
+
+func TestConvertTraces(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	spans := []clickhouse.TraceSpan{
+		{
+			Timestamp:          start,
+			TraceId:            "\x01\x02",
+			SpanId:             "\x0a",
+			SpanName:           "pipeline",
+			SpanKind:           "SPAN_KIND_INTERNAL",
+			ServiceName:        "gitlab-ci",
+			ResourceAttributes: map[string]string{"ci.project.id": "1"},
+			SpanAttributes:     map[string]string{"ci.pipeline.id": "2", "ci.pipeline.ref": "main"},
+			SpanAttributesInt:  map[string]int64{"ci.pipeline.id": 2},
+			Duration:           int64(time.Minute),
+			StatusCode:         "STATUS_CODE_ERROR",
+			StatusMessage:      "script_failure",
+		},
+		{
+			Timestamp:          start.Add(time.Second),
+			TraceId:            "\x01\x02",
+			SpanId:             "\x0b",
+			ParentSpanId:       "\x0a",
+			SpanName:           "build",
+			ServiceName:        "gitlab-ci",
+			ResourceAttributes: map[string]string{"ci.project.id": "1"},
+			Duration:           int64(time.Second),
+			EventTimestamps:    []time.Time{start},
+			EventNames:         []string{"queued"},
+			EventAttributes:    []map[string]string{{}},
+			LinkTraceIds:       []string{"\x03\x04"},
+			LinkSpanIds:        []string{"\x0c"},
+		},
+		{
+			Timestamp:   start,
+			TraceId:     "\x03\x04",
+			SpanId:      "\x0c",
+			SpanName:    "pipeline",
+			ServiceName: "gitlab-ci",
+		},
+	}
+
+	traces := convertTraces(spans)
+	if len(traces) != 2 {
+		t.Fatalf("Expected 2 traces, got %d", len(traces))
+	}
+
+	tr := traces[0]
+	if tr.TraceID != "0102" || len(tr.Spans) != 2 {
+		t.Fatalf("Unexpected trace: %s with %d spans", tr.TraceID, len(tr.Spans))
+	}
+	if len(tr.Processes) != 1 || tr.Spans[0].ProcessID != tr.Spans[1].ProcessID {
+		t.Errorf("Expected spans to share a process, got %v", tr.Processes)
+	}
+
+	root := tr.Spans[0]
+	if root.SpanID != "0a" || root.Duration != time.Minute.Microseconds() || root.StartTime != start.UnixMicro() {
+		t.Errorf("Unexpected root span: %+v", root)
+	}
+	tags := map[string]keyValue{}
+	for _, tag := range root.Tags {
+		tags[tag.Key] = tag
+	}
+	if tag := tags["ci.pipeline.id"]; tag.Type != "int64" || tag.Value != int64(2) {
+		t.Errorf("Expected typed int tag, got %+v", tag)
+	}
+	if tag := tags["ci.pipeline.ref"]; tag.Type != "string" || tag.Value != "main" {
+		t.Errorf("Expected string tag, got %+v", tag)
+	}
+	if tag := tags["error"]; tag.Value != true {
+		t.Errorf("Expected error tag, got %+v", tag)
+	}
+	if tag := tags["span.kind"]; tag.Value != "internal" {
+		t.Errorf("Expected span kind tag, got %+v", tag)
+	}
+
+	child := tr.Spans[1]
+	if len(child.References) != 2 {
+		t.Fatalf("Expected 2 references, got %v", child.References)
+	}
+	if ref := child.References[0]; ref.RefType != "CHILD_OF" || ref.SpanID != "0a" || ref.TraceID != "0102" {
+		t.Errorf("Unexpected parent reference: %+v", ref)
+	}
+	if ref := child.References[1]; ref.RefType != "FOLLOWS_FROM" || ref.SpanID != "0c" || ref.TraceID != "0304" {
+		t.Errorf("Unexpected link reference: %+v", ref)
+	}
+	if len(child.Logs) != 1 || child.Logs[0].Fields[0].Value != "queued" {
+		t.Errorf("Unexpected logs: %+v", child.Logs)
+	}
+}
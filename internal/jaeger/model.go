@@ -0,0 +1,264 @@
+package jaeger
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+// The types below mirror the JSON model of the Jaeger query API as consumed
+// by the Jaeger UI.
+
+type response struct {
+	Data   any             `json:"data"`
+	Total  int             `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+	Errors []responseError `json:"errors"`
+}
+
+type responseError struct {
+	Code    int    `json:"code,omitempty"`
+	Msg     string `json:"msg"`
+	TraceID string `json:"traceID,omitempty"`
+}
+
+type operation struct {
+	Name     string `json:"name"`
+	SpanKind string `json:"spanKind"`
+}
+
+type trace struct {
+	TraceID   string             `json:"traceID"`
+	Spans     []span             `json:"spans"`
+	Processes map[string]process `json:"processes"`
+	Warnings  []string           `json:"warnings"`
+}
+
+type span struct {
+	TraceID       string      `json:"traceID"`
+	SpanID        string      `json:"spanID"`
+	OperationName string      `json:"operationName"`
+	References    []reference `json:"references"`
+	StartTime     int64       `json:"startTime"` // microseconds since the epoch
+	Duration      int64       `json:"duration"`  // microseconds
+	Tags          []keyValue  `json:"tags"`
+	Logs          []logEntry  `json:"logs"`
+	ProcessID     string      `json:"processID"`
+	Warnings      []string    `json:"warnings"`
+}
+
+type reference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type process struct {
+	ServiceName string     `json:"serviceName"`
+	Tags        []keyValue `json:"tags"`
+}
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+type logEntry struct {
+	Timestamp int64      `json:"timestamp"`
+	Fields    []keyValue `json:"fields"`
+}
+
+// convertTraces groups the spans by trace, keeping the order in which the
+// traces first appear.
+func convertTraces(spans []clickhouse.TraceSpan) []trace {
+	var (
+		order   []string
+		builder = map[string]*traceBuilder{}
+	)
+	for i := range spans {
+		traceId := formatId(spans[i].TraceId)
+		b, ok := builder[traceId]
+		if !ok {
+			b = &traceBuilder{
+				trace: trace{
+					TraceID:   traceId,
+					Spans:     []span{},
+					Processes: map[string]process{},
+				},
+				processIds: map[string]string{},
+			}
+			builder[traceId] = b
+			order = append(order, traceId)
+		}
+		b.add(&spans[i])
+	}
+
+	traces := make([]trace, 0, len(order))
+	for _, id := range order {
+		traces = append(traces, builder[id].trace)
+	}
+	return traces
+}
+
+type traceBuilder struct {
+	trace trace
+	// process ids keyed by service name and resource attributes
+	processIds map[string]string
+}
+
+func (b *traceBuilder) add(s *clickhouse.TraceSpan) {
+	b.trace.Spans = append(b.trace.Spans, convertSpan(s, b.processId(s)))
+}
+
+func (b *traceBuilder) processId(s *clickhouse.TraceSpan) string {
+	tags := stringTags(s.ResourceAttributes)
+
+	var key strings.Builder
+	key.WriteString(s.ServiceName)
+	for _, tag := range tags {
+		fmt.Fprintf(&key, "\x00%s=%v", tag.Key, tag.Value)
+	}
+
+	id, ok := b.processIds[key.String()]
+	if !ok {
+		id = fmt.Sprintf("p%d", len(b.processIds)+1)
+		b.processIds[key.String()] = id
+		b.trace.Processes[id] = process{
+			ServiceName: s.ServiceName,
+			Tags:        tags,
+		}
+	}
+	return id
+}
+
+func convertSpan(s *clickhouse.TraceSpan, processId string) span {
+	traceId := formatId(s.TraceId)
+
+	references := []reference{}
+	if s.ParentSpanId != "" {
+		references = append(references, reference{
+			RefType: "CHILD_OF",
+			TraceID: traceId,
+			SpanID:  formatId(s.ParentSpanId),
+		})
+	}
+	for i := range s.LinkSpanIds {
+		if i >= len(s.LinkTraceIds) {
+			break
+		}
+		references = append(references, reference{
+			RefType: "FOLLOWS_FROM",
+			TraceID: formatId(s.LinkTraceIds[i]),
+			SpanID:  formatId(s.LinkSpanIds[i]),
+		})
+	}
+
+	logs := []logEntry{}
+	for i, ts := range s.EventTimestamps {
+		fields := []keyValue{}
+		if i < len(s.EventNames) {
+			fields = append(fields, keyValue{Key: "event", Type: "string", Value: s.EventNames[i]})
+		}
+		if i < len(s.EventAttributes) {
+			fields = append(fields, stringTags(s.EventAttributes[i])...)
+		}
+		logs = append(logs, logEntry{
+			Timestamp: ts.UnixMicro(),
+			Fields:    fields,
+		})
+	}
+
+	return span{
+		TraceID:       traceId,
+		SpanID:        formatId(s.SpanId),
+		OperationName: s.SpanName,
+		References:    references,
+		StartTime:     s.Timestamp.UnixMicro(),
+		Duration:      s.Duration / 1000,
+		Tags:          spanTags(s),
+		Logs:          logs,
+		ProcessID:     processId,
+		Warnings:      []string{},
+	}
+}
+
+// spanTags returns the span attributes, typed where the original type is
+// known, followed by the tags Jaeger uses for OpenTelemetry span fields.
+func spanTags(s *clickhouse.TraceSpan) []keyValue {
+	keys := make([]string, 0, len(s.SpanAttributes))
+	for key := range s.SpanAttributes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	tags := make([]keyValue, 0, len(keys)+6)
+	for _, key := range keys {
+		if v, ok := s.SpanAttributesInt[key]; ok {
+			tags = append(tags, keyValue{Key: key, Type: "int64", Value: v})
+		} else if v, ok := s.SpanAttributesFloat[key]; ok {
+			tags = append(tags, keyValue{Key: key, Type: "float64", Value: v})
+		} else {
+			tags = append(tags, keyValue{Key: key, Type: "string", Value: s.SpanAttributes[key]})
+		}
+	}
+
+	if kind := spanKind(s.SpanKind); kind != "" {
+		tags = append(tags, keyValue{Key: "span.kind", Type: "string", Value: kind})
+	}
+	if s.ScopeName != "" {
+		tags = append(tags, keyValue{Key: "otel.scope.name", Type: "string", Value: s.ScopeName})
+	}
+	if s.ScopeVersion != "" {
+		tags = append(tags, keyValue{Key: "otel.scope.version", Type: "string", Value: s.ScopeVersion})
+	}
+	switch s.StatusCode {
+	case "STATUS_CODE_OK":
+		tags = append(tags, keyValue{Key: "otel.status_code", Type: "string", Value: "OK"})
+	case "STATUS_CODE_ERROR":
+		tags = append(tags,
+			keyValue{Key: "otel.status_code", Type: "string", Value: "ERROR"},
+			keyValue{Key: "error", Type: "bool", Value: true},
+		)
+	}
+	if s.StatusMessage != "" {
+		tags = append(tags, keyValue{Key: "otel.status_description", Type: "string", Value: s.StatusMessage})
+	}
+
+	return tags
+}
+
+func stringTags(m map[string]string) []keyValue {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	tags := make([]keyValue, 0, len(keys))
+	for _, key := range keys {
+		tags = append(tags, keyValue{Key: key, Type: "string", Value: m[key]})
+	}
+	return tags
+}
+
+// spanKind converts the stored OpenTelemetry span kind to Jaeger's, e.g.
+// `SPAN_KIND_SERVER` to `server`. Unspecified kinds yield an empty string.
+func spanKind(kind string) string {
+	switch kind {
+	case "", "SPAN_KIND_UNSPECIFIED":
+		return ""
+	default:
+		return strings.ToLower(strings.TrimPrefix(kind, "SPAN_KIND_"))
+	}
+}
+
+// formatId hex encodes the trace or span id as stored.
+func formatId(id string) string {
+	return hex.EncodeToString([]byte(id))
+}
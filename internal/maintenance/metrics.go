@@ -0,0 +1,63 @@
+package maintenance
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/promutil"
+)
+
+type metrics struct {
+	promutil.Collectors
+
+	runs     *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	lastRun  *prometheus.GaugeVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		runs: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "maintenance",
+				Name:      "runs_total",
+				Help:      "Total number of maintenance job runs by table and outcome.",
+			},
+			[]string{"table", "outcome"},
+		),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "maintenance",
+				Name:      "duration_seconds",
+				Help:      "Duration of maintenance job runs by table and outcome.",
+				Buckets:   []float64{0.1, 1, 10, 30, 60, 300, 900, 1800, 3600},
+			},
+			[]string{"table", "outcome"},
+		),
+		lastRun: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "maintenance",
+				Name:      "last_run_timestamp_seconds",
+				Help:      "Unix timestamp of the last maintenance job run by table and outcome.",
+			},
+			[]string{"table", "outcome"},
+		),
+	}
+	m.Collectors = promutil.Collectors{m.runs, m.duration, m.lastRun}
+	return m
+}
+
+func (m *metrics) observe(table string, outcome string, duration time.Duration) {
+	m.runs.WithLabelValues(table, outcome).Inc()
+	m.duration.WithLabelValues(table, outcome).Observe(duration.Seconds())
+	m.lastRun.WithLabelValues(table, outcome).SetToCurrentTime()
+}
+
+// MetricsCollector returns the collector of the scheduler's metrics.
+func (s *Scheduler) MetricsCollector() prometheus.Collector {
+	return s.metrics
+}
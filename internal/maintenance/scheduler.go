@@ -0,0 +1,182 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+const (
+	OutcomeSuccess string = "success"
+	OutcomeError   string = "error"
+	OutcomeHealthy string = "skipped_healthy"
+	OutcomeOverlap string = "skipped_overlap"
+)
+
+type Job struct {
+	Schedule cron.Schedule
+	Options  clickhouse.DeduplicateTableOptions
+
+	// Only deduplicate partitions exceeding the thresholds, if set
+	Adaptive *clickhouse.AdaptiveDeduplicateOptions
+}
+
+// NewJob creates a deduplication job that runs on the given cron schedule,
+// e.g. `0 * * * *` or `@hourly`.
+func NewJob(schedule string, opt clickhouse.DeduplicateTableOptions) (Job, error) {
+	s, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return Job{}, fmt.Errorf("invalid schedule `%s`: %w", schedule, err)
+	}
+
+	return Job{
+		Schedule: s,
+		Options:  opt,
+	}, nil
+}
+
+type Scheduler struct {
+	client *clickhouse.Client
+	jobs   []Job
+
+	// tables that are currently being maintained
+	mu     sync.Mutex
+	active map[string]bool
+
+	metrics *metrics
+}
+
+func NewScheduler(client *clickhouse.Client, jobs []Job) *Scheduler {
+	return &Scheduler{
+		client:  client,
+		jobs:    jobs,
+		active:  map[string]bool{},
+		metrics: newMetrics(),
+	}
+}
+
+// Run executes the jobs according to their schedules until the context is
+// cancelled. Runs that would overlap with a running job on the same table
+// are skipped.
+func (s *Scheduler) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			s.schedule(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (s *Scheduler) schedule(ctx context.Context, job Job) {
+	for {
+		next := job.Schedule.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.RunJob(ctx, job)
+	}
+}
+
+// RunJob executes the job once and returns the outcome.
+func (s *Scheduler) RunJob(ctx context.Context, job Job) string {
+	table := job.Options.Table
+
+	if !s.acquire(table) {
+		slog.Info("Skipping maintenance job, table is already being maintained", "table", table)
+		s.metrics.observe(table, OutcomeOverlap, 0)
+		return OutcomeOverlap
+	}
+	defer s.release(table)
+
+	start := time.Now()
+	outcome, err := s.runJob(ctx, job)
+	duration := time.Since(start)
+
+	s.metrics.observe(table, outcome, duration)
+	if err != nil {
+		slog.Error("Maintenance job failed", "table", table, "duration", duration, "error", err)
+	} else {
+		slog.Info("Maintenance job finished", "table", table, "outcome", outcome, "duration", duration)
+	}
+
+	return outcome
+}
+
+func (s *Scheduler) runJob(ctx context.Context, job Job) (string, error) {
+	partitions, err := clickhouse.SelectTablePartitions(s.client, ctx, job.Options.Table)
+	if err != nil {
+		return OutcomeError, fmt.Errorf("select partitions: %w", err)
+	}
+	if isHealthy(partitions) {
+		return OutcomeHealthy, nil
+	}
+
+	if job.Adaptive != nil {
+		results, err := clickhouse.AdaptiveDeduplicateTable(ctx, job.Options, *job.Adaptive, s.client)
+		if err != nil {
+			return OutcomeError, fmt.Errorf("deduplicate table: %w", err)
+		}
+		for _, r := range results {
+			if r.Optimized {
+				return OutcomeSuccess, nil
+			}
+		}
+		return OutcomeHealthy, nil
+	}
+
+	if err := clickhouse.DeduplicateTable(ctx, job.Options, s.client); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return OutcomeError, err
+		}
+		return OutcomeError, fmt.Errorf("deduplicate table: %w", err)
+	}
+
+	return OutcomeSuccess, nil
+}
+
+func (s *Scheduler) acquire(table string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active[table] {
+		return false
+	}
+	s.active[table] = true
+	return true
+}
+
+func (s *Scheduler) release(table string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.active, table)
+}
+
+// isHealthy reports whether every partition consists of a single part, in
+// which case the table has been deduplicated since the last insert.
+func isHealthy(partitions []clickhouse.Partition) bool {
+	for _, p := range partitions {
+		if p.Parts > 1 {
+			return false
+		}
+	}
+	return true
+}
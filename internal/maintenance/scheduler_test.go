@@ -0,0 +1,56 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+func TestNewJob(t *testing.T) {
+	job, err := NewJob("0 * * * *", clickhouse.DeduplicateTableOptions{Table: "pipelines"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	now := time.Date(2024, 6, 1, 12, 30, 0, 0, time.Local)
+	want := time.Date(2024, 6, 1, 13, 0, 0, 0, time.Local)
+	if got := job.Schedule.Next(now); !got.Equal(want) {
+		t.Errorf("Expected next run at %v, got %v", want, got)
+	}
+
+	if _, err := NewJob("every hour", clickhouse.DeduplicateTableOptions{}); err == nil {
+		t.Error("Expected error for invalid schedule")
+	}
+}
+
+func TestIsHealthy(t *testing.T) {
+	healthy := []clickhouse.Partition{{Parts: 1}, {Parts: 1}}
+	if !isHealthy(healthy) {
+		t.Error("Expected single part partitions to be healthy")
+	}
+
+	unhealthy := []clickhouse.Partition{{Parts: 1}, {Parts: 3}}
+	if isHealthy(unhealthy) {
+		t.Error("Expected multi part partition to be unhealthy")
+	}
+}
+
+func TestScheduler_NoOverlap(t *testing.T) {
+	s := NewScheduler(nil, nil)
+
+	if !s.acquire("pipelines") {
+		t.Fatal("Expected to acquire idle table")
+	}
+	if s.acquire("pipelines") {
+		t.Error("Expected not to acquire active table")
+	}
+	if !s.acquire("jobs") {
+		t.Error("Expected to acquire other table")
+	}
+
+	s.release("pipelines")
+	if !s.acquire("pipelines") {
+		t.Error("Expected to acquire released table")
+	}
+}
@@ -0,0 +1,197 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	contentTypeProtobuf string = "application/x-protobuf"
+	contentTypeJSON     string = "application/json"
+
+	// maximum size of a (decompressed) request body
+	maxBodySize int64 = 64 << 20
+)
+
+// HTTPHandlers returns the OTLP/HTTP handlers keyed by their URL path.
+func HTTPHandlers(recorder Recorder) map[string]http.Handler {
+	traces := NewTraceService(recorder)
+	logs := NewLogsService(recorder)
+	metrics := NewMetricsService(recorder)
+
+	return map[string]http.Handler{
+		"/v1/traces": exportHandler(
+			func() *coltracepb.ExportTraceServiceRequest { return &coltracepb.ExportTraceServiceRequest{} },
+			traces.Export,
+		),
+		"/v1/logs": exportHandler(
+			func() *collogspb.ExportLogsServiceRequest { return &collogspb.ExportLogsServiceRequest{} },
+			logs.Export,
+		),
+		"/v1/metrics": exportHandler(
+			func() *colmetricspb.ExportMetricsServiceRequest { return &colmetricspb.ExportMetricsServiceRequest{} },
+			metrics.Export,
+		),
+	}
+}
+
+type exportFunc[Req proto.Message, Resp proto.Message] func(context.Context, Req) (Resp, error)
+
+// exportHandler returns a handler that decodes OTLP/HTTP requests encoded as
+// binary protobuf or JSON and encodes the response the same way.
+func exportHandler[Req proto.Message, Resp proto.Message](newRequest func() Req, export exportFunc[Req, Resp]) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeStatus(w, contentTypeProtobuf, http.StatusMethodNotAllowed, status.New(codes.Unimplemented, "method not allowed"))
+			return
+		}
+
+		contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || (contentType != contentTypeProtobuf && contentType != contentTypeJSON) {
+			writeStatus(w, contentTypeProtobuf, http.StatusUnsupportedMediaType, status.Newf(codes.InvalidArgument, "unsupported content type: %q", r.Header.Get("Content-Type")))
+			return
+		}
+
+		body, err := readBody(r)
+		if err != nil {
+			writeStatus(w, contentType, http.StatusBadRequest, status.Newf(codes.InvalidArgument, "read body: %v", err))
+			return
+		}
+
+		req := newRequest()
+		if err := unmarshal(contentType, body, req); err != nil {
+			writeStatus(w, contentType, http.StatusBadRequest, status.Newf(codes.InvalidArgument, "decode request: %v", err))
+			return
+		}
+
+		resp, err := export(r.Context(), req)
+		if err != nil {
+			st, _ := status.FromError(err)
+			code := http.StatusInternalServerError
+			if st.Code() == codes.Unavailable {
+				code = http.StatusServiceUnavailable
+			}
+			writeStatus(w, contentType, code, st)
+			return
+		}
+
+		writeMessage(w, contentType, http.StatusOK, resp)
+	})
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	var body io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %q", r.Header.Get("Content-Encoding"))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	} else if int64(len(data)) > maxBodySize {
+		return nil, fmt.Errorf("body exceeds %d bytes", maxBodySize)
+	}
+	return data, nil
+}
+
+func unmarshal(contentType string, data []byte, m proto.Message) error {
+	if contentType == contentTypeJSON {
+		data, err := convertJSONIds(data)
+		if err != nil {
+			return err
+		}
+		return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, m)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func writeMessage(w http.ResponseWriter, contentType string, code int, m proto.Message) {
+	var (
+		data []byte
+		err  error
+	)
+	if contentType == contentTypeJSON {
+		data, err = protojson.Marshal(m)
+	} else {
+		data, err = proto.Marshal(m)
+	}
+	if err != nil {
+		slog.Error("Failed to encode OTLP response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
+
+func writeStatus(w http.ResponseWriter, contentType string, code int, st *status.Status) {
+	writeMessage(w, contentType, code, st.Proto())
+}
+
+// convertJSONIds converts the hex encoded trace and span ids used by OTLP/JSON
+// to the base64 encoding that protojson expects for bytes fields.
+func convertJSONIds(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(convertIds(v))
+}
+
+func convertIds(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, val := range v {
+			switch key {
+			case "traceId", "spanId", "parentSpanId", "trace_id", "span_id", "parent_span_id":
+				if s, ok := val.(string); ok {
+					if b, err := hex.DecodeString(s); err == nil {
+						v[key] = base64.StdEncoding.EncodeToString(b)
+						continue
+					}
+				}
+			}
+			v[key] = convertIds(val)
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = convertIds(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,101 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestHandler(got **coltracepb.ExportTraceServiceRequest) http.Handler {
+	return exportHandler(
+		func() *coltracepb.ExportTraceServiceRequest { return &coltracepb.ExportTraceServiceRequest{} },
+		func(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+			*got = req
+			return &coltracepb.ExportTraceServiceResponse{}, nil
+		},
+	)
+}
+
+func TestExportHandler_JSON(t *testing.T) {
+	var got *coltracepb.ExportTraceServiceRequest
+	h := newTestHandler(&got)
+
+	body := `{"resourceSpans":[{"scopeSpans":[{"spans":[{
+		"traceId":"5b8efff798038103d269b633813fc60c",
+		"spanId":"eee19b7ec3c1b174",
+		"name":"compile",
+		"startTimeUnixNano":"1544712660000000000",
+		"endTimeUnixNano":1544712661000000000
+	}]}]}]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/traces", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected json response, got %q", ct)
+	}
+
+	span := got.GetResourceSpans()[0].GetScopeSpans()[0].GetSpans()[0]
+	wantTraceId := []byte{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c}
+	if !bytes.Equal(span.GetTraceId(), wantTraceId) {
+		t.Errorf("Expected trace id %x, got %x", wantTraceId, span.GetTraceId())
+	}
+	if span.GetName() != "compile" {
+		t.Errorf("Expected span name `compile`, got %q", span.GetName())
+	}
+	if span.GetEndTimeUnixNano() != 1544712661000000000 {
+		t.Errorf("Expected end time to be preserved, got %d", span.GetEndTimeUnixNano())
+	}
+}
+
+func TestExportHandler_Protobuf(t *testing.T) {
+	var got *coltracepb.ExportTraceServiceRequest
+	h := newTestHandler(&got)
+
+	data, err := proto.Marshal(&coltracepb.ExportTraceServiceRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/traces", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if got == nil {
+		t.Error("Expected request to be exported")
+	}
+}
+
+func TestExportHandler_UnsupportedContentType(t *testing.T) {
+	var got *coltracepb.ExportTraceServiceRequest
+	h := newTestHandler(&got)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/traces", bytes.NewBufferString("spans"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status 415, got %d", rec.Code)
+	}
+	if got != nil {
+		t.Error("Expected request not to be exported")
+	}
+}
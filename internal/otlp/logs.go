@@ -0,0 +1,48 @@
+package otlp
+
+import (
+	"context"
+	"log/slog"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/servicepb"
+)
+
+// LogsRecorder records the received log records, like the recorder's
+// RecordLogs method, which validates and inserts them.
+type LogsRecorder interface {
+	RecordLogs(ctx context.Context, data []*logspb.LogsData) (*servicepb.RecordSummary, error)
+}
+
+type LogsService struct {
+	collogspb.UnimplementedLogsServiceServer
+
+	recorder LogsRecorder
+}
+
+func NewLogsService(recorder LogsRecorder) *LogsService {
+	return &LogsService{
+		recorder: recorder,
+	}
+}
+
+func (s *LogsService) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	if len(req.GetResourceLogs()) == 0 {
+		return &collogspb.ExportLogsServiceResponse{}, nil
+	}
+
+	logs := []*logspb.LogsData{
+		{ResourceLogs: req.GetResourceLogs()},
+	}
+
+	if _, err := s.recorder.RecordLogs(ctx, logs); err != nil {
+		slog.Error("Failed to insert OTLP logs", "error", err)
+		return nil, status.Errorf(codes.Unavailable, "insert logs: %v", err)
+	}
+
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
@@ -0,0 +1,48 @@
+package otlp
+
+import (
+	"context"
+	"log/slog"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/servicepb"
+)
+
+// MetricsRecorder records the received metrics, like the recorder's
+// RecordOTLPMetrics method, which validates and inserts them.
+type MetricsRecorder interface {
+	RecordOTLPMetrics(ctx context.Context, data []*metricspb.MetricsData) (*servicepb.RecordSummary, error)
+}
+
+type MetricsService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+
+	recorder MetricsRecorder
+}
+
+func NewMetricsService(recorder MetricsRecorder) *MetricsService {
+	return &MetricsService{
+		recorder: recorder,
+	}
+}
+
+func (s *MetricsService) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	if len(req.GetResourceMetrics()) == 0 {
+		return &colmetricspb.ExportMetricsServiceResponse{}, nil
+	}
+
+	metrics := []*metricspb.MetricsData{
+		{ResourceMetrics: req.GetResourceMetrics()},
+	}
+
+	if _, err := s.recorder.RecordOTLPMetrics(ctx, metrics); err != nil {
+		slog.Error("Failed to insert OTLP metrics", "error", err)
+		return nil, status.Errorf(codes.Unavailable, "insert metrics: %v", err)
+	}
+
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
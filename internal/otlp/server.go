@@ -0,0 +1,52 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/encoding/gzip" // register gzip compressor
+)
+
+// Recorder records the data received by the OTLP collector services.
+type Recorder interface {
+	TraceRecorder
+	LogsRecorder
+	MetricsRecorder
+}
+
+// Server serves the OTLP collector services over gRPC.
+type Server struct {
+	grpcServer *grpc.Server
+}
+
+func NewServer(recorder Recorder) *Server {
+	s := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(s, NewTraceService(recorder))
+	collogspb.RegisterLogsServiceServer(s, NewLogsService(recorder))
+	colmetricspb.RegisterMetricsServiceServer(s, NewMetricsService(recorder))
+
+	return &Server{
+		grpcServer: s,
+	}
+}
+
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	slog.Info("Serving OTLP over gRPC", "addr", lis.Addr().String())
+
+	go func() {
+		<-ctx.Done()
+		s.grpcServer.GracefulStop()
+	}()
+
+	return s.grpcServer.Serve(lis)
+}
@@ -0,0 +1,49 @@
+package otlp
+
+import (
+	"context"
+	"log/slog"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/servicepb"
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+)
+
+// TraceRecorder records the received traces, like the recorder's RecordTraces
+// method, which samples, validates, captures and inserts them.
+type TraceRecorder interface {
+	RecordTraces(ctx context.Context, r *servicepb.RecordTracesRequest) (*servicepb.RecordSummary, error)
+}
+
+type TraceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+
+	recorder TraceRecorder
+}
+
+func NewTraceService(recorder TraceRecorder) *TraceService {
+	return &TraceService{
+		recorder: recorder,
+	}
+}
+
+func (s *TraceService) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	if len(req.GetResourceSpans()) == 0 {
+		return &coltracepb.ExportTraceServiceResponse{}, nil
+	}
+
+	traces := []*typespb.Trace{
+		{Data: &tracepb.TracesData{ResourceSpans: req.GetResourceSpans()}},
+	}
+
+	if _, err := s.recorder.RecordTraces(ctx, &servicepb.RecordTracesRequest{Data: traces}); err != nil {
+		slog.Error("Failed to insert OTLP traces", "error", err)
+		return nil, status.Errorf(codes.Unavailable, "insert traces: %v", err)
+	}
+
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
@@ -0,0 +1,51 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/servicepb"
+)
+
+type testTraceRecorder struct {
+	requests []*servicepb.RecordTracesRequest
+	err      error
+}
+
+func (r *testTraceRecorder) RecordTraces(_ context.Context, req *servicepb.RecordTracesRequest) (*servicepb.RecordSummary, error) {
+	r.requests = append(r.requests, req)
+	return &servicepb.RecordSummary{}, r.err
+}
+
+func TestTraceService_Export(t *testing.T) {
+	rec := &testTraceRecorder{}
+	s := NewTraceService(rec)
+
+	spans := []*tracepb.ResourceSpans{{ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{{Name: "compile"}}}}}}
+	if _, err := s.Export(context.Background(), &coltracepb.ExportTraceServiceRequest{ResourceSpans: spans}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.requests) != 1 || len(rec.requests[0].GetData()) != 1 {
+		t.Fatalf("Expected the spans to be recorded as one trace, got %v", rec.requests)
+	}
+	if got := rec.requests[0].GetData()[0].GetData().GetResourceSpans(); len(got) != 1 || got[0] != spans[0] {
+		t.Errorf("Expected the resource spans to be recorded, got %v", got)
+	}
+}
+
+func TestTraceService_ExportError(t *testing.T) {
+	s := NewTraceService(&testTraceRecorder{err: errors.New("unavailable")})
+
+	spans := []*tracepb.ResourceSpans{{}}
+	_, err := s.Export(context.Background(), &coltracepb.ExportTraceServiceRequest{ResourceSpans: spans})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected status Unavailable, got %v", err)
+	}
+}
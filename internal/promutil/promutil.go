@@ -0,0 +1,27 @@
+// Package promutil provides helpers shared by the metrics of the recorder's
+// components.
+package promutil
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace is the namespace of all metrics of the recorder.
+const Namespace string = "gitlab_exporter_clickhouse_recorder"
+
+// Collectors is a collector of the metrics of the collectors it consists of.
+// It's embedded in the metrics of a component, so that they are registered
+// as one collector.
+type Collectors []prometheus.Collector
+
+func (c Collectors) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c {
+		collector.Describe(ch)
+	}
+}
+
+func (c Collectors) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c {
+		collector.Collect(ch)
+	}
+}
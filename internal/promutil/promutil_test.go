@@ -0,0 +1,23 @@
+package promutil
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectors(t *testing.T) {
+	a := prometheus.NewCounter(prometheus.CounterOpts{Namespace: Namespace, Name: "a_total", Help: "A."})
+	b := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: Namespace, Name: "b", Help: "B."})
+
+	c := Collectors{a, b}
+	if n := testutil.CollectAndCount(c); n != 2 {
+		t.Errorf("Expected 2 metrics, got %d", n)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Errorf("Expected collectors to register, got %v", err)
+	}
+}
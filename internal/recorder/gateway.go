@@ -0,0 +1,232 @@
+package recorder
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/servicepb"
+)
+
+const (
+	contentTypeProtobuf string = "application/x-protobuf"
+	contentTypeJSON     string = "application/json"
+
+	// maximum size of a (decompressed) gateway request body
+	maxGatewayBodySize int64 = 64 << 20
+
+	// GatewayPattern is the URL pattern the gateway handler is served at.
+	GatewayPattern string = "POST /v1/record/{entity}"
+)
+
+// gatewayMethod decodes a request and records its data.
+type gatewayMethod func(ctx context.Context, contentType string, body []byte) (*servicepb.RecordSummary, error)
+
+func gatewayRoute[Req any, PReq interface {
+	*Req
+	proto.Message
+}](record func(context.Context, PReq) (*servicepb.RecordSummary, error)) gatewayMethod {
+	return func(ctx context.Context, contentType string, body []byte) (*servicepb.RecordSummary, error) {
+		req := PReq(new(Req))
+		if err := unmarshalGatewayRequest(contentType, body, req); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "decode request: %v", err)
+		}
+		return record(ctx, req)
+	}
+}
+
+// gatewayRoutes returns the Record* methods keyed by the entity they record.
+func (s *ClickHouseRecorder) gatewayRoutes() map[string]gatewayMethod {
+	return map[string]gatewayMethod{
+		"pipelines":               gatewayRoute(s.RecordPipelines),
+		"jobs":                    gatewayRoute(s.RecordJobs),
+		"sections":                gatewayRoute(s.RecordSections),
+		"testreports":             gatewayRoute(s.RecordTestReports),
+		"testsuites":              gatewayRoute(s.RecordTestSuites),
+		"testcases":               gatewayRoute(s.RecordTestCases),
+		"mergerequests":           gatewayRoute(s.RecordMergeRequests),
+		"mergerequest_noteevents": gatewayRoute(s.RecordMergeRequestNoteEvents),
+		"projects":                gatewayRoute(s.RecordProjects),
+		"coverage_reports":        gatewayRoute(s.RecordCoverageReports),
+		"coverage_packages":       gatewayRoute(s.RecordCoveragePackages),
+		"coverage_classes":        gatewayRoute(s.RecordCoverageClasses),
+		"coverage_methods":        gatewayRoute(s.RecordCoverageMethods),
+		"deployments":             gatewayRoute(s.RecordDeployments),
+		"issues":                  gatewayRoute(s.RecordIssues),
+		"metrics":                 gatewayRoute(s.RecordMetrics),
+		"traces":                  gatewayRoute(s.RecordTraces),
+	}
+}
+
+// gatewayEntities maps the Record* methods to the entity they're served at.
+var gatewayEntities = map[string]string{
+	"RecordPipelines":              "pipelines",
+	"RecordJobs":                   "jobs",
+	"RecordSections":               "sections",
+	"RecordTestReports":            "testreports",
+	"RecordTestSuites":             "testsuites",
+	"RecordTestCases":              "testcases",
+	"RecordMergeRequests":          "mergerequests",
+	"RecordMergeRequestNoteEvents": "mergerequest_noteevents",
+	"RecordProjects":               "projects",
+	"RecordCoverageReports":        "coverage_reports",
+	"RecordCoveragePackages":       "coverage_packages",
+	"RecordCoverageClasses":        "coverage_classes",
+	"RecordCoverageMethods":        "coverage_methods",
+	"RecordDeployments":            "deployments",
+	"RecordIssues":                 "issues",
+	"RecordMetrics":                "metrics",
+	"RecordTraces":                 "traces",
+}
+
+// GatewayPath returns the URL path the gateway serves the Record* method at.
+func GatewayPath(method string) (string, error) {
+	entity, ok := gatewayEntities[method]
+	if !ok {
+		return "", fmt.Errorf("unknown method: %q", method)
+	}
+	return "/v1/record/" + entity, nil
+}
+
+// Replay decodes the binary protobuf request of the Record* method, e.g. as
+// captured by a capturer, and records it.
+func (s *ClickHouseRecorder) Replay(ctx context.Context, method string, data []byte) (*servicepb.RecordSummary, error) {
+	entity, ok := gatewayEntities[method]
+	if !ok {
+		return nil, fmt.Errorf("unknown method: %q", method)
+	}
+	return s.gatewayRoutes()[entity](ctx, contentTypeProtobuf, data)
+}
+
+// GatewayHandler returns a handler that serves the Record* methods over HTTP
+// at `POST /v1/record/{entity}`, e.g. `/v1/record/pipelines`. Requests are
+// the method's request message encoded as binary protobuf or protobuf JSON,
+// optionally gzip compressed, and are answered with the record summary or a
+// status in the same encoding.
+func (s *ClickHouseRecorder) GatewayHandler() http.Handler {
+	routes := s.gatewayRoutes()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || (contentType != contentTypeProtobuf && contentType != contentTypeJSON) {
+			writeGatewayStatus(w, contentTypeJSON, http.StatusUnsupportedMediaType, status.Newf(codes.InvalidArgument, "unsupported content type: %q", r.Header.Get("Content-Type")))
+			return
+		}
+
+		method, ok := routes[r.PathValue("entity")]
+		if !ok {
+			writeGatewayStatus(w, contentType, http.StatusNotFound, status.Newf(codes.NotFound, "unknown entity: %q", r.PathValue("entity")))
+			return
+		}
+
+		body, err := readGatewayBody(r)
+		if err != nil {
+			writeGatewayStatus(w, contentType, http.StatusBadRequest, status.Newf(codes.InvalidArgument, "read body: %v", err))
+			return
+		}
+
+		summary, err := method(gatewayContext(r), contentType, body)
+		if err != nil {
+			st, _ := status.FromError(err)
+			writeGatewayStatus(w, contentType, httpStatusFromCode(st.Code()), st)
+			return
+		}
+
+		writeGatewayMessage(w, contentType, http.StatusOK, summary)
+	})
+}
+
+// gatewayContext returns the request's context with the client's address as
+// peer, like for gRPC requests.
+func gatewayContext(r *http.Request) context.Context {
+	addr, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return r.Context()
+	}
+	return peer.NewContext(r.Context(), &peer.Peer{Addr: net.TCPAddrFromAddrPort(addr)})
+}
+
+func readGatewayBody(r *http.Request) ([]byte, error) {
+	var body io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %q", r.Header.Get("Content-Encoding"))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxGatewayBodySize+1))
+	if err != nil {
+		return nil, err
+	} else if int64(len(data)) > maxGatewayBodySize {
+		return nil, fmt.Errorf("body exceeds %d bytes", maxGatewayBodySize)
+	}
+	return data, nil
+}
+
+func unmarshalGatewayRequest(contentType string, data []byte, m proto.Message) error {
+	if contentType == contentTypeJSON {
+		return protojson.Unmarshal(data, m)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// httpStatusFromCode maps the gRPC status code of a failed request to the
+// HTTP status code of the response.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeGatewayMessage(w http.ResponseWriter, contentType string, code int, m proto.Message) {
+	var (
+		data []byte
+		err  error
+	)
+	if contentType == contentTypeJSON {
+		data, err = protojson.Marshal(m)
+	} else {
+		data, err = proto.Marshal(m)
+	}
+	if err != nil {
+		slog.Error("Failed to encode gateway response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
+
+func writeGatewayStatus(w http.ResponseWriter, contentType string, code int, st *status.Status) {
+	writeGatewayMessage(w, contentType, code, st.Proto())
+}
@@ -0,0 +1,110 @@
+package recorder
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestGatewayHandler(t *testing.T) {
+	rec := New(nil)
+	mux := http.NewServeMux()
+	mux.Handle(GatewayPattern, rec.GatewayHandler())
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		encoding    string
+		status      int
+	}{
+		{
+			name:        "method not allowed",
+			method:      http.MethodGet,
+			path:        "/v1/record/pipelines",
+			contentType: contentTypeJSON,
+			status:      http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "unsupported content type",
+			method:      http.MethodPost,
+			path:        "/v1/record/pipelines",
+			contentType: "text/plain",
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "unknown entity",
+			method:      http.MethodPost,
+			path:        "/v1/record/builds",
+			contentType: contentTypeJSON,
+			status:      http.StatusNotFound,
+		},
+		{
+			name:        "unsupported encoding",
+			method:      http.MethodPost,
+			path:        "/v1/record/jobs",
+			contentType: contentTypeProtobuf,
+			encoding:    "br",
+			status:      http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(nil))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGatewayRoutes(t *testing.T) {
+	routes := New(nil).gatewayRoutes()
+	if len(routes) != 17 {
+		t.Errorf("Expected a route for each of the 17 record methods, got %d", len(routes))
+	}
+}
+
+func TestHTTPStatusFromCode(t *testing.T) {
+	tests := map[codes.Code]int{
+		codes.InvalidArgument: http.StatusBadRequest,
+		codes.Unavailable:     http.StatusServiceUnavailable,
+		codes.Unknown:         http.StatusInternalServerError,
+	}
+	for code, want := range tests {
+		if got := httpStatusFromCode(code); got != want {
+			t.Errorf("Expected %d for %s, got %d", want, code, got)
+		}
+	}
+}
+
+func TestGatewayPath(t *testing.T) {
+	for method := range gatewayEntities {
+		path, err := GatewayPath(method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entity := strings.TrimPrefix(path, "/v1/record/")
+		if _, ok := New(nil).gatewayRoutes()[entity]; !ok {
+			t.Errorf("Method %s maps to unknown entity %q", method, entity)
+		}
+	}
+
+	if _, err := GatewayPath("RecordBuilds"); err == nil {
+		t.Error("Expected error for unknown method")
+	}
+}
@@ -0,0 +1,105 @@
+package recorder
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/promutil"
+)
+
+type metrics struct {
+	promutil.Collectors
+
+	received *prometheus.CounterVec
+	inserted *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	dropped  *prometheus.CounterVec
+	rejected *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		received: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "recorder",
+				Name:      "records_received_total",
+				Help:      "Total number of records received by table.",
+			},
+			[]string{"table"},
+		),
+		inserted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "recorder",
+				Name:      "records_inserted_total",
+				Help:      "Total number of records inserted by table.",
+			},
+			[]string{"table"},
+		),
+		errors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "recorder",
+				Name:      "insert_errors_total",
+				Help:      "Total number of failed inserts by table.",
+			},
+			[]string{"table"},
+		),
+		dropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "recorder",
+				Name:      "records_dropped_total",
+				Help:      "Total number of records dropped without inserting by table.",
+			},
+			[]string{"table"},
+		),
+		rejected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "recorder",
+				Name:      "records_rejected_total",
+				Help:      "Total number of records rejected by validation by table.",
+			},
+			[]string{"table"},
+		),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "recorder",
+				Name:      "insert_duration_seconds",
+				Help:      "Duration of inserts by table.",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"table"},
+		),
+	}
+	m.Collectors = promutil.Collectors{m.received, m.inserted, m.errors, m.dropped, m.rejected, m.duration}
+	return m
+}
+
+func (m *metrics) observe(table string, received int, inserted int, duration time.Duration, err error) {
+	m.received.WithLabelValues(table).Add(float64(received))
+	m.duration.WithLabelValues(table).Observe(duration.Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(table).Inc()
+	} else if inserted > 0 {
+		m.inserted.WithLabelValues(table).Add(float64(inserted))
+	}
+}
+
+func (m *metrics) observeDropped(table string, n int) {
+	m.dropped.WithLabelValues(table).Add(float64(n))
+}
+
+func (m *metrics) observeRejected(table string, n int) {
+	m.rejected.WithLabelValues(table).Add(float64(n))
+}
+
+// MetricsCollector returns the collector of the recorder's metrics.
+func (s *ClickHouseRecorder) MetricsCollector() prometheus.Collector {
+	return s.metrics
+}
@@ -0,0 +1,25 @@
+package recorder
+
+import (
+	"context"
+
+	otlp_logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	otlp_metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/servicepb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+// RecordLogs records OTLP log records to the logs table. Like remote-write
+// samples, they are validated and counted by the recorder metrics but not
+// captured, since only the Record* requests of the service can be replayed.
+func (s *ClickHouseRecorder) RecordLogs(ctx context.Context, data []*otlp_logspb.LogsData) (*servicepb.RecordSummary, error) {
+	return record[otlp_logspb.LogsData](s, ctx, clickhouse.LogsTable, data, clickhouse.InsertLogs)
+}
+
+// RecordOTLPMetrics records the data points of OTLP metrics to the metrics
+// tables, and is not captured either.
+func (s *ClickHouseRecorder) RecordOTLPMetrics(ctx context.Context, data []*otlp_metricspb.MetricsData) (*servicepb.RecordSummary, error) {
+	return record[otlp_metricspb.MetricsData](s, ctx, clickhouse.MetricsTable, data, clickhouse.InsertOTLPMetrics)
+}
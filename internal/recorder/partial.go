@@ -0,0 +1,152 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+type partialRecordsKey struct{}
+
+// WithPartialRecords marks the records of the requests made with the context
+// as partial, like those converted from webhook payloads, which lack data the
+// exporter sends. Partial records must be versioned with PartialVersion. They
+// are only inserted if no record with their id is in the table yet, or if the
+// recorded one is partial as well and not newer, and are not added to the id
+// cache, so that they never take precedence over the complete records.
+func WithPartialRecords(ctx context.Context) context.Context {
+	return context.WithValue(ctx, partialRecordsKey{}, true)
+}
+
+func partialRecords(ctx context.Context) bool {
+	partial, _ := ctx.Value(partialRecordsKey{}).(bool)
+	return partial
+}
+
+// PartialVersion returns the version of a partial record of an event at t:
+// the last millisecond of the second before the event. Versions are stored
+// with millisecond precision, so records of the same event sent by the
+// exporter take precedence, and the last millisecond marks recorded rows as
+// partial. Complete rows that happen to be versioned at the last millisecond
+// of a second are treated as partial too, until the exporter updates them.
+func PartialVersion(t time.Time) *timestamppb.Timestamp {
+	return timestamppb.New(t.Truncate(time.Second).Add(-time.Millisecond))
+}
+
+func isPartialVersion(t time.Time) bool {
+	return t.Nanosecond() == int(time.Second-time.Millisecond)
+}
+
+// partialVersionColumns are the version columns of the tables partial records
+// are recorded to. Records of unversioned tables, like jobs, which webhooks
+// only send once finished, are only inserted if their id is unknown.
+var partialVersionColumns = map[string]string{
+	clickhouse.PipelinesTable:              "updated_at",
+	clickhouse.MergeRequestsTable:          "updated_at",
+	clickhouse.MergeRequestNoteEventsTable: "updated_at",
+	clickhouse.IssuesTable:                 "updated_at",
+	clickhouse.DeploymentsTable:            "updated_at",
+}
+
+// newPartialRecords returns the partial records that are not superseded by
+// the recorded ones.
+func newPartialRecords[T any](srv *ClickHouseRecorder, ctx context.Context, table string, data []*T) ([]*T, error) {
+	ids := make([]int64, 0, len(data))
+	for _, r := range data {
+		if id, ok := recordId(r); ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return data, nil
+	}
+
+	column, versioned := partialVersionColumns[table]
+	if !versioned {
+		known, err := clickhouse.SelectKnownTableIDs(srv.client, ctx, table, ids)
+		if err != nil {
+			return nil, fmt.Errorf("select known ids: %w", err)
+		}
+
+		unknown := data[:0:0]
+		for _, r := range data {
+			if id, ok := recordId(r); ok {
+				if _, ok := known[id]; ok {
+					continue
+				}
+			}
+			unknown = append(unknown, r)
+		}
+		return unknown, nil
+	}
+
+	versions, err := clickhouse.SelectTableIDVersions(srv.client, ctx, table, column, ids)
+	if err != nil {
+		return nil, fmt.Errorf("select versions: %w", err)
+	}
+
+	newer := data[:0:0]
+	for _, r := range data {
+		if id, ok := recordId(r); ok {
+			recorded, ok := versions[id]
+			if ok && !supersedes(recordVersion(r), recorded) {
+				continue
+			}
+		}
+		newer = append(newer, r)
+	}
+	return newer, nil
+}
+
+// supersedes reports whether a partial record of the version replaces the
+// recorded row of the recorded version. Events of the same second, like the
+// created, pending and running events of a pipeline hook, have the same
+// partial version, so the later one replaces the earlier one.
+func supersedes(version time.Time, recorded time.Time) bool {
+	return isPartialVersion(recorded) && !version.Before(recorded)
+}
+
+// recordId returns the id of the records that can be partial.
+func recordId(record any) (int64, bool) {
+	switch r := record.(type) {
+	case *typespb.Pipeline:
+		return r.GetId(), true
+	case *typespb.Job:
+		return r.GetId(), true
+	case *typespb.MergeRequest:
+		return r.GetId(), true
+	case *typespb.MergeRequestNoteEvent:
+		return r.GetId(), true
+	case *typespb.Issue:
+		return r.GetId(), true
+	case *typespb.Deployment:
+		return r.GetId(), true
+	default:
+		return 0, false
+	}
+}
+
+// recordVersion returns the version of the versioned records that can be
+// partial.
+func recordVersion(record any) time.Time {
+	switch r := record.(type) {
+	case *typespb.Pipeline:
+		return r.GetTimestamps().GetUpdatedAt().AsTime()
+	case *typespb.MergeRequest:
+		return r.GetTimestamps().GetUpdatedAt().AsTime()
+	case *typespb.MergeRequestNoteEvent:
+		return r.GetUpdatedAt().AsTime()
+	case *typespb.Issue:
+		return r.GetTimestamps().GetUpdatedAt().AsTime()
+	case *typespb.Deployment:
+		return r.GetTimestamps().GetUpdatedAt().AsTime()
+	default:
+		return time.Time{}
+	}
+}
@@ -0,0 +1,51 @@
+package recorder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSupersedes(t *testing.T) {
+	created := time.Date(2016, 8, 12, 15, 23, 28, 0, time.UTC)
+	finished := time.Date(2016, 8, 12, 15, 26, 29, 0, time.UTC)
+
+	running := PartialVersion(created).AsTime()
+	success := PartialVersion(finished).AsTime()
+	exported := time.Date(2016, 8, 12, 15, 26, 29, 85e6, time.UTC)
+
+	tests := []struct {
+		name     string
+		version  time.Time
+		recorded time.Time
+		want     bool
+	}{
+		{name: "newer partial", version: success, recorded: running, want: true},
+		{name: "same partial", version: running, recorded: running, want: true},
+		{name: "older partial", version: running, recorded: success, want: false},
+		{name: "complete", version: success, recorded: exported, want: false},
+		{name: "older complete", version: success, recorded: created, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := supersedes(tt.version, tt.recorded); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPartialVersion(t *testing.T) {
+	event := time.Date(2016, 8, 12, 15, 26, 29, 500e6, time.UTC)
+
+	got := PartialVersion(event).AsTime()
+	if want := time.Date(2016, 8, 12, 15, 26, 28, 999e6, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if !isPartialVersion(got) {
+		t.Errorf("Expected %v to be a partial version", got)
+	}
+	if isPartialVersion(event) {
+		t.Errorf("Expected %v not to be a partial version", event)
+	}
+}
@@ -3,48 +3,129 @@ package recorder
 import (
 	"context"
 	"log/slog"
+	"time"
 
 	"go.cluttr.dev/gitlab-exporter/protobuf/servicepb"
 	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
 
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/capture"
 	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/idcache"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/sampling"
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/validation"
 )
 
 type ClickHouseRecorder struct {
 	servicepb.UnimplementedGitLabExporterServer
 
-	client *clickhouse.Client
+	client  *clickhouse.Client
+	metrics *metrics
+
+	// synthesizes traces from pipelines, jobs and sections, nil synthesizes
+	// none
+	synthesizer *synthesizer
+	// samples traces before they are inserted, nil keeps all
+	sampler *sampling.Sampler
+	// captures received requests for replay, nil captures none
+	capturer *capture.Capturer
+	// validates records before they are inserted, nil inserts all
+	validator *validation.Validator
+	// skips records that are already known, nil inserts all
+	ids *idcache.Cache
 }
 
 func New(client *clickhouse.Client) *ClickHouseRecorder {
 	return &ClickHouseRecorder{
-		client: client,
+		client:  client,
+		metrics: newMetrics(),
 	}
 }
 
+// SetCapturer sets the capturer that received requests are written to.
+func (s *ClickHouseRecorder) SetCapturer(capturer *capture.Capturer) {
+	s.capturer = capturer
+}
+
+// SetValidator sets the validator that records are checked with before they
+// are inserted.
+func (s *ClickHouseRecorder) SetValidator(validator *validation.Validator) {
+	s.validator = validator
+}
+
+// SetIDCache sets the cache that records already in the database are skipped
+// with.
+func (s *ClickHouseRecorder) SetIDCache(ids *idcache.Cache) {
+	s.ids = ids
+}
+
+// SetTraceSampler sets the sampler that recorded traces are passed through.
+func (s *ClickHouseRecorder) SetTraceSampler(sampler *sampling.Sampler) {
+	s.sampler = sampler
+}
+
 type insertFunc[T any] func(client *clickhouse.Client, ctx context.Context, data []*T) (int, error)
 
-func record[T any](srv *ClickHouseRecorder, ctx context.Context, data []*T, insert insertFunc[T]) (*servicepb.RecordSummary, error) {
+func record[T any](srv *ClickHouseRecorder, ctx context.Context, table string, data []*T, insert insertFunc[T]) (*servicepb.RecordSummary, error) {
 	if len(data) == 0 {
 		return &servicepb.RecordSummary{}, nil
 	}
 
+	// rejected records are not part of the recorded count, they are
+	// reported in the recorder metrics
+	data, result := validation.Validate(srv.validator, table, data)
+	if result.Rejected > 0 {
+		srv.metrics.observeRejected(table, result.Rejected)
+	}
+	if len(data) == 0 {
+		return &servicepb.RecordSummary{}, nil
+	}
+
+	// known records are already recorded, and part of the recorded count
+	partial := partialRecords(ctx)
+	known := len(data)
+	if partial {
+		var err error
+		if data, err = newPartialRecords(srv, ctx, table, data); err != nil {
+			slog.Error("Failed to filter partial data", "table", table, "error", err)
+			return nil, err
+		}
+	} else {
+		data = idcache.Filter(srv.ids, ctx, table, data)
+	}
+	known -= len(data)
+	if len(data) == 0 {
+		return &servicepb.RecordSummary{
+			RecordedCount: int32(known),
+		}, nil
+	}
+
+	start := time.Now()
 	n, err := insert(srv.client, context.Background(), data)
+	srv.metrics.observe(table, len(data), n, time.Since(start), err)
 	if err != nil {
-		slog.Error("Failed to insert data", "error", err)
+		slog.Error("Failed to insert data", "table", table, "error", err)
 		return nil, err
 	}
+	if !partial {
+		idcache.Add(srv.ids, table, data)
+	}
 
 	return &servicepb.RecordSummary{
-		RecordedCount: int32(n),
+		RecordedCount: int32(n + known),
 	}, nil
 }
 
 func (s *ClickHouseRecorder) RecordPipelines(ctx context.Context, r *servicepb.RecordPipelinesRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.Pipeline](s, ctx, r.Data, clickhouse.InsertPipelines)
+	s.capturer.Capture(ctx, "RecordPipelines", r)
+	summary, err := record[typespb.Pipeline](s, ctx, clickhouse.PipelinesTable, r.Data, clickhouse.InsertPipelines)
+	if err == nil {
+		s.synthesize(len(r.Data), func() []*typespb.Trace { return clickhouse.SynthesizePipelineTraces(r.Data) })
+	}
+	return summary, err
 }
 
 func (s *ClickHouseRecorder) RecordJobs(ctx context.Context, r *servicepb.RecordJobsRequest) (*servicepb.RecordSummary, error) {
+	s.capturer.Capture(ctx, "RecordJobs", r)
 	var (
 		builds  []*typespb.Job
 		bridges []*typespb.Job
@@ -57,76 +138,98 @@ func (s *ClickHouseRecorder) RecordJobs(ctx context.Context, r *servicepb.Record
 		}
 	}
 
-	buildsSummary, err := record[typespb.Job](s, ctx, builds, clickhouse.InsertJobs)
+	buildsSummary, err := record[typespb.Job](s, ctx, clickhouse.JobsTable, builds, clickhouse.InsertJobs)
 	if err != nil {
 		return buildsSummary, err
 	}
-	bridgesSummary, err := record[typespb.Job](s, ctx, bridges, clickhouse.InsertBridges)
+	bridgesSummary, err := record[typespb.Job](s, ctx, clickhouse.BridgesTable, bridges, clickhouse.InsertBridges)
 	if err != nil {
 		return bridgesSummary, err
 	}
 
+	s.synthesize(len(r.Data), func() []*typespb.Trace { return clickhouse.SynthesizeJobTraces(r.Data) })
+
 	return &servicepb.RecordSummary{
 		RecordedCount: buildsSummary.RecordedCount + bridgesSummary.RecordedCount,
 	}, nil
 }
 
 func (s *ClickHouseRecorder) RecordSections(ctx context.Context, r *servicepb.RecordSectionsRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.Section](s, ctx, r.Data, clickhouse.InsertSections)
+	s.capturer.Capture(ctx, "RecordSections", r)
+	summary, err := record[typespb.Section](s, ctx, clickhouse.SectionsTable, r.Data, clickhouse.InsertSections)
+	if err == nil {
+		s.synthesize(len(r.Data), func() []*typespb.Trace { return clickhouse.SynthesizeSectionTraces(r.Data) })
+	}
+	return summary, err
 }
 
 func (s *ClickHouseRecorder) RecordTestReports(ctx context.Context, r *servicepb.RecordTestReportsRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.TestReport](s, ctx, r.Data, clickhouse.InsertTestReports)
+	s.capturer.Capture(ctx, "RecordTestReports", r)
+	return record[typespb.TestReport](s, ctx, clickhouse.TestReportsTable, r.Data, clickhouse.InsertTestReports)
 }
 
 func (s *ClickHouseRecorder) RecordTestSuites(ctx context.Context, r *servicepb.RecordTestSuitesRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.TestSuite](s, ctx, r.Data, clickhouse.InsertTestSuites)
+	s.capturer.Capture(ctx, "RecordTestSuites", r)
+	return record[typespb.TestSuite](s, ctx, clickhouse.TestSuitesTable, r.Data, clickhouse.InsertTestSuites)
 }
 
 func (s *ClickHouseRecorder) RecordTestCases(ctx context.Context, r *servicepb.RecordTestCasesRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.TestCase](s, ctx, r.Data, clickhouse.InsertTestCases)
+	s.capturer.Capture(ctx, "RecordTestCases", r)
+	return record[typespb.TestCase](s, ctx, clickhouse.TestCasesTable, r.Data, clickhouse.InsertTestCases)
 }
 
 func (s *ClickHouseRecorder) RecordMergeRequests(ctx context.Context, r *servicepb.RecordMergeRequestsRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.MergeRequest](s, ctx, r.Data, clickhouse.InsertMergeRequests)
+	s.capturer.Capture(ctx, "RecordMergeRequests", r)
+	return record[typespb.MergeRequest](s, ctx, clickhouse.MergeRequestsTable, r.Data, clickhouse.InsertMergeRequests)
 }
 
 func (s *ClickHouseRecorder) RecordMergeRequestNoteEvents(ctx context.Context, r *servicepb.RecordMergeRequestNoteEventsRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.MergeRequestNoteEvent](s, ctx, r.Data, clickhouse.InsertMergeRequestNoteEvents)
+	s.capturer.Capture(ctx, "RecordMergeRequestNoteEvents", r)
+	return record[typespb.MergeRequestNoteEvent](s, ctx, clickhouse.MergeRequestNoteEventsTable, r.Data, clickhouse.InsertMergeRequestNoteEvents)
 }
 
 func (s *ClickHouseRecorder) RecordProjects(ctx context.Context, r *servicepb.RecordProjectsRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.Project](s, ctx, r.Data, clickhouse.InsertProjects)
+	s.capturer.Capture(ctx, "RecordProjects", r)
+	return record[typespb.Project](s, ctx, clickhouse.ProjectsTable, r.Data, clickhouse.InsertProjects)
 }
 
 func (s *ClickHouseRecorder) RecordCoverageReports(ctx context.Context, r *servicepb.RecordCoverageReportsRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.CoverageReport](s, ctx, r.Data, clickhouse.InsertCoverageReports)
+	s.capturer.Capture(ctx, "RecordCoverageReports", r)
+	return record[typespb.CoverageReport](s, ctx, clickhouse.CoverageReportsTable, r.Data, clickhouse.InsertCoverageReports)
 }
 
 func (s *ClickHouseRecorder) RecordCoveragePackages(ctx context.Context, r *servicepb.RecordCoveragePackagesRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.CoveragePackage](s, ctx, r.Data, clickhouse.InsertCoveragePackages)
+	s.capturer.Capture(ctx, "RecordCoveragePackages", r)
+	return record[typespb.CoveragePackage](s, ctx, clickhouse.CoveragePackagesTable, r.Data, clickhouse.InsertCoveragePackages)
 }
 
 func (s *ClickHouseRecorder) RecordCoverageClasses(ctx context.Context, r *servicepb.RecordCoverageClassesRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.CoverageClass](s, ctx, r.Data, clickhouse.InsertCoverageClasses)
+	s.capturer.Capture(ctx, "RecordCoverageClasses", r)
+	return record[typespb.CoverageClass](s, ctx, clickhouse.CoverageClassesTable, r.Data, clickhouse.InsertCoverageClasses)
 }
 
 func (s *ClickHouseRecorder) RecordCoverageMethods(ctx context.Context, r *servicepb.RecordCoverageMethodsRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.CoverageMethod](s, ctx, r.Data, clickhouse.InsertCoverageMethods)
+	s.capturer.Capture(ctx, "RecordCoverageMethods", r)
+	return record[typespb.CoverageMethod](s, ctx, clickhouse.CoverageMethodsTable, r.Data, clickhouse.InsertCoverageMethods)
 }
 
 func (s *ClickHouseRecorder) RecordDeployments(ctx context.Context, r *servicepb.RecordDeploymentsRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.Deployment](s, ctx, r.Data, clickhouse.InsertDeployments)
+	s.capturer.Capture(ctx, "RecordDeployments", r)
+	return record[typespb.Deployment](s, ctx, clickhouse.DeploymentsTable, r.Data, clickhouse.InsertDeployments)
 }
 
 func (s *ClickHouseRecorder) RecordIssues(ctx context.Context, r *servicepb.RecordIssuesRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.Issue](s, ctx, r.Data, clickhouse.InsertIssues)
+	s.capturer.Capture(ctx, "RecordIssues", r)
+	return record[typespb.Issue](s, ctx, clickhouse.IssuesTable, r.Data, clickhouse.InsertIssues)
 }
 
 func (s *ClickHouseRecorder) RecordMetrics(ctx context.Context, r *servicepb.RecordMetricsRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.Metric](s, ctx, r.Data, clickhouse.InsertMetrics)
+	s.capturer.Capture(ctx, "RecordMetrics", r)
+	return record[typespb.Metric](s, ctx, clickhouse.MetricsTable, r.Data, clickhouse.InsertMetrics)
 }
 
 func (s *ClickHouseRecorder) RecordTraces(ctx context.Context, r *servicepb.RecordTracesRequest) (*servicepb.RecordSummary, error) {
-	return record[typespb.Trace](s, ctx, r.Data, clickhouse.InsertTraces)
+	s.capturer.Capture(ctx, "RecordTraces", r)
+	s.synthesizer.observeTraces(r.Data)
+	return record[typespb.Trace](s, ctx, clickhouse.TraceSpansTable, r.Data, s.sampler.InsertTraces)
 }
@@ -0,0 +1,283 @@
+package recorder
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+
+	"github.com/klauspost/compress/snappy"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+const (
+	// maximum size of a (decompressed) remote-write request body
+	maxRemoteWriteSize int = 32 << 20
+
+	metricNameLabel string = "__name__"
+
+	// Prometheus marks series that disappeared with this special NaN value
+	staleNaN uint64 = 0x7ff0000000000002
+)
+
+// RemoteWriteHandler returns a handler that receives samples sent by the
+// Prometheus remote-write protocol (version 1.0) and records them to the
+// time series table. If allowedLabels is not empty, only the given labels
+// are kept.
+func (s *ClickHouseRecorder) RemoteWriteHandler(allowedLabels []string) http.Handler {
+	allowed := make(map[string]bool, len(allowedLabels))
+	for _, l := range allowedLabels {
+		allowed[l] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if ct := r.Header.Get("Content-Type"); ct != "" {
+			mediaType, params, err := mime.ParseMediaType(ct)
+			if err != nil || mediaType != "application/x-protobuf" {
+				http.Error(w, fmt.Sprintf("unsupported content type: %q", ct), http.StatusUnsupportedMediaType)
+				return
+			} else if proto, ok := params["proto"]; ok && proto != "prometheus.WriteRequest" {
+				http.Error(w, fmt.Sprintf("unsupported protobuf message: %q", proto), http.StatusUnsupportedMediaType)
+				return
+			}
+		}
+		if ce := r.Header.Get("Content-Encoding"); ce != "" && ce != "snappy" {
+			http.Error(w, fmt.Sprintf("unsupported content encoding: %q", ce), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		body, err := readSnappyBody(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("read body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		series, err := decodeWriteRequest(body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("decode request: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		samples := convertTimeSeries(series, allowed)
+		if _, err := record[clickhouse.TimeSeriesSample](s, r.Context(), clickhouse.TimeSeriesTable, samples, clickhouse.InsertTimeSeries); err != nil {
+			// let the sender retry with backoff
+			http.Error(w, "insert samples failed", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func readSnappyBody(r io.Reader) ([]byte, error) {
+	compressed, err := io.ReadAll(io.LimitReader(r, int64(maxRemoteWriteSize)+1))
+	if err != nil {
+		return nil, err
+	} else if len(compressed) > maxRemoteWriteSize {
+		return nil, fmt.Errorf("body exceeds %d bytes", maxRemoteWriteSize)
+	}
+
+	n, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, err
+	} else if n > maxRemoteWriteSize {
+		return nil, fmt.Errorf("decoded body exceeds %d bytes", maxRemoteWriteSize)
+	}
+
+	return snappy.Decode(nil, compressed)
+}
+
+type remoteLabel struct {
+	Name  string
+	Value string
+}
+
+type remoteSample struct {
+	Value     float64
+	Timestamp int64
+}
+
+type remoteTimeSeries struct {
+	Labels  []remoteLabel
+	Samples []remoteSample
+}
+
+// decodeWriteRequest decodes the time series of a `prometheus.WriteRequest`
+// message. Exemplars, native histograms and metadata are skipped.
+func decodeWriteRequest(b []byte) ([]remoteTimeSeries, error) {
+	var series []remoteTimeSeries
+	err := decodeMessage(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num != 1 || typ != protowire.BytesType {
+			return -1, nil
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+		ts, err := decodeTimeSeries(v)
+		if err != nil {
+			return 0, fmt.Errorf("timeseries: %w", err)
+		}
+		series = append(series, ts)
+		return n, nil
+	})
+	return series, err
+}
+
+func decodeTimeSeries(b []byte) (remoteTimeSeries, error) {
+	var ts remoteTimeSeries
+	err := decodeMessage(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.BytesType || (num != 1 && num != 2) {
+			return -1, nil
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+
+		switch num {
+		case 1:
+			l, err := decodeLabel(v)
+			if err != nil {
+				return 0, fmt.Errorf("label: %w", err)
+			}
+			ts.Labels = append(ts.Labels, l)
+		case 2:
+			s, err := decodeSample(v)
+			if err != nil {
+				return 0, fmt.Errorf("sample: %w", err)
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+		return n, nil
+	})
+	return ts, err
+}
+
+func decodeLabel(b []byte) (remoteLabel, error) {
+	var l remoteLabel
+	err := decodeMessage(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.BytesType || (num != 1 && num != 2) {
+			return -1, nil
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if num == 1 {
+			l.Name = string(v)
+		} else {
+			l.Value = string(v)
+		}
+		return n, nil
+	})
+	return l, err
+}
+
+func decodeSample(b []byte) (remoteSample, error) {
+	var s remoteSample
+	err := decodeMessage(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			s.Value = math.Float64frombits(v)
+			return n, nil
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			s.Timestamp = int64(v)
+			return n, nil
+		}
+		return -1, nil
+	})
+	return s, err
+}
+
+// decodeMessage iterates over the fields of a protobuf message. The field
+// function consumes the value of a field and returns its length, or -1 to
+// skip it.
+func decodeMessage(b []byte, field func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		n, err := field(num, typ, b)
+		if err != nil {
+			return err
+		} else if n == -1 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// convertTimeSeries converts remote-write time series to samples, keeping
+// only the allowed labels if any are given.
+func convertTimeSeries(series []remoteTimeSeries, allowed map[string]bool) []*clickhouse.TimeSeriesSample {
+	var samples []*clickhouse.TimeSeriesSample
+	for _, ts := range series {
+		var name string
+		labels := make(map[string]string, len(ts.Labels))
+		for _, l := range ts.Labels {
+			if l.Name == metricNameLabel {
+				name = l.Value
+			} else if len(allowed) == 0 || allowed[l.Name] {
+				labels[l.Name] = l.Value
+			}
+		}
+		if name == "" {
+			slog.Debug("Skipping time series without metric name", "labels", labels)
+			continue
+		}
+		fingerprint := labelsFingerprint(name, labels)
+
+		for _, s := range ts.Samples {
+			if math.Float64bits(s.Value) == staleNaN {
+				continue
+			}
+			samples = append(samples, &clickhouse.TimeSeriesSample{
+				MetricName:  name,
+				Labels:      labels,
+				Fingerprint: fingerprint,
+				Timestamp:   s.Timestamp,
+				Value:       s.Value,
+			})
+		}
+	}
+	return samples
+}
+
+// labelsFingerprint returns a hash identifying a series by its metric name
+// and (allowed) labels.
+func labelsFingerprint(name string, labels map[string]string) uint64 {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	for _, k := range keys {
+		_, _ = h.Write([]byte{0xff})
+		_, _ = h.Write([]byte(k))
+		_, _ = h.Write([]byte{0xff})
+		_, _ = h.Write([]byte(labels[k]))
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,195 @@
+package recorder
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+func encodeWriteRequest(series []remoteTimeSeries) []byte {
+	var req []byte
+	for _, ts := range series {
+		var b []byte
+		for _, l := range ts.Labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.Name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.Value)
+
+			b = protowire.AppendTag(b, 1, protowire.BytesType)
+			b = protowire.AppendBytes(b, lb)
+		}
+		for _, s := range ts.Samples {
+			var sb []byte
+			sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+			sb = protowire.AppendFixed64(sb, math.Float64bits(s.Value))
+			sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+			sb = protowire.AppendVarint(sb, uint64(s.Timestamp))
+
+			b = protowire.AppendTag(b, 2, protowire.BytesType)
+			b = protowire.AppendBytes(b, sb)
+		}
+		// exemplars are skipped
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, []byte{})
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, b)
+	}
+	return req
+}
+
+func TestDecodeWriteRequest(t *testing.T) {
+	want := []remoteTimeSeries{
+		{
+			Labels: []remoteLabel{
+				{Name: "__name__", Value: "node_cpu_seconds_total"},
+				{Name: "instance", Value: "runner-1"},
+				{Name: "mode", Value: "user"},
+			},
+			Samples: []remoteSample{
+				{Value: 42.5, Timestamp: 1700690851366},
+				{Value: 43.0, Timestamp: 1700690866366},
+			},
+		},
+		{
+			Labels: []remoteLabel{
+				{Name: "__name__", Value: "node_memory_MemAvailable_bytes"},
+			},
+			Samples: []remoteSample{
+				{Value: 1024, Timestamp: 1700690851366},
+			},
+		},
+	}
+
+	got, err := decodeWriteRequest(encodeWriteRequest(want))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := decodeWriteRequest([]byte{0x0a, 0x05, 0x01}); err == nil {
+		t.Error("Expected error decoding truncated message")
+	}
+}
+
+func TestConvertTimeSeries(t *testing.T) {
+	series := []remoteTimeSeries{
+		{
+			Labels: []remoteLabel{
+				{Name: "__name__", Value: "node_cpu_seconds_total"},
+				{Name: "instance", Value: "runner-1"},
+				{Name: "mode", Value: "user"},
+			},
+			Samples: []remoteSample{
+				{Value: 42.5, Timestamp: 1700690851366},
+				{Value: math.Float64frombits(staleNaN), Timestamp: 1700690866366},
+			},
+		},
+		{
+			Labels: []remoteLabel{
+				{Name: "instance", Value: "runner-1"},
+			},
+			Samples: []remoteSample{
+				{Value: 1, Timestamp: 1700690851366},
+			},
+		},
+	}
+
+	got := convertTimeSeries(series, map[string]bool{"instance": true})
+	labels := map[string]string{"instance": "runner-1"}
+	want := []*clickhouse.TimeSeriesSample{
+		{
+			MetricName:  "node_cpu_seconds_total",
+			Labels:      labels,
+			Fingerprint: labelsFingerprint("node_cpu_seconds_total", labels),
+			Timestamp:   1700690851366,
+			Value:       42.5,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	all := convertTimeSeries(series, nil)
+	if len(all) != 1 || len(all[0].Labels) != 2 {
+		t.Errorf("Expected all labels to be kept, got %v", all)
+	}
+	if all[0].Fingerprint == got[0].Fingerprint {
+		t.Error("Expected fingerprint to depend on labels")
+	}
+}
+
+func TestRemoteWriteHandler(t *testing.T) {
+	rec := New(nil)
+	handler := rec.RemoteWriteHandler(nil)
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		encoding    string
+		body        []byte
+		status      int
+	}{
+		{
+			name:   "method not allowed",
+			method: http.MethodGet,
+			status: http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "unsupported protobuf message",
+			method:      http.MethodPost,
+			contentType: "application/x-protobuf;proto=io.prometheus.write.v2.Request",
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:     "unsupported encoding",
+			method:   http.MethodPost,
+			encoding: "gzip",
+			status:   http.StatusUnsupportedMediaType,
+		},
+		{
+			name:   "invalid snappy",
+			method: http.MethodPost,
+			body:   []byte("not snappy"),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "empty request",
+			method: http.MethodPost,
+			body:   snappy.Encode(nil, encodeWriteRequest(nil)),
+			status: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/write", bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
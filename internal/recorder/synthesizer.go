@@ -0,0 +1,178 @@
+package recorder
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	otlp_comonpb "go.opentelemetry.io/proto/otlp/common/v1"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+// the metrics label of synthesized traces, which are recorded into the traces
+// table along with the received ones
+const synthesizedTracesLabel string = "traces_synthesized"
+
+// maximum number of queued batches that are synthesized and inserted together
+const maxSynthesisBatches int = 100
+
+type SynthesisOptions struct {
+	// Only synthesize traces of projects that no traces have been received
+	// for
+	UntracedOnly bool
+	// Maximum number of batches of records queued for synthesis, new batches
+	// are dropped if the queue is full
+	QueueSize int
+}
+
+// synthesizer synthesizes traces from recorded pipelines, jobs and sections
+// in the background, so that requests don't wait for them.
+type synthesizer struct {
+	opts  SynthesisOptions
+	queue chan func() []*typespb.Trace
+
+	mu sync.RWMutex
+	// projects that traces have been received for
+	traced map[int64]struct{}
+}
+
+func newSynthesizer(opts SynthesisOptions) *synthesizer {
+	return &synthesizer{
+		opts:   opts,
+		queue:  make(chan func() []*typespb.Trace, max(opts.QueueSize, 1)),
+		traced: map[int64]struct{}{},
+	}
+}
+
+// SetTraceSynthesis enables the synthesis of traces from recorded pipelines,
+// jobs and sections. The traces are recorded by `RunTraceSynthesis`.
+func (s *ClickHouseRecorder) SetTraceSynthesis(opts SynthesisOptions) {
+	s.synthesizer = newSynthesizer(opts)
+}
+
+// RunTraceSynthesis records the synthesized traces until the context is
+// cancelled, after which the remaining queued traces are recorded.
+func (s *ClickHouseRecorder) RunTraceSynthesis(ctx context.Context) error {
+	if s.synthesizer == nil {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for len(s.synthesizer.queue) > 0 {
+				s.recordSynthesizedTraces(<-s.synthesizer.queue)
+			}
+			return ctx.Err()
+		case synthesize := <-s.synthesizer.queue:
+			s.recordSynthesizedTraces(synthesize)
+		}
+	}
+}
+
+// synthesize queues the synthesis of traces. It never blocks, the records
+// are dropped if the queue is full.
+func (s *ClickHouseRecorder) synthesize(n int, synthesize func() []*typespb.Trace) {
+	if s.synthesizer == nil || n == 0 {
+		return
+	}
+
+	select {
+	case s.synthesizer.queue <- synthesize:
+	default:
+		s.metrics.observeDropped(synthesizedTracesLabel, n)
+		slog.Warn("Dropped records to synthesize traces from, queue is full", "records", n)
+	}
+}
+
+// recordSynthesizedTraces records the traces of the given and the further
+// queued syntheses in a single insert. Failures are only logged since the
+// source data has already been recorded.
+func (s *ClickHouseRecorder) recordSynthesizedTraces(synthesize func() []*typespb.Trace) {
+	traces := s.synthesizer.untraced(synthesize())
+	for i := 1; i < maxSynthesisBatches && len(s.synthesizer.queue) > 0; i++ {
+		traces = append(traces, s.synthesizer.untraced((<-s.synthesizer.queue)())...)
+	}
+	if len(traces) == 0 {
+		return
+	}
+
+	start := time.Now()
+	n, err := s.sampler.InsertTraces(s.client, context.Background(), traces)
+	s.metrics.observe(synthesizedTracesLabel, len(traces), n, time.Since(start), err)
+	if err != nil {
+		slog.Warn("Failed to record synthesized traces", "error", err)
+	}
+}
+
+// observeTraces marks the projects of the received traces as traced.
+func (s *synthesizer) observeTraces(traces []*typespb.Trace) {
+	if s == nil || !s.opts.UntracedOnly {
+		return
+	}
+
+	var projects []int64
+	for _, trace := range traces {
+		for _, rs := range trace.GetData().GetResourceSpans() {
+			if id := projectIdAttribute(rs.GetResource().GetAttributes()); id != 0 {
+				projects = append(projects, id)
+			}
+		}
+	}
+	if len(projects) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range projects {
+		s.traced[id] = struct{}{}
+	}
+}
+
+// untraced returns the synthesized traces of the projects that no traces have
+// been received for, or all if not restricted to those.
+func (s *synthesizer) untraced(traces []*typespb.Trace) []*typespb.Trace {
+	if !s.opts.UntracedOnly {
+		return traces
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := traces[:0]
+	for _, trace := range traces {
+		rs := trace.GetData().GetResourceSpans()
+		if len(rs) > 0 {
+			if _, ok := s.traced[projectIdAttribute(rs[0].GetResource().GetAttributes())]; ok {
+				continue
+			}
+		}
+		result = append(result, trace)
+	}
+	return result
+}
+
+// projectIdAttribute returns the project id of the resource attributes, or 0
+// if absent or invalid.
+func projectIdAttribute(attrs []*otlp_comonpb.KeyValue) int64 {
+	for _, attr := range attrs {
+		if attr.GetKey() != clickhouse.ProjectIdAttribute {
+			continue
+		}
+		switch v := attr.GetValue().GetValue().(type) {
+		case *otlp_comonpb.AnyValue_IntValue:
+			return v.IntValue
+		case *otlp_comonpb.AnyValue_StringValue:
+			id, _ := strconv.ParseInt(v.StringValue, 10, 64)
+			return id
+		}
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package recorder
+
+import (
+	"testing"
+
+	otlp_comonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	otlp_resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	otlp_tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+func testProjectTrace(projectId *otlp_comonpb.AnyValue) *typespb.Trace {
+	return &typespb.Trace{
+		Data: &otlp_tracepb.TracesData{
+			ResourceSpans: []*otlp_tracepb.ResourceSpans{{
+				Resource: &otlp_resourcepb.Resource{
+					Attributes: []*otlp_comonpb.KeyValue{
+						{Key: clickhouse.ProjectIdAttribute, Value: projectId},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func intValue(i int64) *otlp_comonpb.AnyValue {
+	return &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_IntValue{IntValue: i}}
+}
+
+func TestSynthesizer_Untraced(t *testing.T) {
+	s := newSynthesizer(SynthesisOptions{UntracedOnly: true, QueueSize: 1})
+
+	s.observeTraces([]*typespb.Trace{
+		testProjectTrace(&otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_StringValue{StringValue: "1"}}),
+	})
+
+	got := s.untraced([]*typespb.Trace{testProjectTrace(intValue(1)), testProjectTrace(intValue(2))})
+	if len(got) != 1 || projectIdAttribute(got[0].Data.ResourceSpans[0].Resource.Attributes) != 2 {
+		t.Errorf("Expected only the trace of project 2 to be kept, got %d", len(got))
+	}
+
+	all := newSynthesizer(SynthesisOptions{QueueSize: 1})
+	all.observeTraces([]*typespb.Trace{testProjectTrace(intValue(1))})
+	if got := all.untraced([]*typespb.Trace{testProjectTrace(intValue(1))}); len(got) != 1 {
+		t.Errorf("Expected all traces to be kept, got %d", len(got))
+	}
+}
+
+func TestSynthesize_QueueFull(t *testing.T) {
+	rec := New(nil)
+	rec.SetTraceSynthesis(SynthesisOptions{QueueSize: 1})
+
+	synthesize := func() []*typespb.Trace { return nil }
+	rec.synthesize(1, synthesize)
+	rec.synthesize(1, synthesize)
+
+	if n := len(rec.synthesizer.queue); n != 1 {
+		t.Errorf("Expected 1 queued synthesis, got %d", n)
+	}
+
+	// without synthesizer, nothing is queued
+	New(nil).synthesize(1, synthesize)
+}
@@ -0,0 +1,61 @@
+package sampling
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/promutil"
+)
+
+type metrics struct {
+	promutil.Collectors
+
+	spans    *prometheus.CounterVec
+	failed   prometheus.Counter
+	buffered prometheus.GaugeFunc
+}
+
+func newMetrics(buffered func() float64) *metrics {
+	m := &metrics{
+		spans: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "sampling",
+				Name:      "spans_total",
+				Help:      "Total number of sampled spans by stage and decision.",
+			},
+			[]string{"stage", "decision"},
+		),
+		failed: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "sampling",
+				Name:      "failed_spans_total",
+				Help:      "Total number of tail sampled spans that failed to be inserted.",
+			},
+		),
+		buffered: prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "sampling",
+				Name:      "buffered_traces",
+				Help:      "Number of traces buffered for tail sampling.",
+			},
+			buffered,
+		),
+	}
+	m.Collectors = promutil.Collectors{m.spans, m.failed, m.buffered}
+	return m
+}
+
+func (m *metrics) observe(stage string, decision string, spans float64) {
+	m.spans.WithLabelValues(stage, decision).Add(spans)
+}
+
+func (m *metrics) observeFailed(spans int) {
+	m.failed.Add(float64(spans))
+}
+
+// MetricsCollector returns the collector of the sampler's metrics.
+func (s *Sampler) MetricsCollector() prometheus.Collector {
+	return s.metrics
+}
@@ -0,0 +1,473 @@
+package sampling
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	otlp_tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/clickhouse"
+)
+
+const (
+	StageHead string = "head"
+	StageTail string = "tail"
+
+	DecisionKept    string = "kept"
+	DecisionDropped string = "dropped"
+)
+
+type Options struct {
+	Head HeadOptions
+	Tail TailOptions
+}
+
+// HeadOptions configure the probabilistic sampling of spans as they are
+// received.
+type HeadOptions struct {
+	// Probability to keep a span, if no rule matches
+	Rate float64
+	// Rules to apply per service and span name, the first matching rule wins
+	Rules []HeadRule
+}
+
+type HeadRule struct {
+	// Service name to match, empty matches all
+	Service string
+	// Span name to match, empty matches all
+	SpanName string
+	// Probability to keep a matching span
+	Rate float64
+}
+
+// TailOptions configure the sampling of whole traces after their spans have
+// been buffered for a while.
+type TailOptions struct {
+	Enabled bool
+	// How long spans are buffered before the trace is sampled
+	Window time.Duration
+	// Maximum number of buffered traces, the oldest traces are sampled early
+	// if exceeded
+	MaxTraces int
+	// Keep traces that contain a span with error status
+	KeepErrors bool
+	// Keep traces that take at least this long, 0 disables the rule
+	KeepSlowerThan time.Duration
+	// Probability to keep traces that match no rule
+	Rate float64
+	// How long the decision of a sampled trace is remembered, so that spans
+	// received late get the same decision
+	DecisionTTL time.Duration
+}
+
+// Sampler samples traces before they are inserted. A nil sampler keeps all
+// traces.
+type Sampler struct {
+	client *clickhouse.Client
+	opts   Options
+
+	mu        sync.Mutex
+	traces    map[string]*bufferedTrace
+	order     []string
+	decisions map[string]decision
+
+	// signals the worker that the buffer exceeds the maximum number of traces
+	overflow chan struct{}
+
+	// called with the kept traces before they are inserted
+	forward func([]*typespb.Trace)
+
+	metrics *metrics
+}
+
+type bufferedTrace struct {
+	received time.Time
+	spans    []*otlp_tracepb.ResourceSpans
+}
+
+type decision struct {
+	kept    bool
+	expires time.Time
+}
+
+func NewSampler(client *clickhouse.Client, opts Options) *Sampler {
+	s := &Sampler{
+		client:    client,
+		opts:      opts,
+		traces:    map[string]*bufferedTrace{},
+		decisions: map[string]decision{},
+		overflow:  make(chan struct{}, 1),
+	}
+	s.metrics = newMetrics(func() float64 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return float64(len(s.traces))
+	})
+	return s
+}
+
+// SetForwardFunc sets a function that the kept traces are passed to before
+// they are inserted, regardless of the outcome of the insert.
+func (s *Sampler) SetForwardFunc(f func([]*typespb.Trace)) {
+	s.forward = f
+}
+
+// InsertTraces samples the spans of the traces and inserts the kept ones. If
+// tail sampling is enabled, spans are buffered and inserted once their trace
+// has been sampled, or inserted right away if their trace has already been
+// kept. It returns the number of inserted and buffered spans. Buffered spans
+// are inserted at most once: if the insert fails after sampling, they are
+// counted by the failed spans metric and lost.
+func (s *Sampler) InsertTraces(c *clickhouse.Client, ctx context.Context, traces []*typespb.Trace) (int, error) {
+	if s == nil {
+		return clickhouse.InsertTraces(c, ctx, traces)
+	}
+
+	sampled := make([]*typespb.Trace, 0, len(traces))
+	for _, trace := range traces {
+		if data := s.sampleHead(trace.GetData()); data != nil {
+			sampled = append(sampled, &typespb.Trace{Data: data})
+		}
+	}
+	if len(sampled) == 0 {
+		return 0, nil
+	}
+
+	if !s.opts.Tail.Enabled {
+		s.forwardTraces(sampled)
+		return clickhouse.InsertTraces(c, ctx, sampled)
+	}
+
+	late, buffered := s.buffer(sampled)
+	if len(late) == 0 {
+		return buffered, nil
+	}
+	s.forwardTraces(late)
+	n, err := clickhouse.InsertTraces(c, ctx, late)
+	return n + buffered, err
+}
+
+// Run samples the buffered traces whose window has elapsed, or the oldest ones
+// if too many are buffered, until the context is cancelled, after which all
+// remaining traces are sampled. It is the only place buffered traces are
+// inserted from.
+func (s *Sampler) Run(ctx context.Context) error {
+	if !s.opts.Tail.Enabled {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	interval := s.opts.Tail.Window / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.flush(context.Background(), s.expired(time.Time{}))
+			return ctx.Err()
+		case now := <-ticker.C:
+			s.flush(ctx, s.expired(now))
+		case <-s.overflow:
+			s.flush(ctx, s.expired(time.Now()))
+		}
+	}
+}
+
+// sampleHead returns the spans that are kept by head sampling, or nil if
+// none are.
+func (s *Sampler) sampleHead(data *otlp_tracepb.TracesData) *otlp_tracepb.TracesData {
+	if s.opts.Head.Rate >= 1 && len(s.opts.Head.Rules) == 0 {
+		return data
+	}
+
+	var result []*otlp_tracepb.ResourceSpans
+	for _, rs := range data.GetResourceSpans() {
+		service := serviceName(rs)
+
+		var scopeSpans []*otlp_tracepb.ScopeSpans
+		for _, ss := range rs.GetScopeSpans() {
+			var spans []*otlp_tracepb.Span
+			for _, span := range ss.GetSpans() {
+				if keep(span.GetTraceId(), s.headRate(service, span.GetName())) {
+					spans = append(spans, span)
+					s.metrics.observe(StageHead, DecisionKept, 1)
+				} else {
+					s.metrics.observe(StageHead, DecisionDropped, 1)
+				}
+			}
+			if len(spans) > 0 {
+				scopeSpans = append(scopeSpans, &otlp_tracepb.ScopeSpans{
+					Scope:     ss.GetScope(),
+					Spans:     spans,
+					SchemaUrl: ss.GetSchemaUrl(),
+				})
+			}
+		}
+		if len(scopeSpans) > 0 {
+			result = append(result, &otlp_tracepb.ResourceSpans{
+				Resource:   rs.GetResource(),
+				ScopeSpans: scopeSpans,
+				SchemaUrl:  rs.GetSchemaUrl(),
+			})
+		}
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+	return &otlp_tracepb.TracesData{ResourceSpans: result}
+}
+
+func (s *Sampler) headRate(service string, spanName string) float64 {
+	for _, r := range s.opts.Head.Rules {
+		if (r.Service == "" || r.Service == service) && (r.SpanName == "" || r.SpanName == spanName) {
+			return r.Rate
+		}
+	}
+	return s.opts.Head.Rate
+}
+
+// buffer adds the spans to the buffered traces and returns the number of
+// buffered spans. Spans of traces that have already been sampled are not
+// buffered but get the remembered decision, the kept ones are returned.
+func (s *Sampler) buffer(traces []*typespb.Trace) ([]*typespb.Trace, int) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		late     []*typespb.Trace
+		buffered int
+	)
+	for _, trace := range traces {
+		for traceId, rs := range splitByTraceId(trace.GetData()) {
+			if d, ok := s.decisions[traceId]; ok && now.Before(d.expires) {
+				if d.kept {
+					late = append(late, &typespb.Trace{Data: &otlp_tracepb.TracesData{ResourceSpans: rs}})
+					s.metrics.observe(StageTail, DecisionKept, float64(countSpans(rs)))
+				} else {
+					s.metrics.observe(StageTail, DecisionDropped, float64(countSpans(rs)))
+				}
+				continue
+			}
+
+			bt, ok := s.traces[traceId]
+			if !ok {
+				bt = &bufferedTrace{received: now}
+				s.traces[traceId] = bt
+				s.order = append(s.order, traceId)
+			}
+			bt.spans = append(bt.spans, rs...)
+			buffered += countSpans(rs)
+		}
+	}
+
+	if limit := s.opts.Tail.MaxTraces; limit > 0 && len(s.order) > limit {
+		// have the worker sample the oldest traces early
+		select {
+		case s.overflow <- struct{}{}:
+		default:
+		}
+	}
+
+	return late, buffered
+}
+
+// expired removes and returns the traces that have been buffered for longer
+// than the window or exceed the maximum number of traces, or all if now is
+// zero. Decisions that are no longer remembered are removed as well.
+func (s *Sampler) expired(now time.Time) map[string]*bufferedTrace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for traceId, d := range s.decisions {
+		if !now.Before(d.expires) {
+			delete(s.decisions, traceId)
+		}
+	}
+
+	var n int
+	if limit := s.opts.Tail.MaxTraces; limit > 0 && len(s.order) > limit {
+		n = len(s.order) - limit
+	}
+	for n < len(s.order) {
+		bt := s.traces[s.order[n]]
+		if !now.IsZero() && now.Sub(bt.received) < s.opts.Tail.Window {
+			break
+		}
+		n++
+	}
+
+	ids := s.order[:n]
+	s.order = s.order[n:]
+	return s.takeLocked(ids)
+}
+
+func (s *Sampler) takeLocked(ids []string) map[string]*bufferedTrace {
+	traces := make(map[string]*bufferedTrace, len(ids))
+	for _, id := range ids {
+		if bt, ok := s.traces[id]; ok {
+			traces[id] = bt
+			delete(s.traces, id)
+		}
+	}
+	return traces
+}
+
+// flush applies the tail sampling rules to the traces, remembers the
+// decisions and inserts the kept ones.
+func (s *Sampler) flush(ctx context.Context, traces map[string]*bufferedTrace) {
+	if len(traces) == 0 {
+		return
+	}
+
+	var (
+		kept      []*typespb.Trace
+		keptSpans int
+	)
+	decisions := make(map[string]bool, len(traces))
+	for traceId, bt := range traces {
+		n := countSpans(bt.spans)
+		decisions[traceId] = s.sampleTail([]byte(traceId), bt.spans)
+		if decisions[traceId] {
+			kept = append(kept, &typespb.Trace{Data: &otlp_tracepb.TracesData{ResourceSpans: bt.spans}})
+			keptSpans += n
+			s.metrics.observe(StageTail, DecisionKept, float64(n))
+		} else {
+			s.metrics.observe(StageTail, DecisionDropped, float64(n))
+		}
+	}
+	s.remember(decisions)
+	if len(kept) == 0 {
+		return
+	}
+
+	s.forwardTraces(kept)
+	if _, err := clickhouse.InsertTraces(s.client, ctx, kept); err != nil {
+		s.metrics.observeFailed(keptSpans)
+		slog.Error("Failed to insert sampled traces", "traces", len(kept), "spans", keptSpans, "error", err)
+	}
+}
+
+// remember stores the decisions of sampled traces for the configured time.
+func (s *Sampler) remember(decisions map[string]bool) {
+	if s.opts.Tail.DecisionTTL <= 0 {
+		return
+	}
+	expires := time.Now().Add(s.opts.Tail.DecisionTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for traceId, kept := range decisions {
+		s.decisions[traceId] = decision{kept: kept, expires: expires}
+	}
+}
+
+func (s *Sampler) forwardTraces(traces []*typespb.Trace) {
+	if s.forward != nil {
+		s.forward(traces)
+	}
+}
+
+// sampleTail reports whether the trace is kept.
+func (s *Sampler) sampleTail(traceId []byte, spans []*otlp_tracepb.ResourceSpans) bool {
+	var (
+		start, end uint64
+		hasError   bool
+	)
+	for _, rs := range spans {
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				if span.GetStatus().GetCode() == otlp_tracepb.Status_STATUS_CODE_ERROR {
+					hasError = true
+				}
+				if start == 0 || span.GetStartTimeUnixNano() < start {
+					start = span.GetStartTimeUnixNano()
+				}
+				if span.GetEndTimeUnixNano() > end {
+					end = span.GetEndTimeUnixNano()
+				}
+			}
+		}
+	}
+
+	if s.opts.Tail.KeepErrors && hasError {
+		return true
+	}
+	if d := s.opts.Tail.KeepSlowerThan; d > 0 && end > start && time.Duration(end-start) >= d {
+		return true
+	}
+	return keep(traceId, s.opts.Tail.Rate)
+}
+
+// keep decides deterministically by trace id whether to keep a trace with
+// the given probability, so that all spans of a trace get the same decision.
+func keep(traceId []byte, rate float64) bool {
+	if rate >= 1 {
+		return true
+	} else if rate <= 0 {
+		return false
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(traceId)
+	return float64(h.Sum64()) < rate*math.MaxUint64
+}
+
+// splitByTraceId returns the resource spans of each trace.
+func splitByTraceId(data *otlp_tracepb.TracesData) map[string][]*otlp_tracepb.ResourceSpans {
+	result := map[string][]*otlp_tracepb.ResourceSpans{}
+	for _, rs := range data.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			spans := map[string][]*otlp_tracepb.Span{}
+			var order []string
+			for _, span := range ss.GetSpans() {
+				id := string(span.GetTraceId())
+				if _, ok := spans[id]; !ok {
+					order = append(order, id)
+				}
+				spans[id] = append(spans[id], span)
+			}
+			for _, id := range order {
+				result[id] = append(result[id], &otlp_tracepb.ResourceSpans{
+					Resource: rs.GetResource(),
+					ScopeSpans: []*otlp_tracepb.ScopeSpans{
+						{Scope: ss.GetScope(), Spans: spans[id], SchemaUrl: ss.GetSchemaUrl()},
+					},
+					SchemaUrl: rs.GetSchemaUrl(),
+				})
+			}
+		}
+	}
+	return result
+}
+
+func countSpans(resourceSpans []*otlp_tracepb.ResourceSpans) int {
+	var n int
+	for _, rs := range resourceSpans {
+		for _, ss := range rs.GetScopeSpans() {
+			n += len(ss.GetSpans())
+		}
+	}
+	return n
+}
+
+func serviceName(rs *otlp_tracepb.ResourceSpans) string {
+	for _, attr := range rs.GetResource().GetAttributes() {
+		if attr.GetKey() == "service.name" {
+			return attr.GetValue().GetStringValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,311 @@
+package sampling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	otlp_comonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	otlp_resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	otlp_tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+)
+
+func testSpan(traceId byte, spanId byte, name string, duration time.Duration, code otlp_tracepb.Status_StatusCode) *otlp_tracepb.Span {
+	start := uint64(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	return &otlp_tracepb.Span{
+		TraceId:           []byte{traceId, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+		SpanId:            []byte{spanId, 0, 0, 0, 0, 0, 0, 1},
+		Name:              name,
+		StartTimeUnixNano: start,
+		EndTimeUnixNano:   start + uint64(duration),
+		Status:            &otlp_tracepb.Status{Code: code},
+	}
+}
+
+func testResourceSpans(service string, spans ...*otlp_tracepb.Span) *otlp_tracepb.ResourceSpans {
+	return &otlp_tracepb.ResourceSpans{
+		Resource: &otlp_resourcepb.Resource{
+			Attributes: []*otlp_comonpb.KeyValue{
+				{Key: "service.name", Value: &otlp_comonpb.AnyValue{Value: &otlp_comonpb.AnyValue_StringValue{StringValue: service}}},
+			},
+		},
+		ScopeSpans: []*otlp_tracepb.ScopeSpans{
+			{Scope: &otlp_comonpb.InstrumentationScope{Name: "test"}, Spans: spans},
+		},
+	}
+}
+
+func spanNames(data *otlp_tracepb.TracesData) []string {
+	var names []string
+	for _, rs := range data.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				names = append(names, span.GetName())
+			}
+		}
+	}
+	return names
+}
+
+func TestKeep(t *testing.T) {
+	traceId := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+	if !keep(traceId, 1) {
+		t.Error("expected trace to be kept with rate 1")
+	}
+	if keep(traceId, 0) {
+		t.Error("expected trace to be dropped with rate 0")
+	}
+	if keep(traceId, 0.5) != keep(traceId, 0.5) {
+		t.Error("expected decision to be deterministic")
+	}
+
+	var kept int
+	for i := 0; i < 1000; i++ {
+		if keep([]byte{byte(i), byte(i >> 8), 0, 1}, 0.25) {
+			kept++
+		}
+	}
+	if kept < 150 || kept > 350 {
+		t.Errorf("expected about 250 of 1000 traces to be kept, got %d", kept)
+	}
+}
+
+func TestSampleHead(t *testing.T) {
+	s := NewSampler(nil, Options{
+		Head: HeadOptions{
+			Rate: 1,
+			Rules: []HeadRule{
+				{Service: "noisy", Rate: 0},
+				{SpanName: "health", Rate: 0},
+			},
+		},
+	})
+
+	data := &otlp_tracepb.TracesData{
+		ResourceSpans: []*otlp_tracepb.ResourceSpans{
+			testResourceSpans("noisy",
+				testSpan(1, 1, "request", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+			),
+			testResourceSpans("app",
+				testSpan(2, 1, "request", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+				testSpan(2, 2, "health", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+			),
+		},
+	}
+
+	got := spanNames(s.sampleHead(data))
+	if len(got) != 1 || got[0] != "request" {
+		t.Errorf("expected only the app request span to be kept, got %v", got)
+	}
+
+	dropped := &otlp_tracepb.TracesData{
+		ResourceSpans: []*otlp_tracepb.ResourceSpans{
+			testResourceSpans("noisy", testSpan(1, 1, "request", time.Second, otlp_tracepb.Status_STATUS_CODE_OK)),
+		},
+	}
+	if got := s.sampleHead(dropped); got != nil {
+		t.Errorf("expected nil if all spans are dropped, got %v", got)
+	}
+}
+
+func TestSampleTail(t *testing.T) {
+	s := NewSampler(nil, Options{
+		Tail: TailOptions{
+			Enabled:        true,
+			KeepErrors:     true,
+			KeepSlowerThan: time.Minute,
+			Rate:           0,
+		},
+	})
+
+	tests := []struct {
+		name  string
+		spans []*otlp_tracepb.Span
+		want  bool
+	}{
+		{
+			name: "fast successful",
+			spans: []*otlp_tracepb.Span{
+				testSpan(1, 1, "root", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+				testSpan(1, 2, "child", time.Second, otlp_tracepb.Status_STATUS_CODE_UNSET),
+			},
+			want: false,
+		},
+		{
+			name: "failed",
+			spans: []*otlp_tracepb.Span{
+				testSpan(1, 1, "root", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+				testSpan(1, 2, "child", time.Second, otlp_tracepb.Status_STATUS_CODE_ERROR),
+			},
+			want: true,
+		},
+		{
+			name: "slow",
+			spans: []*otlp_tracepb.Span{
+				testSpan(1, 1, "root", 2*time.Minute, otlp_tracepb.Status_STATUS_CODE_OK),
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := []*otlp_tracepb.ResourceSpans{testResourceSpans("app", tt.spans...)}
+			if got := s.sampleTail(tt.spans[0].TraceId, spans); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	s := NewSampler(nil, Options{
+		Tail: TailOptions{
+			Enabled: true,
+			Window:  time.Minute,
+		},
+	})
+
+	traces := []*typespb.Trace{
+		{Data: &otlp_tracepb.TracesData{
+			ResourceSpans: []*otlp_tracepb.ResourceSpans{
+				testResourceSpans("app",
+					testSpan(1, 1, "a", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+					testSpan(2, 1, "b", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+				),
+			},
+		}},
+		{Data: &otlp_tracepb.TracesData{
+			ResourceSpans: []*otlp_tracepb.ResourceSpans{
+				testResourceSpans("app",
+					testSpan(1, 2, "c", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+				),
+			},
+		}},
+	}
+
+	late, buffered := s.buffer(traces)
+	if len(late) != 0 {
+		t.Errorf("expected all spans to be buffered, got %d late traces", len(late))
+	}
+	if buffered != 3 {
+		t.Errorf("expected 3 buffered spans, got %d", buffered)
+	}
+	if len(s.traces) != 2 {
+		t.Fatalf("expected 2 buffered traces, got %d", len(s.traces))
+	}
+
+	if expired := s.expired(time.Now()); len(expired) != 0 {
+		t.Errorf("expected no expired traces, got %d", len(expired))
+	}
+
+	expired := s.expired(time.Now().Add(time.Minute))
+	if len(expired) != 2 {
+		t.Fatalf("expected 2 expired traces, got %d", len(expired))
+	}
+	for _, bt := range expired {
+		n := countSpans(bt.spans)
+		if n != 1 && n != 2 {
+			t.Errorf("unexpected number of spans in trace: %d", n)
+		}
+	}
+	if len(s.traces) != 0 || len(s.order) != 0 {
+		t.Errorf("expected buffer to be empty")
+	}
+}
+
+func TestBuffer_Overflow(t *testing.T) {
+	s := NewSampler(nil, Options{
+		Tail: TailOptions{
+			Enabled:   true,
+			Window:    time.Minute,
+			MaxTraces: 1,
+		},
+	})
+
+	s.buffer([]*typespb.Trace{{Data: &otlp_tracepb.TracesData{
+		ResourceSpans: []*otlp_tracepb.ResourceSpans{
+			testResourceSpans("app",
+				testSpan(1, 1, "a", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+				testSpan(2, 2, "b", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+			),
+		},
+	}}})
+
+	select {
+	case <-s.overflow:
+	default:
+		t.Fatal("expected the worker to be signalled")
+	}
+	if expired := s.expired(time.Now()); len(expired) != 1 {
+		t.Errorf("expected 1 trace to be sampled early, got %d", len(expired))
+	}
+	if len(s.traces) != 1 {
+		t.Errorf("expected 1 buffered trace, got %d", len(s.traces))
+	}
+}
+
+func TestBuffer_Decisions(t *testing.T) {
+	s := NewSampler(nil, Options{
+		Tail: TailOptions{
+			Enabled:     true,
+			Window:      time.Minute,
+			DecisionTTL: time.Minute,
+		},
+	})
+
+	kept, dropped := testSpan(1, 1, "a", time.Second, otlp_tracepb.Status_STATUS_CODE_OK), testSpan(2, 2, "b", time.Second, otlp_tracepb.Status_STATUS_CODE_OK)
+	s.remember(map[string]bool{
+		string(kept.TraceId):    true,
+		string(dropped.TraceId): false,
+	})
+
+	late, buffered := s.buffer([]*typespb.Trace{{Data: &otlp_tracepb.TracesData{
+		ResourceSpans: []*otlp_tracepb.ResourceSpans{
+			testResourceSpans("app", kept, dropped, testSpan(3, 3, "c", time.Second, otlp_tracepb.Status_STATUS_CODE_OK)),
+		},
+	}}})
+	if len(late) != 1 || countSpans(late[0].Data.ResourceSpans) != 1 || late[0].Data.ResourceSpans[0].ScopeSpans[0].Spans[0] != kept {
+		t.Errorf("expected only the span of the kept trace to be inserted right away")
+	}
+	if len(s.traces) != 1 || buffered != 1 {
+		t.Errorf("expected only the unsampled trace to be buffered, got %d", len(s.traces))
+	}
+
+	s.expired(time.Now().Add(time.Minute))
+	if len(s.decisions) != 0 {
+		t.Errorf("expected decisions to expire, got %d", len(s.decisions))
+	}
+}
+
+func TestInsertTraces_Buffered(t *testing.T) {
+	s := NewSampler(nil, Options{
+		Head: HeadOptions{Rate: 1},
+		Tail: TailOptions{
+			Enabled: true,
+			Window:  time.Minute,
+		},
+	})
+
+	// buffered spans are reported as accepted, without being inserted yet
+	n, err := s.InsertTraces(nil, context.Background(), []*typespb.Trace{{Data: &otlp_tracepb.TracesData{
+		ResourceSpans: []*otlp_tracepb.ResourceSpans{
+			testResourceSpans("app",
+				testSpan(1, 1, "a", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+				testSpan(1, 2, "b", time.Second, otlp_tracepb.Status_STATUS_CODE_OK),
+			),
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 accepted spans, got %d", n)
+	}
+	if len(s.traces) != 1 {
+		t.Errorf("expected 1 buffered trace, got %d", len(s.traces))
+	}
+}
@@ -0,0 +1,181 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+)
+
+const (
+	// RuleRequiredReference is violated by a missing reference to a parent
+	// entity, e.g. the pipeline of a job.
+	RuleRequiredReference string = "required_reference"
+	// RuleNonZeroId is violated by a zero or empty id of the record or of a
+	// referenced entity.
+	RuleNonZeroId string = "non_zero_id"
+	// RuleRequiredTimestamp is violated by a missing or zero timestamp that
+	// every record has, e.g. the creation time.
+	RuleRequiredTimestamp string = "required_timestamp"
+	// RuleTimestampRange is violated by a timestamp before minTime or too
+	// far in the future. Optional timestamps are fixed by unsetting them.
+	RuleTimestampRange string = "timestamp_range"
+	// RuleTimestampOrder is violated by a finish time before the start
+	// time. It's fixed by setting the finish time to the start time.
+	RuleTimestampOrder string = "timestamp_order"
+)
+
+// Violation is a rule violated by a field of a record.
+type Violation struct {
+	Rule  string
+	Field string
+	// whether the violation was fixed up
+	Fixed bool
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s(%s)", v.Rule, v.Field)
+}
+
+// checker collects the violations of a record, and fixes them up if enabled
+// and possible.
+type checker struct {
+	fix bool
+
+	// the range of valid timestamps
+	minTime time.Time
+	maxTime time.Time
+
+	violations []Violation
+}
+
+func (c *checker) add(rule string, field string, fixed bool) {
+	c.violations = append(c.violations, Violation{Rule: rule, Field: field, Fixed: fixed})
+}
+
+// fixable returns whether all violations have been fixed.
+func (c *checker) fixable() bool {
+	for _, v := range c.violations {
+		if !v.Fixed {
+			return false
+		}
+	}
+	return true
+}
+
+// ref checks that a reference is set and returns whether it is.
+func (c *checker) ref(field string, set bool) bool {
+	if !set {
+		c.add(RuleRequiredReference, field, false)
+	}
+	return set
+}
+
+func (c *checker) id(field string, nonZero bool) {
+	if !nonZero {
+		c.add(RuleNonZeroId, field, false)
+	}
+}
+
+// timestamp checks that the timestamp is in range, and set if required.
+// Optional timestamps out of range are unset when fixing up.
+func (c *checker) timestamp(field string, ts **timestamppb.Timestamp, required bool) {
+	if isZero(*ts) {
+		if required {
+			c.add(RuleRequiredTimestamp, field, false)
+		}
+		return
+	}
+
+	t := (*ts).AsTime()
+	if t.Before(c.minTime) || t.After(c.maxTime) {
+		fixed := c.fix && !required
+		if fixed {
+			*ts = nil
+		}
+		c.add(RuleTimestampRange, field, fixed)
+	}
+}
+
+// order checks that the end timestamp isn't before the start timestamp, if
+// both are set. The end is set to the start when fixing up.
+func (c *checker) order(startField string, start *timestamppb.Timestamp, endField string, end **timestamppb.Timestamp) {
+	if isZero(start) || isZero(*end) {
+		return
+	}
+
+	if (*end).AsTime().Before(start.AsTime()) {
+		if c.fix {
+			*end = &timestamppb.Timestamp{Seconds: start.GetSeconds(), Nanos: start.GetNanos()}
+		}
+		c.add(RuleTimestampOrder, endField, c.fix)
+	}
+}
+
+func isZero(ts *timestamppb.Timestamp) bool {
+	return ts.GetSeconds() == 0 && ts.GetNanos() == 0
+}
+
+func (c *checker) projectRef(field string, p *typespb.ProjectReference) {
+	if c.ref(field, p != nil) {
+		c.id(field+".id", p.GetId() != 0)
+	}
+}
+
+func (c *checker) pipelineRef(field string, p *typespb.PipelineReference) {
+	if c.ref(field, p != nil) {
+		c.id(field+".id", p.GetId() != 0)
+		c.projectRef(field+".project", p.GetProject())
+	}
+}
+
+func (c *checker) jobRef(field string, j *typespb.JobReference) {
+	if c.ref(field, j != nil) {
+		c.id(field+".id", j.GetId() != 0)
+		c.pipelineRef(field+".pipeline", j.GetPipeline())
+	}
+}
+
+func (c *checker) mergeRequestRef(field string, mr *typespb.MergeRequestReference) {
+	if c.ref(field, mr != nil) {
+		c.id(field+".id", mr.GetId() != 0)
+		c.projectRef(field+".project", mr.GetProject())
+	}
+}
+
+func (c *checker) testReportRef(field string, r *typespb.TestReportReference) {
+	if c.ref(field, r != nil) {
+		c.id(field+".id", r.GetId() != "")
+		c.jobRef(field+".job", r.GetJob())
+	}
+}
+
+func (c *checker) testSuiteRef(field string, s *typespb.TestSuiteReference) {
+	if c.ref(field, s != nil) {
+		c.id(field+".id", s.GetId() != "")
+		c.testReportRef(field+".test_report", s.GetTestReport())
+	}
+}
+
+func (c *checker) coverageReportRef(field string, r *typespb.CoverageReportReference) {
+	if c.ref(field, r != nil) {
+		c.id(field+".id", r.GetId() != "")
+		c.jobRef(field+".job", r.GetJob())
+	}
+}
+
+func (c *checker) coveragePackageRef(field string, p *typespb.CoveragePackageReference) {
+	if c.ref(field, p != nil) {
+		c.id(field+".id", p.GetId() != "")
+		c.coverageReportRef(field+".report", p.GetReport())
+	}
+}
+
+func (c *checker) coverageClassRef(field string, cls *typespb.CoverageClassReference) {
+	if c.ref(field, cls != nil) {
+		c.id(field+".id", cls.GetId() != "")
+		c.coveragePackageRef(field+".package", cls.GetPackage())
+	}
+}
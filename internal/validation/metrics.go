@@ -0,0 +1,39 @@
+package validation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/promutil"
+)
+
+const (
+	actionRejected string = "rejected"
+	actionWarned   string = "warned"
+	actionFixed    string = "fixed"
+)
+
+type metrics struct {
+	promutil.Collectors
+
+	violations *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		violations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "validation",
+				Name:      "violations_total",
+				Help:      "Total number of rule violations by table, rule and action taken.",
+			},
+			[]string{"table", "rule", "action"},
+		),
+	}
+	m.Collectors = promutil.Collectors{m.violations}
+	return m
+}
+
+func (m *metrics) observeViolation(table string, rule string, action string) {
+	m.violations.WithLabelValues(table, rule, action).Inc()
+}
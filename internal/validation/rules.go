@@ -0,0 +1,166 @@
+package validation
+
+import (
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+)
+
+// check checks the record against the rules of its entity.
+func check(c *checker, record any) {
+	switch r := record.(type) {
+	case *typespb.Pipeline:
+		checkPipeline(c, r)
+	case *typespb.Job:
+		checkJob(c, r)
+	case *typespb.Section:
+		checkSection(c, r)
+	case *typespb.TestReport:
+		c.id("id", r.GetId() != "")
+		c.jobRef("job", r.GetJob())
+	case *typespb.TestSuite:
+		c.id("id", r.GetId() != "")
+		c.testReportRef("test_report", r.GetTestReport())
+	case *typespb.TestCase:
+		c.id("id", r.GetId() != "")
+		c.testSuiteRef("test_suite", r.GetTestSuite())
+	case *typespb.MergeRequest:
+		checkMergeRequest(c, r)
+	case *typespb.MergeRequestNoteEvent:
+		checkMergeRequestNoteEvent(c, r)
+	case *typespb.Project:
+		checkProject(c, r)
+	case *typespb.CoverageReport:
+		c.id("id", r.GetId() != "")
+		c.jobRef("job", r.GetJob())
+		c.timestamp("timestamp", &r.Timestamp, false)
+	case *typespb.CoveragePackage:
+		c.id("id", r.GetId() != "")
+		c.coverageReportRef("report", r.GetReport())
+	case *typespb.CoverageClass:
+		c.id("id", r.GetId() != "")
+		c.coveragePackageRef("package", r.GetPackage())
+	case *typespb.CoverageMethod:
+		c.id("id", r.GetId() != "")
+		c.coverageClassRef("class", r.GetClass())
+	case *typespb.Deployment:
+		checkDeployment(c, r)
+	case *typespb.Issue:
+		checkIssue(c, r)
+	case *typespb.Metric:
+		c.id("id", len(r.GetId()) > 0)
+		c.jobRef("job", r.GetJob())
+		c.timestamp("timestamp", &r.Timestamp, true)
+	case *typespb.Trace:
+		c.ref("data", r.GetData() != nil)
+	}
+}
+
+func checkPipeline(c *checker, p *typespb.Pipeline) {
+	c.id("id", p.GetId() != 0)
+	c.projectRef("project", p.GetProject())
+
+	ts := p.GetTimestamps()
+	if ts == nil {
+		c.add(RuleRequiredTimestamp, "timestamps.created_at", false)
+		return
+	}
+	c.timestamp("timestamps.created_at", &ts.CreatedAt, true)
+	c.timestamp("timestamps.updated_at", &ts.UpdatedAt, false)
+	c.timestamp("timestamps.started_at", &ts.StartedAt, false)
+	c.timestamp("timestamps.finished_at", &ts.FinishedAt, false)
+	c.order("timestamps.started_at", ts.StartedAt, "timestamps.finished_at", &ts.FinishedAt)
+}
+
+func checkJob(c *checker, j *typespb.Job) {
+	c.id("id", j.GetId() != 0)
+	c.pipelineRef("pipeline", j.GetPipeline())
+
+	ts := j.GetTimestamps()
+	if ts == nil {
+		c.add(RuleRequiredTimestamp, "timestamps.created_at", false)
+		return
+	}
+	c.timestamp("timestamps.created_at", &ts.CreatedAt, true)
+	c.timestamp("timestamps.queued_at", &ts.QueuedAt, false)
+	c.timestamp("timestamps.started_at", &ts.StartedAt, false)
+	c.timestamp("timestamps.finished_at", &ts.FinishedAt, false)
+	c.timestamp("timestamps.erased_at", &ts.ErasedAt, false)
+	c.order("timestamps.started_at", ts.StartedAt, "timestamps.finished_at", &ts.FinishedAt)
+}
+
+func checkSection(c *checker, s *typespb.Section) {
+	c.id("id", s.GetId() != 0)
+	c.jobRef("job", s.GetJob())
+
+	c.timestamp("started_at", &s.StartedAt, false)
+	c.timestamp("finished_at", &s.FinishedAt, false)
+	c.order("started_at", s.StartedAt, "finished_at", &s.FinishedAt)
+}
+
+func checkMergeRequest(c *checker, mr *typespb.MergeRequest) {
+	c.id("id", mr.GetId() != 0)
+	c.projectRef("project", mr.GetProject())
+
+	ts := mr.GetTimestamps()
+	if ts == nil {
+		c.add(RuleRequiredTimestamp, "timestamps.created_at", false)
+		return
+	}
+	c.timestamp("timestamps.created_at", &ts.CreatedAt, true)
+	c.timestamp("timestamps.updated_at", &ts.UpdatedAt, false)
+	c.timestamp("timestamps.merged_at", &ts.MergedAt, false)
+	c.timestamp("timestamps.closed_at", &ts.ClosedAt, false)
+}
+
+func checkMergeRequestNoteEvent(c *checker, e *typespb.MergeRequestNoteEvent) {
+	c.id("id", e.GetId() != 0)
+	c.mergeRequestRef("merge_request", e.GetMergeRequest())
+
+	c.timestamp("created_at", &e.CreatedAt, true)
+	c.timestamp("updated_at", &e.UpdatedAt, false)
+	c.timestamp("resolved_at", &e.ResolvedAt, false)
+}
+
+func checkProject(c *checker, p *typespb.Project) {
+	c.id("id", p.GetId() != 0)
+
+	if ts := p.GetTimestamps(); ts != nil {
+		c.timestamp("timestamps.created_at", &ts.CreatedAt, false)
+		c.timestamp("timestamps.updated_at", &ts.UpdatedAt, false)
+		c.timestamp("timestamps.last_activity_at", &ts.LastActivityAt, false)
+	}
+}
+
+func checkDeployment(c *checker, d *typespb.Deployment) {
+	c.id("id", d.GetId() != 0)
+	if env := d.GetEnvironment(); c.ref("environment", env != nil) {
+		c.projectRef("environment.project", env.GetProject())
+	}
+	// deployments triggered by the API have no job
+	if d.GetJob() != nil {
+		c.jobRef("job", d.GetJob())
+	}
+
+	ts := d.GetTimestamps()
+	if ts == nil {
+		c.add(RuleRequiredTimestamp, "timestamps.created_at", false)
+		return
+	}
+	c.timestamp("timestamps.created_at", &ts.CreatedAt, true)
+	c.timestamp("timestamps.updated_at", &ts.UpdatedAt, false)
+	c.timestamp("timestamps.finished_at", &ts.FinishedAt, false)
+	c.order("timestamps.created_at", ts.CreatedAt, "timestamps.finished_at", &ts.FinishedAt)
+}
+
+func checkIssue(c *checker, i *typespb.Issue) {
+	c.id("id", i.GetId() != 0)
+	c.projectRef("project", i.GetProject())
+
+	ts := i.GetTimestamps()
+	if ts == nil {
+		c.add(RuleRequiredTimestamp, "timestamps.created_at", false)
+		return
+	}
+	c.timestamp("timestamps.created_at", &ts.CreatedAt, true)
+	c.timestamp("timestamps.updated_at", &ts.UpdatedAt, false)
+	c.timestamp("timestamps.closed_at", &ts.ClosedAt, false)
+}
@@ -0,0 +1,141 @@
+package validation
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Policy string
+
+const (
+	// PolicyReject drops invalid records.
+	PolicyReject Policy = "reject"
+	// PolicyWarn records invalid records as they are.
+	PolicyWarn Policy = "warn"
+	// PolicyFix fixes up invalid records where possible and drops the
+	// others.
+	PolicyFix Policy = "fix"
+)
+
+var (
+	// timestamps before are considered unset or bogus
+	minTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	// how far timestamps may be in the future, to allow for clock skew
+	maxClockSkew = 24 * time.Hour
+)
+
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyReject, PolicyWarn, PolicyFix:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid validation policy: %q", s)
+	}
+}
+
+type Options struct {
+	// The policy applied to invalid records
+	Policy Policy
+	// Policies overriding the default by table
+	Policies map[string]Policy
+}
+
+// Result reports the outcome of validating a batch of records.
+type Result struct {
+	// The number of invalid records
+	Invalid int
+	// The number of invalid records that have been dropped
+	Rejected int
+	// The number of violations by rule and field, e.g. `non_zero_id(id)`
+	Violations map[string]int
+}
+
+// Validator checks records against the rules of their entity and applies
+// the policy of their table to invalid ones.
+type Validator struct {
+	opts    Options
+	metrics *metrics
+
+	now func() time.Time
+}
+
+func New(opts Options) *Validator {
+	return &Validator{
+		opts:    opts,
+		metrics: newMetrics(),
+		now:     time.Now,
+	}
+}
+
+func (v *Validator) MetricsCollector() prometheus.Collector {
+	return v.metrics
+}
+
+func (v *Validator) policy(table string) Policy {
+	if p, ok := v.opts.Policies[table]; ok {
+		return p
+	}
+	return v.opts.Policy
+}
+
+// Validate checks the records to be inserted into the table and returns the
+// ones to keep. Records are fixed up in place if the table's policy says so.
+// It's a no-op on a nil validator.
+func Validate[T any](v *Validator, table string, records []*T) ([]*T, Result) {
+	result := Result{Violations: map[string]int{}}
+	if v == nil {
+		return records, result
+	}
+
+	policy := v.policy(table)
+	now := v.now()
+
+	kept := records[:0:0]
+	for _, r := range records {
+		c := checker{
+			fix:     policy == PolicyFix,
+			minTime: minTime,
+			maxTime: now.Add(maxClockSkew),
+		}
+		check(&c, r)
+		if len(c.violations) == 0 {
+			kept = append(kept, r)
+			continue
+		}
+
+		result.Invalid++
+		reject := policy == PolicyReject || (policy == PolicyFix && !c.fixable())
+		if reject {
+			result.Rejected++
+		} else {
+			kept = append(kept, r)
+		}
+
+		for _, violation := range c.violations {
+			result.Violations[violation.String()]++
+
+			action := actionWarned
+			if reject {
+				action = actionRejected
+			} else if violation.Fixed {
+				action = actionFixed
+			}
+			v.metrics.observeViolation(table, violation.Rule, action)
+		}
+	}
+
+	if result.Invalid > 0 {
+		slog.Warn("Received invalid records",
+			"table", table,
+			"policy", policy,
+			"invalid", result.Invalid,
+			"rejected", result.Rejected,
+			"violations", result.Violations,
+		)
+	}
+
+	return kept, result
+}
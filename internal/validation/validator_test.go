@@ -0,0 +1,129 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+)
+
+var testNow = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func testValidator(policy Policy) *Validator {
+	v := New(Options{Policy: policy})
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func testJob(id int64) *typespb.Job {
+	return &typespb.Job{
+		Id: id,
+		Pipeline: &typespb.PipelineReference{
+			Id:      1,
+			Project: &typespb.ProjectReference{Id: 42},
+		},
+		Timestamps: &typespb.JobTimestamps{
+			CreatedAt:  timestamppb.New(testNow.Add(-time.Hour)),
+			StartedAt:  timestamppb.New(testNow.Add(-30 * time.Minute)),
+			FinishedAt: timestamppb.New(testNow.Add(-20 * time.Minute)),
+		},
+	}
+}
+
+func TestValidate_Valid(t *testing.T) {
+	jobs := []*typespb.Job{testJob(1), testJob(2)}
+
+	kept, result := Validate(testValidator(PolicyReject), "jobs", jobs)
+	if len(kept) != 2 || result.Invalid != 0 {
+		t.Errorf("Expected all jobs to be valid, got %d kept: %+v", len(kept), result)
+	}
+}
+
+func TestValidate_Reject(t *testing.T) {
+	noPipeline := testJob(2)
+	noPipeline.Pipeline = nil
+	noProject := testJob(3)
+	noProject.Pipeline.Project = nil
+	zeroId := testJob(0)
+
+	jobs := []*typespb.Job{testJob(1), noPipeline, noProject, zeroId}
+	kept, result := Validate(testValidator(PolicyReject), "jobs", jobs)
+
+	if len(kept) != 1 || kept[0].Id != 1 {
+		t.Errorf("Expected only job 1 to be kept, got %d", len(kept))
+	}
+	if result.Invalid != 3 || result.Rejected != 3 {
+		t.Errorf("Expected 3 rejected jobs, got %+v", result)
+	}
+	for _, v := range []string{"required_reference(pipeline)", "required_reference(pipeline.project)", "non_zero_id(id)"} {
+		if result.Violations[v] != 1 {
+			t.Errorf("Expected violation %s, got %v", v, result.Violations)
+		}
+	}
+}
+
+func TestValidate_Warn(t *testing.T) {
+	job := testJob(1)
+	job.Pipeline = nil
+
+	kept, result := Validate(testValidator(PolicyWarn), "jobs", []*typespb.Job{job})
+	if len(kept) != 1 || result.Invalid != 1 || result.Rejected != 0 {
+		t.Errorf("Expected invalid job to be kept, got %d kept: %+v", len(kept), result)
+	}
+}
+
+func TestValidate_Fix(t *testing.T) {
+	reversed := testJob(1)
+	reversed.Timestamps.FinishedAt = timestamppb.New(testNow.Add(-40 * time.Minute))
+	future := testJob(2)
+	future.Timestamps.ErasedAt = timestamppb.New(testNow.Add(48 * time.Hour))
+	noCreatedAt := testJob(3)
+	noCreatedAt.Timestamps.CreatedAt = nil
+
+	jobs := []*typespb.Job{reversed, future, noCreatedAt}
+	kept, result := Validate(testValidator(PolicyFix), "jobs", jobs)
+
+	if len(kept) != 2 || result.Invalid != 3 || result.Rejected != 1 {
+		t.Fatalf("Expected 2 fixed jobs, got %d kept: %+v", len(kept), result)
+	}
+	if !reversed.Timestamps.FinishedAt.AsTime().Equal(reversed.Timestamps.StartedAt.AsTime()) {
+		t.Errorf("Expected finished_at to be set to started_at, got %v", reversed.Timestamps.FinishedAt.AsTime())
+	}
+	if future.Timestamps.ErasedAt != nil {
+		t.Errorf("Expected erased_at to be unset, got %v", future.Timestamps.ErasedAt.AsTime())
+	}
+}
+
+func TestValidate_TablePolicy(t *testing.T) {
+	v := New(Options{
+		Policy:   PolicyWarn,
+		Policies: map[string]Policy{"testcases": PolicyReject},
+	})
+
+	cases := []*typespb.TestCase{{Id: "1"}}
+	if kept, _ := Validate(v, "testcases", cases); len(kept) != 0 {
+		t.Errorf("Expected test case without suite to be rejected")
+	}
+	reports := []*typespb.TestReport{{Id: "1"}}
+	if kept, _ := Validate(v, "testreports", reports); len(kept) != 1 {
+		t.Errorf("Expected test report without job to be kept")
+	}
+}
+
+func TestValidate_Nil(t *testing.T) {
+	jobs := []*typespb.Job{{}}
+	if kept, result := Validate[typespb.Job](nil, "jobs", jobs); len(kept) != 1 || result.Invalid != 0 {
+		t.Errorf("Expected nil validator to keep all records")
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	if p, err := ParsePolicy("fix"); err != nil || p != PolicyFix {
+		t.Errorf("Expected fix policy, got %q (%v)", p, err)
+	}
+	if _, err := ParsePolicy("ignore"); err == nil {
+		t.Error("Expected error for invalid policy")
+	}
+}
@@ -0,0 +1,403 @@
+package webhook
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/recorder"
+)
+
+// statuses of jobs that won't change anymore
+var finishedJobStatuses = []string{"success", "failed", "canceled", "skipped"}
+
+func convertPipeline(h *pipelineHook) *typespb.Pipeline {
+	attrs := h.ObjectAttributes
+
+	// Pipeline hooks don't include the update time, use the latest known
+	// event instead, so that data recorded by the exporter takes precedence.
+	updatedAt := attrs.CreatedAt
+	if attrs.FinishedAt.After(updatedAt.Time) {
+		updatedAt = attrs.FinishedAt
+	}
+
+	pipeline := &typespb.Pipeline{
+		Id:      attrs.Id,
+		Iid:     attrs.Iid,
+		Project: convertProjectReference(h.Project),
+		Name:    attrs.Name,
+		Ref:     attrs.Ref,
+		RefPath: refPath(attrs.Ref, attrs.Tag),
+		Sha:     attrs.Sha,
+		Source:  attrs.Source,
+		Status:  attrs.Status,
+		Timestamps: &typespb.PipelineTimestamps{
+			CreatedAt:  timestamp(attrs.CreatedAt),
+			UpdatedAt:  partialVersion(updatedAt),
+			FinishedAt: timestamp(attrs.FinishedAt),
+		},
+		QueuedDuration: durationpb.New(seconds(attrs.QueuedDuration)),
+		Duration:       durationpb.New(seconds(attrs.Duration)),
+		Child:          attrs.Source == "parent_pipeline",
+		User:           convertUserReference(h.User),
+	}
+
+	if h.MergeRequest != nil {
+		pipeline.MergeRequest = &typespb.MergeRequestReference{
+			Id:      h.MergeRequest.Id,
+			Iid:     h.MergeRequest.Iid,
+			Project: &typespb.ProjectReference{Id: h.MergeRequest.TargetProjectId},
+		}
+	}
+	if h.SourcePipeline != nil {
+		pipeline.UpstreamPipeline = &typespb.PipelineReference{
+			Id:      h.SourcePipeline.PipelineId,
+			Project: &typespb.ProjectReference{Id: h.SourcePipeline.Project.Id},
+		}
+	}
+
+	return pipeline
+}
+
+// jobFinished reports whether the job's status is final. Jobs are recorded
+// once, so that only finished jobs are converted.
+func jobFinished(h *jobHook) bool {
+	return slices.Contains(finishedJobStatuses, h.BuildStatus)
+}
+
+func convertJob(h *jobHook) *typespb.Job {
+	project := &typespb.ProjectReference{Id: h.ProjectId}
+	if h.Project != nil {
+		project.FullPath = h.Project.PathWithNamespace
+	}
+
+	job := &typespb.Job{
+		Id: h.BuildId,
+		Pipeline: &typespb.PipelineReference{
+			Id:      h.PipelineId,
+			Project: project,
+		},
+		Name:          h.BuildName,
+		Ref:           h.Ref,
+		RefPath:       refPath(h.Ref, h.Tag),
+		Status:        h.BuildStatus,
+		FailureReason: h.BuildFailureReason,
+		Timestamps: &typespb.JobTimestamps{
+			CreatedAt:  timestamp(h.BuildCreatedAt),
+			StartedAt:  timestamp(h.BuildStartedAt),
+			FinishedAt: timestamp(h.BuildFinishedAt),
+		},
+		QueuedDuration: durationpb.New(seconds(h.BuildQueuedDuration)),
+		Duration:       durationpb.New(seconds(h.BuildDuration)),
+		Stage:          h.BuildStage,
+		AllowFailure:   h.BuildAllowFailure,
+		Kind:           typespb.JobKind_JOBKIND_BUILD,
+	}
+
+	if h.Runner != nil {
+		job.Runner = &typespb.RunnerReference{
+			Id:   strconv.FormatInt(h.Runner.Id, 10),
+			Name: h.Runner.Description,
+		}
+	}
+
+	return job
+}
+
+func convertMergeRequest(h *mergeRequestHook) *typespb.MergeRequest {
+	attrs := h.ObjectAttributes
+
+	timestamps := &typespb.MergeRequestTimestamps{
+		CreatedAt: timestamp(attrs.CreatedAt),
+		UpdatedAt: partialVersion(attrs.UpdatedAt),
+	}
+	participants := &typespb.MergeRequestParticipants{
+		Author:    &typespb.UserReference{Id: attrs.AuthorId},
+		Assignees: convertUserReferences(h.Assignees),
+		Reviewers: convertUserReferences(h.Reviewers),
+	}
+	switch attrs.Action {
+	case "merge":
+		timestamps.MergedAt = timestamp(attrs.UpdatedAt)
+		participants.MergeUser = convertUserReference(h.User)
+	case "close":
+		timestamps.ClosedAt = timestamp(attrs.UpdatedAt)
+	}
+	if h.User.Id == attrs.AuthorId {
+		participants.Author = convertUserReference(h.User)
+	}
+
+	mr := &typespb.MergeRequest{
+		Id:              attrs.Id,
+		Iid:             attrs.Iid,
+		Project:         convertProjectReference(h.Project),
+		Timestamps:      timestamps,
+		Title:           attrs.Title,
+		Labels:          convertLabels(attrs.Labels),
+		State:           attrs.State,
+		MergeStatus:     attrs.MergeStatus,
+		SourceProjectId: attrs.SourceProjectId,
+		SourceBranch:    attrs.SourceBranch,
+		TargetProjectId: attrs.TargetProjectId,
+		TargetBranch:    attrs.TargetBranch,
+		DiffRefs: &typespb.MergeRequestDiffRefs{
+			HeadSha:        attrs.LastCommit.Id,
+			MergeCommitSha: attrs.MergeCommitSha,
+		},
+		Participants: participants,
+		Flags: &typespb.MergeRequestFlags{
+			Draft: attrs.Draft || attrs.WorkInProgress,
+		},
+	}
+
+	if attrs.MilestoneId != 0 {
+		mr.Milestone = &typespb.MilestoneReference{Id: attrs.MilestoneId}
+	}
+
+	return mr
+}
+
+// convertMergeRequestNoteEvent converts notes on merge requests, it returns
+// nil for notes on other objects, which aren't recorded.
+func convertMergeRequestNoteEvent(h *noteHook) *typespb.MergeRequestNoteEvent {
+	attrs := h.ObjectAttributes
+	if attrs.NoteableType != "MergeRequest" || h.MergeRequest == nil {
+		return nil
+	}
+
+	author := &typespb.UserReference{Id: attrs.AuthorId}
+	if h.User.Id == attrs.AuthorId {
+		author = convertUserReference(h.User)
+	}
+
+	return &typespb.MergeRequestNoteEvent{
+		Id: attrs.Id,
+		MergeRequest: &typespb.MergeRequestReference{
+			Id:      h.MergeRequest.Id,
+			Iid:     h.MergeRequest.Iid,
+			Project: convertProjectReference(h.Project),
+		},
+		CreatedAt: timestamp(attrs.CreatedAt),
+		UpdatedAt: partialVersion(attrs.UpdatedAt),
+		Type:      attrs.Type,
+		System:    attrs.System,
+		Internal:  attrs.Internal || attrs.Confidential,
+		Author:    author,
+	}
+}
+
+func convertIssue(h *issueHook) *typespb.Issue {
+	attrs := h.ObjectAttributes
+
+	issueType := attrs.Type
+	if issueType == "" {
+		issueType = attrs.IssueType
+	}
+
+	return &typespb.Issue{
+		Id:      attrs.Id,
+		Iid:     attrs.Iid,
+		Project: convertProjectReference(h.Project),
+		Timestamps: &typespb.IssueTimestamps{
+			CreatedAt: timestamp(attrs.CreatedAt),
+			UpdatedAt: partialVersion(attrs.UpdatedAt),
+			ClosedAt:  timestamp(attrs.ClosedAt),
+		},
+		Title:    attrs.Title,
+		Labels:   convertLabels(attrs.Labels),
+		Type:     convertIssueType(issueType),
+		Severity: convertIssueSeverity(attrs.Severity),
+		State:    convertIssueState(attrs.State),
+	}
+}
+
+func convertDeployment(h *deploymentHook) *typespb.Deployment {
+	status := convertDeploymentStatus(h.Status)
+
+	timestamps := &typespb.DeploymentTimestamps{
+		UpdatedAt: partialVersion(h.StatusChangedAt),
+	}
+	switch status {
+	case typespb.DeploymentStatus_DEPLOYMENT_STATUS_SUCCESS,
+		typespb.DeploymentStatus_DEPLOYMENT_STATUS_FAILED,
+		typespb.DeploymentStatus_DEPLOYMENT_STATUS_CANCELED,
+		typespb.DeploymentStatus_DEPLOYMENT_STATUS_SKIPPED:
+		timestamps.FinishedAt = timestamp(h.StatusChangedAt)
+	}
+
+	project := convertProjectReference(h.Project)
+	return &typespb.Deployment{
+		Id: h.DeploymentId,
+		Environment: &typespb.EnvironmentReference{
+			Name:    h.Environment,
+			Tier:    convertDeploymentTier(h.EnvironmentTier),
+			Project: project,
+		},
+		Job: &typespb.JobReference{
+			Id: h.DeployableId,
+		},
+		Triggerer:  convertUserReference(h.User),
+		Timestamps: timestamps,
+		Status:     status,
+		Ref:        h.Ref,
+		Sha:        commitSha(h.CommitUrl),
+	}
+}
+
+// commitSha returns the sha of the commit URL, which deployment hooks only
+// include the full sha in.
+func commitSha(url string) string {
+	i := strings.LastIndex(url, "/-/commit/")
+	if i < 0 {
+		return ""
+	}
+	return url[i+len("/-/commit/"):]
+}
+
+func convertProjectReference(p hookProject) *typespb.ProjectReference {
+	return &typespb.ProjectReference{
+		Id:       p.Id,
+		FullPath: p.PathWithNamespace,
+	}
+}
+
+func convertUserReference(u hookUser) *typespb.UserReference {
+	return &typespb.UserReference{
+		Id:       u.Id,
+		Username: u.Username,
+		Name:     u.Name,
+	}
+}
+
+func convertUserReferences(users []hookUser) []*typespb.UserReference {
+	refs := make([]*typespb.UserReference, 0, len(users))
+	for _, u := range users {
+		refs = append(refs, convertUserReference(u))
+	}
+	return refs
+}
+
+func convertLabels(labels []hookLabel) []string {
+	titles := make([]string, 0, len(labels))
+	for _, l := range labels {
+		titles = append(titles, l.Title)
+	}
+	return titles
+}
+
+func convertIssueType(t string) typespb.IssueType {
+	switch strings.ToLower(t) {
+	case "issue":
+		return typespb.IssueType_ISSUE_TYPE_ISSUE
+	case "incident":
+		return typespb.IssueType_ISSUE_TYPE_INCIDENT
+	case "test_case", "testcase":
+		return typespb.IssueType_ISSUE_TYPE_TEST_CASE
+	case "task":
+		return typespb.IssueType_ISSUE_TYPE_TASK
+	default:
+		return typespb.IssueType_ISSUE_TYPE_UNSPECIFIED
+	}
+}
+
+func convertIssueSeverity(s string) typespb.IssueSeverity {
+	switch strings.ToLower(s) {
+	case "unknown":
+		return typespb.IssueSeverity_ISSUE_SEVERITY_UNKNOWN
+	case "low":
+		return typespb.IssueSeverity_ISSUE_SEVERITY_LOW
+	case "medium":
+		return typespb.IssueSeverity_ISSUE_SEVERITY_MEDIUM
+	case "high":
+		return typespb.IssueSeverity_ISSUE_SEVERITY_HIGH
+	case "critical":
+		return typespb.IssueSeverity_ISSUE_SEVERITY_CRITICAL
+	default:
+		return typespb.IssueSeverity_ISSUE_SEVERITY_UNSPECIFIED
+	}
+}
+
+func convertIssueState(s string) typespb.IssueState {
+	switch s {
+	case "opened":
+		return typespb.IssueState_ISSUE_STATE_OPENED
+	case "closed":
+		return typespb.IssueState_ISSUE_STATE_CLOSED
+	default:
+		return typespb.IssueState_ISSUE_STATE_UNSPECIFIED
+	}
+}
+
+func convertDeploymentStatus(s string) typespb.DeploymentStatus {
+	switch s {
+	case "created":
+		return typespb.DeploymentStatus_DEPLOYMENT_STATUS_CREATED
+	case "running":
+		return typespb.DeploymentStatus_DEPLOYMENT_STATUS_RUNNING
+	case "success":
+		return typespb.DeploymentStatus_DEPLOYMENT_STATUS_SUCCESS
+	case "failed":
+		return typespb.DeploymentStatus_DEPLOYMENT_STATUS_FAILED
+	case "canceled":
+		return typespb.DeploymentStatus_DEPLOYMENT_STATUS_CANCELED
+	case "skipped":
+		return typespb.DeploymentStatus_DEPLOYMENT_STATUS_SKIPPED
+	case "blocked":
+		return typespb.DeploymentStatus_DEPLOYMENT_STATUS_BLOCKED
+	default:
+		return typespb.DeploymentStatus_DEPLOYMENT_STATUS_UNSPECIFIED
+	}
+}
+
+func convertDeploymentTier(t string) typespb.DeploymentTier {
+	switch t {
+	case "production":
+		return typespb.DeploymentTier_DEPLOYMENT_TIER_PRODUCTION
+	case "staging":
+		return typespb.DeploymentTier_DEPLOYMENT_TIER_STAGING
+	case "testing":
+		return typespb.DeploymentTier_DEPLOYMENT_TIER_TESTING
+	case "development":
+		return typespb.DeploymentTier_DEPLOYMENT_TIER_DEVELOPMENT
+	case "other":
+		return typespb.DeploymentTier_DEPLOYMENT_TIER_OTHER
+	default:
+		return typespb.DeploymentTier_DEPLOYMENT_TIER_UNSPECIFIED
+	}
+}
+
+func refPath(ref string, tag bool) string {
+	if ref == "" {
+		return ""
+	} else if tag {
+		return "refs/tags/" + ref
+	}
+	return "refs/heads/" + ref
+}
+
+func timestamp(t hookTime) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
+
+// partialVersion returns the version of a record converted from a webhook,
+// which precedes the versions of the records the exporter sends for the same
+// event.
+func partialVersion(t hookTime) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return recorder.PartialVersion(t.Time)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
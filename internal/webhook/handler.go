@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/servicepb"
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/recorder"
+)
+
+const (
+	EventPipeline          string = "Pipeline Hook"
+	EventJob               string = "Job Hook"
+	EventMergeRequest      string = "Merge Request Hook"
+	EventNote              string = "Note Hook"
+	EventConfidentialNote  string = "Confidential Note Hook"
+	EventIssue             string = "Issue Hook"
+	EventConfidentialIssue string = "Confidential Issue Hook"
+	EventDeployment        string = "Deployment Hook"
+
+	ResultRecorded     string = "recorded"
+	ResultIgnored      string = "ignored"
+	ResultInvalid      string = "invalid"
+	ResultFailed       string = "failed"
+	ResultUnauthorized string = "unauthorized"
+
+	// event label of requests that aren't recorded
+	eventUnknown string = "unknown"
+
+	// maximum size of a request body
+	maxBodySize int64 = 25 << 20
+)
+
+// eventFunc decodes a webhook payload and records the data it contains, it
+// returns false if the payload doesn't contain any data to record.
+type eventFunc func(ctx context.Context, body []byte) (bool, error)
+
+// Handler receives GitLab webhooks, converts their payloads and records
+// them like the data sent by the exporter.
+type Handler struct {
+	token   string
+	events  map[string]eventFunc
+	metrics *metrics
+}
+
+// NewHandler returns a handler that records the webhook payloads using the
+// recorder. Requests must carry the token in the `X-Gitlab-Token` header.
+func NewHandler(rec servicepb.GitLabExporterServer, token string) *Handler {
+	notes := eventHandler(func(ctx context.Context, h *noteHook) (bool, error) {
+		event := convertMergeRequestNoteEvent(h)
+		if event == nil {
+			return false, nil
+		}
+		_, err := rec.RecordMergeRequestNoteEvents(ctx, &servicepb.RecordMergeRequestNoteEventsRequest{Data: []*typespb.MergeRequestNoteEvent{event}})
+		return true, err
+	})
+	issues := eventHandler(func(ctx context.Context, h *issueHook) (bool, error) {
+		_, err := rec.RecordIssues(ctx, &servicepb.RecordIssuesRequest{Data: []*typespb.Issue{convertIssue(h)}})
+		return true, err
+	})
+
+	return &Handler{
+		token: token,
+		events: map[string]eventFunc{
+			EventPipeline: eventHandler(func(ctx context.Context, h *pipelineHook) (bool, error) {
+				_, err := rec.RecordPipelines(ctx, &servicepb.RecordPipelinesRequest{Data: []*typespb.Pipeline{convertPipeline(h)}})
+				return true, err
+			}),
+			EventJob: eventHandler(func(ctx context.Context, h *jobHook) (bool, error) {
+				if !jobFinished(h) {
+					return false, nil
+				}
+				_, err := rec.RecordJobs(ctx, &servicepb.RecordJobsRequest{Data: []*typespb.Job{convertJob(h)}})
+				return true, err
+			}),
+			EventMergeRequest: eventHandler(func(ctx context.Context, h *mergeRequestHook) (bool, error) {
+				_, err := rec.RecordMergeRequests(ctx, &servicepb.RecordMergeRequestsRequest{Data: []*typespb.MergeRequest{convertMergeRequest(h)}})
+				return true, err
+			}),
+			EventNote:              notes,
+			EventConfidentialNote:  notes,
+			EventIssue:             issues,
+			EventConfidentialIssue: issues,
+			EventDeployment: eventHandler(func(ctx context.Context, h *deploymentHook) (bool, error) {
+				_, err := rec.RecordDeployments(ctx, &servicepb.RecordDeploymentsRequest{Data: []*typespb.Deployment{convertDeployment(h)}})
+				return true, err
+			}),
+		},
+		metrics: newMetrics(),
+	}
+}
+
+func eventHandler[T any](record func(context.Context, *T) (bool, error)) eventFunc {
+	return func(ctx context.Context, body []byte) (bool, error) {
+		payload := new(T)
+		if err := json.Unmarshal(body, payload); err != nil {
+			return false, &invalidPayloadError{err}
+		}
+		return record(ctx, payload)
+	}
+}
+
+// invalidPayloadError marks payloads that couldn't be decoded.
+type invalidPayloadError struct {
+	err error
+}
+
+func (e *invalidPayloadError) Error() string {
+	return fmt.Sprintf("decode payload: %v", e.err)
+}
+
+func (e *invalidPayloadError) Unwrap() error {
+	return e.err
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	event := r.Header.Get("X-Gitlab-Event")
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), []byte(h.token)) != 1 {
+		h.metrics.observe(eventUnknown, ResultUnauthorized)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	record, ok := h.events[event]
+	if !ok {
+		// acknowledge events that aren't recorded, as GitLab disables
+		// webhooks that keep failing
+		h.metrics.observe(eventUnknown, ResultIgnored)
+		slog.Debug("Ignored unsupported webhook event", "event", event)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		h.metrics.observe(event, ResultInvalid)
+		http.Error(w, fmt.Sprintf("read body: %v", err), http.StatusBadRequest)
+		return
+	} else if int64(len(body)) > maxBodySize {
+		h.metrics.observe(event, ResultInvalid)
+		http.Error(w, fmt.Sprintf("body exceeds %d bytes", maxBodySize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// webhook payloads lack data the exporter sends, their records must not
+	// replace the exporter's
+	recorded, err := record(recorder.WithPartialRecords(r.Context()), body)
+	if err != nil {
+		var perr *invalidPayloadError
+		if errors.As(err, &perr) {
+			h.metrics.observe(event, ResultInvalid)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.metrics.observe(event, ResultFailed)
+		slog.Error("Failed to record webhook event", "event", event, "error", err)
+		http.Error(w, "failed to record event", http.StatusInternalServerError)
+		return
+	}
+
+	if !recorded {
+		h.metrics.observe(event, ResultIgnored)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	h.metrics.observe(event, ResultRecorded)
+	w.WriteHeader(http.StatusOK)
+}
+
+// MetricsCollector returns the collector of the handler's metrics.
+func (h *Handler) MetricsCollector() prometheus.Collector {
+	return h.metrics
+}
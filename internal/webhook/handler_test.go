@@ -0,0 +1,353 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.cluttr.dev/gitlab-exporter/protobuf/servicepb"
+	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+)
+
+// testRecorder captures the recorded data.
+type testRecorder struct {
+	servicepb.UnimplementedGitLabExporterServer
+
+	pipelines []*typespb.Pipeline
+	jobs      []*typespb.Job
+	notes     []*typespb.MergeRequestNoteEvent
+	issues    []*typespb.Issue
+}
+
+func (r *testRecorder) RecordPipelines(_ context.Context, req *servicepb.RecordPipelinesRequest) (*servicepb.RecordSummary, error) {
+	r.pipelines = append(r.pipelines, req.Data...)
+	return &servicepb.RecordSummary{}, nil
+}
+
+func (r *testRecorder) RecordJobs(_ context.Context, req *servicepb.RecordJobsRequest) (*servicepb.RecordSummary, error) {
+	r.jobs = append(r.jobs, req.Data...)
+	return &servicepb.RecordSummary{}, nil
+}
+
+func (r *testRecorder) RecordMergeRequestNoteEvents(_ context.Context, req *servicepb.RecordMergeRequestNoteEventsRequest) (*servicepb.RecordSummary, error) {
+	r.notes = append(r.notes, req.Data...)
+	return &servicepb.RecordSummary{}, nil
+}
+
+func (r *testRecorder) RecordIssues(_ context.Context, req *servicepb.RecordIssuesRequest) (*servicepb.RecordSummary, error) {
+	r.issues = append(r.issues, req.Data...)
+	return &servicepb.RecordSummary{}, nil
+}
+
+const testPipelineHook = `{
+  "object_kind": "pipeline",
+  "object_attributes": {
+    "id": 31,
+    "iid": 3,
+    "ref": "v1.0.0",
+    "tag": true,
+    "sha": "bcbb5ec396a2c0f828686f14fac9b80b780504f2",
+    "source": "parent_pipeline",
+    "status": "success",
+    "created_at": "2016-08-12 15:23:28 UTC",
+    "finished_at": "2016-08-12 15:26:29 UTC",
+    "duration": 63,
+    "queued_duration": 12.5
+  },
+  "user": {"id": 1, "name": "Administrator", "username": "root"},
+  "project": {"id": 1, "path_with_namespace": "gitlab-org/gitlab-test"},
+  "source_pipeline": {"project": {"id": 41}, "pipeline_id": 30, "job_id": 3401}
+}`
+
+const testJobHook = `{
+  "object_kind": "build",
+  "ref": "main",
+  "tag": false,
+  "build_id": 1977,
+  "build_name": "test",
+  "build_stage": "test",
+  "build_status": "%s",
+  "build_created_at": "2021-02-23T02:41:37.886Z",
+  "build_started_at": "2021-02-23T02:41:40.000Z",
+  "build_finished_at": null,
+  "build_duration": 2.5,
+  "build_allow_failure": false,
+  "pipeline_id": 2366,
+  "project_id": 380,
+  "runner": {"id": 380987, "description": "shared-runners-manager-6.gitlab.com"}
+}`
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		event  string
+		body   string
+		status int
+	}{
+		{
+			name:   "method not allowed",
+			method: http.MethodGet,
+			token:  "secret",
+			event:  EventPipeline,
+			status: http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "missing token",
+			method: http.MethodPost,
+			event:  EventPipeline,
+			body:   testPipelineHook,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "invalid token",
+			method: http.MethodPost,
+			token:  "public",
+			event:  EventPipeline,
+			body:   testPipelineHook,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "unsupported event",
+			method: http.MethodPost,
+			token:  "secret",
+			event:  "Push Hook",
+			body:   `{}`,
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "invalid payload",
+			method: http.MethodPost,
+			token:  "secret",
+			event:  EventPipeline,
+			body:   `{"object_attributes": {"created_at": "yesterday"}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "pipeline",
+			method: http.MethodPost,
+			token:  "secret",
+			event:  EventPipeline,
+			body:   testPipelineHook,
+			status: http.StatusOK,
+		},
+		{
+			name:   "running job",
+			method: http.MethodPost,
+			token:  "secret",
+			event:  EventJob,
+			body:   strings.Replace(testJobHook, "%s", "running", 1),
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "finished job",
+			method: http.MethodPost,
+			token:  "secret",
+			event:  EventJob,
+			body:   strings.Replace(testJobHook, "%s", "failed", 1),
+			status: http.StatusOK,
+		},
+		{
+			name:   "issue note",
+			method: http.MethodPost,
+			token:  "secret",
+			event:  EventNote,
+			body:   `{"object_attributes": {"id": 1, "noteable_type": "Issue"}}`,
+			status: http.StatusAccepted,
+		},
+	}
+
+	rec := &testRecorder{}
+	h := NewHandler(rec, "secret")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/webhook", strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("X-Gitlab-Token", tt.token)
+			}
+			req.Header.Set("X-Gitlab-Event", tt.event)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if len(rec.pipelines) != 1 {
+		t.Errorf("Expected 1 recorded pipeline, got %d", len(rec.pipelines))
+	}
+	if len(rec.jobs) != 1 {
+		t.Errorf("Expected 1 recorded job, got %d", len(rec.jobs))
+	}
+	if len(rec.notes) != 0 {
+		t.Errorf("Expected no recorded notes, got %d", len(rec.notes))
+	}
+}
+
+func TestHandler_PipelineStatusChange(t *testing.T) {
+	rec := &testRecorder{}
+	h := NewHandler(rec, "secret")
+
+	running := strings.NewReplacer(
+		`"status": "success"`, `"status": "running"`,
+		`"finished_at": "2016-08-12 15:26:29 UTC"`, `"finished_at": null`,
+	).Replace(testPipelineHook)
+
+	for _, body := range []string{running, testPipelineHook} {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+		req.Header.Set("X-Gitlab-Token", "secret")
+		req.Header.Set("X-Gitlab-Event", EventPipeline)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+
+	if len(rec.pipelines) != 2 {
+		t.Fatalf("Expected 2 recorded pipelines, got %d", len(rec.pipelines))
+	}
+	first, second := rec.pipelines[0], rec.pipelines[1]
+	if first.Status != "running" || second.Status != "success" {
+		t.Errorf("Expected running and success pipelines, got %s and %s", first.Status, second.Status)
+	}
+	// the recorder replaces partial rows with partial records that are not older
+	if second.Timestamps.UpdatedAt.AsTime().Before(first.Timestamps.UpdatedAt.AsTime()) {
+		t.Errorf("Expected the success event to be newer than the running event, got %v and %v",
+			second.Timestamps.UpdatedAt.AsTime(), first.Timestamps.UpdatedAt.AsTime())
+	}
+}
+
+func TestConvertPipeline(t *testing.T) {
+	rec := &testRecorder{}
+	h := NewHandler(rec, "secret")
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(testPipelineHook))
+	req.Header.Set("X-Gitlab-Token", "secret")
+	req.Header.Set("X-Gitlab-Event", EventPipeline)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(rec.pipelines) != 1 {
+		t.Fatalf("Expected 1 recorded pipeline, got %d", len(rec.pipelines))
+	}
+	p := rec.pipelines[0]
+
+	if p.Id != 31 || p.Iid != 3 {
+		t.Errorf("Expected pipeline 31/3, got %d/%d", p.Id, p.Iid)
+	}
+	if p.RefPath != "refs/tags/v1.0.0" {
+		t.Errorf("Expected tag ref path, got %q", p.RefPath)
+	}
+	if p.Project.FullPath != "gitlab-org/gitlab-test" {
+		t.Errorf("Expected project path, got %q", p.Project.FullPath)
+	}
+	if !p.Child || p.UpstreamPipeline.Id != 30 || p.UpstreamPipeline.Project.Id != 41 {
+		t.Errorf("Expected child of pipeline 30 in project 41, got %v", p.UpstreamPipeline)
+	}
+
+	// partial records precede the exporter's of the same version
+	updatedAt := time.Date(2016, 8, 12, 15, 26, 28, 999e6, time.UTC)
+	if got := p.Timestamps.UpdatedAt.AsTime(); !got.Equal(updatedAt) {
+		t.Errorf("Expected update time %v, got %v", updatedAt, got)
+	}
+	if p.Timestamps.StartedAt != nil {
+		t.Errorf("Expected no start time, got %v", p.Timestamps.StartedAt.AsTime())
+	}
+	if got := p.Duration.AsDuration(); got != 63*time.Second {
+		t.Errorf("Expected duration 63s, got %v", got)
+	}
+}
+
+func TestConvertIssue(t *testing.T) {
+	var h issueHook
+	payload := `{
+  "object_kind": "issue",
+  "project": {"id": 1, "path_with_namespace": "gitlab-org/gitlab-test"},
+  "object_attributes": {
+    "id": 301,
+    "iid": 23,
+    "issue_type": "incident",
+    "severity": "high",
+    "state": "closed",
+    "closed_at": null,
+    "labels": [{"id": 206, "title": "API"}]
+  }
+}`
+	if err := json.Unmarshal([]byte(payload), &h); err != nil {
+		t.Fatal(err)
+	}
+	issue := convertIssue(&h)
+
+	if issue.Type != typespb.IssueType_ISSUE_TYPE_INCIDENT {
+		t.Errorf("Expected incident, got %v", issue.Type)
+	}
+	if issue.Severity != typespb.IssueSeverity_ISSUE_SEVERITY_HIGH {
+		t.Errorf("Expected high severity, got %v", issue.Severity)
+	}
+	if issue.State != typespb.IssueState_ISSUE_STATE_CLOSED {
+		t.Errorf("Expected closed state, got %v", issue.State)
+	}
+	if len(issue.Labels) != 1 || issue.Labels[0] != "API" {
+		t.Errorf("Expected label API, got %v", issue.Labels)
+	}
+	if issue.Timestamps.ClosedAt != nil {
+		t.Errorf("Expected no close time, got %v", issue.Timestamps.ClosedAt)
+	}
+}
+
+func TestConvertDeployment(t *testing.T) {
+	var h deploymentHook
+	payload := `{
+  "object_kind": "deployment",
+  "status": "success",
+  "status_changed_at": "2021-04-28 21:50:00 +0200",
+  "deployment_id": 15,
+  "deployable_id": 796,
+  "environment": "staging",
+  "project": {"id": 30, "path_with_namespace": "gitlab-org/gitlab-test"},
+  "short_sha": "279484c0",
+  "commit_url": "http://example.com/gitlab-org/gitlab-test/-/commit/279484c09fbe69ededfced8c1bb6e6d24616b468",
+  "ref": "main"
+}`
+	if err := json.Unmarshal([]byte(payload), &h); err != nil {
+		t.Fatal(err)
+	}
+
+	d := convertDeployment(&h)
+	if d.Sha != "279484c09fbe69ededfced8c1bb6e6d24616b468" {
+		t.Errorf("Expected full sha, got %q", d.Sha)
+	}
+	if d.Job.Id != 796 || d.Job.Pipeline != nil {
+		t.Errorf("Expected job 796 without pipeline, got %v", d.Job)
+	}
+	if d.Timestamps.FinishedAt == nil {
+		t.Errorf("Expected finish time")
+	}
+}
+
+func TestHookTime(t *testing.T) {
+	tests := map[string]time.Time{
+		`"2021-02-23T02:41:37.886Z"`:  time.Date(2021, 2, 23, 2, 41, 37, 886e6, time.UTC),
+		`"2016-08-12 15:23:28 UTC"`:   time.Date(2016, 8, 12, 15, 23, 28, 0, time.UTC),
+		`"2021-04-28 21:50:00 +0200"`: time.Date(2021, 4, 28, 19, 50, 0, 0, time.UTC),
+		`null`:                        {},
+	}
+	for data, want := range tests {
+		var got hookTime
+		if err := got.UnmarshalJSON([]byte(data)); err != nil {
+			t.Errorf("Failed to parse %s: %v", data, err)
+		} else if !got.Equal(want) {
+			t.Errorf("Expected %v for %s, got %v", want, data, got.Time)
+		}
+	}
+}
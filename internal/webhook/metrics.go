@@ -0,0 +1,33 @@
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.cluttr.dev/gitlab-exporter-clickhouse-recorder/internal/promutil"
+)
+
+type metrics struct {
+	promutil.Collectors
+
+	events *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		events: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promutil.Namespace,
+				Subsystem: "webhook",
+				Name:      "events_total",
+				Help:      "Total number of received webhook events by event and result.",
+			},
+			[]string{"event", "result"},
+		),
+	}
+	m.Collectors = promutil.Collectors{m.events}
+	return m
+}
+
+func (m *metrics) observe(event string, result string) {
+	m.events.WithLabelValues(event, result).Inc()
+}
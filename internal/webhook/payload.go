@@ -0,0 +1,198 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// The types below describe the subset of GitLab's webhook payloads that is
+// recorded, see https://docs.gitlab.com/ee/user/project/integrations/webhook_events.html
+
+type hookUser struct {
+	Id       int64  `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+type hookProject struct {
+	Id                int64  `json:"id"`
+	Name              string `json:"name"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	DefaultBranch     string `json:"default_branch"`
+}
+
+type hookLabel struct {
+	Title string `json:"title"`
+}
+
+type pipelineHook struct {
+	ObjectAttributes struct {
+		Id             int64    `json:"id"`
+		Iid            int64    `json:"iid"`
+		Name           string   `json:"name"`
+		Ref            string   `json:"ref"`
+		Tag            bool     `json:"tag"`
+		Sha            string   `json:"sha"`
+		Source         string   `json:"source"`
+		Status         string   `json:"status"`
+		CreatedAt      hookTime `json:"created_at"`
+		FinishedAt     hookTime `json:"finished_at"`
+		Duration       float64  `json:"duration"`
+		QueuedDuration float64  `json:"queued_duration"`
+	} `json:"object_attributes"`
+	MergeRequest *struct {
+		Id              int64 `json:"id"`
+		Iid             int64 `json:"iid"`
+		TargetProjectId int64 `json:"target_project_id"`
+	} `json:"merge_request"`
+	User           hookUser    `json:"user"`
+	Project        hookProject `json:"project"`
+	SourcePipeline *struct {
+		Project struct {
+			Id int64 `json:"id"`
+		} `json:"project"`
+		PipelineId int64 `json:"pipeline_id"`
+	} `json:"source_pipeline"`
+}
+
+type jobHook struct {
+	Ref                 string       `json:"ref"`
+	Tag                 bool         `json:"tag"`
+	Sha                 string       `json:"sha"`
+	BuildId             int64        `json:"build_id"`
+	BuildName           string       `json:"build_name"`
+	BuildStage          string       `json:"build_stage"`
+	BuildStatus         string       `json:"build_status"`
+	BuildCreatedAt      hookTime     `json:"build_created_at"`
+	BuildStartedAt      hookTime     `json:"build_started_at"`
+	BuildFinishedAt     hookTime     `json:"build_finished_at"`
+	BuildDuration       float64      `json:"build_duration"`
+	BuildQueuedDuration float64      `json:"build_queued_duration"`
+	BuildAllowFailure   bool         `json:"build_allow_failure"`
+	BuildFailureReason  string       `json:"build_failure_reason"`
+	PipelineId          int64        `json:"pipeline_id"`
+	ProjectId           int64        `json:"project_id"`
+	Project             *hookProject `json:"project"`
+	Runner              *struct {
+		Id          int64  `json:"id"`
+		Description string `json:"description"`
+	} `json:"runner"`
+}
+
+type mergeRequestHook struct {
+	User             hookUser    `json:"user"`
+	Project          hookProject `json:"project"`
+	ObjectAttributes struct {
+		Id              int64       `json:"id"`
+		Iid             int64       `json:"iid"`
+		Title           string      `json:"title"`
+		State           string      `json:"state"`
+		Action          string      `json:"action"`
+		MergeStatus     string      `json:"merge_status"`
+		SourceProjectId int64       `json:"source_project_id"`
+		SourceBranch    string      `json:"source_branch"`
+		TargetProjectId int64       `json:"target_project_id"`
+		TargetBranch    string      `json:"target_branch"`
+		AuthorId        int64       `json:"author_id"`
+		MilestoneId     int64       `json:"milestone_id"`
+		MergeCommitSha  string      `json:"merge_commit_sha"`
+		Draft           bool        `json:"draft"`
+		WorkInProgress  bool        `json:"work_in_progress"`
+		CreatedAt       hookTime    `json:"created_at"`
+		UpdatedAt       hookTime    `json:"updated_at"`
+		Labels          []hookLabel `json:"labels"`
+		LastCommit      struct {
+			Id string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+	Assignees []hookUser `json:"assignees"`
+	Reviewers []hookUser `json:"reviewers"`
+}
+
+type noteHook struct {
+	User             hookUser    `json:"user"`
+	Project          hookProject `json:"project"`
+	ObjectAttributes struct {
+		Id           int64    `json:"id"`
+		NoteableType string   `json:"noteable_type"`
+		AuthorId     int64    `json:"author_id"`
+		Type         string   `json:"type"`
+		System       bool     `json:"system"`
+		Internal     bool     `json:"internal"`
+		Confidential bool     `json:"confidential"`
+		CreatedAt    hookTime `json:"created_at"`
+		UpdatedAt    hookTime `json:"updated_at"`
+	} `json:"object_attributes"`
+	MergeRequest *struct {
+		Id              int64 `json:"id"`
+		Iid             int64 `json:"iid"`
+		TargetProjectId int64 `json:"target_project_id"`
+	} `json:"merge_request"`
+}
+
+type issueHook struct {
+	Project          hookProject `json:"project"`
+	ObjectAttributes struct {
+		Id        int64       `json:"id"`
+		Iid       int64       `json:"iid"`
+		Title     string      `json:"title"`
+		State     string      `json:"state"`
+		Type      string      `json:"type"`
+		IssueType string      `json:"issue_type"`
+		Severity  string      `json:"severity"`
+		CreatedAt hookTime    `json:"created_at"`
+		UpdatedAt hookTime    `json:"updated_at"`
+		ClosedAt  hookTime    `json:"closed_at"`
+		Labels    []hookLabel `json:"labels"`
+	} `json:"object_attributes"`
+}
+
+type deploymentHook struct {
+	Status          string      `json:"status"`
+	StatusChangedAt hookTime    `json:"status_changed_at"`
+	DeploymentId    int64       `json:"deployment_id"`
+	DeployableId    int64       `json:"deployable_id"`
+	Environment     string      `json:"environment"`
+	EnvironmentTier string      `json:"environment_tier"`
+	Project         hookProject `json:"project"`
+	CommitUrl       string      `json:"commit_url"`
+	Ref             string      `json:"ref"`
+	User            hookUser    `json:"user"`
+}
+
+// hookTime is a timestamp in any of the formats used by GitLab webhooks, a
+// null value yields the zero time.
+type hookTime struct {
+	time.Time
+}
+
+var hookTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05.999999999 -0700",
+}
+
+func (t *hookTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		return nil
+	}
+
+	for _, layout := range hookTimeLayouts {
+		if v, err := time.Parse(layout, s); err == nil {
+			t.Time = v
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time: %q", s)
+}
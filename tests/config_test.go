@@ -2,6 +2,7 @@ package config_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -16,18 +17,67 @@ func defaultConfig() config.Config {
 	cfg.ClickHouse.Database = "default"
 	cfg.ClickHouse.User = "default"
 	cfg.ClickHouse.Password = ""
+	cfg.ClickHouse.HTTPPort = "8123"
 
 	cfg.Server.Host = "0.0.0.0"
 	cfg.Server.Port = "0"
 
+	cfg.OTLP.GRPC.Enabled = false
+	cfg.OTLP.GRPC.Host = "0.0.0.0"
+	cfg.OTLP.GRPC.Port = "4317"
+	cfg.OTLP.HTTP.Enabled = false
+
+	cfg.Prometheus.RemoteWrite.Enabled = false
+	cfg.Prometheus.RemoteWrite.Path = "/api/v1/write"
+
+	cfg.Traces.Synthesize = false
+	cfg.Traces.SynthesizeUntracedOnly = true
+	cfg.Traces.SynthesizeQueueSize = 1000
+	cfg.Traces.Sampling.Head.Rate = 1
+	cfg.Traces.Sampling.Tail.Enabled = false
+	cfg.Traces.Sampling.Tail.Window = 30 * time.Second
+	cfg.Traces.Sampling.Tail.MaxTraces = 10000
+	cfg.Traces.Sampling.Tail.KeepErrors = true
+	cfg.Traces.Sampling.Tail.KeepSlowerThan = 0
+	cfg.Traces.Sampling.Tail.Rate = 0
+	cfg.Traces.Sampling.Tail.DecisionTTL = 5 * time.Minute
+	cfg.Traces.Query.Enabled = false
+	cfg.Traces.Forward.Enabled = false
+	cfg.Traces.Forward.QueueSize = 1000
+	cfg.Traces.Forward.MaxRetries = 5
+	cfg.Traces.Forward.RetryInitialBackoff = time.Second
+	cfg.Traces.Forward.RetryMaxBackoff = 30 * time.Second
+
+	cfg.Webhook.Enabled = false
+	cfg.Webhook.Path = "/webhook"
+	cfg.Webhook.Token = ""
+
+	cfg.Capture.Enabled = false
+	cfg.Capture.Dir = "capture"
+	cfg.Capture.MaxFileSizeMB = 100
+	cfg.Capture.MaxFiles = 10
+	cfg.Capture.SampleRate = 1
+
+	cfg.Validation.Enabled = false
+	cfg.Validation.Policy = "warn"
+
+	cfg.IDCache.Enabled = true
+	cfg.IDCache.FalsePositiveRate = 0.000001
+	cfg.IDCache.MinCapacity = 1000000
+
 	cfg.HTTP.Enabled = true
 	cfg.HTTP.Host = "127.0.0.1"
 	cfg.HTTP.Port = "9100"
 	cfg.HTTP.Debug = false
+	cfg.HTTP.Gateway.Enabled = false
 
 	cfg.Log.Level = "info"
 	cfg.Log.Format = "text"
 
+	cfg.Retention.Enabled = false
+	cfg.Retention.Interval = 24 * time.Hour
+	cfg.Retention.DryRun = false
+
 	return cfg
 }
 
@@ -141,3 +191,95 @@ func TestLoad_DataWithDefaults(t *testing.T) {
 
 	checkConfig(t, expected, cfg)
 }
+
+func TestLoad_SchemaTables(t *testing.T) {
+	data := []byte(`
+    clickhouse:
+      schema:
+        tables:
+          traces:
+            ttl: "toDateTime(Timestamp) + INTERVAL 90 DAY"
+            storage_policy: hot_cold
+            partition_granularity: month
+            settings:
+              ttl_only_drop_parts: "1"
+            codecs:
+              SpanAttributes: "ZSTD(3)"
+    `)
+
+	expected := defaultConfig()
+	expected.ClickHouse.Schema.Tables = map[string]config.ClickHouseTable{
+		"traces": {
+			TTL:                  "toDateTime(Timestamp) + INTERVAL 90 DAY",
+			StoragePolicy:        "hot_cold",
+			PartitionGranularity: "month",
+			Settings:             map[string]string{"ttl_only_drop_parts": "1"},
+			Codecs:               map[string]string{"SpanAttributes": "ZSTD(3)"},
+		},
+	}
+
+	cfg := defaultConfig()
+	if err := config.Load(data, &cfg); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+
+	checkConfig(t, expected, cfg)
+}
+
+func TestLoad_RetentionTables(t *testing.T) {
+	data := []byte(`
+    retention:
+      enabled: true
+      interval: 6h
+      tables:
+        traces:
+          max_age: 720h
+        testcases:
+          max_age: 8760h
+    `)
+
+	expected := defaultConfig()
+	expected.Retention.Enabled = true
+	expected.Retention.Interval = 6 * time.Hour
+	expected.Retention.Tables = map[string]config.RetentionTable{
+		"traces":    {MaxAge: 720 * time.Hour},
+		"testcases": {MaxAge: 8760 * time.Hour},
+	}
+
+	cfg := defaultConfig()
+	if err := config.Load(data, &cfg); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+
+	checkConfig(t, expected, cfg)
+}
+
+func TestLoad_MaintenanceJobs(t *testing.T) {
+	data := []byte(`
+    maintenance:
+      enabled: true
+      jobs:
+        - table: pipelines
+          schedule: "@hourly"
+        - table: jobs
+          schedule: "30 2 * * *"
+          final: false
+          except: [updated_at]
+    `)
+
+	final := false
+
+	expected := defaultConfig()
+	expected.Maintenance.Enabled = true
+	expected.Maintenance.Jobs = []config.MaintenanceJob{
+		{Table: "pipelines", Schedule: "@hourly"},
+		{Table: "jobs", Schedule: "30 2 * * *", Final: &final, Except: []string{"updated_at"}},
+	}
+
+	cfg := defaultConfig()
+	if err := config.Load(data, &cfg); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+
+	checkConfig(t, expected, cfg)
+}
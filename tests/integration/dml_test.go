@@ -5,6 +5,9 @@ import (
 	"testing"
 
 	"go.cluttr.dev/gitlab-exporter/protobuf/typespb"
+	otlp_commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	otlp_logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	otlp_resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
@@ -165,3 +168,52 @@ func TestIntegration_InsertTestCases(t *testing.T) {
 		t.Errorf("Inserted %d testcases, expected: %d", n, 10)
 	}
 }
+
+func TestIntegration_InsertLogs(t *testing.T) {
+	client, err := GetTestClient(testSet)
+	if err != nil {
+		t.Error(err)
+	}
+
+	data := []*otlp_logspb.LogsData{
+		{
+			ResourceLogs: []*otlp_logspb.ResourceLogs{
+				{
+					Resource: &otlp_resourcepb.Resource{
+						Attributes: []*otlp_commonpb.KeyValue{
+							{Key: "service.name", Value: &otlp_commonpb.AnyValue{Value: &otlp_commonpb.AnyValue_StringValue{StringValue: "gitlab-runner"}}},
+							{Key: "ci.project.id", Value: &otlp_commonpb.AnyValue{Value: &otlp_commonpb.AnyValue_IntValue{IntValue: 50817395}}},
+							{Key: "ci.pipeline.id", Value: &otlp_commonpb.AnyValue{Value: &otlp_commonpb.AnyValue_StringValue{StringValue: "1082136862"}}},
+							{Key: "ci.job.id", Value: &otlp_commonpb.AnyValue{Value: &otlp_commonpb.AnyValue_IntValue{IntValue: 5599404160}}},
+						},
+					},
+					ScopeLogs: []*otlp_logspb.ScopeLogs{
+						{
+							LogRecords: []*otlp_logspb.LogRecord{
+								{
+									TimeUnixNano:   1700690851366000000,
+									SeverityNumber: otlp_logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+									SeverityText:   "INFO",
+									Body:           &otlp_commonpb.AnyValue{Value: &otlp_commonpb.AnyValue_StringValue{StringValue: "$ make test"}},
+								},
+								{
+									ObservedTimeUnixNano: 1700690933765000000,
+									Body:                 &otlp_commonpb.AnyValue{Value: &otlp_commonpb.AnyValue_StringValue{StringValue: "Job succeeded"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	n, err := clickhouse.InsertLogs(client, context.Background(), data)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if n != 2 {
+		t.Errorf("Inserted %d log records, expected: %d", n, 2)
+	}
+}